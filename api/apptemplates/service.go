@@ -0,0 +1,414 @@
+package apptemplates
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	// RefreshInterval is the interval at which the template sources are refreshed in the background
+	RefreshInterval = time.Hour
+	// DefaultSourceName is the name of the source created when none is defined
+	DefaultSourceName = "Portainer templates"
+
+	fetchTimeout = 30 * time.Second
+)
+
+// Collection represents the merged list of application templates served to the users
+type Collection struct {
+	Version   string               `json:"version"`
+	Templates []portainer.Template `json:"templates"`
+}
+
+// Service retrieves application templates from the configured sources and keeps
+// the last good copy of each of them inside the datastore
+type Service struct {
+	dataStore   dataservices.DataStore
+	fileService portainer.FileService
+	gitService  portainer.GitService
+	client      *http.Client
+}
+
+// NewService returns a new instance of Service
+func NewService(dataStore dataservices.DataStore, fileService portainer.FileService, gitService portainer.GitService) *Service {
+	return &Service{
+		dataStore:   dataStore,
+		fileService: fileService,
+		gitService:  gitService,
+		client:      &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// Start creates the default source when none is defined and schedules the background refresh
+func (service *Service) Start(ctx context.Context, s *scheduler.Scheduler) error {
+	if err := service.ensureDefaultSource(); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := service.RefreshAll(ctx); err != nil {
+			log.Warn().Err(err).Msg("unable to refresh the application templates")
+		}
+	}()
+
+	s.StartJobEvery(RefreshInterval, func() error {
+		if err := service.RefreshAll(ctx); err != nil {
+			log.Warn().Err(err).Msg("unable to refresh the application templates")
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func (service *Service) ensureDefaultSource() error {
+	return service.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		sources, err := tx.TemplateSource().ReadAll()
+		if err != nil {
+			return err
+		}
+
+		if len(sources) > 0 {
+			return nil
+		}
+
+		return tx.TemplateSource().Create(&portainer.TemplateSource{
+			Name: DefaultSourceName,
+			Type: portainer.TemplateSourceURL,
+		})
+	})
+}
+
+// Templates returns the templates of all the sources merged together. The last good copy
+// stored in the datastore is served, the sources are only retrieved when none was ever retrieved
+func (service *Service) Templates(ctx context.Context) (*Collection, error) {
+	sources, err := service.dataStore.TemplateSource().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(sources, hasContent) {
+		refreshErr := service.RefreshAll(ctx)
+
+		if sources, err = service.dataStore.TemplateSource().ReadAll(); err != nil {
+			return nil, err
+		}
+
+		if refreshErr != nil && !slices.ContainsFunc(sources, hasContent) {
+			return nil, refreshErr
+		}
+	}
+
+	return Merge(sources), nil
+}
+
+// RefreshAll retrieves the templates of every source, the error of the last failing source is returned
+func (service *Service) RefreshAll(ctx context.Context) error {
+	sources, err := service.dataStore.TemplateSource().ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var refreshErr error
+	for _, source := range sources {
+		if err := service.Refresh(ctx, source.ID); err != nil {
+			refreshErr = fmt.Errorf("unable to refresh the template source %q: %w", source.Name, err)
+		}
+	}
+
+	return refreshErr
+}
+
+// Refresh retrieves the templates of a source and stores them in the datastore. On failure the
+// error is recorded on the source and its last good copy is kept. The source is retrieved outside
+// of any transaction and only the retrieved fields are updated, the result is discarded when the
+// origin of the source was changed in the meantime
+func (service *Service) Refresh(ctx context.Context, sourceID portainer.TemplateSourceID) error {
+	source, err := service.dataStore.TemplateSource().Read(sourceID)
+	if err != nil {
+		return err
+	}
+
+	fetched := *source
+	if source.GitConfig != nil {
+		gitConfig := *source.GitConfig
+		fetched.GitConfig = &gitConfig
+	}
+
+	fetchErr := service.fetch(ctx, &fetched)
+
+	err = service.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		current, err := tx.TemplateSource().Read(sourceID)
+		if err != nil {
+			return err
+		}
+
+		if !sameOrigin(current, source) {
+			log.Debug().Str("source", current.Name).Msg("the template source was updated during its refresh, discarding the result")
+
+			return nil
+		}
+
+		current.LastError = ""
+		if fetchErr != nil {
+			current.LastError = fetchErr.Error()
+		} else {
+			current.Version = fetched.Version
+			current.Templates = fetched.Templates
+			current.ETag = fetched.ETag
+			current.LastModified = fetched.LastModified
+			current.FetchedURL = fetched.FetchedURL
+			current.LastRefresh = fetched.LastRefresh
+
+			if current.GitConfig != nil && fetched.GitConfig != nil {
+				current.GitConfig.ConfigHash = fetched.GitConfig.ConfigHash
+			}
+		}
+
+		return tx.TemplateSource().Update(current.ID, current)
+	})
+	if err != nil {
+		return err
+	}
+
+	return fetchErr
+}
+
+// sameOrigin returns true when both sources retrieve their templates from the same location
+func sameOrigin(a, b *portainer.TemplateSource) bool {
+	if a.Type != b.Type || a.URL != b.URL || (a.GitConfig == nil) != (b.GitConfig == nil) {
+		return false
+	}
+
+	if a.GitConfig == nil {
+		return true
+	}
+
+	return a.GitConfig.URL == b.GitConfig.URL &&
+		a.GitConfig.ReferenceName == b.GitConfig.ReferenceName &&
+		a.GitConfig.ConfigFilePath == b.GitConfig.ConfigFilePath
+}
+
+func (service *Service) fetch(ctx context.Context, source *portainer.TemplateSource) error {
+	switch source.Type {
+	case portainer.TemplateSourceURL:
+		return service.fetchURL(ctx, source)
+	case portainer.TemplateSourceGit:
+		return service.fetchGit(source)
+	case portainer.TemplateSourceFile:
+		// Uploaded templates are stored as is and never need to be retrieved
+		return nil
+	}
+
+	return fmt.Errorf("unsupported template source type %d", source.Type)
+}
+
+func (service *Service) fetchURL(ctx context.Context, source *portainer.TemplateSource) error {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return err
+	}
+
+	url := cmp.Or(source.URL, settings.TemplatesURL, portainer.DefaultTemplatesURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	// The validators only apply to the copy retrieved from the same URL
+	if source.FetchedURL == url && hasContent(*source) {
+		if source.ETag != "" {
+			req.Header.Set("If-None-Match", source.ETag)
+		}
+
+		if source.LastModified != "" {
+			req.Header.Set("If-Modified-Since", source.LastModified)
+		}
+	}
+
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		source.LastRefresh = time.Now().Unix()
+
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	collection, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	source.Version = collection.Version
+	source.Templates = collection.Templates
+	source.ETag = resp.Header.Get("ETag")
+	source.LastModified = resp.Header.Get("Last-Modified")
+	source.FetchedURL = url
+	source.LastRefresh = time.Now().Unix()
+
+	return nil
+}
+
+func (service *Service) fetchGit(source *portainer.TemplateSource) error {
+	if source.GitConfig == nil {
+		return errors.New("missing git repository configuration")
+	}
+
+	username, password := "", ""
+	if source.GitConfig.Authentication != nil {
+		username = source.GitConfig.Authentication.Username
+		password = source.GitConfig.Authentication.Password
+	}
+
+	commitID, err := service.gitService.LatestCommitID(source.GitConfig.URL, source.GitConfig.ReferenceName, username, password, source.GitConfig.TLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	if commitID == source.GitConfig.ConfigHash && hasContent(*source) {
+		source.LastRefresh = time.Now().Unix()
+
+		return nil
+	}
+
+	projectPath, err := service.fileService.GetTemporaryPath()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := service.fileService.RemoveDirectory(projectPath); err != nil {
+			log.Debug().Err(err).Msg("unable to remove the template source clone")
+		}
+	}()
+
+	if err := service.gitService.CloneRepository(projectPath, source.GitConfig.URL, source.GitConfig.ReferenceName, username, password, source.GitConfig.TLSSkipVerify); err != nil {
+		return err
+	}
+
+	data, err := service.fileService.GetFileContent(projectPath, filepath.Clean(source.GitConfig.ConfigFilePath))
+	if err != nil {
+		return err
+	}
+
+	collection, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	source.Version = collection.Version
+	source.Templates = collection.Templates
+	source.GitConfig.ConfigHash = commitID
+	source.LastRefresh = time.Now().Unix()
+
+	return nil
+}
+
+// Parse decodes a templates file
+func Parse(data []byte) (*Collection, error) {
+	var collection Collection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("unable to parse the templates file: %w", err)
+	}
+
+	if collection.Templates == nil {
+		return nil, errors.New("the templates file does not contain any template list")
+	}
+
+	return &collection, nil
+}
+
+// Merge combines the templates of the sources. When several sources provide a template with the
+// same type and title, the one from the source with the lowest priority value is kept. The templates
+// are attributed to their source and identified by their source, type and title so their identifiers
+// are unique across sources and stable across refreshes
+func Merge(sources []portainer.TemplateSource) *Collection {
+	sources = slices.Clone(sources)
+	slices.SortStableFunc(sources, func(a, b portainer.TemplateSource) int {
+		return cmp.Or(cmp.Compare(a.Priority, b.Priority), cmp.Compare(a.ID, b.ID))
+	})
+
+	collection := &Collection{Templates: []portainer.Template{}}
+	seen := map[string]string{}
+	ids := map[portainer.TemplateID]bool{}
+
+	for _, source := range sources {
+		if collection.Version == "" {
+			collection.Version = source.Version
+		}
+
+		for _, template := range source.Templates {
+			key := fmt.Sprintf("%d/%s", template.Type, strings.ToLower(strings.TrimSpace(template.Title)))
+			if winner, ok := seen[key]; ok {
+				log.Debug().
+					Str("template", template.Title).
+					Str("source", source.Name).
+					Str("kept_from", winner).
+					Msg("skipping template provided by a source with a lower priority")
+
+				continue
+			}
+
+			seen[key] = source.Name
+
+			template.ID = templateID(source.ID, key, ids)
+			template.SourceID = source.ID
+			template.Source = source.Name
+
+			collection.Templates = append(collection.Templates, template)
+		}
+	}
+
+	return collection
+}
+
+// templateID derives the identifier of a template from its source and key, the following
+// identifiers are used on collision
+func templateID(sourceID portainer.TemplateSourceID, key string, ids map[portainer.TemplateID]bool) portainer.TemplateID {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d/%s", sourceID, key)
+
+	id := portainer.TemplateID(h.Sum32() & 0x7fffffff)
+	for id == 0 || ids[id] {
+		id = (id + 1) & 0x7fffffff
+	}
+
+	ids[id] = true
+
+	return id
+}
+
+func hasContent(source portainer.TemplateSource) bool {
+	return source.LastRefresh > 0 || len(source.Templates) > 0
+}
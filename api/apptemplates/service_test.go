@@ -0,0 +1,150 @@
+package apptemplates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/require"
+)
+
+const templatesFile = `{"version":"3","templates":[{"id":1,"type":1,"title":"Nginx","image":"nginx:latest"}]}`
+
+func TestMerge(t *testing.T) {
+	sources := []portainer.TemplateSource{
+		{
+			ID:       1,
+			Name:     "public",
+			Priority: 10,
+			Version:  "3",
+			Templates: []portainer.Template{
+				{ID: 1, Type: portainer.ContainerTemplate, Title: "Nginx", Image: "nginx:latest"},
+				{ID: 2, Type: portainer.ContainerTemplate, Title: "Redis", Image: "redis:latest"},
+			},
+		},
+		{
+			ID:      2,
+			Name:    "internal",
+			Version: "3",
+			Templates: []portainer.Template{
+				{ID: 1, Type: portainer.ContainerTemplate, Title: "nginx", Image: "registry.internal/nginx:1.25"},
+			},
+		},
+	}
+
+	collection := Merge(sources)
+
+	require.Equal(t, "3", collection.Version)
+	require.Len(t, collection.Templates, 2)
+
+	require.Equal(t, "registry.internal/nginx:1.25", collection.Templates[0].Image)
+	require.Equal(t, "internal", collection.Templates[0].Source)
+
+	require.Equal(t, "Redis", collection.Templates[1].Title)
+	require.Equal(t, portainer.TemplateSourceID(1), collection.Templates[1].SourceID)
+
+	require.NotEqual(t, collection.Templates[0].ID, collection.Templates[1].ID)
+
+	// the identifiers do not depend on the other templates
+	sources[1].Templates = append(sources[1].Templates, portainer.Template{ID: 2, Type: portainer.ContainerTemplate, Title: "Apache", Image: "httpd:latest"})
+	updated := Merge(sources)
+	require.Len(t, updated.Templates, 3)
+	require.Equal(t, collection.Templates[0].ID, updated.Templates[0].ID)
+	require.Equal(t, collection.Templates[1].ID, updated.Templates[2].ID)
+}
+
+func TestRefreshURLSource(t *testing.T) {
+	var requests atomic.Int32
+	available := atomic.Bool{}
+	available.Store(true)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(templatesFile))
+	}))
+	defer srv.Close()
+
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	source := &portainer.TemplateSource{Name: "test", Type: portainer.TemplateSourceURL, URL: srv.URL}
+	require.NoError(t, store.TemplateSource().Create(source))
+
+	service := NewService(store, nil, nil)
+	ctx := context.Background()
+
+	require.NoError(t, service.Refresh(ctx, source.ID))
+
+	source, err := store.TemplateSource().Read(source.ID)
+	require.NoError(t, err)
+	require.Equal(t, `"v1"`, source.ETag)
+	require.Len(t, source.Templates, 1)
+
+	// Not modified, the stored copy is kept
+	require.NoError(t, service.Refresh(ctx, source.ID))
+
+	collection, err := service.Templates(ctx)
+	require.NoError(t, err)
+	require.Len(t, collection.Templates, 1)
+
+	// Unavailable, the last good copy is served
+	available.Store(false)
+	require.Error(t, service.Refresh(ctx, source.ID))
+
+	source, err = store.TemplateSource().Read(source.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, source.LastError)
+
+	collection, err = service.Templates(ctx)
+	require.NoError(t, err)
+	require.Len(t, collection.Templates, 1)
+	require.Equal(t, "test", collection.Templates[0].Source)
+
+	require.Equal(t, int32(3), requests.Load())
+}
+
+func TestRefreshKeepsConcurrentEdits(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	source := &portainer.TemplateSource{Name: "test", Type: portainer.TemplateSourceURL}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the source is edited while its templates are retrieved
+		edited, err := store.TemplateSource().Read(source.ID)
+		require.NoError(t, err)
+
+		edited.Priority = 5
+		require.NoError(t, store.TemplateSource().Update(edited.ID, edited))
+
+		w.Write([]byte(templatesFile))
+	}))
+	defer srv.Close()
+
+	source.URL = srv.URL
+	require.NoError(t, store.TemplateSource().Create(source))
+
+	require.NoError(t, NewService(store, nil, nil).Refresh(context.Background(), source.ID))
+
+	source, err := store.TemplateSource().Read(source.ID)
+	require.NoError(t, err)
+	require.Equal(t, 5, source.Priority)
+	require.Len(t, source.Templates, 1)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/portainer/portainer/api/archive"
@@ -12,6 +13,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -33,9 +35,13 @@ var filesToBackup = []string{
 	"tls",
 }
 
+// plaintextEnvFileName is the name of the file holding the decrypted "in-place" env vars of a compose stack
+// during its deployment. The files left by previous versions must not be archived
+const plaintextEnvFileName = "stack.env"
+
 // Creates a tar.gz system archive and encrypts it if password is not empty. Returns a path to the archive file.
 func CreateBackupArchive(password string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, filestorePath string) (string, error) {
-	backupDirPath, err := backupDatabaseAndFilesystem(gate, datastore, filestorePath)
+	backupDirPath, err := backupDatabaseAndFilesystem(gate, datastore, filestorePath, password != "")
 	if err != nil {
 		return "", err
 	}
@@ -55,7 +61,9 @@ func CreateBackupArchive(password string, gate *offlinegate.OfflineGate, datasto
 	return archivePath, nil
 }
 
-func backupDatabaseAndFilesystem(gate *offlinegate.OfflineGate, datastore dataservices.DataStore, filestorePath string) (string, error) {
+// backupDatabaseAndFilesystem copies the database and the files to back up. The key of the secret stack env vars
+// is only included when the archive is encrypted, so that an unencrypted archive does not disclose the secrets
+func backupDatabaseAndFilesystem(gate *offlinegate.OfflineGate, datastore dataservices.DataStore, filestorePath string, encrypted bool) (string, error) {
 	unlock := gate.Lock()
 	defer unlock()
 
@@ -77,15 +85,50 @@ func backupDatabaseAndFilesystem(gate *offlinegate.OfflineGate, datastore datase
 		return "", errors.Wrap(err, "Failed to backup database")
 	}
 
-	for _, filename := range filesToBackup {
+	files := filesToBackup
+	if encrypted {
+		files = append(slices.Clone(filesToBackup), envsecrets.KeyFileName)
+	}
+
+	for _, filename := range files {
 		if err := filesystem.CopyPath(filepath.Join(filestorePath, filename), backupDirPath); err != nil {
 			return "", errors.Wrap(err, "Failed to create backup file")
 		}
 	}
 
+	if err := removePlaintextEnvFiles(filepath.Join(backupDirPath, "compose")); err != nil {
+		return "", errors.Wrap(err, "Failed to remove the plaintext env files from the backup")
+	}
+
 	return backupDirPath, nil
 }
 
+// removePlaintextEnvFiles removes the env files generated at the root of each stack project, the files
+// sharing their name deeper inside a project belong to the stack itself and are kept
+func removePlaintextEnvFiles(composeDir string) error {
+	paths, err := filepath.Glob(filepath.Join(composeDir, "*", plaintextEnvFileName))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func backupDb(backupDirPath string, datastore dataservices.DataStore) error {
 	dbFileName := datastore.Connection().GetDatabaseFileName()
 	_, err := datastore.Backup(filepath.Join(backupDirPath, dbFileName))
@@ -7,18 +7,21 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/portainer/portainer/api/archive"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/sqlite"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 )
 
-var filesToRestore = append(filesToBackup, "portainer.db")
+var filesToRestore = append(slices.Clone(filesToBackup), "portainer.db", envsecrets.KeyFileName)
 
 // Restores system state from backup archive, will trigger system shutdown, when finished.
 func RestoreArchive(archive io.Reader, password string, filestorePath string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, shutdownTrigger context.CancelFunc) error {
@@ -68,8 +71,8 @@ func extractArchive(r io.Reader, destinationDirPath string) error {
 }
 
 func getRestoreSourcePath(dir string) (string, error) {
-	// find portainer.db or portainer.edb file. Return the parent directory
-	var portainerdbRegex = regexp.MustCompile(`^portainer.e?db$`)
+	// find the portainer.db, portainer.edb, portainer.sqlite or portainer.esqlite file. Return the parent directory
+	var portainerdbRegex = regexp.MustCompile(`^portainer\.e?(db|sqlite)$`)
 
 	backupDirPath := dir
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
@@ -95,19 +98,26 @@ func restoreFiles(srcDir string, destinationDir string) error {
 		}
 	}
 
-	// TODO:  This is very boltdb module specific once again due to the filename.  Move to bolt module? Refactor for another day
+	databaseFileNames := []string{
+		boltdb.EncryptedDatabaseFileName,
+		boltdb.DatabaseFileName,
+		sqlite.EncryptedDatabaseFileName,
+		sqlite.DatabaseFileName,
+	}
 
 	// Prevent the possibility of having both databases.  Remove any default new instance
-	os.Remove(filepath.Join(destinationDir, boltdb.DatabaseFileName))
-	os.Remove(filepath.Join(destinationDir, boltdb.EncryptedDatabaseFileName))
+	for _, filename := range databaseFileNames {
+		os.Remove(filepath.Join(destinationDir, filename))
+	}
 
-	// Now copy the database.  It'll be either portainer.db or portainer.edb
+	// Now copy the database.  It'll be one of portainer.db, portainer.edb, portainer.sqlite or portainer.esqlite
 
 	// Note: CopyPath does not return an error if the source file doesn't exist
-	err := filesystem.CopyPath(filepath.Join(srcDir, boltdb.EncryptedDatabaseFileName), destinationDir)
-	if err != nil {
-		return err
+	for _, filename := range databaseFileNames {
+		if err := filesystem.CopyPath(filepath.Join(srcDir, filename), destinationDir); err != nil {
+			return err
+		}
 	}
 
-	return filesystem.CopyPath(filepath.Join(srcDir, boltdb.DatabaseFileName), destinationDir)
+	return nil
 }
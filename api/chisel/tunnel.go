@@ -12,6 +12,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/pkg/libcrypto"
 
@@ -51,7 +52,7 @@ func (s *Service) Open(endpoint *portainer.Endpoint) error {
 		return nil
 	}
 
-	defer cache.Del(endpoint.ID)
+	defer notify.Send(endpoint.ID, notify.TunnelRequested)
 
 	tun := &portainer.TunnelDetails{
 		Status:       portainer.EdgeAgentManagementRequired,
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,9 @@ var (
 	ErrInvalidEndpointProtocol       = errors.New("Invalid environment protocol: Portainer only supports unix://, npipe:// or tcp://")
 	ErrSocketOrNamedPipeNotFound     = errors.New("Unable to locate Unix socket or named pipe")
 	ErrInvalidSnapshotInterval       = errors.New("Invalid snapshot interval")
+	ErrInvalidSnapshotConcurrency    = errors.New("Invalid snapshot concurrency, it must be at least 1")
+	ErrInvalidSnapshotTimeout        = errors.New("Invalid snapshot timeout, it must be greater than 0")
+	ErrInvalidDockerInventoryResync  = errors.New("Invalid Docker inventory resync interval, it must not be negative")
 	ErrAdminPassExcludeAdminPassFile = errors.New("Cannot use --admin-password with --admin-password-file")
 )
 
@@ -47,6 +51,9 @@ func CLIFlags() *portainer.CLIFlags {
 		SSLKey:                    kingpin.Flag("sslkey", "Path to the SSL key used to secure the Portainer instance").String(),
 		Rollback:                  kingpin.Flag("rollback", "Rollback the database to the previous backup").Bool(),
 		SnapshotInterval:          kingpin.Flag("snapshot-interval", "Duration between each environment snapshot job").String(),
+		SnapshotConcurrency:       kingpin.Flag("snapshot-concurrency", "Maximum number of environments snapshotted at the same time").Default(strconv.Itoa(portainer.DefaultSnapshotConcurrency)).Int(),
+		SnapshotTimeout:           kingpin.Flag("snapshot-timeout", "Maximum duration of the snapshot of a single environment").Default(portainer.DefaultSnapshotTimeout.String()).Duration(),
+		DockerInventoryResync:     kingpin.Flag("docker-inventory-resync", "Duration between each full resync of the live Docker inventory, 0 disables the inventory").Default(portainer.DefaultDockerInventoryResyncInterval.String()).Duration(),
 		AdminPassword:             kingpin.Flag("admin-password", "Set admin password with provided hash").String(),
 		AdminPasswordFile:         kingpin.Flag("admin-password-file", "Path to the file containing the password for the admin user").String(),
 		Labels:                    pairs(kingpin.Flag("hide-label", "Hide containers with a specific label in the UI").Short('l')),
@@ -56,7 +63,15 @@ func CLIFlags() *portainer.CLIFlags {
 		InitialMmapSize:           kingpin.Flag("initial-mmap-size", "Initial mmap size of the database in bytes").Int(),
 		MaxBatchSize:              kingpin.Flag("max-batch-size", "Maximum size of a batch").Int(),
 		MaxBatchDelay:             kingpin.Flag("max-batch-delay", "Maximum delay before a batch starts").Duration(),
+		CheckIntegrity:            kingpin.Flag("check-integrity", "Check that every object of the database can be decoded and look for orphaned references, print the report and exit").Bool(),
+		RepairIntegrity:           kingpin.Flag("repair-integrity", "Remove the orphaned references found by --check-integrity").Bool(),
+		CompactDatabase:           kingpin.Flag("compact-database", "Rewrite the database into a fresh file after --check-integrity").Bool(),
+		DatabaseType:              kingpin.Flag("database-type", "Database backend used to store the Portainer data").Default(defaultDatabaseType).Enum("boltdb", "sqlite"),
+		MigrateToSQLite:           kingpin.Flag("migrate-to-sqlite", "Copy the BoltDB database into a new SQLite database and exit").Bool(),
 		SecretKeyName:             kingpin.Flag("secret-key-name", "Secret key name for encryption and will be used as /run/secrets/<secret-key-name>.").Default(defaultSecretKeyName).String(),
+		RotateSecretKeyName:       kingpin.Flag("rotate-secret-key-name", "Re-encrypt the database with the secret /run/secrets/<rotate-secret-key-name> and exit. Portainer then refuses to start until --secret-key-name is set to the new secret.").String(),
+		ConfigApply:               kingpin.Flag("config-apply", "Path to a configuration document applied to the instance at startup").String(),
+		ConfigDryRun:              kingpin.Flag("config-dry-run", "Print the changes --config-apply would make and exit").Bool(),
 		LogLevel:                  kingpin.Flag("log-level", "Set the minimum logging level to show").Default("INFO").Enum("DEBUG", "INFO", "WARN", "ERROR"),
 		LogMode:                   kingpin.Flag("log-mode", "Set the logging output mode").Default("PRETTY").Enum("NOCOLOR", "PRETTY", "JSON"),
 		KubectlShellImage:         kingpin.Flag("kubectl-shell-image", "Kubectl shell image").Envar(portainer.KubectlShellImageEnvVar).Default(portainer.DefaultKubectlShellImage).String(),
@@ -95,6 +110,18 @@ func (*Service) ValidateFlags(flags *portainer.CLIFlags) error {
 		return err
 	}
 
+	if *flags.SnapshotConcurrency < 1 {
+		return ErrInvalidSnapshotConcurrency
+	}
+
+	if *flags.SnapshotTimeout <= 0 {
+		return ErrInvalidSnapshotTimeout
+	}
+
+	if *flags.DockerInventoryResync < 0 {
+		return ErrInvalidDockerInventoryResync
+	}
+
 	if *flags.AdminPassword != "" && *flags.AdminPasswordFile != "" {
 		return ErrAdminPassExcludeAdminPassFile
 	}
@@ -19,5 +19,6 @@ const (
 	defaultHTTPEnabled         = "false"
 	defaultSSL                 = "false"
 	defaultBaseURL             = "/"
+	defaultDatabaseType        = "boltdb"
 	defaultSecretKeyName       = "portainer"
 )
@@ -17,5 +17,6 @@ const (
 	defaultSSL                 = "false"
 	defaultSnapshotInterval    = "5m"
 	defaultBaseURL             = "/"
+	defaultDatabaseType        = "boltdb"
 	defaultSecretKeyName       = "portainer"
 )
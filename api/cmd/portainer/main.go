@@ -3,25 +3,32 @@ package main
 import (
 	"cmp"
 	"context"
-	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/apptemplates"
 	"github.com/portainer/portainer/api/chisel"
 	"github.com/portainer/portainer/api/cli"
+	"github.com/portainer/portainer/api/configascode"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database"
 	"github.com/portainer/portainer/api/database/boltdb"
 	"github.com/portainer/portainer/api/database/models"
+	"github.com/portainer/portainer/api/database/sqlite"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/datastore"
 	"github.com/portainer/portainer/api/datastore/migrator"
 	"github.com/portainer/portainer/api/datastore/postinit"
 	"github.com/portainer/portainer/api/docker"
 	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/docker/inventory"
 	"github.com/portainer/portainer/api/exec"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/git"
@@ -32,6 +39,7 @@ import (
 	"github.com/portainer/portainer/api/internal/authorization"
 	"github.com/portainer/portainer/api/internal/edge/edgestacks"
 	"github.com/portainer/portainer/api/internal/endpointutils"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/internal/snapshot"
 	"github.com/portainer/portainer/api/internal/ssl"
 	"github.com/portainer/portainer/api/internal/upgrade"
@@ -80,17 +88,27 @@ func initFileService(dataStorePath string) portainer.FileService {
 }
 
 func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService portainer.FileService, shutdownCtx context.Context) dataservices.DataStore {
-	connection, err := database.NewDatabase("boltdb", *flags.Data, secretKey)
+	connection, err := database.NewDatabase(*flags.DatabaseType, *flags.Data, secretKey)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed creating database connection")
 	}
 
-	if bconn, ok := connection.(*boltdb.DbConnection); ok {
-		bconn.MaxBatchSize = *flags.MaxBatchSize
-		bconn.MaxBatchDelay = *flags.MaxBatchDelay
-		bconn.InitialMmapSize = *flags.InitialMmapSize
-	} else {
-		log.Fatal().Msg("failed creating database connection: expecting a boltdb database type but a different one was received")
+	switch conn := connection.(type) {
+	case *boltdb.DbConnection:
+		conn.MaxBatchSize = *flags.MaxBatchSize
+		conn.MaxBatchDelay = *flags.MaxBatchDelay
+		conn.InitialMmapSize = *flags.InitialMmapSize
+	case *sqlite.DbConnection:
+		// Don't silently start from an empty store next to an existing BoltDB one
+		if fileExists(*flags.Data, sqlite.DatabaseFileName, sqlite.EncryptedDatabaseFileName) {
+			break
+		}
+
+		if fileExists(*flags.Data, boltdb.DatabaseFileName, boltdb.EncryptedDatabaseFileName) {
+			log.Fatal().Msg("a BoltDB database was found, run Portainer with --migrate-to-sqlite to copy it into the SQLite database first")
+		}
+	default:
+		log.Fatal().Msg("failed creating database connection: unexpected database type")
 	}
 
 	store := datastore.NewStore(flags, fileService, connection)
@@ -109,6 +127,14 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 		os.Exit(0)
 	}
 
+	if *flags.RotateSecretKeyName != "" {
+		rotateEncryptionKey(store, *flags.Data, *flags.RotateSecretKeyName)
+	}
+
+	if *flags.CheckIntegrity {
+		checkIntegrity(store, *flags.RepairIntegrity, *flags.CompactDatabase)
+	}
+
 	// Init sets some defaults - it's basically a migration
 	if err := store.Init(); err != nil {
 		log.Fatal().Err(err).Msg("failed initializing data store")
@@ -208,6 +234,8 @@ func initSSLService(addr, certPath, keyPath string, fileService portainer.FileSe
 
 func initSnapshotService(
 	snapshotIntervalFromFlag string,
+	snapshotConcurrency int,
+	snapshotTimeout time.Duration,
 	dataStore dataservices.DataStore,
 	dockerClientFactory *dockerclient.ClientFactory,
 	kubernetesClientFactory *kubecli.ClientFactory,
@@ -217,7 +245,7 @@ func initSnapshotService(
 	dockerSnapshotter := docker.NewSnapshotter(dockerClientFactory)
 	kubernetesSnapshotter := kubernetes.NewSnapshotter(kubernetesClientFactory)
 
-	snapshotService, err := snapshot.NewService(snapshotIntervalFromFlag, dataStore, dockerSnapshotter, kubernetesSnapshotter, shutdownCtx, pendingActionsService)
+	snapshotService, err := snapshot.NewService(snapshotIntervalFromFlag, snapshotConcurrency, snapshotTimeout, dataStore, dockerSnapshotter, kubernetesSnapshotter, shutdownCtx, pendingActionsService)
 	if err != nil {
 		return nil, err
 	}
@@ -303,8 +331,38 @@ func initKeyPair(fileService portainer.FileService, signatureService portainer.D
 	return generateAndStoreKeyPair(fileService, signatureService)
 }
 
+// initTemplateBundleKeyPair generates the key pair signing the custom template bundles, it is kept apart from
+// the key pair used to sign the requests sent to the agents
+func initTemplateBundleKeyPair(fileService portainer.FileService) error {
+	existingKeyPair, err := fileService.TemplateBundleKeyPairFilesExist()
+	if err != nil || existingKeyPair {
+		return err
+	}
+
+	signatureService := crypto.NewECDSAService("")
+
+	private, public, err := signatureService.GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+
+	privateHeader, publicHeader := signatureService.PEMHeaders()
+
+	return fileService.StoreTemplateBundleKeyPair(private, public, privateHeader, publicHeader)
+}
+
+// secretKeyName returns the --secret-key-name flag, Portainer refuses to start when the
+// database was re-encrypted with another secret
+func secretKeyName(dataPath, flagValue string) string {
+	if err := crypto.CheckSecretKeyName(dataPath, flagValue); err != nil {
+		log.Fatal().Err(err).Str("secret_key_name", flagValue).Msg("failed checking the encryption key secret")
+	}
+
+	return flagValue
+}
+
 func loadEncryptionSecretKey(keyfilename string) []byte {
-	content, err := os.ReadFile(path.Join("/run/secrets", keyfilename))
+	key, err := crypto.LoadSecretKey(keyfilename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Info().Str("filename", keyfilename).Msg("encryption key file not present")
@@ -315,10 +373,144 @@ func loadEncryptionSecretKey(keyfilename string) []byte {
 		return nil
 	}
 
-	// return a 32 byte hash of the secret (required for AES)
-	hash := sha256.Sum256(content)
+	return key
+}
 
-	return hash[:]
+func fileExists(dir string, filenames ...string) bool {
+	for _, filename := range filenames {
+		if _, err := os.Stat(filepath.Join(dir, filename)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// migrateToSQLite copies the BoltDB store into a new SQLite store and exits,
+// Portainer must then be restarted with --database-type=sqlite
+func migrateToSQLite(dataStorePath string, secretKey []byte) {
+	if err := sqlite.MigrateFromBoltDB(dataStorePath, secretKey); err != nil {
+		log.Fatal().Err(err).Msg("failed migrating the BoltDB database to SQLite")
+	}
+
+	log.Info().Msg("database migrated to SQLite, restart Portainer with --database-type=sqlite")
+
+	os.Exit(0)
+}
+
+// checkIntegrity prints the integrity report of the store and exits, with a
+// non-zero code when the store is not healthy
+func checkIntegrity(store dataservices.DataStore, repair, compact bool) {
+	report, err := store.Maintain(repair, compact)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed checking the database integrity")
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed encoding the database integrity report")
+	}
+
+	fmt.Println(string(b))
+
+	store.Close()
+
+	if !report.Healthy() {
+		log.Error().Msg("database integrity check failed")
+		os.Exit(1)
+	}
+
+	log.Info().Msg("database integrity check passed")
+	os.Exit(0)
+}
+
+// applyConfig reconciles the instance with the configuration document at
+// path on behalf of the initial administrator. In dry-run mode the changes are
+// printed and Portainer exits.
+func applyConfig(dataStore dataservices.DataStore, fileService portainer.FileService, apiKeyService apikey.APIKeyService, path string, dryRun bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed reading the configuration")
+	}
+
+	config, err := configascode.Parse(data)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed parsing the configuration")
+	}
+
+	admins, err := dataStore.User().UsersByRole(portainer.AdministratorRole)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed getting admin user")
+	}
+
+	// Without any administrator yet, the configuration is applied by the system
+	var userID portainer.UserID
+	if len(admins) > 0 {
+		userID = slices.MinFunc(admins, func(a, b portainer.User) int {
+			return cmp.Compare(a.ID, b.ID)
+		}).ID
+	}
+
+	plan, err := configascode.NewService(dataStore, fileService, apiKeyService).Apply(config, configascode.ApplyOptions{
+		DryRun: dryRun,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("failed applying the configuration")
+	}
+
+	for _, warning := range plan.Warnings {
+		log.Warn().Str("path", path).Msg(warning)
+	}
+
+	if dryRun {
+		b, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed encoding the configuration plan")
+		}
+
+		fmt.Println(string(b))
+
+		dataStore.Close()
+		os.Exit(0)
+	}
+
+	for _, change := range plan.Changes {
+		log.Info().
+			Str("kind", change.Kind).
+			Str("name", change.Name).
+			Str("operation", string(change.Operation)).
+			Msg("configuration change")
+	}
+
+	log.Info().Str("path", path).Int("changes", len(plan.Changes)).Msg("configuration applied")
+}
+
+// rotateEncryptionKey re-encrypts the store with the key loaded from the
+// given secret and exits. The name of the new secret is recorded in the data
+// directory so that it is used on the next start
+func rotateEncryptionKey(store dataservices.DataStore, dataPath, secretKeyName string) {
+	newKey, err := crypto.LoadSecretKey(secretKeyName)
+	if err != nil {
+		log.Fatal().Err(err).Str("filename", secretKeyName).Msg("failed loading the new encryption key")
+	}
+
+	backupFilename, err := store.RotateEncryptionKey(newKey)
+	if err != nil {
+		log.Fatal().Err(err).Str("backup", backupFilename).Msg("failed rotating the database encryption key")
+	}
+
+	if err := crypto.SaveRotatedSecretKeyName(dataPath, secretKeyName); err != nil {
+		log.Fatal().Err(err).Str("secret_key_name", secretKeyName).Msg("database encryption key rotated but failed recording the new secret, restart Portainer with --secret-key-name set to the new secret")
+	}
+
+	log.Info().
+		Str("backup", backupFilename).
+		Str("secret_key_name", secretKeyName).
+		Msg("database encryption key rotated, restart Portainer with --secret-key-name set to the new secret")
+
+	store.Close()
+	os.Exit(0)
 }
 
 func buildServer(flags *portainer.CLIFlags) portainer.Server {
@@ -329,11 +521,20 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	}
 
 	fileService := initFileService(*flags.Data)
-	encryptionKey := loadEncryptionSecretKey(*flags.SecretKeyName)
+
+	if err := envsecrets.Init(*flags.Data); err != nil {
+		log.Fatal().Err(err).Msg("failed initializing the stack secrets encryption")
+	}
+
+	encryptionKey := loadEncryptionSecretKey(secretKeyName(*flags.Data, *flags.SecretKeyName))
 	if encryptionKey == nil {
 		log.Info().Msg("proceeding without encryption key")
 	}
 
+	if *flags.MigrateToSQLite {
+		migrateToSQLite(*flags.Data, encryptionKey)
+	}
+
 	dataStore := initDataStore(flags, encryptionKey, fileService, shutdownCtx)
 
 	if err := dataStore.CheckCurrentEdition(); err != nil {
@@ -390,6 +591,10 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		log.Fatal().Err(err).Msg("failed initializing key pair")
 	}
 
+	if err := initTemplateBundleKeyPair(fileService); err != nil {
+		log.Fatal().Err(err).Msg("failed initializing the template bundle key pair")
+	}
+
 	reverseTunnelService := chisel.NewService(dataStore, shutdownCtx, fileService)
 
 	dockerClientFactory := dockerclient.NewClientFactory(signatureService, reverseTunnelService)
@@ -428,14 +633,17 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	pendingActionsService.RegisterHandler(actions.DeletePortainerK8sRegistrySecrets, handlers.NewHandlerDeleteRegistrySecrets(authorizationService, dataStore, kubernetesClientFactory))
 	pendingActionsService.RegisterHandler(actions.PostInitMigrateEnvironment, handlers.NewHandlerPostInitMigrateEnvironment(authorizationService, dataStore, kubernetesClientFactory, dockerClientFactory, *flags.Assets, kubernetesDeployer))
 
-	snapshotService, err := initSnapshotService(*flags.SnapshotInterval, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx, pendingActionsService)
+	snapshotService, err := initSnapshotService(*flags.SnapshotInterval, *flags.SnapshotConcurrency, *flags.SnapshotTimeout, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx, pendingActionsService)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed initializing snapshot service")
 	}
 
 	snapshotService.Start()
 
-	proxyManager.NewProxyFactory(dataStore, signatureService, reverseTunnelService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager, gitService, snapshotService)
+	dockerInventoryService := inventory.NewService(shutdownCtx, dataStore, dockerClientFactory, *flags.DockerInventoryResync)
+	dockerInventoryService.Start()
+
+	proxyManager.NewProxyFactory(dataStore, signatureService, reverseTunnelService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager, gitService, snapshotService, dockerInventoryService)
 
 	helmPackageManager, err := initHelmPackageManager(*flags.Assets)
 	if err != nil {
@@ -491,6 +699,10 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		}
 	}
 
+	if *flags.ConfigApply != "" {
+		applyConfig(dataStore, fileService, apiKeyService, *flags.ConfigApply, *flags.ConfigDryRun)
+	}
+
 	if err := reverseTunnelService.StartTunnelServer(*flags.TunnelAddr, *flags.TunnelPort, snapshotService); err != nil {
 		log.Fatal().Err(err).Msg("failed starting tunnel server")
 	}
@@ -499,6 +711,21 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	stackDeployer := deployments.NewStackDeployer(swarmStackManager, composeStackManager, kubernetesDeployer, dockerClientFactory, dataStore)
 	deployments.StartStackSchedules(scheduler, stackDeployer, dataStore, gitService)
 
+	imageWatcher := deployments.NewImageWatcher(scheduler, stackDeployer, dataStore, dockerClientFactory)
+	if err := imageWatcher.StartSchedules(); err != nil {
+		log.Error().Err(err).Msg("failed to start the stack image watch schedules")
+	}
+
+	driftDetector := deployments.NewDriftDetector(scheduler, stackDeployer, dataStore, composeStackManager, dockerClientFactory, kubernetesClientFactory)
+	if err := driftDetector.StartSchedules(); err != nil {
+		log.Error().Err(err).Msg("failed to start the stack drift check schedules")
+	}
+
+	templatesService := apptemplates.NewService(dataStore, fileService, gitService)
+	if err := templatesService.Start(shutdownCtx, scheduler); err != nil {
+		log.Fatal().Err(err).Msg("failed starting the application templates service")
+	}
+
 	sslDBSettings, err := dataStore.SSLSettings().Settings()
 	if err != nil {
 		log.Fatal().Msg("failed to fetch SSL settings from DB")
@@ -566,15 +793,19 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		SnapshotService:             snapshotService,
 		SSLService:                  sslService,
 		DockerClientFactory:         dockerClientFactory,
+		DockerInventoryService:      dockerInventoryService,
 		KubernetesClientFactory:     kubernetesClientFactory,
 		Scheduler:                   scheduler,
 		ShutdownCtx:                 shutdownCtx,
 		ShutdownTrigger:             shutdownTrigger,
 		StackDeployer:               stackDeployer,
+		ImageWatcher:                imageWatcher,
+		DriftDetector:               driftDetector,
 		UpgradeService:              upgradeService,
 		AdminCreationDone:           adminCreationDone,
 		PendingActionsService:       pendingActionsService,
 		PlatformService:             platformService,
+		TemplatesService:            templatesService,
 	}
 }
 
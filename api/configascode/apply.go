@@ -0,0 +1,1254 @@
+package configascode
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/internal/authorization"
+	"github.com/portainer/portainer/api/internal/edge"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Operation is the kind of change made to an object by Apply
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+const (
+	KindSettings         = "settings"
+	KindTag              = "tag"
+	KindTeam             = "team"
+	KindUser             = "user"
+	KindEnvironmentGroup = "environmentGroup"
+	KindEdgeGroup        = "edgeGroup"
+	KindRegistry         = "registry"
+	KindCustomTemplate   = "customTemplate"
+)
+
+// ErrEdgeGroupInUse is returned when the configuration removes an Edge group
+// that is still targeted by Edge stacks or Edge jobs
+var ErrEdgeGroupInUse = errors.New("edge group is used by an edge stack or an edge job")
+
+type (
+	// Change is a change made, or to be made in dry-run mode, to an object
+	Change struct {
+		Kind      string    `example:"team"`
+		Name      string    `example:"developers"`
+		Operation Operation `example:"create" enums:"create,update,delete"`
+	}
+
+	// Plan lists the changes required to reconcile the instance with a
+	// configuration, in the order they are applied
+	Plan struct {
+		DryRun   bool
+		Changes  []Change
+		Warnings []string
+	}
+
+	// ApplyOptions customizes Apply
+	ApplyOptions struct {
+		// DryRun computes the plan without modifying the instance
+		DryRun bool
+		// UserID is the user applying the configuration, it is never deleted
+		// and owns the created custom templates. It is 0 when the
+		// configuration is applied by the system
+		UserID portainer.UserID
+	}
+
+	// Service exports and applies configurations
+	Service struct {
+		dataStore     dataservices.DataStore
+		fileService   portainer.FileService
+		apiKeyService apikey.APIKeyService
+	}
+
+	applier struct {
+		tx          dataservices.DataStoreTx
+		fileService portainer.FileService
+		options     ApplyOptions
+		plan        *Plan
+
+		tags                map[string]portainer.TagID
+		teams               map[string]portainer.TeamID
+		updateEdgeRelations bool
+		// updatedUsers are the users deleted or whose role changed
+		updatedUsers []portainer.UserID
+		// rollbacks undo the changes made to the files when the transaction is rolled back
+		rollbacks []func()
+	}
+)
+
+// NewService returns a new configuration as code service
+func NewService(dataStore dataservices.DataStore, fileService portainer.FileService, apiKeyService apikey.APIKeyService) *Service {
+	return &Service{
+		dataStore:     dataStore,
+		fileService:   fileService,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Apply reconciles the instance with the configuration inside a single
+// transaction and returns the changes that were made. In dry-run mode the
+// changes are only computed.
+func (service *Service) Apply(config *Config, options ApplyOptions) (*Plan, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var plan *Plan
+	var updatedUsers []portainer.UserID
+	var rollbacks []func()
+
+	apply := func(tx dataservices.DataStoreTx) error {
+		plan = &Plan{
+			DryRun:   options.DryRun,
+			Changes:  []Change{},
+			Warnings: []string{},
+		}
+
+		a := &applier{
+			tx:          tx,
+			fileService: service.fileService,
+			options:     options,
+			plan:        plan,
+		}
+
+		err := a.apply(config)
+		rollbacks = a.rollbacks
+		if err != nil {
+			return err
+		}
+
+		updatedUsers = a.updatedUsers
+
+		return nil
+	}
+
+	var err error
+	if options.DryRun {
+		err = service.dataStore.ViewTx(apply)
+	} else {
+		err = service.dataStore.UpdateTx(apply)
+	}
+
+	if err != nil {
+		// The files are written along with the transaction, they are restored when it is rolled back
+		for _, rollback := range slices.Backward(rollbacks) {
+			rollback()
+		}
+
+		return nil, err
+	}
+
+	// The cached API keys of the users would otherwise keep granting their previous access
+	for _, userID := range updatedUsers {
+		service.apiKeyService.InvalidateUserKeyCache(userID)
+	}
+
+	return plan, nil
+}
+
+func (a *applier) record(kind, name string, operation Operation) {
+	a.plan.Changes = append(a.plan.Changes, Change{Kind: kind, Name: name, Operation: operation})
+}
+
+func (a *applier) warn(format string, args ...any) {
+	a.plan.Warnings = append(a.plan.Warnings, fmt.Sprintf(format, args...))
+}
+
+// onRollback registers fn to undo a change made outside of the transaction if it is rolled back
+func (a *applier) onRollback(fn func()) {
+	a.rollbacks = append(a.rollbacks, fn)
+}
+
+// write runs fn unless the changes are only being planned
+func (a *applier) write(fn func() error) error {
+	if a.options.DryRun {
+		return nil
+	}
+
+	return fn()
+}
+
+func (a *applier) apply(config *Config) error {
+	steps := []func(*Config) error{
+		a.applySettings,
+		a.applyTags,
+		a.applyTeams,
+		a.applyUsers,
+		a.applyEnvironmentGroups,
+		a.applyEdgeGroups,
+		a.applyRegistries,
+		a.applyCustomTemplates,
+	}
+
+	for _, step := range steps {
+		if err := step(config); err != nil {
+			return err
+		}
+	}
+
+	if !a.updateEdgeRelations {
+		return nil
+	}
+
+	return a.write(a.refreshEdgeRelations)
+}
+
+func (a *applier) applySettings(config *Config) error {
+	if config.Settings == nil {
+		return nil
+	}
+
+	settings, err := a.tx.Settings().Settings()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the settings: %w", err)
+	}
+
+	updated := *settings
+	desired := config.Settings
+
+	setIfPresent(&updated.LogoURL, desired.LogoURL)
+	if desired.BlackListedLabels != nil && (len(*desired.BlackListedLabels) > 0 || len(settings.BlackListedLabels) > 0) {
+		updated.BlackListedLabels = *desired.BlackListedLabels
+	}
+	setIfPresent(&updated.SnapshotInterval, desired.SnapshotInterval)
+	setIfPresent(&updated.TemplatesURL, desired.TemplatesURL)
+	setIfPresent(&updated.EdgeAgentCheckinInterval, desired.EdgeAgentCheckinInterval)
+	setIfPresent(&updated.EnableEdgeComputeFeatures, desired.EnableEdgeComputeFeatures)
+	setIfPresent(&updated.UserSessionTimeout, desired.UserSessionTimeout)
+	setIfPresent(&updated.KubeconfigExpiry, desired.KubeconfigExpiry)
+	setIfPresent(&updated.EnableTelemetry, desired.EnableTelemetry)
+	setIfPresent(&updated.HelmRepositoryURL, desired.HelmRepositoryURL)
+	setIfPresent(&updated.KubectlShellImage, desired.KubectlShellImage)
+	setIfPresent(&updated.TrustOnFirstConnect, desired.TrustOnFirstConnect)
+	setIfPresent(&updated.EnforceEdgeID, desired.EnforceEdgeID)
+	setIfPresent(&updated.EdgePortainerURL, desired.EdgePortainerURL)
+
+	if reflect.DeepEqual(settings, &updated) {
+		return nil
+	}
+
+	a.record(KindSettings, KindSettings, OperationUpdate)
+
+	return a.write(func() error {
+		return a.tx.Settings().UpdateSettings(&updated)
+	})
+}
+
+func setIfPresent[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func (a *applier) applyTags(config *Config) error {
+	tags, err := a.tx.Tag().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the tags: %w", err)
+	}
+
+	a.tags = make(map[string]portainer.TagID, len(tags))
+	for _, tag := range tags {
+		a.tags[tag.Name] = tag.ID
+	}
+
+	if config.Tags == nil {
+		return nil
+	}
+
+	for _, name := range *config.Tags {
+		if _, ok := a.tags[name]; ok {
+			continue
+		}
+
+		a.record(KindTag, name, OperationCreate)
+
+		tag := &portainer.Tag{
+			Name:           name,
+			EndpointGroups: map[portainer.EndpointGroupID]bool{},
+			Endpoints:      map[portainer.EndpointID]bool{},
+		}
+
+		if err := a.write(func() error { return a.tx.Tag().Create(tag) }); err != nil {
+			return fmt.Errorf("unable to create the %q tag: %w", name, err)
+		}
+
+		a.tags[name] = tag.ID
+	}
+
+	for _, tag := range tags {
+		if slices.Contains(*config.Tags, tag.Name) {
+			continue
+		}
+
+		a.record(KindTag, tag.Name, OperationDelete)
+		a.updateEdgeRelations = true
+		delete(a.tags, tag.Name)
+
+		if err := a.write(func() error { return a.deleteTag(&tag) }); err != nil {
+			return fmt.Errorf("unable to delete the %q tag: %w", tag.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteTag removes the tag and every reference to it
+func (a *applier) deleteTag(tag *portainer.Tag) error {
+	withoutTag := func(t portainer.TagID) bool { return t == tag.ID }
+
+	for endpointID := range tag.Endpoints {
+		endpoint, err := a.tx.Endpoint().Endpoint(endpointID)
+		if a.tx.IsErrObjectNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		endpoint.TagIDs = slices.DeleteFunc(endpoint.TagIDs, withoutTag)
+		if err := a.tx.Endpoint().UpdateEndpoint(endpoint.ID, endpoint); err != nil {
+			return err
+		}
+	}
+
+	for endpointGroupID := range tag.EndpointGroups {
+		endpointGroup, err := a.tx.EndpointGroup().Read(endpointGroupID)
+		if a.tx.IsErrObjectNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		endpointGroup.TagIDs = slices.DeleteFunc(endpointGroup.TagIDs, withoutTag)
+		if err := a.tx.EndpointGroup().Update(endpointGroup.ID, endpointGroup); err != nil {
+			return err
+		}
+	}
+
+	edgeGroups, err := a.tx.EdgeGroup().ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for _, edgeGroup := range edgeGroups {
+		if !slices.Contains(edgeGroup.TagIDs, tag.ID) {
+			continue
+		}
+
+		edgeGroup.TagIDs = slices.DeleteFunc(edgeGroup.TagIDs, withoutTag)
+		if err := a.tx.EdgeGroup().Update(edgeGroup.ID, &edgeGroup); err != nil {
+			return err
+		}
+	}
+
+	return a.tx.Tag().Delete(tag.ID)
+}
+
+// resolveTags returns the identifiers of the named tags. Tags created in
+// dry-run mode resolve to the zero identifier.
+func (a *applier) resolveTags(kind, name string, tagNames []string) ([]portainer.TagID, error) {
+	tagIDs := make([]portainer.TagID, 0, len(tagNames))
+
+	for _, tagName := range tagNames {
+		tagID, ok := a.tags[tagName]
+		if !ok {
+			return nil, invalidf("%s %q references the unknown tag %q", kind, name, tagName)
+		}
+
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	return tagIDs, nil
+}
+
+func (a *applier) applyTeams(config *Config) error {
+	teams, err := a.tx.Team().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the teams: %w", err)
+	}
+
+	a.teams = make(map[string]portainer.TeamID, len(teams))
+	for _, team := range teams {
+		a.teams[strings.ToLower(team.Name)] = team.ID
+	}
+
+	if config.Teams == nil {
+		return nil
+	}
+
+	desired := make(map[string]bool, len(*config.Teams))
+
+	for _, name := range *config.Teams {
+		desired[strings.ToLower(name)] = true
+
+		if _, ok := a.teams[strings.ToLower(name)]; ok {
+			continue
+		}
+
+		a.record(KindTeam, name, OperationCreate)
+
+		team := &portainer.Team{Name: name}
+		if err := a.write(func() error { return a.tx.Team().Create(team) }); err != nil {
+			return fmt.Errorf("unable to create the %q team: %w", name, err)
+		}
+
+		a.teams[strings.ToLower(name)] = team.ID
+	}
+
+	for _, team := range teams {
+		if desired[strings.ToLower(team.Name)] {
+			continue
+		}
+
+		a.record(KindTeam, team.Name, OperationDelete)
+		delete(a.teams, strings.ToLower(team.Name))
+
+		if err := a.write(func() error { return a.deleteTeam(team.ID) }); err != nil {
+			return fmt.Errorf("unable to delete the %q team: %w", team.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *applier) deleteTeam(teamID portainer.TeamID) error {
+	if err := a.tx.Team().Delete(teamID); err != nil {
+		return err
+	}
+
+	if err := a.tx.TeamMembership().DeleteTeamMembershipByTeamID(teamID); err != nil {
+		return err
+	}
+
+	settings, err := a.tx.Settings().Settings()
+	if err != nil {
+		return err
+	}
+
+	if settings.OAuthSettings.DefaultTeamID != teamID {
+		return nil
+	}
+
+	settings.OAuthSettings.DefaultTeamID = 0
+
+	return a.tx.Settings().UpdateSettings(settings)
+}
+
+func (a *applier) applyUsers(config *Config) error {
+	if config.Users == nil {
+		return nil
+	}
+
+	users, err := a.tx.User().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the users: %w", err)
+	}
+
+	existing := make(map[string]portainer.User, len(users))
+	for _, user := range users {
+		existing[strings.ToLower(user.Username)] = user
+	}
+
+	desired := make(map[string]bool, len(*config.Users))
+
+	for _, u := range *config.Users {
+		desired[strings.ToLower(u.Username)] = true
+
+		memberships := make(map[portainer.TeamID]portainer.MembershipRole, len(u.Teams))
+		for _, membership := range u.Teams {
+			teamID, ok := a.teams[strings.ToLower(membership.Team)]
+			if !ok {
+				return invalidf("user %q references the unknown team %q", u.Username, membership.Team)
+			}
+
+			memberships[teamID] = membershipRoles[membership.Role]
+		}
+
+		user, ok := existing[strings.ToLower(u.Username)]
+		if !ok {
+			a.record(KindUser, u.Username, OperationCreate)
+
+			user = portainer.User{Username: u.Username, Role: userRoles[u.Role]}
+			if err := a.write(func() error {
+				if err := a.tx.User().Create(&user); err != nil {
+					return err
+				}
+
+				return a.syncMemberships(user.ID, nil, memberships)
+			}); err != nil {
+				return fmt.Errorf("unable to create the %q user: %w", u.Username, err)
+			}
+
+			continue
+		}
+
+		current, err := a.tx.TeamMembership().TeamMembershipsByUserID(user.ID)
+		if err != nil {
+			return fmt.Errorf("unable to retrieve the memberships of the %q user: %w", u.Username, err)
+		}
+
+		currentRoles := make(map[portainer.TeamID]portainer.MembershipRole, len(current))
+		for _, membership := range current {
+			currentRoles[membership.TeamID] = membership.Role
+		}
+
+		if user.Role == userRoles[u.Role] && maps.Equal(currentRoles, memberships) {
+			continue
+		}
+
+		a.record(KindUser, user.Username, OperationUpdate)
+
+		if err := a.write(func() error {
+			if user.Role != userRoles[u.Role] {
+				user.Role = userRoles[u.Role]
+				if err := a.tx.User().Update(user.ID, &user); err != nil {
+					return err
+				}
+
+				a.updatedUsers = append(a.updatedUsers, user.ID)
+			}
+
+			return a.syncMemberships(user.ID, current, memberships)
+		}); err != nil {
+			return fmt.Errorf("unable to update the %q user: %w", u.Username, err)
+		}
+	}
+
+	for _, user := range users {
+		if desired[strings.ToLower(user.Username)] {
+			continue
+		}
+
+		if user.ID == 1 || user.ID == a.options.UserID {
+			a.warn("the %q user is not part of the configuration but cannot be deleted", user.Username)
+			continue
+		}
+
+		a.record(KindUser, user.Username, OperationDelete)
+
+		if err := a.write(func() error { return a.deleteUser(user.ID) }); err != nil {
+			return fmt.Errorf("unable to delete the %q user: %w", user.Username, err)
+		}
+	}
+
+	return nil
+}
+
+// syncMemberships updates the team memberships of a user to match desired
+func (a *applier) syncMemberships(userID portainer.UserID, current []portainer.TeamMembership, desired map[portainer.TeamID]portainer.MembershipRole) error {
+	for _, membership := range current {
+		role, ok := desired[membership.TeamID]
+		if !ok {
+			if err := a.tx.TeamMembership().Delete(membership.ID); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		delete(desired, membership.TeamID)
+
+		if role == membership.Role {
+			continue
+		}
+
+		membership.Role = role
+		if err := a.tx.TeamMembership().Update(membership.ID, &membership); err != nil {
+			return err
+		}
+	}
+
+	for teamID, role := range desired {
+		if err := a.tx.TeamMembership().Create(&portainer.TeamMembership{
+			UserID: userID,
+			TeamID: teamID,
+			Role:   role,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *applier) deleteUser(userID portainer.UserID) error {
+	if err := a.tx.User().Delete(userID); err != nil {
+		return err
+	}
+
+	if err := a.tx.TeamMembership().DeleteTeamMembershipByUserID(userID); err != nil {
+		return err
+	}
+
+	apiKeys, err := a.tx.APIKeyRepository().GetAPIKeysByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, apiKey := range apiKeys {
+		if err := a.tx.APIKeyRepository().Delete(apiKey.ID); err != nil {
+			return err
+		}
+	}
+
+	a.updatedUsers = append(a.updatedUsers, userID)
+
+	return nil
+}
+
+func (a *applier) applyEnvironmentGroups(config *Config) error {
+	if config.EnvironmentGroups == nil {
+		return nil
+	}
+
+	endpointGroups, err := a.tx.EndpointGroup().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the environment groups: %w", err)
+	}
+
+	existing := make(map[string]portainer.EndpointGroup, len(endpointGroups))
+	for _, endpointGroup := range endpointGroups {
+		existing[endpointGroup.Name] = endpointGroup
+	}
+
+	desired := make(map[string]bool, len(*config.EnvironmentGroups))
+
+	for _, g := range *config.EnvironmentGroups {
+		desired[g.Name] = true
+
+		tagIDs, err := a.resolveTags(KindEnvironmentGroup, g.Name, g.Tags)
+		if err != nil {
+			return err
+		}
+
+		endpointGroup, ok := existing[g.Name]
+		if !ok {
+			a.record(KindEnvironmentGroup, g.Name, OperationCreate)
+
+			endpointGroup = portainer.EndpointGroup{
+				Name:               g.Name,
+				Description:        g.Description,
+				UserAccessPolicies: portainer.UserAccessPolicies{},
+				TeamAccessPolicies: portainer.TeamAccessPolicies{},
+				TagIDs:             tagIDs,
+			}
+
+			if err := a.write(func() error {
+				if err := a.tx.EndpointGroup().Create(&endpointGroup); err != nil {
+					return err
+				}
+
+				return a.updateGroupTags(endpointGroup.ID, nil, tagIDs)
+			}); err != nil {
+				return fmt.Errorf("unable to create the %q environment group: %w", g.Name, err)
+			}
+
+			continue
+		}
+
+		if endpointGroup.Description == g.Description && sameElements(endpointGroup.TagIDs, tagIDs) {
+			continue
+		}
+
+		a.record(KindEnvironmentGroup, g.Name, OperationUpdate)
+		a.updateEdgeRelations = true
+
+		previousTagIDs := endpointGroup.TagIDs
+		endpointGroup.Description = g.Description
+		endpointGroup.TagIDs = tagIDs
+
+		if err := a.write(func() error {
+			if err := a.tx.EndpointGroup().Update(endpointGroup.ID, &endpointGroup); err != nil {
+				return err
+			}
+
+			return a.updateGroupTags(endpointGroup.ID, previousTagIDs, tagIDs)
+		}); err != nil {
+			return fmt.Errorf("unable to update the %q environment group: %w", g.Name, err)
+		}
+	}
+
+	for _, endpointGroup := range endpointGroups {
+		if desired[endpointGroup.Name] {
+			continue
+		}
+
+		if endpointGroup.ID == 1 {
+			a.warn("the %q environment group is not part of the configuration but cannot be deleted", endpointGroup.Name)
+			continue
+		}
+
+		a.record(KindEnvironmentGroup, endpointGroup.Name, OperationDelete)
+		a.updateEdgeRelations = true
+
+		if err := a.write(func() error { return a.deleteEndpointGroup(&endpointGroup) }); err != nil {
+			return fmt.Errorf("unable to delete the %q environment group: %w", endpointGroup.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// updateGroupTags keeps the EndpointGroups set of the tags in sync with the
+// tags of an environment group
+func (a *applier) updateGroupTags(endpointGroupID portainer.EndpointGroupID, previous, current []portainer.TagID) error {
+	for _, tagID := range previous {
+		if slices.Contains(current, tagID) {
+			continue
+		}
+
+		tag, err := a.tx.Tag().Read(tagID)
+		if a.tx.IsErrObjectNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		delete(tag.EndpointGroups, endpointGroupID)
+
+		if err := a.tx.Tag().Update(tagID, tag); err != nil {
+			return err
+		}
+	}
+
+	for _, tagID := range current {
+		tag, err := a.tx.Tag().Read(tagID)
+		if err != nil {
+			return err
+		}
+
+		if tag.EndpointGroups == nil {
+			tag.EndpointGroups = map[portainer.EndpointGroupID]bool{}
+		}
+		tag.EndpointGroups[endpointGroupID] = true
+
+		if err := a.tx.Tag().Update(tagID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteEndpointGroup removes the environment group, its environments are
+// moved to the default group
+func (a *applier) deleteEndpointGroup(endpointGroup *portainer.EndpointGroup) error {
+	if err := a.tx.EndpointGroup().Delete(endpointGroup.ID); err != nil {
+		return err
+	}
+
+	endpoints, err := a.tx.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.GroupID != endpointGroup.ID {
+			continue
+		}
+
+		endpoint.GroupID = portainer.EndpointGroupID(1)
+		if err := a.tx.Endpoint().UpdateEndpoint(endpoint.ID, &endpoint); err != nil {
+			return err
+		}
+	}
+
+	return a.updateGroupTags(endpointGroup.ID, endpointGroup.TagIDs, nil)
+}
+
+func (a *applier) applyEdgeGroups(config *Config) error {
+	if config.EdgeGroups == nil {
+		return nil
+	}
+
+	edgeGroups, err := a.tx.EdgeGroup().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the edge groups: %w", err)
+	}
+
+	existing := make(map[string]portainer.EdgeGroup, len(edgeGroups))
+	for _, edgeGroup := range edgeGroups {
+		existing[edgeGroup.Name] = edgeGroup
+	}
+
+	endpoints, err := a.tx.Endpoint().Endpoints()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the environments: %w", err)
+	}
+
+	edgeEndpoints := make(map[string][]portainer.EndpointID)
+	for _, endpoint := range endpoints {
+		if endpointutils.IsEdgeEndpoint(&endpoint) {
+			edgeEndpoints[endpoint.Name] = append(edgeEndpoints[endpoint.Name], endpoint.ID)
+		}
+	}
+
+	desired := make(map[string]bool, len(*config.EdgeGroups))
+
+	for _, g := range *config.EdgeGroups {
+		desired[g.Name] = true
+
+		tagIDs, err := a.resolveTags(KindEdgeGroup, g.Name, g.Tags)
+		if err != nil {
+			return err
+		}
+
+		endpointIDs := make([]portainer.EndpointID, 0, len(g.Environments))
+		for _, name := range g.Environments {
+			ids := edgeEndpoints[name]
+			switch len(ids) {
+			case 0:
+				return invalidf("edge group %q references the unknown edge environment %q", g.Name, name)
+			case 1:
+				endpointIDs = append(endpointIDs, ids[0])
+			default:
+				return invalidf("edge group %q references the edge environment %q which is ambiguous", g.Name, name)
+			}
+		}
+
+		edgeGroup, ok := existing[g.Name]
+		if !ok {
+			a.record(KindEdgeGroup, g.Name, OperationCreate)
+
+			edgeGroup = portainer.EdgeGroup{Name: g.Name}
+		} else if edgeGroup.Dynamic == g.Dynamic &&
+			edgeGroup.PartialMatch == g.PartialMatch &&
+			sameElements(edgeGroup.TagIDs, tagIDs) &&
+			sameElements(edgeGroup.Endpoints, endpointIDs) &&
+			slices.Equal(edgeGroup.Rules, g.rules()) {
+			continue
+		} else {
+			a.record(KindEdgeGroup, g.Name, OperationUpdate)
+		}
+
+		a.updateEdgeRelations = true
+
+		edgeGroup.Dynamic = g.Dynamic
+		edgeGroup.PartialMatch = g.PartialMatch
+		edgeGroup.TagIDs = tagIDs
+		edgeGroup.Endpoints = endpointIDs
+		edgeGroup.Rules = g.rules()
+
+		if ok && edge.EdgeGroupHasTimeBasedRules(&edgeGroup) {
+			edgeStacks, err := a.tx.EdgeStack().EdgeStacks()
+			if err != nil {
+				return fmt.Errorf("unable to retrieve the edge stacks: %w", err)
+			}
+
+			if err := edge.ValidateEdgeStackEdgeGroups(edgeStacksEdgeGroups(edgeStacks), []portainer.EdgeGroup{edgeGroup}); err != nil {
+				return invalidf("edge group %q is targeted by edge stacks: %s", g.Name, err)
+			}
+		}
+
+		if err := a.write(func() error {
+			if ok {
+				return a.tx.EdgeGroup().Update(edgeGroup.ID, &edgeGroup)
+			}
+
+			return a.tx.EdgeGroup().Create(&edgeGroup)
+		}); err != nil {
+			return fmt.Errorf("unable to persist the %q edge group: %w", g.Name, err)
+		}
+	}
+
+	var inUse map[portainer.EdgeGroupID]bool
+
+	for _, edgeGroup := range edgeGroups {
+		if desired[edgeGroup.Name] {
+			continue
+		}
+
+		if inUse == nil {
+			if inUse, err = a.usedEdgeGroups(); err != nil {
+				return err
+			}
+		}
+
+		if inUse[edgeGroup.ID] {
+			return fmt.Errorf("unable to delete the %q edge group: %w", edgeGroup.Name, ErrEdgeGroupInUse)
+		}
+
+		a.record(KindEdgeGroup, edgeGroup.Name, OperationDelete)
+		a.updateEdgeRelations = true
+
+		if err := a.write(func() error { return a.tx.EdgeGroup().Delete(edgeGroup.ID) }); err != nil {
+			return fmt.Errorf("unable to delete the %q edge group: %w", edgeGroup.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// edgeStacksEdgeGroups returns the Edge groups targeted by the Edge stacks
+func edgeStacksEdgeGroups(edgeStacks []portainer.EdgeStack) []portainer.EdgeGroupID {
+	var edgeGroupIDs []portainer.EdgeGroupID
+	for _, edgeStack := range edgeStacks {
+		edgeGroupIDs = append(edgeGroupIDs, edgeStack.EdgeGroups...)
+	}
+
+	return edgeGroupIDs
+}
+
+// usedEdgeGroups returns the set of Edge groups targeted by Edge stacks or
+// Edge jobs
+func (a *applier) usedEdgeGroups() (map[portainer.EdgeGroupID]bool, error) {
+	inUse := make(map[portainer.EdgeGroupID]bool)
+
+	edgeStacks, err := a.tx.EdgeStack().EdgeStacks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the edge stacks: %w", err)
+	}
+
+	for _, edgeStack := range edgeStacks {
+		for _, edgeGroupID := range edgeStack.EdgeGroups {
+			inUse[edgeGroupID] = true
+		}
+	}
+
+	edgeJobs, err := a.tx.EdgeJob().ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the edge jobs: %w", err)
+	}
+
+	for _, edgeJob := range edgeJobs {
+		for _, edgeGroupID := range edgeJob.EdgeGroups {
+			inUse[edgeGroupID] = true
+		}
+	}
+
+	return inUse, nil
+}
+
+func (a *applier) applyRegistries(config *Config) error {
+	if config.Registries == nil {
+		return nil
+	}
+
+	registries, err := a.tx.Registry().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the registries: %w", err)
+	}
+
+	existing := make(map[string]portainer.Registry, len(registries))
+	for _, registry := range registries {
+		existing[registry.Name] = registry
+	}
+
+	desired := make(map[string]bool, len(*config.Registries))
+
+	for _, r := range *config.Registries {
+		desired[r.Name] = true
+
+		registry, ok := existing[r.Name]
+		if !ok {
+			a.record(KindRegistry, r.Name, OperationCreate)
+
+			if r.Authentication {
+				a.warn("the credentials of the %q registry must be set once it is created", r.Name)
+			}
+
+			registry = portainer.Registry{
+				Name:             r.Name,
+				RegistryAccesses: portainer.RegistryAccesses{},
+			}
+		}
+
+		updated := registry
+		updated.Type = registryTypes[r.Type]
+		updated.URL = r.URL
+		updated.BaseURL = r.BaseURL
+		updated.Authentication = r.Authentication
+		updated.Username = r.Username
+		updated.Ecr.Region = r.EcrRegion
+		updated.Quay = portainer.QuayRegistryData{
+			UseOrganisation:  r.QuayOrganisation != "",
+			OrganisationName: r.QuayOrganisation,
+		}
+
+		if ok {
+			if reflect.DeepEqual(registry, updated) {
+				continue
+			}
+
+			a.record(KindRegistry, r.Name, OperationUpdate)
+		}
+
+		if err := a.write(func() error {
+			if ok {
+				return a.tx.Registry().Update(updated.ID, &updated)
+			}
+
+			return a.tx.Registry().Create(&updated)
+		}); err != nil {
+			return fmt.Errorf("unable to persist the %q registry: %w", r.Name, err)
+		}
+	}
+
+	for _, registry := range registries {
+		if desired[registry.Name] {
+			continue
+		}
+
+		a.record(KindRegistry, registry.Name, OperationDelete)
+
+		if err := a.write(func() error { return a.tx.Registry().Delete(registry.ID) }); err != nil {
+			return fmt.Errorf("unable to delete the %q registry: %w", registry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *applier) applyCustomTemplates(config *Config) error {
+	if config.CustomTemplates == nil {
+		return nil
+	}
+
+	customTemplates, err := a.tx.CustomTemplate().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the custom templates: %w", err)
+	}
+
+	existing := make(map[string]portainer.CustomTemplate, len(customTemplates))
+	for _, customTemplate := range customTemplates {
+		existing[customTemplate.Title] = customTemplate
+	}
+
+	desired := make(map[string]bool, len(*config.CustomTemplates))
+
+	for _, t := range *config.CustomTemplates {
+		desired[t.Title] = true
+
+		customTemplate, ok := existing[t.Title]
+		if ok && customTemplate.GitConfig != nil {
+			return invalidf("custom template %q is backed by a git repository and cannot be managed from the configuration", t.Title)
+		}
+
+		updated := customTemplate
+		updated.Title = t.Title
+		updated.Description = t.Description
+		updated.Note = t.Note
+		updated.Logo = t.Logo
+		updated.Platform = templatePlatforms[t.Platform]
+		updated.Type = stackTypes[t.Type]
+		updated.EdgeTemplate = t.EdgeTemplate
+		updated.Variables = t.variables()
+		updated.EntryPoint = t.EntryPoint
+		if updated.EntryPoint == "" {
+			updated.EntryPoint = filesystem.ComposeFileDefaultName
+		}
+
+		if !ok {
+			a.record(KindCustomTemplate, t.Title, OperationCreate)
+
+			if err := a.write(func() error { return a.createCustomTemplate(&updated, t.FileContent) }); err != nil {
+				return fmt.Errorf("unable to create the %q custom template: %w", t.Title, err)
+			}
+
+			continue
+		}
+
+		content, err := a.fileService.GetFileContent(customTemplate.ProjectPath, customTemplate.EntryPoint)
+		if err != nil {
+			log.Warn().Err(err).Str("template", t.Title).Msg("unable to read the custom template file")
+		}
+
+		if reflect.DeepEqual(customTemplate, updated) && string(content) == t.FileContent {
+			continue
+		}
+
+		a.record(KindCustomTemplate, t.Title, OperationUpdate)
+
+		if err := a.write(func() error {
+			if _, err := a.fileService.StoreCustomTemplateFileFromBytes(strconv.Itoa(int(updated.ID)), updated.EntryPoint, []byte(t.FileContent)); err != nil {
+				return err
+			}
+
+			a.onRollback(func() { a.restoreCustomTemplateFile(&customTemplate, updated.EntryPoint, content) })
+
+			return a.tx.CustomTemplate().Update(updated.ID, &updated)
+		}); err != nil {
+			return fmt.Errorf("unable to update the %q custom template: %w", t.Title, err)
+		}
+	}
+
+	for _, customTemplate := range customTemplates {
+		if desired[customTemplate.Title] || customTemplate.GitConfig != nil {
+			continue
+		}
+
+		a.record(KindCustomTemplate, customTemplate.Title, OperationDelete)
+
+		if err := a.write(func() error { return a.deleteCustomTemplate(&customTemplate) }); err != nil {
+			return fmt.Errorf("unable to delete the %q custom template: %w", customTemplate.Title, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *applier) createCustomTemplate(customTemplate *portainer.CustomTemplate, content string) error {
+	customTemplate.ID = portainer.CustomTemplateID(a.tx.CustomTemplate().GetNextIdentifier())
+	customTemplate.CreatedByUserID = a.options.UserID
+
+	identifier := strconv.Itoa(int(customTemplate.ID))
+
+	projectPath, err := a.fileService.StoreCustomTemplateFileFromBytes(identifier, customTemplate.EntryPoint, []byte(content))
+	if err != nil {
+		return err
+	}
+	customTemplate.ProjectPath = projectPath
+
+	a.onRollback(func() {
+		if err := a.fileService.RemoveDirectory(projectPath); err != nil {
+			log.Warn().Err(err).Str("template", customTemplate.Title).Msg("unable to remove the custom template files")
+		}
+	})
+
+	if err := a.tx.CustomTemplate().Create(customTemplate); err != nil {
+		return err
+	}
+
+	resourceControl := authorization.NewAdministratorsOnlyResourceControl(identifier, portainer.CustomTemplateResourceControl)
+
+	return a.tx.ResourceControl().Create(resourceControl)
+}
+
+func (a *applier) deleteCustomTemplate(customTemplate *portainer.CustomTemplate) error {
+	if err := a.tx.CustomTemplate().Delete(customTemplate.ID); err != nil {
+		return err
+	}
+
+	resourceControl, err := a.tx.ResourceControl().ResourceControlByResourceIDAndType(strconv.Itoa(int(customTemplate.ID)), portainer.CustomTemplateResourceControl)
+	if err != nil && !a.tx.IsErrObjectNotFound(err) {
+		return err
+	}
+
+	if resourceControl != nil {
+		if err := a.tx.ResourceControl().Delete(resourceControl.ID); err != nil {
+			return err
+		}
+	}
+
+	// The files are only removed once the template is deleted for good
+	projectPath := customTemplate.ProjectPath
+	title := customTemplate.Title
+
+	a.tx.OnCommit(func() {
+		if err := a.fileService.RemoveDirectory(projectPath); err != nil {
+			log.Warn().Err(err).Str("template", title).Msg("unable to remove the custom template files")
+		}
+	})
+
+	return nil
+}
+
+// restoreCustomTemplateFile restores the content of the file of a custom template replaced by an update,
+// the file written under a new entry point is removed
+func (a *applier) restoreCustomTemplateFile(customTemplate *portainer.CustomTemplate, entryPoint string, content []byte) {
+	if entryPoint != customTemplate.EntryPoint {
+		if err := os.Remove(filesystem.JoinPaths(customTemplate.ProjectPath, entryPoint)); err != nil {
+			log.Warn().Err(err).Str("template", customTemplate.Title).Msg("unable to remove the custom template file")
+		}
+	}
+
+	if content == nil {
+		return
+	}
+
+	if _, err := a.fileService.StoreCustomTemplateFileFromBytes(strconv.Itoa(int(customTemplate.ID)), customTemplate.EntryPoint, content); err != nil {
+		log.Warn().Err(err).Str("template", customTemplate.Title).Msg("unable to restore the custom template file")
+	}
+}
+
+// refreshEdgeRelations recomputes the Edge stacks related to every Edge
+// environment after tags or groups changed
+func (a *applier) refreshEdgeRelations() error {
+	endpoints, err := a.tx.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	edgeGroups, err := a.tx.EdgeGroup().ReadAll()
+	if err != nil {
+		return err
+	}
+
+	edgeStacks, err := a.tx.EdgeStack().EdgeStacks()
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpointutils.IsEdgeEndpoint(&endpoint) {
+			continue
+		}
+
+		endpointGroup, err := a.tx.EndpointGroup().Read(endpoint.GroupID)
+		if err != nil {
+			return err
+		}
+
+		relation, err := a.tx.EndpointRelation().EndpointRelation(endpoint.ID)
+		if a.tx.IsErrObjectNotFound(err) {
+			relation = &portainer.EndpointRelation{EndpointID: endpoint.ID}
+		} else if err != nil {
+			return err
+		}
+
+		relatedEdgeStacks, err := edge.EndpointRelatedEdgeStacks(a.tx, &endpoint, endpointGroup, edgeGroups, edgeStacks)
+		if err != nil {
+			return err
+		}
+
+		relation.EdgeStacks = map[portainer.EdgeStackID]bool{}
+		for _, edgeStackID := range relatedEdgeStacks {
+			relation.EdgeStacks[edgeStackID] = true
+		}
+
+		if err := a.tx.EndpointRelation().UpdateEndpointRelation(endpoint.ID, relation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameElements reports whether both slices hold the same elements,
+// regardless of their order
+func sameElements[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[T]int, len(a))
+	for _, v := range a {
+		counts[v]++
+	}
+
+	for _, v := range b {
+		counts[v]--
+		if counts[v] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
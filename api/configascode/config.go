@@ -0,0 +1,436 @@
+package configascode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/edge"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is returned when a configuration is malformed or
+// references objects that do not exist
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// SchemaVersion is the version of the configuration document produced by
+// Export and accepted by Apply
+const SchemaVersion = 1
+
+type (
+	// Config is the declarative description of the Portainer objects managed
+	// from version control. Objects are referenced by name rather than by
+	// identifier so that the same document can be applied to any instance.
+	//
+	// A section that is omitted is left untouched by Apply. A section that is
+	// present, even empty, is managed: objects missing from it are deleted.
+	Config struct {
+		Version           int                 `yaml:"version"`
+		Settings          *Settings           `yaml:"settings,omitempty"`
+		Tags              *[]string           `yaml:"tags,omitempty"`
+		Teams             *[]string           `yaml:"teams,omitempty"`
+		Users             *[]User             `yaml:"users,omitempty"`
+		EnvironmentGroups *[]EnvironmentGroup `yaml:"environmentGroups,omitempty"`
+		EdgeGroups        *[]EdgeGroup        `yaml:"edgeGroups,omitempty"`
+		Registries        *[]Registry         `yaml:"registries,omitempty"`
+		CustomTemplates   *[]CustomTemplate   `yaml:"customTemplates,omitempty"`
+	}
+
+	// Settings is the subset of portainer.Settings managed as code, fields
+	// left empty are not modified
+	Settings struct {
+		LogoURL                   *string           `yaml:"logoURL,omitempty"`
+		BlackListedLabels         *[]portainer.Pair `yaml:"blackListedLabels,omitempty"`
+		SnapshotInterval          *string           `yaml:"snapshotInterval,omitempty"`
+		TemplatesURL              *string           `yaml:"templatesURL,omitempty"`
+		EdgeAgentCheckinInterval  *int              `yaml:"edgeAgentCheckinInterval,omitempty"`
+		EnableEdgeComputeFeatures *bool             `yaml:"enableEdgeComputeFeatures,omitempty"`
+		UserSessionTimeout        *string           `yaml:"userSessionTimeout,omitempty"`
+		KubeconfigExpiry          *string           `yaml:"kubeconfigExpiry,omitempty"`
+		EnableTelemetry           *bool             `yaml:"enableTelemetry,omitempty"`
+		HelmRepositoryURL         *string           `yaml:"helmRepositoryURL,omitempty"`
+		KubectlShellImage         *string           `yaml:"kubectlShellImage,omitempty"`
+		TrustOnFirstConnect       *bool             `yaml:"trustOnFirstConnect,omitempty"`
+		EnforceEdgeID             *bool             `yaml:"enforceEdgeID,omitempty"`
+		EdgePortainerURL          *string           `yaml:"edgePortainerURL,omitempty"`
+	}
+
+	// User is a Portainer user. Passwords are never managed as code, users
+	// created by Apply are meant to authenticate through LDAP or OAuth or to
+	// have their password set by an administrator.
+	User struct {
+		Username string           `yaml:"username"`
+		Role     string           `yaml:"role"`
+		Teams    []TeamMembership `yaml:"teams,omitempty"`
+	}
+
+	// TeamMembership is the membership of a user in a team
+	TeamMembership struct {
+		Team string `yaml:"team"`
+		Role string `yaml:"role"`
+	}
+
+	// EnvironmentGroup is an environment(endpoint) group
+	EnvironmentGroup struct {
+		Name        string   `yaml:"name"`
+		Description string   `yaml:"description,omitempty"`
+		Tags        []string `yaml:"tags,omitempty"`
+	}
+
+	// EdgeGroup is an Edge group, static groups list their environments by
+	// name while dynamic groups are defined by tags and rules
+	EdgeGroup struct {
+		Name         string          `yaml:"name"`
+		Dynamic      bool            `yaml:"dynamic,omitempty"`
+		PartialMatch bool            `yaml:"partialMatch,omitempty"`
+		Tags         []string        `yaml:"tags,omitempty"`
+		Rules        []EdgeGroupRule `yaml:"rules,omitempty"`
+		Environments []string        `yaml:"environments,omitempty"`
+	}
+
+	// EdgeGroupRule is a rule of a dynamic Edge group
+	EdgeGroupRule struct {
+		Field    string `yaml:"field"`
+		Operator string `yaml:"operator"`
+		Value    string `yaml:"value"`
+	}
+
+	// Registry is a registry definition without its credentials secret. The
+	// password of an existing registry is preserved by Apply.
+	Registry struct {
+		Name             string `yaml:"name"`
+		Type             string `yaml:"type"`
+		URL              string `yaml:"url"`
+		BaseURL          string `yaml:"baseURL,omitempty"`
+		Authentication   bool   `yaml:"authentication,omitempty"`
+		Username         string `yaml:"username,omitempty"`
+		EcrRegion        string `yaml:"ecrRegion,omitempty"`
+		QuayOrganisation string `yaml:"quayOrganisation,omitempty"`
+	}
+
+	// CustomTemplate is a custom template whose file content is stored in the
+	// configuration. Templates backed by a git repository are not managed.
+	CustomTemplate struct {
+		Title        string             `yaml:"title"`
+		Description  string             `yaml:"description"`
+		Note         string             `yaml:"note,omitempty"`
+		Logo         string             `yaml:"logo,omitempty"`
+		Platform     string             `yaml:"platform,omitempty"`
+		Type         string             `yaml:"type"`
+		EdgeTemplate bool               `yaml:"edgeTemplate,omitempty"`
+		Variables    []TemplateVariable `yaml:"variables,omitempty"`
+		EntryPoint   string             `yaml:"entryPoint,omitempty"`
+		FileContent  string             `yaml:"fileContent"`
+	}
+
+	// TemplateVariable is a variable of a custom template
+	TemplateVariable struct {
+		Name         string `yaml:"name"`
+		Label        string `yaml:"label,omitempty"`
+		DefaultValue string `yaml:"defaultValue,omitempty"`
+		Description  string `yaml:"description,omitempty"`
+		Type         string `yaml:"type,omitempty"`
+		Required     bool   `yaml:"required,omitempty"`
+		Pattern      string `yaml:"pattern,omitempty"`
+	}
+)
+
+var (
+	userRoles = map[string]portainer.UserRole{
+		"administrator": portainer.AdministratorRole,
+		"user":          portainer.StandardUserRole,
+	}
+
+	membershipRoles = map[string]portainer.MembershipRole{
+		"leader": portainer.TeamLeader,
+		"member": portainer.TeamMember,
+	}
+
+	registryTypes = map[string]portainer.RegistryType{
+		"quay":      portainer.QuayRegistry,
+		"azure":     portainer.AzureRegistry,
+		"custom":    portainer.CustomRegistry,
+		"gitlab":    portainer.GitlabRegistry,
+		"proget":    portainer.ProGetRegistry,
+		"dockerhub": portainer.DockerHubRegistry,
+		"ecr":       portainer.EcrRegistry,
+	}
+
+	templatePlatforms = map[string]portainer.CustomTemplatePlatform{
+		"linux":   portainer.CustomTemplatePlatformLinux,
+		"windows": portainer.CustomTemplatePlatformWindows,
+	}
+
+	stackTypes = map[string]portainer.StackType{
+		"swarm":      portainer.DockerSwarmStack,
+		"compose":    portainer.DockerComposeStack,
+		"kubernetes": portainer.KubernetesStack,
+	}
+)
+
+// nameOf returns the configuration name associated with value in m
+func nameOf[T comparable](m map[string]T, value T) string {
+	for name, v := range m {
+		if v == value {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+}
+
+// Parse decodes and validates a configuration document. Unknown fields are
+// rejected so that typos do not silently leave objects unmanaged.
+func Parse(data []byte) (*Config, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return nil, invalidf("unable to decode the configuration: %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Marshal encodes the configuration as a YAML document
+func Marshal(config *Config) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Validate checks the configuration for invalid values and duplicated names.
+// References between sections are checked by Apply, against the state the
+// configuration describes.
+func (config *Config) Validate() error {
+	if config.Version != SchemaVersion {
+		return invalidf("unsupported configuration version %d, expected %d", config.Version, SchemaVersion)
+	}
+
+	if config.Settings != nil {
+		if err := config.Settings.validate(); err != nil {
+			return err
+		}
+	}
+
+	if config.Tags != nil {
+		if err := unique("tag", *config.Tags, func(name string) string { return name }); err != nil {
+			return err
+		}
+	}
+
+	if config.Teams != nil {
+		if err := unique("team", *config.Teams, func(name string) string { return strings.ToLower(name) }); err != nil {
+			return err
+		}
+	}
+
+	if config.Users != nil {
+		if err := unique("user", *config.Users, func(u User) string { return strings.ToLower(u.Username) }); err != nil {
+			return err
+		}
+
+		for _, user := range *config.Users {
+			if _, ok := userRoles[user.Role]; !ok {
+				return invalidf("invalid role %q for user %q", user.Role, user.Username)
+			}
+
+			for _, membership := range user.Teams {
+				if _, ok := membershipRoles[membership.Role]; !ok {
+					return invalidf("invalid role %q for user %q in team %q", membership.Role, user.Username, membership.Team)
+				}
+			}
+		}
+	}
+
+	if config.EnvironmentGroups != nil {
+		if err := unique("environment group", *config.EnvironmentGroups, func(g EnvironmentGroup) string { return g.Name }); err != nil {
+			return err
+		}
+	}
+
+	if config.EdgeGroups != nil {
+		if err := unique("edge group", *config.EdgeGroups, func(g EdgeGroup) string { return g.Name }); err != nil {
+			return err
+		}
+
+		for _, group := range *config.EdgeGroups {
+			if err := group.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if config.Registries != nil {
+		if err := unique("registry", *config.Registries, func(r Registry) string { return r.Name }); err != nil {
+			return err
+		}
+
+		for _, registry := range *config.Registries {
+			if _, ok := registryTypes[registry.Type]; !ok {
+				return invalidf("invalid type %q for registry %q", registry.Type, registry.Name)
+			}
+		}
+	}
+
+	if config.CustomTemplates != nil {
+		if err := unique("custom template", *config.CustomTemplates, func(t CustomTemplate) string { return t.Title }); err != nil {
+			return err
+		}
+
+		for _, template := range *config.CustomTemplates {
+			if err := template.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func unique[T any](kind string, items []T, key func(T) string) error {
+	seen := make(map[string]bool, len(items))
+
+	for _, item := range items {
+		k := key(item)
+		if k == "" {
+			return invalidf("a %s is missing its name", kind)
+		}
+
+		if seen[k] {
+			return invalidf("duplicated %s %q", kind, k)
+		}
+
+		seen[k] = true
+	}
+
+	return nil
+}
+
+func (settings *Settings) validate() error {
+	durations := map[string]*string{
+		"snapshotInterval":   settings.SnapshotInterval,
+		"userSessionTimeout": settings.UserSessionTimeout,
+		"kubeconfigExpiry":   settings.KubeconfigExpiry,
+	}
+
+	for name, value := range durations {
+		if value == nil {
+			continue
+		}
+
+		if _, err := time.ParseDuration(*value); err != nil {
+			return invalidf("invalid %s setting: %v", name, err)
+		}
+	}
+
+	if settings.EdgeAgentCheckinInterval != nil && *settings.EdgeAgentCheckinInterval < 0 {
+		return invalidf("invalid edgeAgentCheckinInterval setting: must be positive")
+	}
+
+	return nil
+}
+
+func (group *EdgeGroup) validate() error {
+	if group.Dynamic {
+		if len(group.Environments) > 0 {
+			return invalidf("dynamic edge group %q cannot list environments", group.Name)
+		}
+
+		if len(group.Tags) == 0 && len(group.Rules) == 0 {
+			return invalidf("dynamic edge group %q requires tags or rules", group.Name)
+		}
+
+		if err := edge.ValidateEdgeGroupRules(group.rules()); err != nil {
+			return invalidf("invalid rules for edge group %q: %v", group.Name, err)
+		}
+
+		return nil
+	}
+
+	if len(group.Tags) > 0 || len(group.Rules) > 0 {
+		return invalidf("static edge group %q cannot have tags or rules", group.Name)
+	}
+
+	return nil
+}
+
+func (group *EdgeGroup) rules() []portainer.EdgeGroupRule {
+	rules := make([]portainer.EdgeGroupRule, 0, len(group.Rules))
+	for _, rule := range group.Rules {
+		rules = append(rules, portainer.EdgeGroupRule{
+			Field:    portainer.EdgeGroupRuleField(rule.Field),
+			Operator: portainer.EdgeGroupRuleOperator(rule.Operator),
+			Value:    rule.Value,
+		})
+	}
+
+	return rules
+}
+
+func (template *CustomTemplate) validate() error {
+	if template.Description == "" {
+		return invalidf("custom template %q is missing its description", template.Title)
+	}
+
+	if _, ok := stackTypes[template.Type]; !ok {
+		return invalidf("invalid type %q for custom template %q", template.Type, template.Title)
+	}
+
+	if template.Platform != "" {
+		if _, ok := templatePlatforms[template.Platform]; !ok {
+			return invalidf("invalid platform %q for custom template %q", template.Platform, template.Title)
+		}
+	}
+
+	if template.FileContent == "" {
+		return invalidf("custom template %q is missing its file content", template.Title)
+	}
+
+	return nil
+}
+
+func (template *CustomTemplate) variables() []portainer.CustomTemplateVariableDefinition {
+	if len(template.Variables) == 0 {
+		return nil
+	}
+
+	variables := make([]portainer.CustomTemplateVariableDefinition, 0, len(template.Variables))
+	for _, v := range template.Variables {
+		variables = append(variables, portainer.CustomTemplateVariableDefinition{
+			Name:         v.Name,
+			Label:        v.Label,
+			DefaultValue: v.DefaultValue,
+			Description:  v.Description,
+			Type:         portainer.CustomTemplateVariableType(v.Type),
+			Required:     v.Required,
+			Pattern:      v.Pattern,
+		})
+	}
+
+	return variables
+}
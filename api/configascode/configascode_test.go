@@ -0,0 +1,304 @@
+package configascode
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/filesystem"
+	gittypes "github.com/portainer/portainer/api/git/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+version: 1
+settings:
+  snapshotInterval: 10m
+tags:
+  - prod
+teams:
+  - devs
+users:
+  - username: admin
+    role: administrator
+  - username: alice
+    role: user
+    teams:
+      - team: devs
+        role: leader
+environmentGroups:
+  - name: Unassigned
+  - name: Production
+    description: production environments
+    tags:
+      - prod
+edgeGroups:
+  - name: prod-devices
+    dynamic: true
+    tags:
+      - prod
+registries:
+  - name: internal
+    type: custom
+    url: registry.example.com
+    authentication: true
+    username: deployer
+customTemplates:
+  - title: nginx
+    description: web server
+    type: compose
+    platform: linux
+    fileContent: |
+      services:
+        web:
+          image: nginx
+`
+
+func newTestService(t *testing.T) (*Service, *datastore.Store) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	fileService, err := filesystem.NewService(t.TempDir(), "")
+	require.NoError(t, err)
+
+	require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, store.User().Create(&portainer.User{Username: "bob", Role: portainer.StandardUserRole}))
+	require.NoError(t, store.Team().Create(&portainer.Team{Name: "qa"}))
+	require.NoError(t, store.TeamMembership().Create(&portainer.TeamMembership{UserID: 2, TeamID: 1, Role: portainer.TeamMember}))
+	require.NoError(t, store.Tag().Create(&portainer.Tag{Name: "legacy"}))
+	require.NoError(t, store.Registry().Create(&portainer.Registry{Name: "internal", Type: portainer.CustomRegistry, URL: "old.example.com", Password: "secret"}))
+
+	return NewService(store, fileService, apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())), store
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(testConfig))
+	require.NoError(t, err)
+	require.Len(t, *config.Users, 2)
+	require.Nil(t, config.Settings.LogoURL)
+
+	invalid := map[string]string{
+		"version":        "version: 2",
+		"unknown field":  "version: 1\nteamz: []",
+		"duplicated tag": "version: 1\ntags: [a, a]",
+		"user role":      "version: 1\nusers: [{username: a, role: root}]",
+		"registry type":  "version: 1\nregistries: [{name: a, type: nope, url: b}]",
+		"static rules":   "version: 1\nedgeGroups: [{name: a, tags: [t]}]",
+		"duration":       "version: 1\nsettings: {snapshotInterval: soon}",
+	}
+
+	for name, document := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(document))
+			require.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	service, store := newTestService(t)
+
+	config, err := Parse([]byte(testConfig))
+	require.NoError(t, err)
+
+	plan, err := service.Apply(config, ApplyOptions{DryRun: true, UserID: 1})
+	require.NoError(t, err)
+	require.True(t, plan.DryRun)
+	require.Contains(t, plan.Changes, Change{Kind: KindTag, Name: "prod", Operation: OperationCreate})
+	require.Contains(t, plan.Changes, Change{Kind: KindTag, Name: "legacy", Operation: OperationDelete})
+	require.Contains(t, plan.Changes, Change{Kind: KindTeam, Name: "qa", Operation: OperationDelete})
+	require.Contains(t, plan.Changes, Change{Kind: KindUser, Name: "bob", Operation: OperationDelete})
+	require.Contains(t, plan.Changes, Change{Kind: KindRegistry, Name: "internal", Operation: OperationUpdate})
+
+	tags, err := store.Tag().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, tags, 1, "a dry run must not modify the instance")
+
+	applied, err := service.Apply(config, ApplyOptions{UserID: 1})
+	require.NoError(t, err)
+	require.Equal(t, plan.Changes, applied.Changes)
+
+	tags, err = store.Tag().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	require.Equal(t, "prod", tags[0].Name)
+
+	_, err = store.User().UserByUsername("bob")
+	require.True(t, store.IsErrObjectNotFound(err))
+
+	alice, err := store.User().UserByUsername("alice")
+	require.NoError(t, err)
+
+	memberships, err := store.TeamMembership().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, memberships, 1, "the memberships of deleted users and teams must be removed")
+	require.Equal(t, alice.ID, memberships[0].UserID)
+	require.Equal(t, portainer.TeamLeader, memberships[0].Role)
+
+	registries, err := store.Registry().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, registries, 1)
+	require.Equal(t, "registry.example.com", registries[0].URL)
+	require.Equal(t, "secret", registries[0].Password, "the registry password must be preserved")
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	require.Equal(t, "10m", settings.SnapshotInterval)
+
+	// applying the same configuration again is a no-op
+	plan, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.NoError(t, err)
+	require.Empty(t, plan.Changes)
+
+	// as is applying the exported configuration
+	exported, err := service.Export()
+	require.NoError(t, err)
+
+	data, err := Marshal(exported)
+	require.NoError(t, err)
+
+	config, err = Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, "services:\n  web:\n    image: nginx\n", (*config.CustomTemplates)[0].FileContent)
+
+	plan, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.NoError(t, err)
+	require.Empty(t, plan.Changes)
+}
+
+func TestApplyProtectedObjects(t *testing.T) {
+	service, store := newTestService(t)
+
+	config, err := Parse([]byte("version: 1\nusers: []\nenvironmentGroups: []"))
+	require.NoError(t, err)
+
+	plan, err := service.Apply(config, ApplyOptions{UserID: 2})
+	require.NoError(t, err)
+	require.Empty(t, plan.Changes)
+	require.Len(t, plan.Warnings, 3)
+
+	users, err := store.User().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+
+	_, err = store.EndpointGroup().Read(1)
+	require.NoError(t, err)
+}
+
+func TestApplyInvalidatesAPIKeys(t *testing.T) {
+	service, store := newTestService(t)
+
+	bob, err := store.User().UserByUsername("bob")
+	require.NoError(t, err)
+
+	rawKey, _, err := service.apiKeyService.GenerateApiKey(*bob, "ci")
+	require.NoError(t, err)
+
+	digest := service.apiKeyService.HashRaw(rawKey)
+	_, _, err = service.apiKeyService.GetDigestUserAndKey(digest)
+	require.NoError(t, err)
+
+	config, err := Parse([]byte("version: 1\nusers: [{username: admin, role: administrator}]"))
+	require.NoError(t, err)
+
+	_, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.NoError(t, err)
+
+	_, _, err = service.apiKeyService.GetDigestUserAndKey(digest)
+	require.Error(t, err, "the API keys of the deleted users must no longer be cached")
+}
+
+func TestApplyUnknownReference(t *testing.T) {
+	service, _ := newTestService(t)
+
+	config, err := Parse([]byte("version: 1\ntags: []\nenvironmentGroups: [{name: Unassigned, tags: [legacy]}]"))
+	require.NoError(t, err)
+
+	_, err = service.Apply(config, ApplyOptions{DryRun: true, UserID: 1})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestApplyEdgeGroupInUse(t *testing.T) {
+	service, store := newTestService(t)
+
+	require.NoError(t, store.EdgeGroup().Create(&portainer.EdgeGroup{Name: "devices"}))
+	require.NoError(t, store.EdgeStack().Create(1, &portainer.EdgeStack{ID: 1, Name: "stack", EdgeGroups: []portainer.EdgeGroupID{1}}))
+
+	config, err := Parse([]byte("version: 1\nedgeGroups: []"))
+	require.NoError(t, err)
+
+	_, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.ErrorIs(t, err, ErrEdgeGroupInUse)
+
+	edgeGroups, err := store.EdgeGroup().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, edgeGroups, 1)
+}
+
+func TestApplyCustomTemplateFiles(t *testing.T) {
+	service, store := newTestService(t)
+
+	storeTemplate := func(customTemplate *portainer.CustomTemplate, content string) {
+		customTemplate.ID = portainer.CustomTemplateID(store.CustomTemplate().GetNextIdentifier())
+		customTemplate.EntryPoint = filesystem.ComposeFileDefaultName
+
+		projectPath, err := service.fileService.StoreCustomTemplateFileFromBytes(strconv.Itoa(int(customTemplate.ID)), customTemplate.EntryPoint, []byte(content))
+		require.NoError(t, err)
+		customTemplate.ProjectPath = projectPath
+
+		require.NoError(t, store.CustomTemplate().Create(customTemplate))
+	}
+
+	web := &portainer.CustomTemplate{Title: "web", Type: portainer.DockerComposeStack}
+	storeTemplate(web, "services:\n  web:\n    image: nginx\n")
+
+	old := &portainer.CustomTemplate{Title: "old", Type: portainer.DockerComposeStack}
+	storeTemplate(old, "services:\n  old:\n    image: redis\n")
+
+	storeTemplate(&portainer.CustomTemplate{Title: "git", Type: portainer.DockerComposeStack, GitConfig: &gittypes.RepoConfig{URL: "https://example.com/repo.git"}}, "")
+
+	// The git template cannot be managed, the transaction is rolled back after the other templates were written
+	config, err := Parse([]byte(`
+version: 1
+customTemplates:
+  - title: web
+    description: web
+    type: compose
+    platform: linux
+    fileContent: "services: {}"
+  - title: created
+    description: created
+    type: compose
+    platform: linux
+    fileContent: "services: {}"
+  - title: git
+    description: git
+    type: compose
+    platform: linux
+    fileContent: "services: {}"
+`))
+	require.NoError(t, err)
+
+	_, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+
+	content, err := service.fileService.GetFileContent(web.ProjectPath, web.EntryPoint)
+	require.NoError(t, err)
+	require.Equal(t, "services:\n  web:\n    image: nginx\n", string(content), "the updated file must be restored")
+
+	_, err = os.Stat(service.fileService.GetCustomTemplateProjectPath("4"))
+	require.True(t, os.IsNotExist(err), "the files of the created template must be removed")
+
+	// The files of the deleted templates are removed once the changes are committed
+	config, err = Parse([]byte("version: 1\ncustomTemplates: []"))
+	require.NoError(t, err)
+
+	_, err = service.Apply(config, ApplyOptions{UserID: 1})
+	require.NoError(t, err)
+
+	_, err = os.Stat(old.ProjectPath)
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,330 @@
+package configascode
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+)
+
+// Export returns the configuration describing the current state of the
+// instance. Registry credentials and git backed custom templates are left out.
+func (service *Service) Export() (*Config, error) {
+	config := &Config{Version: SchemaVersion}
+
+	err := service.dataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
+		steps := []func(dataservices.DataStoreTx, *Config) error{
+			exportSettings,
+			exportTags,
+			exportTeams,
+			exportUsers,
+			exportEnvironmentGroups,
+			exportEdgeGroups,
+			exportRegistries,
+			service.exportCustomTemplates,
+		}
+
+		for _, step := range steps {
+			if err := step(tx, config); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return config, err
+}
+
+func exportSettings(tx dataservices.DataStoreTx, config *Config) error {
+	settings, err := tx.Settings().Settings()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the settings: %w", err)
+	}
+
+	config.Settings = &Settings{
+		LogoURL:                   &settings.LogoURL,
+		BlackListedLabels:         &settings.BlackListedLabels,
+		SnapshotInterval:          &settings.SnapshotInterval,
+		TemplatesURL:              &settings.TemplatesURL,
+		EdgeAgentCheckinInterval:  &settings.EdgeAgentCheckinInterval,
+		EnableEdgeComputeFeatures: &settings.EnableEdgeComputeFeatures,
+		UserSessionTimeout:        &settings.UserSessionTimeout,
+		KubeconfigExpiry:          &settings.KubeconfigExpiry,
+		EnableTelemetry:           &settings.EnableTelemetry,
+		HelmRepositoryURL:         &settings.HelmRepositoryURL,
+		KubectlShellImage:         &settings.KubectlShellImage,
+		TrustOnFirstConnect:       &settings.TrustOnFirstConnect,
+		EnforceEdgeID:             &settings.EnforceEdgeID,
+		EdgePortainerURL:          &settings.EdgePortainerURL,
+	}
+
+	return nil
+}
+
+func exportTags(tx dataservices.DataStoreTx, config *Config) error {
+	tags, err := tx.Tag().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the tags: %w", err)
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+
+	config.Tags = &names
+
+	return nil
+}
+
+func exportTeams(tx dataservices.DataStoreTx, config *Config) error {
+	teams, err := tx.Team().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the teams: %w", err)
+	}
+
+	names := make([]string, 0, len(teams))
+	for _, team := range teams {
+		names = append(names, team.Name)
+	}
+
+	config.Teams = &names
+
+	return nil
+}
+
+func exportUsers(tx dataservices.DataStoreTx, config *Config) error {
+	users, err := tx.User().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the users: %w", err)
+	}
+
+	teams, err := tx.Team().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the teams: %w", err)
+	}
+
+	teamNames := make(map[portainer.TeamID]string, len(teams))
+	for _, team := range teams {
+		teamNames[team.ID] = team.Name
+	}
+
+	exported := make([]User, 0, len(users))
+	for _, user := range users {
+		memberships, err := tx.TeamMembership().TeamMembershipsByUserID(user.ID)
+		if err != nil {
+			return fmt.Errorf("unable to retrieve the memberships of the %q user: %w", user.Username, err)
+		}
+
+		u := User{
+			Username: user.Username,
+			Role:     nameOf(userRoles, user.Role),
+		}
+
+		for _, membership := range memberships {
+			teamName, ok := teamNames[membership.TeamID]
+			if !ok {
+				continue
+			}
+
+			u.Teams = append(u.Teams, TeamMembership{
+				Team: teamName,
+				Role: nameOf(membershipRoles, membership.Role),
+			})
+		}
+
+		exported = append(exported, u)
+	}
+
+	config.Users = &exported
+
+	return nil
+}
+
+func exportEnvironmentGroups(tx dataservices.DataStoreTx, config *Config) error {
+	endpointGroups, err := tx.EndpointGroup().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the environment groups: %w", err)
+	}
+
+	tagNames, err := readTagNames(tx)
+	if err != nil {
+		return err
+	}
+
+	exported := make([]EnvironmentGroup, 0, len(endpointGroups))
+	for _, endpointGroup := range endpointGroups {
+		exported = append(exported, EnvironmentGroup{
+			Name:        endpointGroup.Name,
+			Description: endpointGroup.Description,
+			Tags:        tagsToNames(tagNames, endpointGroup.TagIDs),
+		})
+	}
+
+	config.EnvironmentGroups = &exported
+
+	return nil
+}
+
+func exportEdgeGroups(tx dataservices.DataStoreTx, config *Config) error {
+	edgeGroups, err := tx.EdgeGroup().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the edge groups: %w", err)
+	}
+
+	tagNames, err := readTagNames(tx)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := tx.Endpoint().Endpoints()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the environments: %w", err)
+	}
+
+	endpointNames := make(map[portainer.EndpointID]string, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpointutils.IsEdgeEndpoint(&endpoint) {
+			endpointNames[endpoint.ID] = endpoint.Name
+		}
+	}
+
+	exported := make([]EdgeGroup, 0, len(edgeGroups))
+	for _, edgeGroup := range edgeGroups {
+		g := EdgeGroup{
+			Name:         edgeGroup.Name,
+			Dynamic:      edgeGroup.Dynamic,
+			PartialMatch: edgeGroup.PartialMatch,
+		}
+
+		if edgeGroup.Dynamic {
+			g.Tags = tagsToNames(tagNames, edgeGroup.TagIDs)
+
+			for _, rule := range edgeGroup.Rules {
+				g.Rules = append(g.Rules, EdgeGroupRule{
+					Field:    string(rule.Field),
+					Operator: string(rule.Operator),
+					Value:    rule.Value,
+				})
+			}
+		} else {
+			for _, endpointID := range edgeGroup.Endpoints {
+				if name, ok := endpointNames[endpointID]; ok {
+					g.Environments = append(g.Environments, name)
+				}
+			}
+		}
+
+		exported = append(exported, g)
+	}
+
+	config.EdgeGroups = &exported
+
+	return nil
+}
+
+func exportRegistries(tx dataservices.DataStoreTx, config *Config) error {
+	registries, err := tx.Registry().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the registries: %w", err)
+	}
+
+	exported := make([]Registry, 0, len(registries))
+	for _, registry := range registries {
+		r := Registry{
+			Name:           registry.Name,
+			Type:           nameOf(registryTypes, registry.Type),
+			URL:            registry.URL,
+			BaseURL:        registry.BaseURL,
+			Authentication: registry.Authentication,
+			Username:       registry.Username,
+			EcrRegion:      registry.Ecr.Region,
+		}
+
+		if registry.Quay.UseOrganisation {
+			r.QuayOrganisation = registry.Quay.OrganisationName
+		}
+
+		exported = append(exported, r)
+	}
+
+	config.Registries = &exported
+
+	return nil
+}
+
+func (service *Service) exportCustomTemplates(tx dataservices.DataStoreTx, config *Config) error {
+	customTemplates, err := tx.CustomTemplate().ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the custom templates: %w", err)
+	}
+
+	exported := make([]CustomTemplate, 0, len(customTemplates))
+	for _, customTemplate := range customTemplates {
+		if customTemplate.GitConfig != nil {
+			continue
+		}
+
+		content, err := service.fileService.GetFileContent(customTemplate.ProjectPath, customTemplate.EntryPoint)
+		if err != nil {
+			return fmt.Errorf("unable to read the file of the %q custom template: %w", customTemplate.Title, err)
+		}
+
+		t := CustomTemplate{
+			Title:        customTemplate.Title,
+			Description:  customTemplate.Description,
+			Note:         customTemplate.Note,
+			Logo:         customTemplate.Logo,
+			Platform:     nameOf(templatePlatforms, customTemplate.Platform),
+			Type:         nameOf(stackTypes, customTemplate.Type),
+			EdgeTemplate: customTemplate.EdgeTemplate,
+			EntryPoint:   customTemplate.EntryPoint,
+			FileContent:  string(content),
+		}
+
+		for _, v := range customTemplate.Variables {
+			t.Variables = append(t.Variables, TemplateVariable{
+				Name:         v.Name,
+				Label:        v.Label,
+				DefaultValue: v.DefaultValue,
+				Description:  v.Description,
+				Type:         string(v.Type),
+				Required:     v.Required,
+				Pattern:      v.Pattern,
+			})
+		}
+
+		exported = append(exported, t)
+	}
+
+	config.CustomTemplates = &exported
+
+	return nil
+}
+
+func readTagNames(tx dataservices.DataStoreTx) (map[portainer.TagID]string, error) {
+	tags, err := tx.Tag().ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the tags: %w", err)
+	}
+
+	names := make(map[portainer.TagID]string, len(tags))
+	for _, tag := range tags {
+		names[tag.ID] = tag.Name
+	}
+
+	return names, nil
+}
+
+func tagsToNames(names map[portainer.TagID]string, tagIDs []portainer.TagID) []string {
+	var result []string
+	for _, tagID := range tagIDs {
+		if name, ok := names[tagID]; ok {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
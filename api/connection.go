@@ -21,6 +21,9 @@ type Transaction interface {
 	CreateObjectWithStringId(bucketName string, id []byte, obj any) error
 	DeleteAllObjects(bucketName string, obj any, matching func(o any) (id int, ok bool)) error
 	GetNextIdentifier(bucketName string) int
+	// OnCommit registers fn to be called once the changes are committed. It is never called if
+	// the transaction is rolled back
+	OnCommit(fn func())
 }
 
 type Connection interface {
@@ -45,6 +48,8 @@ type Connection interface {
 	IsEncryptedStore() bool
 	NeedsEncryptionMigration() (bool, error)
 	SetEncrypted(encrypted bool)
+	RotateEncryptionKey(newKey []byte) error
+	Compact() error
 
 	BackupMetadata() (map[string]any, error)
 	RestoreMetadata(s map[string]any) error
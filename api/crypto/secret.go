@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecretsPath is the directory where the Docker and Kubernetes secrets are mounted
+const SecretsPath = "/run/secrets"
+
+// RotatedSecretKeyFileName is the file of the data directory holding the name of the
+// secret the database was last re-encrypted with
+const RotatedSecretKeyFileName = "secret_key_name"
+
+// LoadSecretKey reads the secret mounted as /run/secrets/<name> and returns a
+// 32 byte hash of its content, suitable for AES-256
+func LoadSecretKey(name string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(SecretsPath, filepath.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(content)
+
+	return hash[:], nil
+}
+
+// ErrSecretKeyNameMismatch is returned when the secret key name does not match the
+// secret the database was last re-encrypted with
+var ErrSecretKeyNameMismatch = errors.New("the database was re-encrypted with another secret")
+
+// SaveRotatedSecretKeyName records in the data directory the name of the secret the
+// database was re-encrypted with, so that a start with --secret-key-name still pointing
+// to the previous secret is refused
+func SaveRotatedSecretKeyName(dataPath, name string) error {
+	return os.WriteFile(filepath.Join(dataPath, RotatedSecretKeyFileName), []byte(filepath.Base(name)), 0600)
+}
+
+// LoadRotatedSecretKeyName returns the name of the secret recorded by SaveRotatedSecretKeyName,
+// or an empty string when the encryption key was never rotated
+func LoadRotatedSecretKeyName(dataPath string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dataPath, RotatedSecretKeyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// CheckSecretKeyName returns ErrSecretKeyNameMismatch when the database was re-encrypted
+// with a secret other than name
+func CheckSecretKeyName(dataPath, name string) error {
+	rotated, err := LoadRotatedSecretKeyName(dataPath)
+	if err != nil {
+		return err
+	}
+
+	if rotated != "" && rotated != filepath.Base(name) {
+		return fmt.Errorf("%w, set --secret-key-name to %q instead of %q", ErrSecretKeyNameMismatch, rotated, name)
+	}
+
+	return nil
+}
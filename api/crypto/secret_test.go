@@ -0,0 +1,32 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatedSecretKeyName(t *testing.T) {
+	dataPath := t.TempDir()
+
+	name, err := LoadRotatedSecretKeyName(dataPath)
+	require.NoError(t, err)
+	require.Empty(t, name, "no name is recorded before a rotation")
+
+	require.NoError(t, SaveRotatedSecretKeyName(dataPath, "portainer-2024"))
+
+	name, err = LoadRotatedSecretKeyName(dataPath)
+	require.NoError(t, err)
+	require.Equal(t, "portainer-2024", name)
+}
+
+func TestCheckSecretKeyName(t *testing.T) {
+	dataPath := t.TempDir()
+
+	require.NoError(t, CheckSecretKeyName(dataPath, "portainer"), "any name is accepted before a rotation")
+
+	require.NoError(t, SaveRotatedSecretKeyName(dataPath, "portainer-2024"))
+
+	require.NoError(t, CheckSecretKeyName(dataPath, "portainer-2024"))
+	require.ErrorIs(t, CheckSecretKeyName(dataPath, "portainer"), ErrSecretKeyNameMismatch)
+}
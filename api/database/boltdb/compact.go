@@ -0,0 +1,64 @@
+package boltdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// compactTxMaxSize is the size of the transactions used to copy the database
+const compactTxMaxSize = 64 * 1024 * 1024
+
+// Compact rewrites the database into a fresh file, releasing the pages freed
+// by deleted objects. The database is closed while it is compacted.
+func (connection *DbConnection) Compact() (err error) {
+	databasePath := connection.GetDatabaseFilePath()
+	compactedPath := databasePath + ".compact"
+
+	log.Info().Str("filename", databasePath).Msg("compacting PortainerDB")
+
+	if err := connection.Close(); err != nil {
+		return err
+	}
+
+	// Always reopen the database, either the compacted one or the original
+	// one if the compaction failed
+	defer func() {
+		if openErr := connection.Open(); openErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to reopen the database after compacting it: %w", openErr))
+		}
+	}()
+
+	if err := compact(databasePath, compactedPath); err != nil {
+		os.Remove(compactedPath)
+
+		return fmt.Errorf("failed to compact the database: %w", err)
+	}
+
+	return os.Rename(compactedPath, databasePath)
+}
+
+func compact(srcPath, dstPath string) error {
+	src, err := bolt.Open(srcPath, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := bolt.Open(dstPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	if err := bolt.Compact(dst, src, compactTxMaxSize); err != nil {
+		dst.Close()
+
+		return err
+	}
+
+	return dst.Close()
+}
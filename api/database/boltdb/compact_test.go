@@ -0,0 +1,35 @@
+package boltdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Compact(t *testing.T) {
+	conn := &DbConnection{Path: t.TempDir()}
+	require.NoError(t, conn.Open())
+	t.Cleanup(func() { conn.Close() })
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	for i := range 200 {
+		require.NoError(t, conn.CreateObjectWithId("objects", i, rotateTestObject{Name: string(make([]byte, 4096))}))
+	}
+	for i := range 200 {
+		require.NoError(t, conn.DeleteObject("objects", conn.ConvertToKey(i)))
+	}
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, rotateTestObject{Name: "kept"}))
+
+	before, err := conn.GetDatabaseFileSize()
+	require.NoError(t, err)
+
+	require.NoError(t, conn.Compact())
+
+	after, err := conn.GetDatabaseFileSize()
+	require.NoError(t, err)
+	require.Less(t, after, before)
+
+	var obj rotateTestObject
+	require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+	require.Equal(t, "kept", obj.Name)
+}
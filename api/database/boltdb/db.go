@@ -300,6 +300,11 @@ func (connection *DbConnection) DeleteAllObjects(bucketName string, obj any, mat
 	})
 }
 
+// OnCommit calls fn right away, the operations made outside of a transaction are committed one by one
+func (connection *DbConnection) OnCommit(fn func()) {
+	fn()
+}
+
 // GetNextIdentifier is a generic function that returns the specified bucket identifier incremented by 1.
 func (connection *DbConnection) GetNextIdentifier(bucketName string) int {
 	var identifier int
@@ -0,0 +1,86 @@
+package boltdb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/portainer/portainer/api/database/codec"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type rotatedEntry struct {
+	key   []byte
+	value []byte
+}
+
+// RotateEncryptionKey re-encrypts every value of the database with newKey.
+// All the values of every bucket are decrypted with the current key and
+// re-encrypted with the new one inside a single transaction, each of them is
+// verified to decrypt back to its original content before the transaction is
+// committed. Any failure rolls the whole transaction back and leaves the
+// database encrypted with the current key.
+func (connection *DbConnection) RotateEncryptionKey(newKey []byte) error {
+	currentKey := connection.getEncryptionKey()
+	if currentKey == nil {
+		return dserrors.ErrNotEncrypted
+	}
+
+	if err := codec.ValidateKey(newKey); err != nil {
+		return err
+	}
+
+	if bytes.Equal(currentKey, newKey) {
+		return dserrors.ErrSameEncryptionKey
+	}
+
+	err := connection.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			entries, err := reencryptBucket(bucket, currentKey, newKey)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt bucket %s: %w", name, err)
+			}
+
+			for _, entry := range entries {
+				if err := bucket.Put(entry.key, entry.value); err != nil {
+					return fmt.Errorf("failed to write bucket %s: %w", name, err)
+				}
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	connection.EncryptionKey = newKey
+
+	return nil
+}
+
+// reencryptBucket returns the values of the bucket encrypted with newKey. The
+// bucket is left untouched as it cannot be written while being iterated.
+func reencryptBucket(bucket *bolt.Bucket, currentKey, newKey []byte) ([]rotatedEntry, error) {
+	var entries []rotatedEntry
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		// Nested buckets and the unencrypted "false" special case of decrypt
+		// are kept as is
+		if v == nil || string(v) == "false" {
+			return nil
+		}
+
+		encrypted, err := codec.Reencrypt(v, currentKey, newKey)
+		if err != nil {
+			return fmt.Errorf("key=%s: %w", keyToString(k), err)
+		}
+
+		entries = append(entries, rotatedEntry{key: bytes.Clone(k), value: encrypted})
+
+		return nil
+	})
+
+	return entries, err
+}
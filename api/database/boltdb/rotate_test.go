@@ -0,0 +1,78 @@
+package boltdb
+
+import (
+	"testing"
+
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type rotateTestObject struct {
+	Name string
+}
+
+func newEncryptedTestConnection(t *testing.T, key []byte) *DbConnection {
+	conn := &DbConnection{Path: t.TempDir(), EncryptionKey: key}
+	conn.SetEncrypted(key != nil)
+	require.NoError(t, conn.Open())
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func Test_RotateEncryptionKey(t *testing.T) {
+	oldKey := secretToEncryptionKey("old secret")
+	newKey := secretToEncryptionKey("new secret")
+
+	conn := newEncryptedTestConnection(t, oldKey)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, rotateTestObject{Name: "first"}))
+	require.NoError(t, conn.CreateObjectWithStringId("objects", []byte("second"), rotateTestObject{Name: "second"}))
+
+	require.ErrorIs(t, conn.RotateEncryptionKey(oldKey), dserrors.ErrSameEncryptionKey)
+	require.Error(t, conn.RotateEncryptionKey([]byte("too short")))
+
+	require.NoError(t, conn.RotateEncryptionKey(newKey))
+	require.Equal(t, newKey, conn.EncryptionKey)
+
+	var obj rotateTestObject
+	require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+	require.Equal(t, "first", obj.Name)
+
+	require.NoError(t, conn.GetObject("objects", []byte("second"), &obj))
+	require.Equal(t, "second", obj.Name)
+
+	conn.EncryptionKey = oldKey
+	require.Error(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+}
+
+func Test_RotateEncryptionKey_RollsBackOnFailure(t *testing.T) {
+	oldKey := secretToEncryptionKey("old secret")
+	newKey := secretToEncryptionKey("new secret")
+
+	conn := newEncryptedTestConnection(t, oldKey)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, rotateTestObject{Name: "first"}))
+
+	// A value that cannot be decrypted with the current key
+	require.NoError(t, conn.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("objects")).Put(conn.ConvertToKey(2), []byte("not encrypted with the current key"))
+	}))
+
+	require.Error(t, conn.RotateEncryptionKey(newKey))
+	require.Equal(t, oldKey, conn.EncryptionKey)
+
+	var obj rotateTestObject
+	require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+	require.Equal(t, "first", obj.Name)
+}
+
+func Test_RotateEncryptionKey_NotEncrypted(t *testing.T) {
+	conn := newEncryptedTestConnection(t, nil)
+
+	require.ErrorIs(t, conn.RotateEncryptionKey(secretToEncryptionKey("new secret")), dserrors.ErrNotEncrypted)
+}
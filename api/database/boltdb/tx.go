@@ -72,6 +72,10 @@ func (tx *DbTransaction) DeleteAllObjects(bucketName string, obj any, matchingFn
 	return nil
 }
 
+func (tx *DbTransaction) OnCommit(fn func()) {
+	tx.tx.OnCommit(fn)
+}
+
 func (tx *DbTransaction) GetNextIdentifier(bucketName string) int {
 	bucket := tx.tx.Bucket([]byte(bucketName))
 
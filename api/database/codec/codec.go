@@ -0,0 +1,151 @@
+// Package codec implements the encoding and encryption of the objects stored
+// by the database backends, so that the payloads are identical across them.
+package codec
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/encoding/json"
+)
+
+var errEncryptedStringTooShort = errors.New("encrypted string too short")
+
+// Marshal encodes an object to binary format, encrypting it when a key is given
+func Marshal(object any, key []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	// Special case for the VERSION bucket. Here we're not using json
+	if v, ok := object.(string); ok {
+		buf.WriteString(v)
+	} else {
+		enc := json.NewEncoder(buf)
+		enc.SetSortMapKeys(false)
+		enc.SetAppendNewline(false)
+
+		if err := enc.Encode(object); err != nil {
+			return nil, err
+		}
+	}
+
+	if key == nil {
+		return buf.Bytes(), nil
+	}
+
+	return Encrypt(buf.Bytes(), key)
+}
+
+// Unmarshal decodes an object from binary data, decrypting it when a key is given
+func Unmarshal(data []byte, object any, key []byte) error {
+	var err error
+	if key != nil {
+		data, err = Decrypt(data, key)
+		if err != nil {
+			return errors.Wrap(err, "Failed decrypting object")
+		}
+	}
+
+	if e := json.Unmarshal(data, object); e != nil {
+		// Special case for the VERSION bucket. Here we're not using json
+		// So we need to return it as a string
+		s, ok := object.(*string)
+		if !ok {
+			return errors.Wrap(err, e.Error())
+		}
+
+		*s = string(data)
+	}
+
+	return err
+}
+
+// mmm, don't have a KMS .... aes GCM seems the most likely from
+// https://gist.github.com/atoponce/07d8d4c833873be2f68c34f9afc5a78a#symmetric-encryption
+
+// Encrypt encrypts plaintext with AES-GCM, the nonce is prepended to the result
+func Encrypt(plaintext []byte, passphrase []byte) (encrypted []byte, err error) {
+	block, _ := aes.NewCipher(passphrase)
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return encrypted, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return encrypted, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func Decrypt(encrypted []byte, passphrase []byte) (plaintextByte []byte, err error) {
+	if string(encrypted) == "false" {
+		return []byte("false"), nil
+	}
+
+	block, err := aes.NewCipher(passphrase)
+	if err != nil {
+		return encrypted, errors.Wrap(err, "Error creating cypher block")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return encrypted, errors.Wrap(err, "Error creating GCM")
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(encrypted) < nonceSize {
+		return encrypted, errEncryptedStringTooShort
+	}
+
+	nonce, ciphertextByteClean := encrypted[:nonceSize], encrypted[nonceSize:]
+
+	plaintextByte, err = gcm.Open(nil, nonce, ciphertextByteClean, nil)
+	if err != nil {
+		return encrypted, errors.Wrap(err, "Error decrypting text")
+	}
+
+	return plaintextByte, err
+}
+
+// ValidateKey returns an error when key cannot be used as an AES key
+func ValidateKey(key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	return nil
+}
+
+var errReencryptionVerification = errors.New("re-encrypted value does not match the original")
+
+// Reencrypt decrypts a value with currentKey and encrypts it with newKey. The
+// result is verified to decrypt back to the original plaintext.
+func Reencrypt(encrypted []byte, currentKey, newKey []byte) ([]byte, error) {
+	plaintext, err := Decrypt(encrypted, currentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	reencrypted, err := Encrypt(plaintext, newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	verified, err := Decrypt(reencrypted, newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(verified, plaintext) {
+		return nil, errReencryptionVerification
+	}
+
+	return reencrypted, nil
+}
@@ -0,0 +1,479 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/codec"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DatabaseFileName          = "portainer.sqlite"
+	EncryptedDatabaseFileName = "portainer.esqlite"
+
+	// sequencesTable holds the identifier sequence of every bucket
+	sequencesTable = "__sequences"
+)
+
+var (
+	ErrHaveEncryptedAndUnencrypted = errors.New("Portainer has detected both an encrypted and un-encrypted database and cannot start.  Only one database should exist")
+	ErrHaveEncryptedWithNoKey      = errors.New("The portainer database is encrypted, but no secret was loaded")
+)
+
+// DbConnection is a portainer.Connection backed by SQLite. Every bucket is a
+// table of key/value rows holding the same payloads as the BoltDB store.
+type DbConnection struct {
+	Path          string
+	EncryptionKey []byte
+	isEncrypted   bool
+
+	db *sql.DB
+}
+
+// GetDatabaseFileName get the database filename
+func (connection *DbConnection) GetDatabaseFileName() string {
+	if connection.IsEncryptedStore() {
+		return EncryptedDatabaseFileName
+	}
+
+	return DatabaseFileName
+}
+
+// GetDatabaseFilePath get the path + filename for the database file
+func (connection *DbConnection) GetDatabaseFilePath() string {
+	return path.Join(connection.Path, connection.GetDatabaseFileName())
+}
+
+// GetStorePath get the filename and path for the database file
+func (connection *DbConnection) GetStorePath() string {
+	return connection.Path
+}
+
+func (connection *DbConnection) GetDatabaseFileSize() (int64, error) {
+	file, err := os.Stat(connection.GetDatabaseFilePath())
+	if err != nil {
+		return 0, fmt.Errorf("Failed to stat database file path: %s err: %w", connection.GetDatabaseFilePath(), err)
+	}
+
+	return file.Size(), nil
+}
+
+func (connection *DbConnection) SetEncrypted(flag bool) {
+	connection.isEncrypted = flag
+}
+
+// Return true if the database is encrypted
+func (connection *DbConnection) IsEncryptedStore() bool {
+	return connection.getEncryptionKey() != nil
+}
+
+func (connection *DbConnection) getEncryptionKey() []byte {
+	if !connection.isEncrypted {
+		return nil
+	}
+
+	return connection.EncryptionKey
+}
+
+// NeedsEncryptionMigration returns true if database encryption is enabled and
+// we have an un-encrypted DB that requires migration to an encrypted DB.
+// See boltdb.DbConnection.NeedsEncryptionMigration for the different cases.
+func (connection *DbConnection) NeedsEncryptionMigration() (bool, error) {
+	if connection.EncryptionKey != nil {
+		connection.SetEncrypted(true)
+	}
+
+	_, err := os.Stat(path.Join(connection.Path, DatabaseFileName))
+	haveDbFile := err == nil
+
+	_, err = os.Stat(path.Join(connection.Path, EncryptedDatabaseFileName))
+	haveEdbFile := err == nil
+
+	switch {
+	case haveDbFile && haveEdbFile:
+		return false, ErrHaveEncryptedAndUnencrypted
+	case haveDbFile && connection.EncryptionKey != nil:
+		return true, nil
+	case haveEdbFile && connection.EncryptionKey == nil:
+		return false, ErrHaveEncryptedWithNoKey
+	}
+
+	return false, nil
+}
+
+// dataSourceName returns the DSN of the database file. Writers take the
+// database lock when their transaction begins so that concurrent writers wait
+// on the busy timeout instead of failing on lock upgrades, while the WAL
+// journal lets readers run alongside them.
+func dataSourceName(filename string) string {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(10000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+
+	return "file:" + filepath.ToSlash(filename) + "?" + params.Encode()
+}
+
+// Open opens and initializes the SQLite database.
+func (connection *DbConnection) Open() error {
+	log.Info().Str("filename", connection.GetDatabaseFileName()).Msg("loading PortainerDB")
+
+	db, err := sql.Open("sqlite", dataSourceName(connection.GetDatabaseFilePath()))
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS "` + sequencesTable + `" (bucket TEXT PRIMARY KEY, seq INTEGER NOT NULL)`); err != nil {
+		db.Close()
+
+		return err
+	}
+
+	if err := os.Chmod(connection.GetDatabaseFilePath(), 0600); err != nil {
+		db.Close()
+
+		return err
+	}
+
+	connection.db = db
+
+	return nil
+}
+
+// Close closes the SQLite database.
+// Safe to being called multiple times.
+func (connection *DbConnection) Close() error {
+	log.Info().Msg("closing PortainerDB")
+
+	if connection.db == nil {
+		return nil
+	}
+
+	err := connection.db.Close()
+	connection.db = nil
+
+	return err
+}
+
+// UpdateTx executes the given function inside a read-write transaction
+func (connection *DbConnection) UpdateTx(fn func(portainer.Transaction) error) error {
+	dbTx := &DbTransaction{conn: connection}
+
+	if err := connection.update(func(tx *sql.Tx) error {
+		dbTx.tx = tx
+
+		return fn(dbTx)
+	}); err != nil {
+		return err
+	}
+
+	for _, onCommit := range dbTx.onCommit {
+		onCommit()
+	}
+
+	return nil
+}
+
+// ViewTx executes the given function inside a read-only transaction
+func (connection *DbConnection) ViewTx(fn func(portainer.Transaction) error) error {
+	return connection.view(func(tx *sql.Tx) error {
+		return fn(&DbTransaction{conn: connection, tx: tx})
+	})
+}
+
+func (connection *DbConnection) update(fn func(*sql.Tx) error) error {
+	return connection.transaction(&sql.TxOptions{}, fn)
+}
+
+func (connection *DbConnection) view(fn func(*sql.Tx) error) error {
+	return connection.transaction(&sql.TxOptions{ReadOnly: true}, fn)
+}
+
+func (connection *DbConnection) transaction(opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := connection.db.BeginTx(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// BackupTo backs up db to a provided writer.
+// It does hot backup and doesn't block other database reads and writes
+func (connection *DbConnection) BackupTo(w io.Writer) error {
+	dir, err := os.MkdirTemp(connection.Path, "backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, connection.GetDatabaseFileName())
+	if _, err := connection.db.Exec(`VACUUM INTO ?`, filename); err != nil {
+		return err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+func (connection *DbConnection) ExportRaw(filename string) error {
+	b, err := connection.ExportJSON(true)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, b, 0600)
+}
+
+// ConvertToKey returns an 8-byte big endian representation of v.
+// This function is typically used for encoding integer IDs to byte slices
+// so that they can be used as keys.
+func (connection *DbConnection) ConvertToKey(v int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+
+	return b
+}
+
+// keyToString Converts a key to a string value suitable for logging
+func keyToString(b []byte) string {
+	if len(b) != 8 {
+		return string(b)
+	}
+
+	v := binary.BigEndian.Uint64(b)
+	if v <= math.MaxInt32 {
+		return strconv.FormatUint(v, 10)
+	}
+
+	return string(b)
+}
+
+// quoteIdentifier quotes a bucket name to be used as a table name
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// MarshalObject encodes an object to binary format
+func (connection *DbConnection) MarshalObject(object any) ([]byte, error) {
+	return codec.Marshal(object, connection.getEncryptionKey())
+}
+
+// UnmarshalObject decodes an object from binary data
+func (connection *DbConnection) UnmarshalObject(data []byte, object any) error {
+	return codec.Unmarshal(data, object, connection.getEncryptionKey())
+}
+
+// SetServiceName creates the table of a bucket if it doesn't exist.
+func (connection *DbConnection) SetServiceName(bucketName string) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.SetServiceName(bucketName)
+	})
+}
+
+// GetObject is a generic function used to retrieve an unmarshalled object from a database.
+func (connection *DbConnection) GetObject(bucketName string, key []byte, object any) error {
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetObject(bucketName, key, object)
+	})
+}
+
+// UpdateObject is a generic function used to update an object inside a database.
+func (connection *DbConnection) UpdateObject(bucketName string, key []byte, object any) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.UpdateObject(bucketName, key, object)
+	})
+}
+
+// UpdateObjectFunc is a generic function used to update an object safely without race conditions.
+func (connection *DbConnection) UpdateObjectFunc(bucketName string, key []byte, object any, updateFn func()) error {
+	return connection.update(func(tx *sql.Tx) error {
+		t := &DbTransaction{conn: connection, tx: tx}
+
+		if err := t.GetObject(bucketName, key, object); err != nil {
+			return err
+		}
+
+		updateFn()
+
+		return t.UpdateObject(bucketName, key, object)
+	})
+}
+
+// DeleteObject is a generic function used to delete an object inside a database.
+func (connection *DbConnection) DeleteObject(bucketName string, key []byte) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.DeleteObject(bucketName, key)
+	})
+}
+
+// DeleteAllObjects delete all objects where matching() returns (id, ok).
+func (connection *DbConnection) DeleteAllObjects(bucketName string, obj any, matching func(o any) (id int, ok bool)) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.DeleteAllObjects(bucketName, obj, matching)
+	})
+}
+
+// OnCommit calls fn right away, the operations made outside of a transaction are committed one by one
+func (connection *DbConnection) OnCommit(fn func()) {
+	fn()
+}
+
+// GetNextIdentifier is a generic function that returns the specified bucket identifier incremented by 1.
+func (connection *DbConnection) GetNextIdentifier(bucketName string) int {
+	var identifier int
+
+	_ = connection.UpdateTx(func(tx portainer.Transaction) error {
+		identifier = tx.GetNextIdentifier(bucketName)
+		return nil
+	})
+
+	return identifier
+}
+
+// CreateObject creates a new object in the bucket, using the next bucket sequence id
+func (connection *DbConnection) CreateObject(bucketName string, fn func(uint64) (int, any)) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.CreateObject(bucketName, fn)
+	})
+}
+
+// CreateObjectWithId creates a new object in the bucket, using the specified id
+func (connection *DbConnection) CreateObjectWithId(bucketName string, id int, obj any) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.CreateObjectWithId(bucketName, id, obj)
+	})
+}
+
+// CreateObjectWithStringId creates a new object in the bucket, using the specified id
+func (connection *DbConnection) CreateObjectWithStringId(bucketName string, id []byte, obj any) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return tx.CreateObjectWithStringId(bucketName, id, obj)
+	})
+}
+
+func (connection *DbConnection) GetAll(bucketName string, obj any, appendFn func(o any) (any, error)) error {
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetAll(bucketName, obj, appendFn)
+	})
+}
+
+func (connection *DbConnection) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetAllWithKeyPrefix(bucketName, keyPrefix, obj, appendFn)
+	})
+}
+
+// bucketNames returns the name of every bucket of the database
+func bucketNames(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != ? ORDER BY name`, sequencesTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// BackupMetadata will return a copy of the sequence numbers for all buckets.
+func (connection *DbConnection) BackupMetadata() (map[string]any, error) {
+	buckets := map[string]any{}
+
+	err := connection.view(func(tx *sql.Tx) error {
+		names, err := bucketNames(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			seq, err := sequence(tx, name)
+			if err != nil {
+				return err
+			}
+
+			buckets[name] = int(seq)
+		}
+
+		return nil
+	})
+
+	return buckets, err
+}
+
+// RestoreMetadata will restore the sequence numbers for all buckets.
+func (connection *DbConnection) RestoreMetadata(s map[string]any) error {
+	var err error
+
+	for bucketName, v := range s {
+		id, ok := v.(float64) // JSON ints are unmarshalled to interface as float64. See: https://pkg.go.dev/encoding/json#Decoder.Decode
+		if !ok {
+			log.Error().Str("bucket", bucketName).Msg("failed to restore metadata to bucket, skipped")
+
+			continue
+		}
+
+		err = connection.update(func(tx *sql.Tx) error {
+			if err := createBucket(tx, bucketName); err != nil {
+				return err
+			}
+
+			return setSequence(tx, bucketName, uint64(id))
+		})
+	}
+
+	return err
+}
+
+// Compact rebuilds the database file, releasing the pages freed by deleted
+// objects
+func (connection *DbConnection) Compact() error {
+	log.Info().Str("filename", connection.GetDatabaseFilePath()).Msg("compacting PortainerDB")
+
+	if _, err := connection.db.Exec(`VACUUM`); err != nil {
+		return err
+	}
+
+	// Fold the WAL back into the database file so that its size is accurate
+	_, err := connection.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+
+	return err
+}
@@ -0,0 +1,231 @@
+package sqlite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/boltdb"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testObject struct {
+	ID   int
+	Name string
+}
+
+func secretToEncryptionKey(passphrase string) []byte {
+	hash := sha256.Sum256([]byte(passphrase))
+	return hash[:]
+}
+
+func newTestConnection(t *testing.T, path string, key []byte) *DbConnection {
+	conn := &DbConnection{Path: path, EncryptionKey: key}
+	_, err := conn.NeedsEncryptionMigration()
+	require.NoError(t, err)
+	require.NoError(t, conn.Open())
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func Test_Connection(t *testing.T) {
+	for _, key := range [][]byte{nil, secretToEncryptionKey("secret")} {
+		conn := newTestConnection(t, t.TempDir(), key)
+		require.Equal(t, key != nil, conn.IsEncryptedStore())
+
+		require.NoError(t, conn.SetServiceName("objects"))
+
+		for range 3 {
+			require.NoError(t, conn.CreateObject("objects", func(id uint64) (int, any) {
+				return int(id), testObject{ID: int(id), Name: "object"}
+			}))
+		}
+
+		require.Equal(t, 4, conn.GetNextIdentifier("objects"))
+
+		var obj testObject
+		require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(2), &obj))
+		require.Equal(t, testObject{ID: 2, Name: "object"}, obj)
+
+		err := conn.GetObject("objects", conn.ConvertToKey(10), &obj)
+		require.ErrorIs(t, err, dserrors.ErrObjectNotFound)
+
+		require.NoError(t, conn.UpdateObjectFunc("objects", conn.ConvertToKey(2), &obj, func() { obj.Name = "updated" }))
+		require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(2), &obj))
+		require.Equal(t, "updated", obj.Name)
+
+		var ids []int
+		require.NoError(t, conn.GetAll("objects", &testObject{}, func(o any) (any, error) {
+			ids = append(ids, o.(*testObject).ID)
+			return &testObject{}, nil
+		}))
+		require.Equal(t, []int{1, 2, 3}, ids)
+
+		require.NoError(t, conn.DeleteAllObjects("objects", &testObject{}, func(o any) (int, bool) {
+			obj := o.(*testObject)
+			return obj.ID, obj.ID != 2
+		}))
+
+		ids = nil
+		require.NoError(t, conn.GetAll("objects", &testObject{}, func(o any) (any, error) {
+			ids = append(ids, o.(*testObject).ID)
+			return &testObject{}, nil
+		}))
+		require.Equal(t, []int{2}, ids)
+
+		metadata, err := conn.BackupMetadata()
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"objects": 4}, metadata)
+	}
+}
+
+func Test_GetAllWithKeyPrefix(t *testing.T) {
+	conn := newTestConnection(t, t.TempDir(), nil)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+
+	for _, key := range []string{"a-1", "b-1", "b-2", "c-1"} {
+		require.NoError(t, conn.CreateObjectWithStringId("objects", []byte(key), key))
+	}
+
+	var keys []string
+	require.NoError(t, conn.GetAllWithKeyPrefix("objects", []byte("b-"), new(string), func(o any) (any, error) {
+		keys = append(keys, *o.(*string))
+		return new(string), nil
+	}))
+	require.Equal(t, []string{"b-1", "b-2"}, keys)
+}
+
+func Test_UpdateTxRollsBack(t *testing.T) {
+	conn := newTestConnection(t, t.TempDir(), nil)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+
+	err := conn.UpdateTx(func(tx portainer.Transaction) error {
+		require.NoError(t, tx.CreateObjectWithId("objects", 1, testObject{ID: 1}))
+
+		return dserrors.ErrDBImportFailed
+	})
+	require.ErrorIs(t, err, dserrors.ErrDBImportFailed)
+
+	var obj testObject
+	require.ErrorIs(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj), dserrors.ErrObjectNotFound)
+}
+
+func Test_UpdateTxOnCommit(t *testing.T) {
+	conn := newTestConnection(t, t.TempDir(), nil)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+
+	committed := 0
+
+	err := conn.UpdateTx(func(tx portainer.Transaction) error {
+		tx.OnCommit(func() { committed++ })
+
+		return dserrors.ErrDBImportFailed
+	})
+	require.ErrorIs(t, err, dserrors.ErrDBImportFailed)
+	require.Zero(t, committed, "nothing must be called when the transaction is rolled back")
+
+	err = conn.UpdateTx(func(tx portainer.Transaction) error {
+		tx.OnCommit(func() { committed++ })
+
+		return tx.CreateObjectWithId("objects", 1, testObject{ID: 1})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, committed)
+}
+
+func Test_BackupTo(t *testing.T) {
+	conn := newTestConnection(t, t.TempDir(), nil)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, testObject{ID: 1}))
+
+	var buf bytes.Buffer
+	require.NoError(t, conn.BackupTo(&buf))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte("SQLite format 3")))
+}
+
+func Test_RotateEncryptionKey(t *testing.T) {
+	oldKey := secretToEncryptionKey("old secret")
+	newKey := secretToEncryptionKey("new secret")
+
+	conn := newTestConnection(t, t.TempDir(), oldKey)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, testObject{ID: 1, Name: "first"}))
+
+	require.ErrorIs(t, conn.RotateEncryptionKey(oldKey), dserrors.ErrSameEncryptionKey)
+	require.NoError(t, conn.RotateEncryptionKey(newKey))
+
+	var obj testObject
+	require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+	require.Equal(t, "first", obj.Name)
+
+	conn.EncryptionKey = oldKey
+	require.Error(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+}
+
+func Test_MigrateFromBoltDB(t *testing.T) {
+	for _, key := range [][]byte{nil, secretToEncryptionKey("secret")} {
+		dir := t.TempDir()
+
+		source := &boltdb.DbConnection{Path: dir, EncryptionKey: key}
+		_, err := source.NeedsEncryptionMigration()
+		require.NoError(t, err)
+		require.NoError(t, source.Open())
+		require.NoError(t, source.SetServiceName("objects"))
+		require.NoError(t, source.SetServiceName("version"))
+		require.NoError(t, source.CreateObject("objects", func(id uint64) (int, any) {
+			return int(id), testObject{ID: int(id), Name: "first"}
+		}))
+		require.NoError(t, source.CreateObjectWithStringId("version", []byte("VERSION"), `{"SchemaVersion":"2.27.0"}`))
+		require.NoError(t, source.Close())
+
+		require.NoError(t, MigrateFromBoltDB(dir, key))
+		require.ErrorIs(t, MigrateFromBoltDB(dir, key), ErrDatabaseExists)
+
+		conn := newTestConnection(t, dir, key)
+
+		var obj testObject
+		require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+		require.Equal(t, "first", obj.Name)
+
+		var version string
+		require.NoError(t, conn.GetObject("version", []byte("VERSION"), &version))
+		require.JSONEq(t, `{"SchemaVersion":"2.27.0"}`, version)
+
+		require.Equal(t, 2, conn.GetNextIdentifier("objects"))
+	}
+}
+
+func Test_MigrateFromBoltDB_NoDatabase(t *testing.T) {
+	require.ErrorIs(t, MigrateFromBoltDB(t.TempDir(), nil), ErrBoltDBNotFound)
+}
+
+func Test_Compact(t *testing.T) {
+	conn := newTestConnection(t, t.TempDir(), nil)
+
+	require.NoError(t, conn.SetServiceName("objects"))
+	for i := range 200 {
+		require.NoError(t, conn.CreateObjectWithId("objects", i, testObject{ID: i, Name: string(make([]byte, 4096))}))
+	}
+	for i := range 200 {
+		require.NoError(t, conn.DeleteObject("objects", conn.ConvertToKey(i)))
+	}
+	require.NoError(t, conn.CreateObjectWithId("objects", 1, testObject{ID: 1}))
+
+	require.NoError(t, conn.Compact())
+
+	size, err := conn.GetDatabaseFileSize()
+	require.NoError(t, err)
+	require.Less(t, size, int64(200*4096))
+
+	var obj testObject
+	require.NoError(t, conn.GetObject("objects", conn.ConvertToKey(1), &obj))
+}
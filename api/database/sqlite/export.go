@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+// ExportJSON creates a JSON representation of the database, in the same
+// format as boltdb.DbConnection.ExportJSON. You can include the database's
+// metadata or ignore it.
+func (connection *DbConnection) ExportJSON(metadata bool) ([]byte, error) {
+	backup := make(map[string]any)
+	if metadata {
+		meta, err := connection.BackupMetadata()
+		if err != nil {
+			log.Error().Err(err).Msg("failed exporting metadata")
+		}
+
+		backup["__metadata"] = meta
+	}
+
+	if err := connection.view(func(tx *sql.Tx) error {
+		names, err := bucketNames(tx)
+		if err != nil {
+			return err
+		}
+
+		t := &DbTransaction{conn: connection, tx: tx}
+
+		for _, bucketName := range names {
+			var list []any
+			version := make(map[string]string)
+
+			if err := t.forEach(bucketName, nil, func(k, v []byte) error {
+				var obj any
+				err := connection.UnmarshalObject(v, &obj)
+				if err != nil {
+					log.Error().
+						Str("bucket", bucketName).
+						Str("object", string(v)).
+						Err(err).
+						Msg("failed to unmarshal")
+
+					obj = v
+				}
+
+				if bucketName == "version" {
+					version[string(k)] = string(v)
+				} else {
+					list = append(list, obj)
+				}
+
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			switch bucketName {
+			case "version":
+				backup[bucketName] = version
+			case "ssl", "settings", "tunnel_server":
+				backup[bucketName] = nil
+				if len(list) > 0 {
+					backup[bucketName] = list[0]
+				}
+			default:
+				backup[bucketName] = list
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return []byte("{}"), err
+	}
+
+	return json.MarshalIndent(backup, "", "  ")
+}
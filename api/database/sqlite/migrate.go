@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/portainer/portainer/api/database/boltdb"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrBoltDBNotFound    = errors.New("no BoltDB database found to migrate")
+	ErrDatabaseExists    = errors.New("a SQLite database already exists, remove it before migrating the BoltDB database")
+	ErrBoltDBNotMigrated = errors.New("the BoltDB database must be encrypted before being migrated, start Portainer once with the BoltDB backend")
+)
+
+// MigrateFromBoltDB copies the BoltDB store found in storePath into a new
+// SQLite store next to it. The payloads are copied as is, so both stores use
+// the same encryption key. The BoltDB store is left untouched and the SQLite
+// store is removed if the copy fails.
+func MigrateFromBoltDB(storePath string, encryptionKey []byte) error {
+	source := &boltdb.DbConnection{Path: storePath, EncryptionKey: encryptionKey}
+
+	needsEncryption, err := source.NeedsEncryptionMigration()
+	if err != nil {
+		return err
+	}
+
+	if needsEncryption {
+		return ErrBoltDBNotMigrated
+	}
+
+	if _, err := os.Stat(source.GetDatabaseFilePath()); err != nil {
+		return fmt.Errorf("%w: %w", ErrBoltDBNotFound, err)
+	}
+
+	destination := &DbConnection{Path: storePath, EncryptionKey: encryptionKey}
+	destination.SetEncrypted(encryptionKey != nil)
+
+	for _, filename := range []string{DatabaseFileName, EncryptedDatabaseFileName} {
+		if _, err := os.Stat(path.Join(destination.Path, filename)); err == nil {
+			return ErrDatabaseExists
+		}
+	}
+
+	if err := source.Open(); err != nil {
+		return err
+	}
+	defer source.Close()
+
+	if err := destination.Open(); err != nil {
+		return err
+	}
+
+	if err := destination.importBoltDB(source.DB); err != nil {
+		destination.Close()
+		destination.remove()
+
+		return err
+	}
+
+	return destination.Close()
+}
+
+// importBoltDB copies every bucket of db, with its sequence, and verifies the
+// number of objects of each of them before committing
+func (connection *DbConnection) importBoltDB(db *bolt.DB) error {
+	return db.View(func(boltTx *bolt.Tx) error {
+		return connection.update(func(tx *sql.Tx) error {
+			return boltTx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+				bucketName := string(name)
+
+				if err := createBucket(tx, bucketName); err != nil {
+					return err
+				}
+
+				if err := setSequence(tx, bucketName, bucket.Sequence()); err != nil {
+					return err
+				}
+
+				count := 0
+				if err := bucket.ForEach(func(k, v []byte) error {
+					if v == nil {
+						return nil
+					}
+
+					count++
+
+					return put(tx, bucketName, k, v)
+				}); err != nil {
+					return fmt.Errorf("failed to copy bucket %s: %w", bucketName, err)
+				}
+
+				var copied int
+				if err := tx.QueryRow(`SELECT COUNT(*) FROM ` + quoteIdentifier(bucketName)).Scan(&copied); err != nil {
+					return err
+				}
+
+				if copied != count {
+					return fmt.Errorf("bucket %s: %d objects copied out of %d", bucketName, copied, count)
+				}
+
+				log.Info().Str("bucket", bucketName).Int("objects", count).Msg("bucket migrated")
+
+				return nil
+			})
+		})
+	})
+}
+
+func (connection *DbConnection) remove() {
+	filename := connection.GetDatabaseFilePath()
+
+	for _, f := range []string{filename, filename + "-wal", filename + "-shm"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("filename", f).Msg("failed to remove the SQLite database")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+
+	"github.com/portainer/portainer/api/database/codec"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+// RotateEncryptionKey re-encrypts every value of the database with newKey
+// inside a single transaction. See boltdb.DbConnection.RotateEncryptionKey.
+func (connection *DbConnection) RotateEncryptionKey(newKey []byte) error {
+	currentKey := connection.getEncryptionKey()
+	if currentKey == nil {
+		return dserrors.ErrNotEncrypted
+	}
+
+	if err := codec.ValidateKey(newKey); err != nil {
+		return err
+	}
+
+	if bytes.Equal(currentKey, newKey) {
+		return dserrors.ErrSameEncryptionKey
+	}
+
+	err := connection.update(func(tx *sql.Tx) error {
+		names, err := bucketNames(tx)
+		if err != nil {
+			return err
+		}
+
+		t := &DbTransaction{conn: connection, tx: tx}
+
+		for _, name := range names {
+			if err := t.forEach(name, nil, func(k, v []byte) error {
+				// The unencrypted "false" special case of decrypt is kept as is
+				if string(v) == "false" {
+					return nil
+				}
+
+				encrypted, err := codec.Reencrypt(v, currentKey, newKey)
+				if err != nil {
+					return fmt.Errorf("key=%s: %w", keyToString(k), err)
+				}
+
+				return put(tx, name, k, encrypted)
+			}); err != nil {
+				return fmt.Errorf("failed to re-encrypt bucket %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	connection.EncryptionKey = newKey
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/rs/zerolog/log"
+)
+
+type DbTransaction struct {
+	conn     *DbConnection
+	tx       *sql.Tx
+	onCommit []func()
+}
+
+func createBucket(tx *sql.Tx, bucketName string) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS ` + quoteIdentifier(bucketName) + ` (key BLOB PRIMARY KEY, value BLOB NOT NULL) WITHOUT ROWID`)
+
+	return err
+}
+
+func sequence(tx *sql.Tx, bucketName string) (uint64, error) {
+	var seq uint64
+
+	err := tx.QueryRow(`SELECT seq FROM "`+sequencesTable+`" WHERE bucket = ?`, bucketName).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return seq, err
+}
+
+func setSequence(tx *sql.Tx, bucketName string, seq uint64) error {
+	_, err := tx.Exec(`INSERT INTO "`+sequencesTable+`" (bucket, seq) VALUES (?, ?) ON CONFLICT (bucket) DO UPDATE SET seq = excluded.seq`, bucketName, seq)
+
+	return err
+}
+
+// nextSequence increments and returns the sequence of a bucket
+func nextSequence(tx *sql.Tx, bucketName string) (uint64, error) {
+	var seq uint64
+
+	err := tx.QueryRow(`INSERT INTO "`+sequencesTable+`" (bucket, seq) VALUES (?, 1) ON CONFLICT (bucket) DO UPDATE SET seq = seq + 1 RETURNING seq`, bucketName).Scan(&seq)
+
+	return seq, err
+}
+
+func put(tx *sql.Tx, bucketName string, key, value []byte) error {
+	_, err := tx.Exec(`INSERT INTO `+quoteIdentifier(bucketName)+` (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+
+	return err
+}
+
+func (tx *DbTransaction) SetServiceName(bucketName string) error {
+	return createBucket(tx.tx, bucketName)
+}
+
+func (tx *DbTransaction) GetObject(bucketName string, key []byte, object any) error {
+	var value []byte
+
+	err := tx.tx.QueryRow(`SELECT value FROM `+quoteIdentifier(bucketName)+` WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w (bucket=%s, key=%s)", dserrors.ErrObjectNotFound, bucketName, keyToString(key))
+	} else if err != nil {
+		return err
+	}
+
+	return tx.conn.UnmarshalObject(value, object)
+}
+
+func (tx *DbTransaction) UpdateObject(bucketName string, key []byte, object any) error {
+	data, err := tx.conn.MarshalObject(object)
+	if err != nil {
+		return err
+	}
+
+	return put(tx.tx, bucketName, key, data)
+}
+
+func (tx *DbTransaction) DeleteObject(bucketName string, key []byte) error {
+	_, err := tx.tx.Exec(`DELETE FROM `+quoteIdentifier(bucketName)+` WHERE key = ?`, key)
+
+	return err
+}
+
+func (tx *DbTransaction) DeleteAllObjects(bucketName string, obj any, matchingFn func(o any) (id int, ok bool)) error {
+	var ids []int
+
+	if err := tx.forEach(bucketName, nil, func(k, v []byte) error {
+		if err := tx.conn.UnmarshalObject(v, &obj); err != nil {
+			return err
+		}
+
+		if id, ok := matchingFn(obj); ok {
+			ids = append(ids, id)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := tx.DeleteObject(bucketName, tx.conn.ConvertToKey(id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *DbTransaction) OnCommit(fn func()) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+func (tx *DbTransaction) GetNextIdentifier(bucketName string) int {
+	id, err := nextSequence(tx.tx, bucketName)
+	if err != nil {
+		log.Error().Err(err).Str("bucket", bucketName).Msg("failed to get the next identifier")
+
+		return 0
+	}
+
+	return int(id)
+}
+
+func (tx *DbTransaction) CreateObject(bucketName string, fn func(uint64) (int, any)) error {
+	seqId, _ := nextSequence(tx.tx, bucketName)
+	id, obj := fn(seqId)
+
+	data, err := tx.conn.MarshalObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return put(tx.tx, bucketName, tx.conn.ConvertToKey(id), data)
+}
+
+func (tx *DbTransaction) CreateObjectWithId(bucketName string, id int, obj any) error {
+	data, err := tx.conn.MarshalObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return put(tx.tx, bucketName, tx.conn.ConvertToKey(id), data)
+}
+
+func (tx *DbTransaction) CreateObjectWithStringId(bucketName string, id []byte, obj any) error {
+	data, err := tx.conn.MarshalObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return put(tx.tx, bucketName, id, data)
+}
+
+func (tx *DbTransaction) GetAll(bucketName string, obj any, appendFn func(o any) (any, error)) error {
+	return tx.forEach(bucketName, nil, func(k, v []byte) error {
+		err := tx.conn.UnmarshalObject(v, obj)
+		if err == nil {
+			obj, err = appendFn(obj)
+		}
+
+		return err
+	})
+}
+
+func (tx *DbTransaction) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
+	return tx.forEach(bucketName, keyPrefix, func(k, v []byte) error {
+		err := tx.conn.UnmarshalObject(v, obj)
+		if err == nil {
+			obj, err = appendFn(obj)
+		}
+
+		return err
+	})
+}
+
+// forEach calls fn for every row of the bucket whose key starts with
+// keyPrefix, in key order. The rows are read before fn is called so that fn
+// can use the transaction.
+func (tx *DbTransaction) forEach(bucketName string, keyPrefix []byte, fn func(k, v []byte) error) error {
+	query := `SELECT key, value FROM ` + quoteIdentifier(bucketName)
+	var args []any
+	if len(keyPrefix) > 0 {
+		query += ` WHERE key >= ?`
+		args = append(args, keyPrefix)
+	}
+
+	rows, err := tx.tx.Query(query+` ORDER BY key`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys, values [][]byte
+	for rows.Next() {
+		var k, v []byte
+		if err := rows.Scan(&k, &v); err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(k, keyPrefix) {
+			break
+		}
+
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	for i := range keys {
+		if err := fn(keys[i], values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
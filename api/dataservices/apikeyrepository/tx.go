@@ -0,0 +1,61 @@
+package apikeyrepository
+
+import (
+	"errors"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+type ServiceTx struct {
+	dataservices.BaseDataServiceTx[portainer.APIKey, portainer.APIKeyID]
+}
+
+// GetAPIKeysByUserID returns a slice containing all the APIKeys a user has access to.
+func (service ServiceTx) GetAPIKeysByUserID(userID portainer.UserID) ([]portainer.APIKey, error) {
+	result := make([]portainer.APIKey, 0)
+
+	return result, service.Tx.GetAll(
+		BucketName,
+		&portainer.APIKey{},
+		dataservices.FilterFn(&result, func(e portainer.APIKey) bool {
+			return e.UserID == userID
+		}),
+	)
+}
+
+// GetAPIKeyByDigest returns the API key for the associated digest.
+func (service ServiceTx) GetAPIKeyByDigest(digest string) (*portainer.APIKey, error) {
+	var k portainer.APIKey
+
+	err := service.Tx.GetAll(
+		BucketName,
+		&portainer.APIKey{},
+		dataservices.FirstFn(&k, func(e portainer.APIKey) bool {
+			return e.Digest == digest
+		}),
+	)
+
+	if errors.Is(err, dataservices.ErrStop) {
+		return &k, nil
+	}
+
+	if err == nil {
+		return nil, dserrors.ErrObjectNotFound
+	}
+
+	return nil, err
+}
+
+// Create creates a new APIKey object.
+func (service ServiceTx) Create(record *portainer.APIKey) error {
+	return service.Tx.CreateObject(
+		BucketName,
+		func(id uint64) (int, any) {
+			record.ID = portainer.APIKeyID(id)
+
+			return int(record.ID), record
+		},
+	)
+}
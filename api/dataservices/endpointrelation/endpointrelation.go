@@ -5,7 +5,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
-	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 
 	"github.com/rs/zerolog/log"
 )
@@ -80,7 +80,7 @@ func (service *Service) EndpointRelation(endpointID portainer.EndpointID) (*port
 // CreateEndpointRelation saves endpointRelation
 func (service *Service) Create(endpointRelation *portainer.EndpointRelation) error {
 	err := service.connection.CreateObjectWithId(BucketName, int(endpointRelation.EndpointID), endpointRelation)
-	cache.Del(endpointRelation.EndpointID)
+	notify.Send(endpointRelation.EndpointID, notify.StackVersionChanged)
 
 	service.mu.Lock()
 	service.endpointRelationsCache = nil
@@ -95,7 +95,7 @@ func (service *Service) UpdateEndpointRelation(endpointID portainer.EndpointID,
 
 	identifier := service.connection.ConvertToKey(int(endpointID))
 	err := service.connection.UpdateObject(BucketName, identifier, endpointRelation)
-	cache.Del(endpointID)
+	notify.Send(endpointID, notify.StackVersionChanged)
 	if err != nil {
 		return err
 	}
@@ -129,7 +129,7 @@ func (service *Service) DeleteEndpointRelation(endpointID portainer.EndpointID)
 
 	identifier := service.connection.ConvertToKey(int(endpointID))
 	err := service.connection.DeleteObject(BucketName, identifier)
-	cache.Del(endpointID)
+	notify.Send(endpointID, notify.StackVersionChanged)
 	if err != nil {
 		return err
 	}
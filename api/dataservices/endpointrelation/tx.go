@@ -3,7 +3,7 @@ package endpointrelation
 import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
-	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 
 	"github.com/rs/zerolog/log"
 )
@@ -45,7 +45,7 @@ func (service ServiceTx) EndpointRelation(endpointID portainer.EndpointID) (*por
 // CreateEndpointRelation saves endpointRelation
 func (service ServiceTx) Create(endpointRelation *portainer.EndpointRelation) error {
 	err := service.tx.CreateObjectWithId(BucketName, int(endpointRelation.EndpointID), endpointRelation)
-	cache.Del(endpointRelation.EndpointID)
+	notify.SendOnCommit(service.tx, endpointRelation.EndpointID, notify.StackVersionChanged)
 
 	service.service.mu.Lock()
 	service.service.endpointRelationsCache = nil
@@ -60,7 +60,7 @@ func (service ServiceTx) UpdateEndpointRelation(endpointID portainer.EndpointID,
 
 	identifier := service.service.connection.ConvertToKey(int(endpointID))
 	err := service.tx.UpdateObject(BucketName, identifier, endpointRelation)
-	cache.Del(endpointID)
+	notify.SendOnCommit(service.tx, endpointID, notify.StackVersionChanged)
 	if err != nil {
 		return err
 	}
@@ -87,7 +87,7 @@ func (service ServiceTx) AddEndpointRelationsForEdgeStack(endpointIDs []portaine
 
 		identifier := service.service.connection.ConvertToKey(int(endpointID))
 		err = service.tx.UpdateObject(BucketName, identifier, rel)
-		cache.Del(endpointID)
+		notify.SendOnCommit(service.tx, endpointID, notify.StackVersionChanged)
 		if err != nil {
 			return err
 		}
@@ -113,7 +113,7 @@ func (service ServiceTx) RemoveEndpointRelationsForEdgeStack(endpointIDs []porta
 
 		identifier := service.service.connection.ConvertToKey(int(endpointID))
 		err = service.tx.UpdateObject(BucketName, identifier, rel)
-		cache.Del(endpointID)
+		notify.SendOnCommit(service.tx, endpointID, notify.StackVersionChanged)
 		if err != nil {
 			return err
 		}
@@ -134,7 +134,7 @@ func (service ServiceTx) DeleteEndpointRelation(endpointID portainer.EndpointID)
 
 	identifier := service.service.connection.ConvertToKey(int(endpointID))
 	err := service.tx.DeleteObject(BucketName, identifier)
-	cache.Del(endpointID)
+	notify.SendOnCommit(service.tx, endpointID, notify.StackVersionChanged)
 	if err != nil {
 		return err
 	}
@@ -157,7 +157,7 @@ func (service ServiceTx) InvalidateEdgeCacheForEdgeStack(edgeStackID portainer.E
 
 	for _, rel := range rels {
 		if _, ok := rel.EdgeStacks[edgeStackID]; ok {
-			cache.Del(rel.EndpointID)
+			notify.SendOnCommit(service.tx, rel.EndpointID, notify.StackVersionChanged)
 		}
 	}
 }
@@ -9,4 +9,6 @@ var (
 	ErrWrongDBEdition     = errors.New("the Portainer database is set for Portainer Business Edition, please follow the instructions in our documentation to downgrade it: https://documentation.portainer.io/v2.0-be/downgrade/be-to-ce/")
 	ErrDBImportFailed     = errors.New("importing backup failed")
 	ErrDatabaseIsUpdating = errors.New("database is currently in updating state. Failed prior upgrade. Please restore from backup or delete the database and restart Portainer")
+	ErrNotEncrypted       = errors.New("the Portainer database is not encrypted, there is no encryption key to rotate")
+	ErrSameEncryptionKey  = errors.New("the new encryption key is the same as the current one")
 )
@@ -0,0 +1,51 @@
+package dataservices
+
+type (
+	// IntegrityReport is the result of a datastore integrity check
+	IntegrityReport struct {
+		// Decoding result of every bucket of the datastore
+		Buckets []BucketIntegrity
+		// References to objects that no longer exist
+		Orphans []OrphanedReference
+		// Size of the database file in bytes before the maintenance
+		DatabaseSize int64 `example:"1048576"`
+		// Size of the database file in bytes after the compaction, 0 when it was not compacted
+		CompactedDatabaseSize int64 `example:"524288"`
+	}
+
+	// BucketIntegrity reports the objects of a bucket that cannot be decoded into their model
+	BucketIntegrity struct {
+		Name    string `example:"stacks"`
+		Objects int    `example:"12"`
+		Errors  []string
+	}
+
+	// OrphanedReference is an object referencing another object that no longer exists
+	OrphanedReference struct {
+		// Bucket of the object holding the reference
+		Bucket string `example:"stacks"`
+		// Identifier of the object holding the reference
+		ID string `example:"3"`
+		// Description of the missing object
+		Reference string `example:"environment 5"`
+		// Whether the reference was removed
+		Repaired bool `example:"false"`
+	}
+)
+
+// Healthy returns true when every object decodes and no orphaned reference is left
+func (r *IntegrityReport) Healthy() bool {
+	for _, b := range r.Buckets {
+		if len(b.Errors) > 0 {
+			return false
+		}
+	}
+
+	for _, o := range r.Orphans {
+		if !o.Repaired {
+			return false
+		}
+	}
+
+	return true
+}
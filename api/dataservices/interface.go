@@ -8,6 +8,8 @@ import (
 type (
 	DataStoreTx interface {
 		IsErrObjectNotFound(err error) bool
+		// OnCommit registers fn to be called once the changes are committed
+		OnCommit(fn func())
 		CustomTemplate() CustomTemplateService
 		EdgeGroup() EdgeGroupService
 		EdgeJob() EdgeJobService
@@ -27,6 +29,7 @@ type (
 		Tag() TagService
 		TeamMembership() TeamMembershipService
 		Team() TeamService
+		TemplateSource() TemplateSourceService
 		TunnelServer() TunnelServerService
 		User() UserService
 		Version() VersionService
@@ -46,6 +49,8 @@ type (
 		CheckCurrentEdition() error
 		Backup(path string) (string, error)
 		Export(filename string) (err error)
+		RotateEncryptionKey(newKey []byte) (string, error)
+		Maintain(repair, compact bool) (*IntegrityReport, error)
 
 		DataStoreTx
 	}
@@ -159,6 +164,9 @@ type (
 
 	SnapshotService interface {
 		BaseCRUD[portainer.Snapshot, portainer.EndpointID]
+		// ReadRaw returns the raw Docker data of the snapshot of an environment(endpoint),
+		// it is not part of the snapshots returned by Read and ReadAll
+		ReadRaw(ID portainer.EndpointID) (*portainer.DockerSnapshotRaw, error)
 	}
 
 	// SSLSettingsService represents a service for managing application settings
@@ -176,6 +184,8 @@ type (
 		GetNextIdentifier() int
 		StackByWebhookID(ID string) (*portainer.Stack, error)
 		RefreshableStacks() ([]portainer.Stack, error)
+		ImageWatchedStacks() ([]portainer.Stack, error)
+		DriftCheckedStacks() ([]portainer.Stack, error)
 	}
 
 	// TagService represents a service for managing tag data
@@ -190,6 +200,11 @@ type (
 		TeamByName(name string) (*portainer.Team, error)
 	}
 
+	// TemplateSourceService represents a service for managing application template sources
+	TemplateSourceService interface {
+		BaseCRUD[portainer.TemplateSource, portainer.TemplateSourceID]
+	}
+
 	// TeamMembershipService represents a service for managing team membership data
 	TeamMembershipService interface {
 		BaseCRUD[portainer.TeamMembership, portainer.TeamMembershipID]
@@ -0,0 +1,60 @@
+package snapshot
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/segmentio/encoding/json"
+)
+
+// RawData is the object stored in the raw bucket, it holds the raw Docker data
+// of a snapshot encoded as JSON and compressed with zstd
+type RawData struct {
+	EndpointID portainer.EndpointID `json:"EndpointId"`
+	Data       []byte               `json:"Data"`
+}
+
+var (
+	encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	decoder, _ = zstd.NewReader(nil)
+)
+
+// split returns a copy of the snapshot without the raw Docker data, along
+// with the compressed raw data when there is some
+func split(snapshot *portainer.Snapshot) (*portainer.Snapshot, *RawData, error) {
+	if snapshot.Docker == nil || snapshot.Docker.SnapshotRaw == nil {
+		return snapshot, nil, nil
+	}
+
+	data, err := json.Marshal(snapshot.Docker.SnapshotRaw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to encode the raw snapshot data: %w", err)
+	}
+
+	docker := *snapshot.Docker
+	docker.SnapshotRaw = nil
+
+	summary := *snapshot
+	summary.Docker = &docker
+
+	return &summary, &RawData{
+		EndpointID: snapshot.EndpointID,
+		Data:       encoder.EncodeAll(data, nil),
+	}, nil
+}
+
+func (raw *RawData) decompress() (*portainer.DockerSnapshotRaw, error) {
+	data, err := decoder.DecodeAll(raw.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress the raw snapshot data: %w", err)
+	}
+
+	var snapshotRaw portainer.DockerSnapshotRaw
+	if err := json.Unmarshal(data, &snapshotRaw); err != nil {
+		return nil, fmt.Errorf("unable to decode the raw snapshot data: %w", err)
+	}
+
+	return &snapshotRaw, nil
+}
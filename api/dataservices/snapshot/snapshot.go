@@ -5,7 +5,11 @@ import (
 	"github.com/portainer/portainer/api/dataservices"
 )
 
-const BucketName = "snapshots"
+const (
+	BucketName = "snapshots"
+	// RawBucketName is the bucket holding the compressed raw Docker data of the snapshots
+	RawBucketName = "snapshot_raw"
+)
 
 type Service struct {
 	dataservices.BaseDataService[portainer.Snapshot, portainer.EndpointID]
@@ -17,6 +21,10 @@ func NewService(connection portainer.Connection) (*Service, error) {
 		return nil, err
 	}
 
+	if err := connection.SetServiceName(RawBucketName); err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		BaseDataService: dataservices.BaseDataService[portainer.Snapshot, portainer.EndpointID]{
 			Bucket:     BucketName,
@@ -35,6 +43,36 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 	}
 }
 
+// Create stores the snapshot of an environment(endpoint), the raw Docker data is
+// compressed and stored separately so that it is only decoded by ReadRaw
 func (service *Service) Create(snapshot *portainer.Snapshot) error {
-	return service.Connection.CreateObjectWithId(BucketName, int(snapshot.EndpointID), snapshot)
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Create(snapshot)
+	})
+}
+
+// Update replaces the snapshot of an environment(endpoint), see Create
+func (service *Service) Update(ID portainer.EndpointID, snapshot *portainer.Snapshot) error {
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Update(ID, snapshot)
+	})
+}
+
+// Delete removes the snapshot of an environment(endpoint) along with its raw Docker data
+func (service *Service) Delete(ID portainer.EndpointID) error {
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Delete(ID)
+	})
+}
+
+// ReadRaw returns the raw Docker data of the snapshot of an environment(endpoint)
+func (service *Service) ReadRaw(ID portainer.EndpointID) (*portainer.DockerSnapshotRaw, error) {
+	var raw *portainer.DockerSnapshotRaw
+
+	return raw, service.Connection.ViewTx(func(tx portainer.Transaction) error {
+		var err error
+		raw, err = service.Tx(tx).ReadRaw(ID)
+
+		return err
+	})
 }
@@ -9,6 +9,54 @@ type ServiceTx struct {
 	dataservices.BaseDataServiceTx[portainer.Snapshot, portainer.EndpointID]
 }
 
+// Create stores the snapshot of an environment(endpoint), replacing the previous one
+// and its raw Docker data
 func (service ServiceTx) Create(snapshot *portainer.Snapshot) error {
-	return service.Tx.CreateObjectWithId(BucketName, int(snapshot.EndpointID), snapshot)
+	return service.write(snapshot.EndpointID, snapshot, true)
+}
+
+// Update replaces the snapshot of an environment(endpoint). The stored raw Docker
+// data is kept when the snapshot does not carry any, as with the values returned by Read
+func (service ServiceTx) Update(ID portainer.EndpointID, snapshot *portainer.Snapshot) error {
+	return service.write(ID, snapshot, false)
+}
+
+func (service ServiceTx) write(ID portainer.EndpointID, snapshot *portainer.Snapshot, replaceRaw bool) error {
+	summary, raw, err := split(snapshot)
+	if err != nil {
+		return err
+	}
+
+	key := service.Connection.ConvertToKey(int(ID))
+
+	switch {
+	case raw != nil:
+		err = service.Tx.UpdateObject(RawBucketName, key, raw)
+	case replaceRaw || snapshot.Docker == nil:
+		err = service.Tx.DeleteObject(RawBucketName, key)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return service.Tx.UpdateObject(BucketName, key, summary)
+}
+
+func (service ServiceTx) Delete(ID portainer.EndpointID) error {
+	if err := service.Tx.DeleteObject(RawBucketName, service.Connection.ConvertToKey(int(ID))); err != nil {
+		return err
+	}
+
+	return service.BaseDataServiceTx.Delete(ID)
+}
+
+func (service ServiceTx) ReadRaw(ID portainer.EndpointID) (*portainer.DockerSnapshotRaw, error) {
+	var data RawData
+
+	if err := service.Tx.GetObject(RawBucketName, service.Connection.ConvertToKey(int(ID)), &data); err != nil {
+		return nil, err
+	}
+
+	return data.decompress()
 }
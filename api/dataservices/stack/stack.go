@@ -120,3 +120,29 @@ func (service *Service) RefreshableStacks() ([]portainer.Stack, error) {
 		}),
 	)
 }
+
+// ImageWatchedStacks returns stacks that are configured to check their images periodically
+func (service *Service) ImageWatchedStacks() ([]portainer.Stack, error) {
+	stacks := make([]portainer.Stack, 0)
+
+	return stacks, service.Connection.GetAll(
+		BucketName,
+		&portainer.Stack{},
+		dataservices.FilterFn(&stacks, func(e portainer.Stack) bool {
+			return e.ImageWatch != nil && e.ImageWatch.Interval != ""
+		}),
+	)
+}
+
+// DriftCheckedStacks returns stacks that are configured to check their drift periodically
+func (service *Service) DriftCheckedStacks() ([]portainer.Stack, error) {
+	stacks := make([]portainer.Stack, 0)
+
+	return stacks, service.Connection.GetAll(
+		BucketName,
+		&portainer.Stack{},
+		dataservices.FilterFn(&stacks, func(e portainer.Stack) bool {
+			return e.DriftCheck != nil && e.DriftCheck.Interval != ""
+		}),
+	)
+}
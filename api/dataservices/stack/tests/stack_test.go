@@ -101,3 +101,43 @@ func Test_RefreshableStacks(t *testing.T) {
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []portainer.Stack{refreshableStack}, stacks)
 }
+
+func Test_ImageWatchedStacks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode. Normally takes ~1s to run.")
+	}
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	staticStack := portainer.Stack{ID: 1}
+	disabledStack := portainer.Stack{ID: 2, ImageWatch: &portainer.StackImageWatchSettings{}}
+	watchedStack := portainer.Stack{ID: 3, ImageWatch: &portainer.StackImageWatchSettings{Interval: "1h"}}
+
+	for _, stack := range []*portainer.Stack{&staticStack, &disabledStack, &watchedStack} {
+		err := store.Stack().Create(stack)
+		assert.NoError(t, err)
+	}
+
+	stacks, err := store.Stack().ImageWatchedStacks()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []portainer.Stack{watchedStack}, stacks)
+}
+
+func Test_DriftCheckedStacks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode. Normally takes ~1s to run.")
+	}
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	staticStack := portainer.Stack{ID: 1}
+	disabledStack := portainer.Stack{ID: 2, DriftCheck: &portainer.StackDriftCheckSettings{}}
+	checkedStack := portainer.Stack{ID: 3, DriftCheck: &portainer.StackDriftCheckSettings{Interval: "15m"}}
+
+	for _, stack := range []*portainer.Stack{&staticStack, &disabledStack, &checkedStack} {
+		err := store.Stack().Create(stack)
+		assert.NoError(t, err)
+	}
+
+	stacks, err := store.Stack().DriftCheckedStacks()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []portainer.Stack{checkedStack}, stacks)
+}
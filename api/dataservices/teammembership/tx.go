@@ -56,7 +56,7 @@ func (service ServiceTx) DeleteTeamMembershipByUserID(userID portainer.UserID) e
 		BucketName,
 		&portainer.TeamMembership{},
 		func(obj any) (id int, ok bool) {
-			membership, ok := obj.(portainer.TeamMembership)
+			membership, ok := obj.(*portainer.TeamMembership)
 			if !ok {
 				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
 				//return fmt.Errorf("Failed to convert to TeamMembership object: %s", obj)
@@ -77,7 +77,7 @@ func (service ServiceTx) DeleteTeamMembershipByTeamID(teamID portainer.TeamID) e
 		BucketName,
 		&portainer.TeamMembership{},
 		func(obj any) (id int, ok bool) {
-			membership, ok := obj.(portainer.TeamMembership)
+			membership, ok := obj.(*portainer.TeamMembership)
 			if !ok {
 				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
 				//return fmt.Errorf("Failed to convert to TeamMembership object: %s", obj)
@@ -97,7 +97,7 @@ func (service ServiceTx) DeleteTeamMembershipByTeamIDAndUserID(teamID portainer.
 		BucketName,
 		&portainer.TeamMembership{},
 		func(obj any) (id int, ok bool) {
-			membership, ok := obj.(portainer.TeamMembership)
+			membership, ok := obj.(*portainer.TeamMembership)
 			if !ok {
 				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
 				//return fmt.Errorf("Failed to convert to TeamMembership object: %s", obj)
@@ -110,6 +110,11 @@ func (store *Store) edition() portainer.SoftwareEdition {
 	return edition
 }
 
+// OnCommit calls fn right away, the operations made outside of a transaction are committed one by one
+func (store *Store) OnCommit(fn func()) {
+	store.connection.OnCommit(fn)
+}
+
 // TODO: move the use of this to dataservices.IsErrObjectNotFound()?
 func (store *Store) IsErrObjectNotFound(e error) bool {
 	return errors.Is(e, portainerErrors.ErrObjectNotFound)
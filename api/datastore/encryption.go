@@ -0,0 +1,38 @@
+package datastore
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RotateEncryptionKey re-encrypts the whole database with newKey.
+// A backup of the database, still encrypted with the current key, is taken
+// before the rotation and its path is returned. The rotation either succeeds
+// for every bucket or leaves the database untouched.
+func (store *Store) RotateEncryptionKey(newKey []byte) (string, error) {
+	if !store.connection.IsEncryptedStore() {
+		return "", dserrors.ErrNotEncrypted
+	}
+
+	backupFilename := path.Join(store.connection.GetStorePath(), "backups", fmt.Sprintf("%s.%d.bak", store.connection.GetDatabaseFileName(), time.Now().Unix()))
+
+	backupFilename, err := store.Backup(backupFilename)
+	if err != nil {
+		return "", fmt.Errorf("failed to backup database prior to rotating the encryption key: %w", err)
+	}
+
+	log.Info().Str("backup", backupFilename).Msg("rotating database encryption key")
+
+	if err := store.connection.RotateEncryptionKey(newKey); err != nil {
+		return backupFilename, fmt.Errorf("failed to rotate the database encryption key: %w", err)
+	}
+
+	log.Info().Str("backup", backupFilename).Msg("database encryption key rotated, the backup is still encrypted with the previous key")
+
+	return backupFilename, nil
+}
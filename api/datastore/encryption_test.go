@@ -0,0 +1,46 @@
+package datastore
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRotateEncryptionKey(t *testing.T) {
+	_, store := MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+
+	hash := sha256.Sum256([]byte("new secret"))
+	newKey := hash[:]
+
+	backupPath, err := store.RotateEncryptionKey(newKey)
+	require.NoError(t, err)
+	require.FileExists(t, backupPath)
+
+	// The store keeps working with the new key, including after a restart
+	user, err := store.User().UserByUsername("admin")
+	require.NoError(t, err)
+	require.Equal(t, portainer.AdministratorRole, user.Role)
+
+	require.NoError(t, store.Close())
+
+	_, err = store.Open()
+	require.NoError(t, err)
+
+	_, err = store.User().UserByUsername("admin")
+	require.NoError(t, err)
+}
+
+func TestStoreRotateEncryptionKey_NotEncrypted(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+
+	hash := sha256.Sum256([]byte("new secret"))
+
+	_, err := store.RotateEncryptionKey(hash[:])
+	require.ErrorIs(t, err, dserrors.ErrNotEncrypted)
+}
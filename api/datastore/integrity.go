@@ -0,0 +1,396 @@
+package datastore
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/dataservices/apikeyrepository"
+	"github.com/portainer/portainer/api/dataservices/customtemplate"
+	"github.com/portainer/portainer/api/dataservices/dockerhub"
+	"github.com/portainer/portainer/api/dataservices/edgegroup"
+	"github.com/portainer/portainer/api/dataservices/edgejob"
+	"github.com/portainer/portainer/api/dataservices/edgestack"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/endpointgroup"
+	"github.com/portainer/portainer/api/dataservices/endpointrelation"
+	"github.com/portainer/portainer/api/dataservices/extension"
+	"github.com/portainer/portainer/api/dataservices/helmuserrepository"
+	"github.com/portainer/portainer/api/dataservices/pendingactions"
+	"github.com/portainer/portainer/api/dataservices/registry"
+	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
+	"github.com/portainer/portainer/api/dataservices/role"
+	"github.com/portainer/portainer/api/dataservices/schedule"
+	"github.com/portainer/portainer/api/dataservices/settings"
+	"github.com/portainer/portainer/api/dataservices/snapshot"
+	"github.com/portainer/portainer/api/dataservices/ssl"
+	"github.com/portainer/portainer/api/dataservices/stack"
+	"github.com/portainer/portainer/api/dataservices/tag"
+	"github.com/portainer/portainer/api/dataservices/team"
+	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/dataservices/templatesource"
+	"github.com/portainer/portainer/api/dataservices/tunnelserver"
+	"github.com/portainer/portainer/api/dataservices/user"
+	"github.com/portainer/portainer/api/dataservices/webhook"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+// bucketModels returns a new instance of the model stored in each bucket.
+// The version bucket holds raw strings and is not decoded.
+var bucketModels = map[string]func() any{
+	apikeyrepository.BucketName:   func() any { return &portainer.APIKey{} },
+	customtemplate.BucketName:     func() any { return &portainer.CustomTemplate{} },
+	dockerhub.BucketName:          func() any { return &portainer.DockerHub{} },
+	edgegroup.BucketName:          func() any { return &portainer.EdgeGroup{} },
+	edgejob.BucketName:            func() any { return &portainer.EdgeJob{} },
+	edgestack.BucketName:          func() any { return &portainer.EdgeStack{} },
+	endpoint.BucketName:           func() any { return &portainer.Endpoint{} },
+	endpointgroup.BucketName:      func() any { return &portainer.EndpointGroup{} },
+	endpointrelation.BucketName:   func() any { return &portainer.EndpointRelation{} },
+	extension.BucketName:          func() any { return &portainer.Extension{} },
+	helmuserrepository.BucketName: func() any { return &portainer.HelmUserRepository{} },
+	pendingactions.BucketName:     func() any { return &portainer.PendingAction{} },
+	registry.BucketName:           func() any { return &portainer.Registry{} },
+	resourcecontrol.BucketName:    func() any { return &portainer.ResourceControl{} },
+	role.BucketName:               func() any { return &portainer.Role{} },
+	schedule.BucketName:           func() any { return &portainer.Schedule{} },
+	settings.BucketName:           func() any { return &portainer.Settings{} },
+	snapshot.BucketName:           func() any { return &portainer.Snapshot{} },
+	snapshot.RawBucketName:        func() any { return &snapshot.RawData{} },
+	ssl.BucketName:                func() any { return &portainer.SSLSettings{} },
+	stack.BucketName:              func() any { return &portainer.Stack{} },
+	tag.BucketName:                func() any { return &portainer.Tag{} },
+	team.BucketName:               func() any { return &portainer.Team{} },
+	teammembership.BucketName:     func() any { return &portainer.TeamMembership{} },
+	templatesource.BucketName:     func() any { return &portainer.TemplateSource{} },
+	tunnelserver.BucketName:       func() any { return &portainer.TunnelServerInfo{} },
+	user.BucketName:               func() any { return &portainer.User{} },
+	webhook.BucketName:            func() any { return &portainer.Webhook{} },
+}
+
+// Maintain checks that every object of the store decodes into its model and
+// looks for references to objects that no longer exist. The orphaned
+// references are removed when repair is set and the database file is rewritten
+// into a fresh one when compact is set. Like Backup, the database is closed and
+// reopened while it is compacted, the offline gate must be held while the server runs.
+func (store *Store) Maintain(repair, compact bool) (*dataservices.IntegrityReport, error) {
+	size, err := store.connection.GetDatabaseFileSize()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dataservices.IntegrityReport{DatabaseSize: size}
+
+	if report.Buckets, err = store.checkBuckets(); err != nil {
+		return report, err
+	}
+
+	txFn := store.ViewTx
+	if repair {
+		txFn = store.UpdateTx
+	}
+
+	if err := txFn(func(tx dataservices.DataStoreTx) error {
+		report.Orphans, err = findOrphans(tx, store.fileService, repair)
+
+		return err
+	}); err != nil {
+		return report, fmt.Errorf("unable to check the orphaned references: %w", err)
+	}
+
+	if !compact {
+		return report, nil
+	}
+
+	if err := store.connection.Compact(); err != nil {
+		return report, err
+	}
+
+	if report.CompactedDatabaseSize, err = store.connection.GetDatabaseFileSize(); err != nil {
+		return report, err
+	}
+
+	log.Info().
+		Int64("before", report.DatabaseSize).
+		Int64("after", report.CompactedDatabaseSize).
+		Msg("database compacted")
+
+	return report, nil
+}
+
+// checkBuckets decodes every object of every bucket into its model
+func (store *Store) checkBuckets() ([]dataservices.BucketIntegrity, error) {
+	metadata, err := store.connection.BackupMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	buckets := make([]dataservices.BucketIntegrity, 0, len(names))
+
+	for _, name := range names {
+		bucket := dataservices.BucketIntegrity{Name: name}
+
+		newModel, ok := bucketModels[name]
+
+		if err := store.connection.ViewTx(func(tx portainer.Transaction) error {
+			// Unmarshalling into a string returns the raw decrypted payload
+			return tx.GetAll(name, new(string), func(o any) (any, error) {
+				bucket.Objects++
+
+				if !ok {
+					return o, nil
+				}
+
+				raw := []byte(*o.(*string))
+				if err := json.Unmarshal(raw, newModel()); err != nil {
+					bucket.Errors = append(bucket.Errors, fmt.Sprintf("object %s: %s", objectID(raw, bucket.Objects), err))
+				}
+
+				return o, nil
+			})
+		}); err != nil {
+			bucket.Errors = append(bucket.Errors, err.Error())
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// objectID returns the identifier of a raw object, or its position in the
+// bucket when it doesn't have one
+func objectID(raw []byte, position int) string {
+	var object struct {
+		ID json.RawMessage
+	}
+
+	if err := json.Unmarshal(raw, &object); err == nil && len(object.ID) > 0 {
+		return string(object.ID)
+	}
+
+	return "#" + strconv.Itoa(position)
+}
+
+func findOrphans(tx dataservices.DataStoreTx, fileService portainer.FileService, repair bool) ([]dataservices.OrphanedReference, error) {
+	endpoints, err := tx.Endpoint().Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	endpointIDs := make(map[portainer.EndpointID]bool, len(endpoints))
+	for _, e := range endpoints {
+		endpointIDs[e.ID] = true
+	}
+
+	orphans, err := findOrphanedStacks(tx, fileService, endpointIDs, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceControls, err := findOrphanedResourceControls(tx, repair)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, resourceControls...)
+
+	relations, err := findOrphanedEndpointRelations(tx, endpointIDs, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(orphans, relations...), nil
+}
+
+// findOrphanedStacks looks for the stacks deployed on a deleted environment.
+// The project directory of a removed stack is deleted once the repair is committed.
+func findOrphanedStacks(tx dataservices.DataStoreTx, fileService portainer.FileService, endpointIDs map[portainer.EndpointID]bool, repair bool) ([]dataservices.OrphanedReference, error) {
+	stacks, err := tx.Stack().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []dataservices.OrphanedReference
+
+	for _, s := range stacks {
+		if endpointIDs[s.EndpointID] {
+			continue
+		}
+
+		orphan := dataservices.OrphanedReference{
+			Bucket:    stack.BucketName,
+			ID:        strconv.Itoa(int(s.ID)),
+			Reference: fmt.Sprintf("environment %d", s.EndpointID),
+		}
+
+		if repair {
+			if err := tx.Stack().Delete(s.ID); err != nil {
+				return nil, err
+			}
+
+			if projectPath := s.ProjectPath; projectPath != "" {
+				tx.OnCommit(func() {
+					if err := fileService.RemoveDirectory(projectPath); err != nil {
+						log.Warn().Err(err).Str("path", projectPath).Msg("unable to remove the project directory of an orphaned stack")
+					}
+				})
+			}
+
+			orphan.Repaired = true
+		}
+
+		orphans = append(orphans, orphan)
+	}
+
+	return orphans, nil
+}
+
+// findOrphanedResourceControls looks for the resource controls of deleted
+// stacks and custom templates. The other resources live in the environments
+// and cannot be checked from the database.
+func findOrphanedResourceControls(tx dataservices.DataStoreTx, repair bool) ([]dataservices.OrphanedReference, error) {
+	resourceControls, err := tx.ResourceControl().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	stacks, err := tx.Stack().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := tx.CustomTemplate().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIDs := map[portainer.ResourceControlType]map[string]bool{
+		portainer.StackResourceControl:          {},
+		portainer.CustomTemplateResourceControl: {},
+	}
+
+	for _, s := range stacks {
+		resourceIDs[portainer.StackResourceControl][stackutils.ResourceControlID(s.EndpointID, s.Name)] = true
+		// Resource controls created before the environment prefix only use the stack name
+		resourceIDs[portainer.StackResourceControl][s.Name] = true
+	}
+
+	for _, t := range templates {
+		resourceIDs[portainer.CustomTemplateResourceControl][strconv.Itoa(int(t.ID))] = true
+	}
+
+	var orphans []dataservices.OrphanedReference
+
+	for _, rc := range resourceControls {
+		ids, ok := resourceIDs[rc.Type]
+		if !ok || ids[rc.ResourceID] {
+			continue
+		}
+
+		reference := "stack " + rc.ResourceID
+		if rc.Type == portainer.CustomTemplateResourceControl {
+			reference = "custom template " + rc.ResourceID
+		}
+
+		orphan := dataservices.OrphanedReference{
+			Bucket:    resourcecontrol.BucketName,
+			ID:        strconv.Itoa(int(rc.ID)),
+			Reference: reference,
+		}
+
+		if repair {
+			if err := tx.ResourceControl().Delete(rc.ID); err != nil {
+				return nil, err
+			}
+
+			orphan.Repaired = true
+		}
+
+		orphans = append(orphans, orphan)
+	}
+
+	return orphans, nil
+}
+
+// findOrphanedEndpointRelations looks for the relations of deleted
+// environments and the relations to deleted edge stacks
+func findOrphanedEndpointRelations(tx dataservices.DataStoreTx, endpointIDs map[portainer.EndpointID]bool, repair bool) ([]dataservices.OrphanedReference, error) {
+	relations, err := tx.EndpointRelation().EndpointRelations()
+	if err != nil {
+		return nil, err
+	}
+
+	edgeStacks, err := tx.EdgeStack().EdgeStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	edgeStackIDs := make(map[portainer.EdgeStackID]bool, len(edgeStacks))
+	for _, s := range edgeStacks {
+		edgeStackIDs[s.ID] = true
+	}
+
+	var orphans []dataservices.OrphanedReference
+
+	for _, relation := range relations {
+		id := strconv.Itoa(int(relation.EndpointID))
+
+		if !endpointIDs[relation.EndpointID] {
+			orphan := dataservices.OrphanedReference{
+				Bucket:    endpointrelation.BucketName,
+				ID:        id,
+				Reference: fmt.Sprintf("environment %d", relation.EndpointID),
+			}
+
+			if repair {
+				if err := tx.EndpointRelation().DeleteEndpointRelation(relation.EndpointID); err != nil {
+					return nil, err
+				}
+
+				orphan.Repaired = true
+			}
+
+			orphans = append(orphans, orphan)
+
+			continue
+		}
+
+		var missing []portainer.EdgeStackID
+		for edgeStackID := range relation.EdgeStacks {
+			if !edgeStackIDs[edgeStackID] {
+				missing = append(missing, edgeStackID)
+			}
+		}
+		slices.Sort(missing)
+
+		for _, edgeStackID := range missing {
+			orphans = append(orphans, dataservices.OrphanedReference{
+				Bucket:    endpointrelation.BucketName,
+				ID:        id,
+				Reference: fmt.Sprintf("edge stack %d", edgeStackID),
+				Repaired:  repair,
+			})
+
+			delete(relation.EdgeStacks, edgeStackID)
+		}
+
+		if repair && len(missing) > 0 {
+			if err := tx.EndpointRelation().UpdateEndpointRelation(relation.EndpointID, &relation); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return orphans, nil
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreMaintain(t *testing.T) {
+	_, store := MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+	require.NoError(t, store.EdgeStack().Create(1, &portainer.EdgeStack{ID: 1}))
+
+	require.NoError(t, store.Stack().Create(&portainer.Stack{ID: 1, Name: "app", EndpointID: 1}))
+	projectPath := filepath.Join(t.TempDir(), "compose", "2")
+	require.NoError(t, os.MkdirAll(projectPath, 0700))
+	require.NoError(t, store.Stack().Create(&portainer.Stack{ID: 2, Name: "gone", EndpointID: 2, ProjectPath: projectPath}))
+
+	require.NoError(t, store.ResourceControl().Create(&portainer.ResourceControl{ID: 1, ResourceID: stackutils.ResourceControlID(1, "app"), Type: portainer.StackResourceControl}))
+	require.NoError(t, store.ResourceControl().Create(&portainer.ResourceControl{ID: 2, ResourceID: "5", Type: portainer.CustomTemplateResourceControl}))
+	require.NoError(t, store.ResourceControl().Create(&portainer.ResourceControl{ID: 3, ResourceID: "container", Type: portainer.ContainerResourceControl}))
+
+	require.NoError(t, store.EndpointRelation().Create(&portainer.EndpointRelation{EndpointID: 1, EdgeStacks: map[portainer.EdgeStackID]bool{1: true, 4: true}}))
+	require.NoError(t, store.EndpointRelation().Create(&portainer.EndpointRelation{EndpointID: 3, EdgeStacks: map[portainer.EdgeStackID]bool{}}))
+
+	// An object that cannot be decoded into its model
+	require.NoError(t, store.connection.UpdateObject("tags", store.connection.ConvertToKey(7), map[string]any{"ID": 7, "Name": 12}))
+
+	report, err := store.Maintain(false, false)
+	require.NoError(t, err)
+	require.False(t, report.Healthy())
+	require.NotZero(t, report.DatabaseSize)
+	require.Zero(t, report.CompactedDatabaseSize)
+
+	for _, bucket := range report.Buckets {
+		if bucket.Name == "tags" {
+			require.Len(t, bucket.Errors, 1)
+			require.Contains(t, bucket.Errors[0], "object 7")
+		} else {
+			require.Empty(t, bucket.Errors, bucket.Name)
+		}
+	}
+
+	expected := []dataservices.OrphanedReference{
+		{Bucket: "stacks", ID: "2", Reference: "environment 2"},
+		{Bucket: "resource_control", ID: "2", Reference: "custom template 5"},
+		{Bucket: "endpoint_relations", ID: "1", Reference: "edge stack 4"},
+		{Bucket: "endpoint_relations", ID: "3", Reference: "environment 3"},
+	}
+	require.Equal(t, expected, report.Orphans)
+	require.DirExists(t, projectPath, "the project directory is only removed by a repair")
+
+	report, err = store.Maintain(true, true)
+	require.NoError(t, err)
+	require.NotZero(t, report.CompactedDatabaseSize)
+
+	for i := range expected {
+		expected[i].Repaired = true
+	}
+	require.Equal(t, expected, report.Orphans)
+
+	_, err = store.Stack().Read(2)
+	require.True(t, store.IsErrObjectNotFound(err))
+	require.NoDirExists(t, projectPath)
+
+	relation, err := store.EndpointRelation().EndpointRelation(1)
+	require.NoError(t, err)
+	require.Equal(t, map[portainer.EdgeStackID]bool{1: true}, relation.EdgeStacks)
+
+	// The store is still usable after being compacted
+	report, err = store.Maintain(false, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Orphans)
+}
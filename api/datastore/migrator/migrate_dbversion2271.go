@@ -0,0 +1,28 @@
+package migrator
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// moveSnapshotRawData_2_27_1 moves the raw Docker data out of the snapshots into
+// the compressed raw bucket, the snapshot service splits it when the snapshot is written
+func (migrator *Migrator) moveSnapshotRawData_2_27_1() error {
+	log.Info().Msg("moving the raw data of the snapshots")
+
+	snapshots, err := migrator.snapshotService.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Docker == nil || snapshot.Docker.SnapshotRaw == nil {
+			continue
+		}
+
+		if err := migrator.snapshotService.Update(snapshot.EndpointID, &snapshot); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
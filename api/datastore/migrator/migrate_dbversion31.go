@@ -214,13 +214,12 @@ func (m *Migrator) updateVolumeResourceControlToDB32() error {
 			continue
 		}
 
-		volumesData := snapshot.SnapshotRaw.Volumes
-		if volumesData.Volumes == nil {
+		if snapshot.SnapshotRaw == nil || snapshot.SnapshotRaw.Volumes.Volumes == nil {
 			log.Debug().Msg("no volume data found")
 			continue
 		}
 
-		findResourcesToUpdateForDB32(endpointDockerID, volumesData, toUpdate, volumeResourceControls)
+		findResourcesToUpdateForDB32(endpointDockerID, snapshot.SnapshotRaw.Volumes, toUpdate, volumeResourceControls)
 
 	}
 
@@ -241,6 +241,9 @@ func (m *Migrator) initMigrations() {
 	m.addMigrations("2.22.0",
 		m.migratePendingActionsDataForDB130,
 	)
+	m.addMigrations("2.27.1",
+		m.moveSnapshotRawData_2_27_1,
+	)
 
 	// Add new migrations above...
 	// One function per migration, each versions migration funcs in the same file.
@@ -30,6 +30,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices/tag"
 	"github.com/portainer/portainer/api/dataservices/team"
 	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/dataservices/templatesource"
 	"github.com/portainer/portainer/api/dataservices/tunnelserver"
 	"github.com/portainer/portainer/api/dataservices/user"
 	"github.com/portainer/portainer/api/dataservices/version"
@@ -68,6 +69,7 @@ type Store struct {
 	TagService                *tag.Service
 	TeamMembershipService     *teammembership.Service
 	TeamService               *team.Service
+	TemplateSourceService     *templatesource.Service
 	TunnelServerService       *tunnelserver.Service
 	UserService               *user.Service
 	VersionService            *version.Service
@@ -199,6 +201,12 @@ func (store *Store) initServices() error {
 	}
 	store.TeamService = teamService
 
+	templateSourceService, err := templatesource.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.TemplateSourceService = templateSourceService
+
 	tunnelServerService, err := tunnelserver.NewService(store.connection)
 	if err != nil {
 		return err
@@ -343,6 +351,11 @@ func (store *Store) Team() dataservices.TeamService {
 	return store.TeamService
 }
 
+// TemplateSource gives access to the TemplateSource data management layer
+func (store *Store) TemplateSource() dataservices.TemplateSourceService {
+	return store.TemplateSourceService
+}
+
 // TunnelServer gives access to the TunnelServer data management layer
 func (store *Store) TunnelServer() dataservices.TunnelServerService {
 	return store.TunnelServerService
@@ -384,6 +397,7 @@ type storeExport struct {
 	Tag                []portainer.Tag                `json:"tags,omitempty"`
 	TeamMembership     []portainer.TeamMembership     `json:"team_membership,omitempty"`
 	Team               []portainer.Team               `json:"teams,omitempty"`
+	TemplateSource     []portainer.TemplateSource     `json:"template_sources,omitempty"`
 	TunnelServer       portainer.TunnelServerInfo     `json:"tunnel_server,omitempty"`
 	User               []portainer.User               `json:"users,omitempty"`
 	Version            models.Version                 `json:"version,omitempty"`
@@ -554,6 +568,14 @@ func (store *Store) Export(filename string) (err error) {
 		backup.Team = t
 	}
 
+	if t, err := store.TemplateSource().ReadAll(); err != nil {
+		if !store.IsErrObjectNotFound(err) {
+			log.Error().Err(err).Msg("exporting Template Sources")
+		}
+	} else {
+		backup.TemplateSource = t
+	}
+
 	if info, err := store.TunnelServer().Info(); err != nil {
 		if !store.IsErrObjectNotFound(err) {
 			log.Error().Err(err).Msg("exporting Tunnel Server")
@@ -680,6 +702,10 @@ func (store *Store) Import(filename string) (err error) {
 		store.Team().Update(v.ID, &v)
 	}
 
+	for _, v := range backup.TemplateSource {
+		store.TemplateSource().Update(v.ID, &v)
+	}
+
 	store.TunnelServer().UpdateInfo(&backup.TunnelServer)
 
 	for _, user := range backup.User {
@@ -14,7 +14,13 @@ func (tx *StoreTx) IsErrObjectNotFound(err error) bool {
 	return tx.store.IsErrObjectNotFound(err)
 }
 
-func (tx *StoreTx) CustomTemplate() dataservices.CustomTemplateService { return nil }
+func (tx *StoreTx) OnCommit(fn func()) {
+	tx.tx.OnCommit(fn)
+}
+
+func (tx *StoreTx) CustomTemplate() dataservices.CustomTemplateService {
+	return tx.store.CustomTemplateService.Tx(tx.tx)
+}
 
 func (tx *StoreTx) PendingActions() dataservices.PendingActionsService {
 	return tx.store.PendingActionsService.Tx(tx.tx)
@@ -58,7 +64,9 @@ func (tx *StoreTx) Role() dataservices.RoleService {
 	return tx.store.RoleService.Tx(tx.tx)
 }
 
-func (tx *StoreTx) APIKeyRepository() dataservices.APIKeyRepository { return nil }
+func (tx *StoreTx) APIKeyRepository() dataservices.APIKeyRepository {
+	return tx.store.APIKeyRepositoryService.Tx(tx.tx)
+}
 
 func (tx *StoreTx) Settings() dataservices.SettingsService {
 	return tx.store.SettingsService.Tx(tx.tx)
@@ -86,6 +94,10 @@ func (tx *StoreTx) Team() dataservices.TeamService {
 	return tx.store.TeamService.Tx(tx.tx)
 }
 
+func (tx *StoreTx) TemplateSource() dataservices.TemplateSourceService {
+	return tx.store.TemplateSourceService.Tx(tx.tx)
+}
+
 func (tx *StoreTx) TunnelServer() dataservices.TunnelServerService { return nil }
 
 func (tx *StoreTx) User() dataservices.UserService {
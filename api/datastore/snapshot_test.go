@@ -0,0 +1,72 @@
+package datastore
+
+import (
+	"fmt"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/snapshot"
+
+	"github.com/docker/docker/api/types"
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRawData(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+
+	raw := &portainer.DockerSnapshotRaw{}
+	for i := range 500 {
+		raw.Containers = append(raw.Containers, portainer.DockerContainerSnapshot{
+			Container: types.Container{
+				ID:     fmt.Sprintf("%064d", i),
+				Names:  []string{fmt.Sprintf("/web-%d", i)},
+				Image:  "nginx:latest",
+				State:  "running",
+				Status: "Up 2 hours",
+				Labels: map[string]string{"com.docker.compose.project": "web"},
+			},
+		})
+	}
+	raw.Info.Architecture = "aarch64"
+
+	require.NoError(t, store.Snapshot().Create(&portainer.Snapshot{
+		EndpointID: 1,
+		Docker:     &portainer.DockerSnapshot{ContainerCount: 500, SnapshotRaw: raw},
+	}))
+
+	s, err := store.Snapshot().Read(1)
+	require.NoError(t, err)
+	require.Equal(t, 500, s.Docker.ContainerCount)
+	require.Nil(t, s.Docker.SnapshotRaw, "the raw data must only be loaded on demand")
+
+	got, err := store.Snapshot().ReadRaw(1)
+	require.NoError(t, err)
+	require.Equal(t, raw, got)
+
+	var stored snapshot.RawData
+	require.NoError(t, store.connection.GetObject(snapshot.RawBucketName, store.connection.ConvertToKey(1), &stored))
+
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+	require.Less(t, len(stored.Data)*10, len(data), "the raw data must be compressed")
+
+	// updating the summary keeps the raw data
+	s.Docker.ContainerCount = 499
+	require.NoError(t, store.Snapshot().Update(1, s))
+
+	_, err = store.Snapshot().ReadRaw(1)
+	require.NoError(t, err)
+
+	// a new snapshot without raw data replaces it
+	require.NoError(t, store.Snapshot().Create(&portainer.Snapshot{EndpointID: 1, Kubernetes: &portainer.KubernetesSnapshot{}}))
+
+	_, err = store.Snapshot().ReadRaw(1)
+	require.True(t, store.IsErrObjectNotFound(err))
+
+	require.NoError(t, store.Snapshot().Create(&portainer.Snapshot{EndpointID: 2, Docker: &portainer.DockerSnapshot{SnapshotRaw: raw}}))
+	require.NoError(t, store.Snapshot().Delete(2))
+
+	_, err = store.Snapshot().ReadRaw(2)
+	require.True(t, store.IsErrObjectNotFound(err))
+}
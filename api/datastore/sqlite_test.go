@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database"
+	"github.com/portainer/portainer/api/filesystem"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	storePath := t.TempDir()
+	defaultKubectlShellImage := portainer.DefaultKubectlShellImage
+	flags := &portainer.CLIFlags{KubectlShellImage: &defaultKubectlShellImage}
+
+	fileService, err := filesystem.NewService(storePath, "")
+	require.NoError(t, err)
+
+	// The data is still there when the store is opened again
+	for i := range 2 {
+		connection, err := database.NewDatabase("sqlite", storePath, []byte("apassphrasewhichneedstobe32bytes"))
+		require.NoError(t, err)
+
+		store := NewStore(flags, fileService, connection)
+
+		newStore, err := store.Open()
+		require.NoError(t, err)
+		require.Equal(t, i == 0, newStore)
+		require.NoError(t, store.Init())
+
+		if newStore {
+			require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+		}
+
+		user, err := store.User().UserByUsername("admin")
+		require.NoError(t, err)
+		require.Equal(t, portainer.AdministratorRole, user.Role)
+
+		backupPath, err := store.Backup("")
+		require.NoError(t, err)
+		require.FileExists(t, backupPath)
+
+		require.NoError(t, store.Close())
+	}
+}
@@ -0,0 +1,286 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+
+	"github.com/distribution/reference"
+	"github.com/rs/zerolog/log"
+)
+
+// Workload represents the settings of a container, a service or a stack service relevant
+// to the admission policies
+type Workload struct {
+	// Name of the workload used in the violation messages
+	Name           string
+	Image          string
+	Labels         map[string]string
+	HasMemoryLimit bool
+	HasCPULimit    bool
+	// Host ports published by the workload, a single port is a range with the same start and end
+	PublishedPorts         []portainer.AdmissionPolicyPortRange
+	ReadOnlyRootFilesystem bool
+	// ResourcesOnly is set when only the resources of the workload are known, e.g. on container update
+	ResourcesOnly bool
+}
+
+// Violation represents a rule of a policy a workload does not comply with
+type Violation struct {
+	Workload string `json:"workload"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Workload == "" {
+		return v.Message
+	}
+
+	return v.Workload + ": " + v.Message
+}
+
+// ViolationsError is returned when a deployment is rejected by an admission policy
+type ViolationsError struct {
+	Violations []Violation
+}
+
+func (e *ViolationsError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.String())
+	}
+
+	return "admission policy violated: " + strings.Join(messages, "; ")
+}
+
+const (
+	RuleAllowedRegistries      = "allowedRegistries"
+	RuleForbidLatestTag        = "forbidLatestTag"
+	RuleRequireMemoryLimit     = "requireMemoryLimit"
+	RuleRequireCPULimit        = "requireCPULimit"
+	RuleRequiredLabels         = "requiredLabels"
+	RuleAllowedPublishedPorts  = "allowedPublishedPortRanges"
+	RuleReadOnlyRootFilesystem = "requireReadOnlyRootFilesystem"
+)
+
+// ValidatePolicy returns an error if the policy is inconsistent
+func ValidatePolicy(policy *portainer.AdmissionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	switch policy.Mode {
+	case "":
+		policy.Mode = portainer.AdmissionPolicyModeEnforce
+	case portainer.AdmissionPolicyModeEnforce, portainer.AdmissionPolicyModeAudit:
+	default:
+		return fmt.Errorf("invalid admission policy mode %q", policy.Mode)
+	}
+
+	for _, registry := range policy.AllowedRegistries {
+		if strings.TrimSpace(registry) == "" {
+			return errors.New("invalid empty allowed registry")
+		}
+	}
+
+	for _, label := range policy.RequiredLabels {
+		if strings.TrimSpace(label) == "" {
+			return errors.New("invalid empty required label")
+		}
+	}
+
+	for _, r := range policy.AllowedPublishedPortRanges {
+		if r.Start == 0 || r.Start > r.End {
+			return fmt.Errorf("invalid port range %d-%d", r.Start, r.End)
+		}
+	}
+
+	return nil
+}
+
+// PolicyForEndpoint returns the enabled admission policy of the group of the environment(endpoint),
+// nil is returned when none applies
+func PolicyForEndpoint(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint) (*portainer.AdmissionPolicy, error) {
+	endpointGroup, err := tx.EndpointGroup().Read(endpoint.GroupID)
+	if tx.IsErrObjectNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the environment group: %w", err)
+	}
+
+	if endpointGroup.AdmissionPolicy == nil || !endpointGroup.AdmissionPolicy.Enabled {
+		return nil, nil
+	}
+
+	return endpointGroup.AdmissionPolicy, nil
+}
+
+// Applies returns true if the policy must be evaluated for the user
+func Applies(policy *portainer.AdmissionPolicy, isAdminOrEndpointAdmin bool) bool {
+	return policy != nil && policy.Enabled && (!isAdminOrEndpointAdmin || policy.ApplyToAdministrators)
+}
+
+// Enforce evaluates the workloads against the policy. The violations are logged and, unless the policy
+// is in audit mode, returned as a *ViolationsError
+func Enforce(policy *portainer.AdmissionPolicy, endpoint *portainer.Endpoint, username string, workloads ...Workload) error {
+	if policy == nil || !policy.Enabled {
+		return nil
+	}
+
+	violations := Evaluate(policy, workloads...)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	err := &ViolationsError{Violations: violations}
+
+	audit := policy.Mode == portainer.AdmissionPolicyModeAudit
+
+	log.Warn().
+		Int("endpoint_id", int(endpoint.ID)).
+		Str("username", username).
+		Bool("audit", audit).
+		Str("violations", err.Error()).
+		Msg("admission policy violated")
+
+	if audit {
+		return nil
+	}
+
+	return err
+}
+
+// Evaluate returns the violations of the policy by the workloads
+func Evaluate(policy *portainer.AdmissionPolicy, workloads ...Workload) []Violation {
+	var violations []Violation
+
+	for _, w := range workloads {
+		violate := func(rule, format string, args ...any) {
+			violations = append(violations, Violation{Workload: w.Name, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if policy.RequireMemoryLimit && !w.HasMemoryLimit {
+			violate(RuleRequireMemoryLimit, "a memory limit is required")
+		}
+
+		if policy.RequireCPULimit && !w.HasCPULimit {
+			violate(RuleRequireCPULimit, "a CPU limit is required")
+		}
+
+		if w.ResourcesOnly {
+			continue
+		}
+
+		if w.Image != "" && (len(policy.AllowedRegistries) > 0 || policy.ForbidLatestTag) {
+			named, err := reference.ParseNormalizedNamed(w.Image)
+			if err != nil {
+				violate(RuleAllowedRegistries, "invalid image reference %q", w.Image)
+			} else {
+				if len(policy.AllowedRegistries) > 0 && !registryAllowed(named, policy.AllowedRegistries) {
+					violate(RuleAllowedRegistries, "the image %q is not pulled from an allowed registry (%s)", w.Image, strings.Join(policy.AllowedRegistries, ", "))
+				}
+
+				if policy.ForbidLatestTag && usesLatestTag(named) {
+					violate(RuleForbidLatestTag, "the image %q must be pinned to a tag other than latest", w.Image)
+				}
+			}
+		}
+
+		for _, label := range policy.RequiredLabels {
+			if _, ok := w.Labels[label]; !ok {
+				violate(RuleRequiredLabels, "the label %q is required", label)
+			}
+		}
+
+		if len(policy.AllowedPublishedPortRanges) > 0 {
+			for _, ports := range w.PublishedPorts {
+				if !portsAllowed(ports, policy.AllowedPublishedPortRanges) {
+					violate(RuleAllowedPublishedPorts, "the port %s cannot be published, allowed ranges are %s", formatRanges([]portainer.AdmissionPolicyPortRange{ports}), formatRanges(policy.AllowedPublishedPortRanges))
+				}
+			}
+		}
+
+		if policy.RequireReadOnlyRootFilesystem && !w.ReadOnlyRootFilesystem {
+			violate(RuleReadOnlyRootFilesystem, "the root filesystem must be read-only")
+		}
+	}
+
+	return violations
+}
+
+func registryAllowed(named reference.Named, allowedRegistries []string) bool {
+	name := named.Name()
+	domain := reference.Domain(named)
+
+	return slices.ContainsFunc(allowedRegistries, func(allowed string) bool {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+
+		// Docker Hub is referenced by several names
+		if allowed == "docker.io" || allowed == "index.docker.io" || allowed == "registry-1.docker.io" {
+			allowed = "docker.io"
+		}
+
+		return domain == allowed || name == allowed || strings.HasPrefix(name, allowed+"/")
+	})
+}
+
+func usesLatestTag(named reference.Named) bool {
+	if _, ok := named.(reference.Digested); ok {
+		return false
+	}
+
+	tagged, ok := named.(reference.Tagged)
+
+	return !ok || tagged.Tag() == "latest"
+}
+
+func portsAllowed(ports portainer.AdmissionPolicyPortRange, ranges []portainer.AdmissionPolicyPortRange) bool {
+	return slices.ContainsFunc(ranges, func(r portainer.AdmissionPolicyPortRange) bool {
+		return ports.Start >= r.Start && ports.End <= r.End
+	})
+}
+
+func formatRanges(ranges []portainer.AdmissionPolicyPortRange) string {
+	formatted := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Start == r.End {
+			formatted = append(formatted, strconv.Itoa(int(r.Start)))
+
+			continue
+		}
+
+		formatted = append(formatted, fmt.Sprintf("%d-%d", r.Start, r.End))
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// parsePortRange parses a host port or a host port range, an empty value means a random port
+func parsePortRange(value string) (*portainer.AdmissionPolicyPortRange, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	start, end, found := strings.Cut(value, "-")
+	if !found {
+		end = start
+	}
+
+	first, err := strconv.ParseUint(start, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host port %q", value)
+	}
+
+	last, err := strconv.ParseUint(end, 10, 16)
+	if err != nil || last < first {
+		return nil, fmt.Errorf("invalid host port %q", value)
+	}
+
+	return &portainer.AdmissionPolicyPortRange{Start: uint16(first), End: uint16(last)}, nil
+}
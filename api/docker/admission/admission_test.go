@@ -0,0 +1,210 @@
+package admission
+
+import (
+	"context"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/compose-spec/compose-go/v2/loader"
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(violations []Violation) []string {
+	r := []string{}
+	for _, v := range violations {
+		r = append(r, v.Rule)
+	}
+
+	return r
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := &portainer.AdmissionPolicy{
+		Enabled:                       true,
+		AllowedRegistries:             []string{"docker.io/library", "registry.example.com:5000"},
+		ForbidLatestTag:               true,
+		RequireMemoryLimit:            true,
+		RequireCPULimit:               true,
+		RequiredLabels:                []string{"team"},
+		AllowedPublishedPortRanges:    []portainer.AdmissionPolicyPortRange{{Start: 8000, End: 8999}},
+		RequireReadOnlyRootFilesystem: true,
+	}
+
+	compliant := Workload{
+		Image:                  "registry.example.com:5000/app:1.0",
+		Labels:                 map[string]string{"team": "web"},
+		HasMemoryLimit:         true,
+		HasCPULimit:            true,
+		PublishedPorts:         []portainer.AdmissionPolicyPortRange{{Start: 8080, End: 8090}},
+		ReadOnlyRootFilesystem: true,
+	}
+
+	cases := []struct {
+		name     string
+		update   func(w *Workload)
+		expected []string
+	}{
+		{
+			name:     "compliant",
+			update:   func(w *Workload) {},
+			expected: []string{},
+		},
+		{
+			name:     "official image",
+			update:   func(w *Workload) { w.Image = "nginx:1.25" },
+			expected: []string{},
+		},
+		{
+			name: "digest",
+			update: func(w *Workload) {
+				w.Image = "nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+			},
+			expected: []string{},
+		},
+		{
+			name:     "registry not allowed",
+			update:   func(w *Workload) { w.Image = "ghcr.io/org/app:1.0" },
+			expected: []string{RuleAllowedRegistries},
+		},
+		{
+			name:     "user image on docker hub",
+			update:   func(w *Workload) { w.Image = "someone/app:1.0" },
+			expected: []string{RuleAllowedRegistries},
+		},
+		{
+			name:     "implicit latest",
+			update:   func(w *Workload) { w.Image = "nginx" },
+			expected: []string{RuleForbidLatestTag},
+		},
+		{
+			name:     "explicit latest",
+			update:   func(w *Workload) { w.Image = "nginx:latest" },
+			expected: []string{RuleForbidLatestTag},
+		},
+		{
+			name: "missing limits",
+			update: func(w *Workload) {
+				w.HasMemoryLimit = false
+				w.HasCPULimit = false
+			},
+			expected: []string{RuleRequireMemoryLimit, RuleRequireCPULimit},
+		},
+		{
+			name:     "missing label",
+			update:   func(w *Workload) { w.Labels = nil },
+			expected: []string{RuleRequiredLabels},
+		},
+		{
+			name:     "port range overflowing",
+			update:   func(w *Workload) { w.PublishedPorts = []portainer.AdmissionPolicyPortRange{{Start: 8990, End: 9010}} },
+			expected: []string{RuleAllowedPublishedPorts},
+		},
+		{
+			name:     "writable root filesystem",
+			update:   func(w *Workload) { w.ReadOnlyRootFilesystem = false },
+			expected: []string{RuleReadOnlyRootFilesystem},
+		},
+		{
+			name: "resources only",
+			update: func(w *Workload) {
+				*w = Workload{HasMemoryLimit: true, ResourcesOnly: true}
+			},
+			expected: []string{RuleRequireCPULimit},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := compliant
+			tc.update(&w)
+
+			require.Equal(t, tc.expected, rules(Evaluate(policy, w)))
+		})
+	}
+}
+
+func TestEnforceAuditMode(t *testing.T) {
+	policy := &portainer.AdmissionPolicy{Enabled: true, Mode: portainer.AdmissionPolicyModeEnforce, ForbidLatestTag: true}
+	endpoint := &portainer.Endpoint{ID: 1}
+	w := Workload{Name: "web", Image: "nginx"}
+
+	err := Enforce(policy, endpoint, "admin", w)
+
+	var violationsErr *ViolationsError
+	require.ErrorAs(t, err, &violationsErr)
+	require.Contains(t, err.Error(), `web: the image "nginx" must be pinned`)
+
+	policy.Mode = portainer.AdmissionPolicyModeAudit
+	require.NoError(t, Enforce(policy, endpoint, "admin", w))
+}
+
+func TestFromContainerCreate(t *testing.T) {
+	body := []byte(`{
+		"Image": "nginx:1.25",
+		"Labels": {"team": "web"},
+		"HostConfig": {
+			"Memory": 268435456,
+			"NanoCpus": 500000000,
+			"ReadonlyRootfs": true,
+			"PortBindings": {"80/tcp": [{"HostPort": "8080"}, {"HostPort": ""}], "81/tcp": [{"HostPort": "9000-9010"}]}
+		}
+	}`)
+
+	w, err := FromContainerCreate("web", body)
+	require.NoError(t, err)
+
+	require.Equal(t, "nginx:1.25", w.Image)
+	require.True(t, w.HasMemoryLimit)
+	require.True(t, w.HasCPULimit)
+	require.True(t, w.ReadOnlyRootFilesystem)
+	require.ElementsMatch(t, []portainer.AdmissionPolicyPortRange{{Start: 8080, End: 8080}, {Start: 9000, End: 9010}}, w.PublishedPorts)
+}
+
+func TestFromComposeProject(t *testing.T) {
+	content := []byte(`
+services:
+  web:
+    image: nginx:${TAG}
+    read_only: true
+    labels:
+      team: web
+    ports:
+      - "8080:80"
+    deploy:
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 256M
+  worker:
+    image: redis
+  cache:
+    image: memcached:1.6
+    mem_limit: 64m
+    cpus: 0.25
+    labels:
+      team: cache
+`)
+
+	project, err := loader.LoadWithContext(context.Background(), composetypes.ConfigDetails{
+		WorkingDir:  t.TempDir(),
+		ConfigFiles: []composetypes.ConfigFile{{Filename: "docker-compose.yml", Content: content}},
+		Environment: map[string]string{"TAG": "1.25"},
+	}, func(o *loader.Options) {
+		o.SetProjectName("test", true)
+	})
+	require.NoError(t, err)
+
+	workloads, err := FromComposeProject(project)
+	require.NoError(t, err)
+	require.Len(t, workloads, 3)
+
+	policy := &portainer.AdmissionPolicy{Enabled: true, ForbidLatestTag: true, RequireMemoryLimit: true, RequiredLabels: []string{"team"}}
+
+	violations := Evaluate(policy, workloads...)
+	for _, v := range violations {
+		require.Equal(t, "worker", v.Workload)
+	}
+	require.ElementsMatch(t, []string{RuleForbidLatestTag, RuleRequireMemoryLimit, RuleRequiredLabels}, rules(violations))
+}
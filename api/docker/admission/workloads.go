@@ -0,0 +1,160 @@
+package admission
+
+import (
+	"fmt"
+	"maps"
+
+	portainer "github.com/portainer/portainer/api"
+
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/segmentio/encoding/json"
+)
+
+// FromContainerCreate returns the workload described by the body of a container create request
+func FromContainerCreate(name string, body []byte) (Workload, error) {
+	var config struct {
+		container.Config
+		HostConfig container.HostConfig
+	}
+
+	if err := json.Unmarshal(body, &config); err != nil {
+		return Workload{}, fmt.Errorf("unable to parse the container configuration: %w", err)
+	}
+
+	w := Workload{
+		Name:                   name,
+		Image:                  config.Image,
+		Labels:                 config.Labels,
+		HasMemoryLimit:         config.HostConfig.Memory > 0,
+		HasCPULimit:            hasCPULimit(config.HostConfig.Resources),
+		ReadOnlyRootFilesystem: config.HostConfig.ReadonlyRootfs,
+	}
+
+	for _, bindings := range config.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			ports, err := parsePortRange(binding.HostPort)
+			if err != nil {
+				return Workload{}, err
+			}
+
+			if ports != nil {
+				w.PublishedPorts = append(w.PublishedPorts, *ports)
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// FromContainerUpdate returns the workload described by the body of a container update request,
+// the limits left unchanged by the request are taken from the current resources of the container
+func FromContainerUpdate(name string, body []byte, current container.Resources) (Workload, error) {
+	var update container.UpdateConfig
+	if err := json.Unmarshal(body, &update); err != nil {
+		return Workload{}, fmt.Errorf("unable to parse the container update: %w", err)
+	}
+
+	resources := current
+	if update.Memory != 0 {
+		resources.Memory = update.Memory
+	}
+
+	if update.NanoCPUs != 0 {
+		resources.NanoCPUs = update.NanoCPUs
+	}
+
+	if update.CPUQuota != 0 {
+		resources.CPUQuota = update.CPUQuota
+	}
+
+	return Workload{
+		Name:           name,
+		HasMemoryLimit: resources.Memory > 0,
+		HasCPULimit:    hasCPULimit(resources),
+		ResourcesOnly:  true,
+	}, nil
+}
+
+// FromServiceSpec returns the workload described by the body of a service create or update request
+func FromServiceSpec(body []byte) (Workload, error) {
+	var spec swarm.ServiceSpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		return Workload{}, fmt.Errorf("unable to parse the service specification: %w", err)
+	}
+
+	w := Workload{
+		Name:   spec.Name,
+		Labels: map[string]string{},
+	}
+
+	maps.Copy(w.Labels, spec.Labels)
+
+	if containerSpec := spec.TaskTemplate.ContainerSpec; containerSpec != nil {
+		w.Image = containerSpec.Image
+		w.ReadOnlyRootFilesystem = containerSpec.ReadOnly
+		maps.Copy(w.Labels, containerSpec.Labels)
+	}
+
+	if resources := spec.TaskTemplate.Resources; resources != nil && resources.Limits != nil {
+		w.HasMemoryLimit = resources.Limits.MemoryBytes > 0
+		w.HasCPULimit = resources.Limits.NanoCPUs > 0
+	}
+
+	if spec.EndpointSpec != nil {
+		for _, port := range spec.EndpointSpec.Ports {
+			if port.PublishedPort > 0 {
+				w.PublishedPorts = append(w.PublishedPorts, portainer.AdmissionPolicyPortRange{Start: uint16(port.PublishedPort), End: uint16(port.PublishedPort)})
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// FromComposeProject returns the workloads described by the services of a compose project,
+// the project is expected to be loaded with all its files merged and its variables interpolated
+func FromComposeProject(project *composetypes.Project) ([]Workload, error) {
+	workloads := make([]Workload, 0, len(project.Services))
+	for _, service := range project.Services {
+		w := Workload{
+			Name:                   service.Name,
+			Image:                  service.Image,
+			Labels:                 map[string]string{},
+			HasMemoryLimit:         service.MemLimit > 0,
+			HasCPULimit:            service.CPUS > 0,
+			ReadOnlyRootFilesystem: service.ReadOnly,
+		}
+
+		if service.Deploy != nil {
+			maps.Copy(w.Labels, service.Deploy.Labels)
+
+			if limits := service.Deploy.Resources.Limits; limits != nil {
+				w.HasMemoryLimit = w.HasMemoryLimit || limits.MemoryBytes > 0
+				w.HasCPULimit = w.HasCPULimit || limits.NanoCPUs > 0
+			}
+		}
+
+		maps.Copy(w.Labels, service.Labels)
+
+		for _, port := range service.Ports {
+			ports, err := parsePortRange(port.Published)
+			if err != nil {
+				return nil, err
+			}
+
+			if ports != nil {
+				w.PublishedPorts = append(w.PublishedPorts, *ports)
+			}
+		}
+
+		workloads = append(workloads, w)
+	}
+
+	return workloads, nil
+}
+
+func hasCPULimit(resources container.Resources) bool {
+	return resources.NanoCPUs > 0 || resources.CPUQuota > 0
+}
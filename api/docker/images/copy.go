@@ -0,0 +1,363 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	imagetypes "github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// CopyOptions describes the images copied from a registry to another
+type CopyOptions struct {
+	// Repository of the images inside the source registry, e.g. team/app
+	SourceRepository string
+	// Repository the images are copied to inside the destination registry, defaults to the source repository
+	DestinationRepository string
+	// Tags to copy
+	Tags []string
+	// Glob pattern matching the tags to copy, e.g. v1.*
+	TagPattern string
+	// Tag the image is copied to, only valid when a single tag is copied
+	DestinationTag string
+	// Platform copied out of a multi-arch image, e.g. linux/amd64. All the platforms are copied when empty
+	Platform string
+}
+
+// CopyProgress represents a step of a copy
+type CopyProgress struct {
+	// Tag being copied
+	Tag string `json:"tag"`
+	// Status of the step, e.g. "Copying blob"
+	Status string `json:"status"`
+	// Digest of the blob or manifest being copied
+	Digest string `json:"digest,omitempty"`
+	// Size in bytes of the blob being copied
+	Size int64 `json:"size,omitempty"`
+}
+
+// CopyResult represents a tag copied to the destination registry
+type CopyResult struct {
+	SourceImage      string `json:"sourceImage"`
+	DestinationImage string `json:"destinationImage"`
+	Digest           string `json:"digest"`
+}
+
+// ImageCopier copies images between the registries known by Portainer
+type ImageCopier struct {
+	registryClient *RegistryClient
+}
+
+// NewImageCopier returns a new ImageCopier using the effective credentials of the registries
+func NewImageCopier(registryClient *RegistryClient) *ImageCopier {
+	return &ImageCopier{registryClient: registryClient}
+}
+
+// Copy copies the images described by the options from the source registry to the destination registry.
+// Multi-arch images are copied along with all their platforms unless a platform is specified.
+// The progress function, if any, is called for each step of the copy
+func (c *ImageCopier) Copy(ctx context.Context, source, destination *portainer.Registry, opts CopyOptions, progress func(CopyProgress)) ([]CopyResult, error) {
+	if opts.DestinationRepository == "" {
+		opts.DestinationRepository = opts.SourceRepository
+	}
+
+	if progress == nil {
+		progress = func(CopyProgress) {}
+	}
+
+	srcCtx, err := c.systemContext(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to retrieve the source registry credentials")
+	}
+
+	destCtx, err := c.systemContext(destination)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to retrieve the destination registry credentials")
+	}
+
+	if opts.Platform != "" {
+		if srcCtx.OSChoice, srcCtx.ArchitectureChoice, srcCtx.VariantChoice, err = parsePlatform(opts.Platform); err != nil {
+			return nil, err
+		}
+	}
+
+	tags, err := c.tags(ctx, srcCtx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DestinationTag != "" && len(tags) != 1 {
+		return nil, errors.New("a destination tag can only be specified when a single tag is copied")
+	}
+
+	results := make([]CopyResult, 0, len(tags))
+	for _, tag := range tags {
+		destTag := tag
+		if opts.DestinationTag != "" {
+			destTag = opts.DestinationTag
+		}
+
+		srcImage := RegistryImageName(source, opts.SourceRepository) + ":" + tag
+		destImage := RegistryImageName(destination, opts.DestinationRepository) + ":" + destTag
+
+		tagProgress := func(p CopyProgress) {
+			p.Tag = tag
+			progress(p)
+		}
+
+		d, err := copyImage(ctx, srcCtx, destCtx, srcImage, destImage, opts.Platform != "", tagProgress)
+		if err != nil {
+			return results, errors.Wrapf(err, "unable to copy %s to %s", srcImage, destImage)
+		}
+
+		tagProgress(CopyProgress{Status: "Copied", Digest: d.String()})
+
+		results = append(results, CopyResult{SourceImage: srcImage, DestinationImage: destImage, Digest: d.String()})
+	}
+
+	return results, nil
+}
+
+// RegistryImageName returns the name of the repository inside the registry, e.g. registry.example.com/team/app
+func RegistryImageName(registry *portainer.Registry, repository string) string {
+	host := registry.URL
+
+	switch registry.Type {
+	case portainer.DockerHubRegistry:
+		host = "docker.io"
+	case portainer.ProGetRegistry:
+		host = registry.BaseURL
+	}
+
+	return strings.TrimSuffix(host, "/") + "/" + strings.TrimPrefix(repository, "/")
+}
+
+func (c *ImageCopier) systemContext(registry *portainer.Registry) (*imagetypes.SystemContext, error) {
+	sysCtx := &imagetypes.SystemContext{}
+
+	if registry.ManagementConfiguration != nil && registry.ManagementConfiguration.TLSConfig.TLSSkipVerify {
+		sysCtx.DockerInsecureSkipTLSVerify = imagetypes.OptionalBoolTrue
+	}
+
+	if !registry.Authentication {
+		return sysCtx, nil
+	}
+
+	username, password, err := c.registryClient.CertainRegistryAuth(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	sysCtx.DockerAuthConfig = &imagetypes.DockerAuthConfig{Username: username, Password: password}
+
+	return sysCtx, nil
+}
+
+func (c *ImageCopier) tags(ctx context.Context, sysCtx *imagetypes.SystemContext, registry *portainer.Registry, opts CopyOptions) ([]string, error) {
+	if opts.TagPattern == "" {
+		if len(opts.Tags) == 0 {
+			return nil, errors.New("at least one tag or a tag pattern must be specified")
+		}
+
+		return opts.Tags, nil
+	}
+
+	if _, err := path.Match(opts.TagPattern, ""); err != nil {
+		return nil, errors.Wrap(err, "invalid tag pattern")
+	}
+
+	ref, err := ParseReference(RegistryImageName(registry, opts.SourceRepository))
+	if err != nil {
+		return nil, err
+	}
+
+	allTags, err := docker.GetRepositoryTags(ctx, sysCtx, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the tags of the source repository")
+	}
+
+	tags := slices.Clone(opts.Tags)
+	for _, tag := range allTags {
+		if match, _ := path.Match(opts.TagPattern, tag); match && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tag matches the pattern %q", opts.TagPattern)
+	}
+
+	return tags, nil
+}
+
+func copyImage(ctx context.Context, srcCtx, destCtx *imagetypes.SystemContext, srcImage, destImage string, singlePlatform bool, progress func(CopyProgress)) (digest.Digest, error) {
+	srcRef, err := ParseReference(srcImage)
+	if err != nil {
+		return "", err
+	}
+
+	destRef, err := ParseReference(destImage)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := srcRef.NewImageSource(ctx, srcCtx)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dest, err := destRef.NewImageDestination(ctx, destCtx)
+	if err != nil {
+		return "", err
+	}
+	defer dest.Close()
+
+	manifestBlob, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return "", err
+		}
+
+		if singlePlatform {
+			instance, err := list.ChooseInstance(srcCtx)
+			if err != nil {
+				return "", err
+			}
+
+			if manifestBlob, mimeType, err = src.GetManifest(ctx, &instance); err != nil {
+				return "", err
+			}
+		} else {
+			for _, instance := range list.Instances() {
+				if err := copyManifest(ctx, src, dest, &instance, progress); err != nil {
+					return "", err
+				}
+			}
+
+			progress(CopyProgress{Status: "Copying manifest list", Digest: digest.FromBytes(manifestBlob).String()})
+
+			if err := dest.PutManifest(ctx, manifestBlob, nil); err != nil {
+				return "", err
+			}
+
+			return commit(ctx, src, dest, manifestBlob)
+		}
+	}
+
+	if err := copyBlobs(ctx, src, dest, manifestBlob, mimeType, progress); err != nil {
+		return "", err
+	}
+
+	progress(CopyProgress{Status: "Copying manifest", Digest: digest.FromBytes(manifestBlob).String()})
+
+	if err := dest.PutManifest(ctx, manifestBlob, nil); err != nil {
+		return "", err
+	}
+
+	return commit(ctx, src, dest, manifestBlob)
+}
+
+func copyManifest(ctx context.Context, src imagetypes.ImageSource, dest imagetypes.ImageDestination, instance *digest.Digest, progress func(CopyProgress)) error {
+	manifestBlob, mimeType, err := src.GetManifest(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	if err := copyBlobs(ctx, src, dest, manifestBlob, mimeType, progress); err != nil {
+		return err
+	}
+
+	progress(CopyProgress{Status: "Copying manifest", Digest: instance.String()})
+
+	return dest.PutManifest(ctx, manifestBlob, instance)
+}
+
+func copyBlobs(ctx context.Context, src imagetypes.ImageSource, dest imagetypes.ImageDestination, manifestBlob []byte, mimeType string, progress func(CopyProgress)) error {
+	m, err := manifest.FromBlob(manifestBlob, mimeType)
+	if err != nil {
+		return err
+	}
+
+	blobs := []imagetypes.BlobInfo{}
+	for _, layer := range m.LayerInfos() {
+		blobs = append(blobs, layer.BlobInfo)
+	}
+
+	config := m.ConfigInfo()
+	if config.Digest != "" {
+		blobs = append(blobs, config)
+	}
+
+	for _, blob := range blobs {
+		isConfig := blob.Digest == config.Digest
+
+		if reused, _, err := dest.TryReusingBlob(ctx, blob, none.NoCache, false); err != nil {
+			return err
+		} else if reused {
+			progress(CopyProgress{Status: "Blob already exists", Digest: blob.Digest.String(), Size: blob.Size})
+
+			continue
+		}
+
+		progress(CopyProgress{Status: "Copying blob", Digest: blob.Digest.String(), Size: blob.Size})
+
+		if err := copyBlob(ctx, src, dest, blob, isConfig); err != nil {
+			return errors.Wrapf(err, "unable to copy the blob %s", blob.Digest)
+		}
+	}
+
+	return nil
+}
+
+func copyBlob(ctx context.Context, src imagetypes.ImageSource, dest imagetypes.ImageDestination, blob imagetypes.BlobInfo, isConfig bool) error {
+	reader, size, err := src.GetBlob(ctx, blob, none.NoCache)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if blob.Size <= 0 {
+		blob.Size = size
+	}
+
+	_, err = dest.PutBlob(ctx, reader, blob, none.NoCache, isConfig)
+
+	return err
+}
+
+func commit(ctx context.Context, src imagetypes.ImageSource, dest imagetypes.ImageDestination, manifestBlob []byte) (digest.Digest, error) {
+	if err := dest.Commit(ctx, image.UnparsedInstance(src, nil)); err != nil {
+		return "", err
+	}
+
+	return manifest.Digest(manifestBlob)
+}
+
+func parsePlatform(platform string) (os, arch, variant string, err error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid platform %q, expected os/arch[/variant]", platform)
+	}
+
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+
+	return parts[0], parts[1], variant, nil
+}
@@ -0,0 +1,131 @@
+package images
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T) *portainer.Registry {
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	return &portainer.Registry{
+		Type: portainer.CustomRegistry,
+		URL:  u.Host,
+		ManagementConfiguration: &portainer.RegistryManagementConfiguration{
+			TLSConfig: portainer.TLSConfiguration{TLSSkipVerify: true},
+		},
+	}
+}
+
+func TestImageCopier(t *testing.T) {
+	source := newTestRegistry(t)
+	destination := newTestRegistry(t)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+
+	index, err := random.Index(512, 1, 2)
+	require.NoError(t, err)
+
+	for _, tag := range []string{"v1.0", "v1.1", "v2.0"} {
+		ref, err := name.ParseReference(source.URL+"/team/app:"+tag, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+
+	indexRef, err := name.ParseReference(source.URL+"/team/multi:1.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(indexRef, index))
+
+	copier := NewImageCopier(nil)
+	ctx := context.Background()
+
+	t.Run("tag pattern", func(t *testing.T) {
+		steps := 0
+		results, err := copier.Copy(ctx, source, destination, CopyOptions{
+			SourceRepository:      "team/app",
+			DestinationRepository: "prod/app",
+			TagPattern:            "v1.*",
+		}, func(CopyProgress) { steps++ })
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.NotZero(t, steps)
+
+		digest, err := img.Digest()
+		require.NoError(t, err)
+
+		for _, tag := range []string{"v1.0", "v1.1"} {
+			ref, err := name.ParseReference(destination.URL+"/prod/app:"+tag, name.Insecure)
+			require.NoError(t, err)
+
+			desc, err := remote.Head(ref)
+			require.NoError(t, err)
+			require.Equal(t, digest, desc.Digest)
+		}
+	})
+
+	t.Run("retag", func(t *testing.T) {
+		_, err := copier.Copy(ctx, source, destination, CopyOptions{
+			SourceRepository: "team/app",
+			Tags:             []string{"v2.0"},
+			DestinationTag:   "stable",
+		}, nil)
+		require.NoError(t, err)
+
+		ref, err := name.ParseReference(destination.URL+"/team/app:stable", name.Insecure)
+		require.NoError(t, err)
+
+		_, err = remote.Head(ref)
+		require.NoError(t, err)
+	})
+
+	t.Run("multi-arch", func(t *testing.T) {
+		results, err := copier.Copy(ctx, source, destination, CopyOptions{
+			SourceRepository: "team/multi",
+			Tags:             []string{"1.0"},
+		}, nil)
+		require.NoError(t, err)
+
+		digest, err := index.Digest()
+		require.NoError(t, err)
+		require.Equal(t, digest.String(), results[0].Digest)
+
+		ref, err := name.ParseReference(destination.URL+"/team/multi:1.0", name.Insecure)
+		require.NoError(t, err)
+
+		copied, err := remote.Index(ref)
+		require.NoError(t, err)
+
+		indexManifest, err := copied.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, indexManifest.Manifests, 2)
+
+		for _, m := range indexManifest.Manifests {
+			_, err := copied.Image(m.Digest)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("invalid destination tag", func(t *testing.T) {
+		_, err := copier.Copy(ctx, source, destination, CopyOptions{
+			SourceRepository: "team/app",
+			TagPattern:       "v*",
+			DestinationTag:   "stable",
+		}, nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,224 @@
+package inventory
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Inventory is the in-memory state of the resources of a Docker environment.
+// It is populated by a full sync and kept up to date from the Docker events.
+// All the accessors return copies that can be modified by the caller.
+type Inventory struct {
+	mu           sync.RWMutex
+	ready        bool
+	syncedAt     time.Time
+	swarmManager bool
+	containers   map[string]types.Container
+	services     map[string]swarm.Service
+	volumes      map[string]volume.Volume
+	networks     map[string]types.NetworkResource
+	images       []image.Summary
+}
+
+func newInventory() *Inventory {
+	return &Inventory{
+		containers: map[string]types.Container{},
+		services:   map[string]swarm.Service{},
+		volumes:    map[string]volume.Volume{},
+		networks:   map[string]types.NetworkResource{},
+	}
+}
+
+// Ready returns true when the inventory reflects the current state of the environment.
+func (inventory *Inventory) Ready() bool {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	return inventory.ready
+}
+
+// SyncedAt returns the time of the last full sync.
+func (inventory *Inventory) SyncedAt() time.Time {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	return inventory.syncedAt
+}
+
+// SwarmManager returns true when the environment is a swarm manager node.
+func (inventory *Inventory) SwarmManager() bool {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	return inventory.swarmManager
+}
+
+// Containers returns the containers, the most recently created first.
+// Only the running containers are returned unless all is true.
+func (inventory *Inventory) Containers(all bool) []types.Container {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	containers := make([]types.Container, 0, len(inventory.containers))
+	for _, container := range inventory.containers {
+		if all || container.State == "running" {
+			containers = append(containers, container)
+		}
+	}
+
+	slices.SortFunc(containers, func(a, b types.Container) int {
+		return cmp.Or(cmp.Compare(b.Created, a.Created), cmp.Compare(a.ID, b.ID))
+	})
+
+	return containers
+}
+
+// Services returns the swarm services, empty when the environment is not a swarm manager.
+func (inventory *Inventory) Services() []swarm.Service {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	services := make([]swarm.Service, 0, len(inventory.services))
+	for _, service := range inventory.services {
+		services = append(services, service)
+	}
+
+	slices.SortFunc(services, func(a, b swarm.Service) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return services
+}
+
+// Volumes returns the volumes.
+func (inventory *Inventory) Volumes() []*volume.Volume {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	volumes := make([]*volume.Volume, 0, len(inventory.volumes))
+	for _, v := range inventory.volumes {
+		volumes = append(volumes, &v)
+	}
+
+	slices.SortFunc(volumes, func(a, b *volume.Volume) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return volumes
+}
+
+// Networks returns the networks.
+func (inventory *Inventory) Networks() []types.NetworkResource {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	networks := make([]types.NetworkResource, 0, len(inventory.networks))
+	for _, network := range inventory.networks {
+		networks = append(networks, network)
+	}
+
+	slices.SortFunc(networks, func(a, b types.NetworkResource) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return networks
+}
+
+// Images returns the images.
+func (inventory *Inventory) Images() []image.Summary {
+	inventory.mu.RLock()
+	defer inventory.mu.RUnlock()
+
+	return slices.Clone(inventory.images)
+}
+
+func (inventory *Inventory) setReady(ready bool) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.ready = ready
+}
+
+func (inventory *Inventory) replace(state *Inventory) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.ready = true
+	inventory.syncedAt = time.Now()
+	inventory.swarmManager = state.swarmManager
+	inventory.containers = state.containers
+	inventory.services = state.services
+	inventory.volumes = state.volumes
+	inventory.networks = state.networks
+	inventory.images = state.images
+}
+
+func (inventory *Inventory) setContainer(container types.Container) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.containers[container.ID] = container
+}
+
+func (inventory *Inventory) deleteContainer(id string) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	delete(inventory.containers, id)
+}
+
+func (inventory *Inventory) setService(service swarm.Service) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.services[service.ID] = service
+}
+
+func (inventory *Inventory) deleteService(id string) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	delete(inventory.services, id)
+}
+
+func (inventory *Inventory) setVolume(v volume.Volume) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.volumes[v.Name] = v
+}
+
+func (inventory *Inventory) deleteVolume(name string) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	delete(inventory.volumes, name)
+}
+
+func (inventory *Inventory) setNetwork(network types.NetworkResource) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.networks[network.ID] = network
+}
+
+func (inventory *Inventory) deleteNetwork(id string) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	delete(inventory.networks, id)
+}
+
+func (inventory *Inventory) setImages(images []image.Summary) {
+	inventory.mu.Lock()
+	defer inventory.mu.Unlock()
+
+	inventory.images = images
+}
@@ -0,0 +1,222 @@
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker is a minimal Docker API serving the resources it holds and
+// streaming the events sent to it.
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers []types.Container
+	volumes    []volume.Volume
+	images     []image.Summary
+	events     chan events.Message
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+
+	switch {
+	case path == "/events":
+		f.mu.Unlock()
+		defer f.mu.Lock()
+
+		f.streamEvents(w, r)
+
+	case path == "/info":
+		writeJSON(w, system.Info{})
+
+	case path == "/containers/json":
+		args, _ := filters.FromJSON(r.URL.Query().Get("filters"))
+
+		containers := []types.Container{}
+		for _, c := range f.containers {
+			if args.Len() == 0 || args.ExactMatch("id", c.ID) {
+				containers = append(containers, c)
+			}
+		}
+
+		writeJSON(w, containers)
+
+	case path == "/volumes":
+		volumes := []*volume.Volume{}
+		for _, v := range f.volumes {
+			volumes = append(volumes, &v)
+		}
+
+		writeJSON(w, volume.ListResponse{Volumes: volumes})
+
+	case strings.HasPrefix(path, "/volumes/"):
+		for _, v := range f.volumes {
+			if v.Name == strings.TrimPrefix(path, "/volumes/") {
+				writeJSON(w, v)
+
+				return
+			}
+		}
+
+		http.Error(w, `{"message": "no such volume"}`, http.StatusNotFound)
+
+	case path == "/networks":
+		writeJSON(w, []types.NetworkResource{})
+
+	case path == "/images/json":
+		writeJSON(w, f.images)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDocker) streamEvents(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-f.events:
+			if err := json.NewEncoder(w).Encode(message); err != nil {
+				return
+			}
+
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func (f *fakeDocker) update(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fn()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func containerIDs(containers []types.Container) []string {
+	ids := []string{}
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+
+	return ids
+}
+
+func TestWatcher(t *testing.T) {
+	fake := &fakeDocker{
+		containers: []types.Container{
+			{ID: "running", State: "running", Created: 2},
+			{ID: "exited", State: "exited", Created: 1},
+		},
+		volumes: []volume.Volume{{Name: "data"}},
+		events:  make(chan events.Message),
+	}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	newClient := func(*portainer.Endpoint) (*client.Client, error) {
+		return client.NewClientWithOpts(client.WithHost(server.URL), client.WithVersion("1.45"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWatcher(portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment}, time.Hour, newClient)
+	w.start(ctx)
+	defer w.stop()
+
+	require.Eventually(t, w.inventory.Ready, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"running", "exited"}, containerIDs(w.inventory.Containers(true)))
+	require.Equal(t, []string{"running"}, containerIDs(w.inventory.Containers(false)))
+	require.Len(t, w.inventory.Volumes(), 1)
+
+	fake.update(func() {
+		fake.containers = append(fake.containers, types.Container{ID: "created", State: "created", Created: 3})
+	})
+	fake.events <- events.Message{Type: events.ContainerEventType, Action: events.ActionCreate, Actor: events.Actor{ID: "created"}}
+
+	require.Eventually(t, func() bool {
+		return len(w.inventory.Containers(true)) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "created", w.inventory.Containers(true)[0].ID)
+
+	fake.update(func() {
+		fake.containers = fake.containers[:1]
+	})
+	fake.events <- events.Message{Type: events.ContainerEventType, Action: events.ActionDestroy, Actor: events.Actor{ID: "exited"}}
+	fake.events <- events.Message{Type: events.ContainerEventType, Action: events.ActionDie, Actor: events.Actor{ID: "created"}}
+
+	require.Eventually(t, func() bool {
+		return len(w.inventory.Containers(true)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fake.update(func() {
+		fake.volumes = append(fake.volumes, volume.Volume{Name: "logs"})
+		fake.images = []image.Summary{{ID: "sha256:nginx"}}
+	})
+	fake.events <- events.Message{Type: events.VolumeEventType, Action: events.ActionCreate, Actor: events.Actor{ID: "logs"}}
+	fake.events <- events.Message{Type: events.VolumeEventType, Action: events.ActionDestroy, Actor: events.Actor{ID: "data"}}
+	fake.events <- events.Message{Type: events.ImageEventType, Action: events.ActionPull, Actor: events.Actor{ID: "nginx"}}
+
+	require.Eventually(t, func() bool {
+		volumes := w.inventory.Volumes()
+
+		return len(volumes) == 1 && volumes[0].Name == "logs" && len(w.inventory.Images()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServiceInventory(t *testing.T) {
+	var service *Service
+
+	_, ok := service.Inventory(1)
+	require.False(t, ok, "a nil service never has an inventory")
+
+	service = NewService(context.Background(), nil, nil, time.Minute)
+	w := newWatcher(portainer.Endpoint{ID: 1}, time.Minute, nil)
+	service.watchers[1] = w
+
+	_, ok = service.Inventory(1)
+	require.False(t, ok, "the inventory must not be used before the first sync")
+
+	w.inventory.replace(newInventory())
+
+	inventory, ok := service.Inventory(1)
+	require.True(t, ok)
+	require.Same(t, w.inventory, inventory)
+}
+
+func TestShouldWatch(t *testing.T) {
+	require.True(t, shouldWatch(&portainer.Endpoint{Type: portainer.DockerEnvironment}))
+	require.True(t, shouldWatch(&portainer.Endpoint{Type: portainer.AgentOnDockerEnvironment}))
+	require.False(t, shouldWatch(&portainer.Endpoint{Type: portainer.EdgeAgentOnDockerEnvironment}))
+	require.False(t, shouldWatch(&portainer.Endpoint{Type: portainer.KubernetesLocalEnvironment}))
+	require.False(t, shouldWatch(&portainer.Endpoint{Type: portainer.DockerEnvironment, Status: portainer.EndpointStatusDown}))
+}
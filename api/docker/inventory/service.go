@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	dockerclient "github.com/portainer/portainer/api/docker/client"
+
+	"github.com/docker/docker/client"
+	"github.com/rs/zerolog/log"
+)
+
+const reconcileInterval = 30 * time.Second
+
+// Service maintains a live inventory of the resources of the reachable
+// non-edge Docker environments. Each environment is watched through its
+// events stream and fully resynced at the configured interval.
+type Service struct {
+	dataStore      dataservices.DataStore
+	clientFactory  *dockerclient.ClientFactory
+	shutdownCtx    context.Context
+	resyncInterval time.Duration
+	mu             sync.RWMutex
+	watchers       map[portainer.EndpointID]*watcher
+}
+
+// NewService creates a new instance of a service. The inventory is disabled
+// when resyncInterval is zero.
+func NewService(shutdownCtx context.Context, dataStore dataservices.DataStore, clientFactory *dockerclient.ClientFactory, resyncInterval time.Duration) *Service {
+	if shutdownCtx == nil {
+		shutdownCtx = context.Background()
+	}
+
+	return &Service{
+		dataStore:      dataStore,
+		clientFactory:  clientFactory,
+		shutdownCtx:    shutdownCtx,
+		resyncInterval: resyncInterval,
+		watchers:       make(map[portainer.EndpointID]*watcher),
+	}
+}
+
+// Start starts watching the environments in the background.
+func (service *Service) Start() {
+	if service.resyncInterval <= 0 {
+		log.Info().Msg("the live Docker inventory is disabled")
+
+		return
+	}
+
+	go service.startReconcileLoop()
+}
+
+// Inventory returns the inventory of an environment, it is only returned
+// when it reflects the current state of the environment.
+func (service *Service) Inventory(endpointID portainer.EndpointID) (*Inventory, bool) {
+	if service == nil {
+		return nil, false
+	}
+
+	service.mu.RLock()
+	w, ok := service.watchers[endpointID]
+	service.mu.RUnlock()
+
+	if !ok || !w.inventory.Ready() {
+		return nil, false
+	}
+
+	return w.inventory, true
+}
+
+func (service *Service) startReconcileLoop() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := service.reconcile(); err != nil {
+			log.Error().Err(err).Msg("unable to update the watched Docker environments")
+		}
+
+		select {
+		case <-service.shutdownCtx.Done():
+			log.Debug().Msg("shutting down the Docker inventory")
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile starts a watcher for each environment that should be watched,
+// restarts the ones whose connection settings changed and stops the others.
+func (service *Service) reconcile() error {
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	watched := make(map[portainer.EndpointID]bool)
+	for _, endpoint := range endpoints {
+		if !shouldWatch(&endpoint) {
+			continue
+		}
+
+		watched[endpoint.ID] = true
+
+		if w, ok := service.watchers[endpoint.ID]; ok {
+			if w.fingerprint == fingerprint(&endpoint) {
+				continue
+			}
+
+			w.stop()
+		}
+
+		w := newWatcher(endpoint, service.resyncInterval, service.createClient)
+		w.start(service.shutdownCtx)
+		service.watchers[endpoint.ID] = w
+	}
+
+	for endpointID, w := range service.watchers {
+		if !watched[endpointID] {
+			w.stop()
+			delete(service.watchers, endpointID)
+		}
+	}
+
+	return nil
+}
+
+func (service *Service) createClient(endpoint *portainer.Endpoint) (*client.Client, error) {
+	// the events stream is long lived, the requests are bounded by their context instead
+	noTimeout := time.Duration(0)
+
+	return service.clientFactory.CreateClient(endpoint, "", &noTimeout)
+}
+
+func shouldWatch(endpoint *portainer.Endpoint) bool {
+	if endpoint.Status == portainer.EndpointStatusDown {
+		return false
+	}
+
+	return endpoint.Type == portainer.DockerEnvironment || endpoint.Type == portainer.AgentOnDockerEnvironment
+}
+
+// fingerprint identifies the connection settings of an environment.
+func fingerprint(endpoint *portainer.Endpoint) string {
+	return fmt.Sprintf("%d|%s|%+v", endpoint.Type, endpoint.URL, endpoint.TLSConfig)
+}
@@ -0,0 +1,382 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	requestTimeout     = 30 * time.Second
+	imagesRefreshDelay = time.Second
+	minRetryDelay      = time.Second
+	maxRetryDelay      = time.Minute
+	containerAttribute = "container"
+)
+
+var errUnsupportedAgentSwarm = errors.New("the inventory of agent environments that are part of a swarm cluster is not supported")
+
+// ignoredContainerActions are the container events that do not change the container list.
+var ignoredContainerActions = map[events.Action]bool{
+	events.ActionAttach:       true,
+	events.ActionDetach:       true,
+	events.ActionResize:       true,
+	events.ActionTop:          true,
+	events.ActionCopy:         true,
+	events.ActionArchivePath:  true,
+	events.ActionExtractToDir: true,
+	events.ActionExport:       true,
+	events.ActionCommit:       true,
+	events.ActionExecCreate:   true,
+	events.ActionExecStart:    true,
+	events.ActionExecDie:      true,
+	events.ActionExecDetach:   true,
+}
+
+// watcher keeps the inventory of a single environment up to date.
+type watcher struct {
+	endpoint       portainer.Endpoint
+	fingerprint    string
+	inventory      *Inventory
+	resyncInterval time.Duration
+	newClient      func(*portainer.Endpoint) (*client.Client, error)
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+func newWatcher(endpoint portainer.Endpoint, resyncInterval time.Duration, newClient func(*portainer.Endpoint) (*client.Client, error)) *watcher {
+	return &watcher{
+		endpoint:       endpoint,
+		fingerprint:    fingerprint(&endpoint),
+		inventory:      newInventory(),
+		resyncInterval: resyncInterval,
+		newClient:      newClient,
+		done:           make(chan struct{}),
+	}
+}
+
+func (w *watcher) start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	go w.run(ctx)
+}
+
+func (w *watcher) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// run watches the environment until the context is cancelled, the watch is
+// restarted with an exponential backoff when the connection is lost.
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	delay := minRetryDelay
+	for {
+		synced, err := w.watch(ctx)
+		w.inventory.setReady(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if synced {
+			delay = minRetryDelay
+		}
+
+		log.Debug().
+			Err(err).
+			Int("environment_id", int(w.endpoint.ID)).
+			Dur("retry_in", delay).
+			Msg("the Docker inventory watch was interrupted")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// watch subscribes to the events of the environment before doing a full sync
+// so that no change is missed, then applies the events until an error occurs.
+func (w *watcher) watch(ctx context.Context) (bool, error) {
+	cli, err := w.newClient(&w.endpoint)
+	if err != nil {
+		return false, err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages, errs := cli.Events(ctx, events.ListOptions{})
+
+	if err := w.sync(ctx, cli); err != nil {
+		return false, err
+	}
+
+	resync := time.NewTicker(w.resyncInterval)
+	defer resync.Stop()
+
+	imagesRefresh := time.NewTimer(imagesRefreshDelay)
+	imagesRefresh.Stop()
+	defer imagesRefresh.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+
+		case err := <-errs:
+			return true, err
+
+		case message := <-messages:
+			if message.Type == events.ImageEventType {
+				imagesRefresh.Reset(imagesRefreshDelay)
+
+				continue
+			}
+
+			if err := w.apply(ctx, cli, message); err != nil {
+				return true, err
+			}
+
+		case <-imagesRefresh.C:
+			if err := w.refreshImages(ctx, cli); err != nil {
+				return true, err
+			}
+
+		case <-resync.C:
+			if err := w.sync(ctx, cli); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// sync replaces the inventory with the current state of the environment.
+func (w *watcher) sync(ctx context.Context, cli *client.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	if w.endpoint.Type == portainer.AgentOnDockerEnvironment && info.Swarm.NodeID != "" {
+		return errUnsupportedAgentSwarm
+	}
+
+	state := newInventory()
+	state.swarmManager = info.Swarm.ControlAvailable && info.Swarm.NodeID != ""
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		state.containers[c.ID] = c
+	}
+
+	if state.swarmManager {
+		services, err := cli.ServiceList(ctx, types.ServiceListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, service := range services {
+			state.services[service.ID] = service
+		}
+	}
+
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range volumes.Volumes {
+		state.volumes[v.Name] = *v
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		state.networks[network.ID] = network
+	}
+
+	state.images, err = cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	w.inventory.replace(state)
+
+	return nil
+}
+
+// apply updates the inventory from a single event, the resources that are
+// neither created nor removed are fetched again to pick up their new state.
+func (w *watcher) apply(ctx context.Context, cli *client.Client, message events.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	id := message.Actor.ID
+
+	switch message.Type {
+	case events.ContainerEventType:
+		switch {
+		case message.Action == events.ActionDestroy:
+			w.inventory.deleteContainer(id)
+		case !ignoredContainerActions[message.Action]:
+			return w.refreshContainer(ctx, cli, id)
+		}
+
+	case events.NetworkEventType:
+		switch message.Action {
+		case events.ActionDestroy, events.ActionRemove:
+			w.inventory.deleteNetwork(id)
+		case events.ActionConnect, events.ActionDisconnect:
+			if containerID := message.Actor.Attributes[containerAttribute]; containerID != "" {
+				return w.refreshContainer(ctx, cli, containerID)
+			}
+		default:
+			return w.refreshNetwork(ctx, cli, id)
+		}
+
+	case events.VolumeEventType:
+		switch message.Action {
+		case events.ActionDestroy:
+			w.inventory.deleteVolume(id)
+		case events.ActionCreate:
+			return w.refreshVolume(ctx, cli, id)
+		}
+
+	case events.ServiceEventType:
+		if !w.inventory.SwarmManager() {
+			return nil
+		}
+
+		if message.Action == events.ActionRemove {
+			w.inventory.deleteService(id)
+
+			return nil
+		}
+
+		return w.refreshService(ctx, cli, id)
+	}
+
+	return nil
+}
+
+func (w *watcher) refreshContainer(ctx context.Context, cli *client.Client, id string) error {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("id", id)),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if c.ID == id {
+			w.inventory.setContainer(c)
+
+			return nil
+		}
+	}
+
+	w.inventory.deleteContainer(id)
+
+	return nil
+}
+
+func (w *watcher) refreshNetwork(ctx context.Context, cli *client.Client, id string) error {
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("id", id)),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		if network.ID == id {
+			w.inventory.setNetwork(network)
+
+			return nil
+		}
+	}
+
+	w.inventory.deleteNetwork(id)
+
+	return nil
+}
+
+func (w *watcher) refreshVolume(ctx context.Context, cli *client.Client, name string) error {
+	v, err := cli.VolumeInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		w.inventory.deleteVolume(name)
+
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	w.inventory.setVolume(v)
+
+	return nil
+}
+
+func (w *watcher) refreshService(ctx context.Context, cli *client.Client, id string) error {
+	services, err := cli.ServiceList(ctx, types.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("id", id)),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if service.ID == id {
+			w.inventory.setService(service)
+
+			return nil
+		}
+	}
+
+	w.inventory.deleteService(id)
+
+	return nil
+}
+
+func (w *watcher) refreshImages(ctx context.Context, cli *client.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	w.inventory.setImages(images)
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"context"
+
 	portainer "github.com/portainer/portainer/api"
 	dockerclient "github.com/portainer/portainer/api/docker/client"
 	"github.com/portainer/portainer/pkg/snapshot"
@@ -19,12 +21,12 @@ func NewSnapshotter(clientFactory *dockerclient.ClientFactory) *Snapshotter {
 }
 
 // CreateSnapshot creates a snapshot of a specific Docker environment(endpoint)
-func (snapshotter *Snapshotter) CreateSnapshot(endpoint *portainer.Endpoint) (*portainer.DockerSnapshot, error) {
+func (snapshotter *Snapshotter) CreateSnapshot(ctx context.Context, endpoint *portainer.Endpoint) (*portainer.DockerSnapshot, error) {
 	cli, err := snapshotter.clientFactory.CreateClient(endpoint, "", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer cli.Close()
 
-	return snapshot.CreateDockerSnapshot(cli)
+	return snapshot.CreateDockerSnapshot(ctx, cli)
 }
@@ -72,6 +72,12 @@ type (
 		// This flag drives `docker compose down --volumes` option
 		// Used only for EE
 		RemoveVolumes bool
+		// Profiles are the compose profiles to enable
+		// This drives `docker compose --profile` option
+		Profiles []string
+		// EnvFiles are the paths of the env files, relative to the stack folder, loaded in order
+		// This drives `docker compose --env-file` option
+		EnvFiles []string
 	}
 
 	// RegistryCredentials holds the credentials for a Docker registry.
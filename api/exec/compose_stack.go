@@ -7,11 +7,13 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/proxy"
 	"github.com/portainer/portainer/api/http/proxy/factory"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/internal/registryutils"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	"github.com/portainer/portainer/pkg/libstack"
@@ -53,22 +55,21 @@ func (manager *ComposeStackManager) Up(ctx context.Context, stack *portainer.Sta
 		defer proxy.Close()
 	}
 
-	envFilePath, err := createEnvFile(stack)
-	if err != nil {
-		return errors.Wrap(err, "failed to create env file")
-	}
-
-	filePaths := stackutils.GetStackFilePaths(stack, true)
-	err = manager.deployer.Deploy(ctx, filePaths, libstack.DeployOptions{
-		Options: libstack.Options{
-			WorkingDir:  stack.ProjectPath,
-			EnvFilePath: envFilePath,
-			Host:        url,
-			ProjectName: stack.Name,
-			Registries:  portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
-		},
-		ForceRecreate:        options.ForceRecreate,
-		AbortOnContainerExit: options.AbortOnContainerExit,
+	err = withStackEnvFiles(stack, func(envFilePaths []string) error {
+		filePaths := stackutils.GetStackFilePaths(stack, true)
+
+		return manager.deployer.Deploy(ctx, filePaths, libstack.DeployOptions{
+			Options: libstack.Options{
+				WorkingDir:   stack.ProjectPath,
+				EnvFilePaths: envFilePaths,
+				Profiles:     stack.ComposeProfiles,
+				Host:         url,
+				ProjectName:  stack.Name,
+				Registries:   portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
+			},
+			ForceRecreate:        options.ForceRecreate,
+			AbortOnContainerExit: options.AbortOnContainerExit,
+		})
 	})
 	return errors.Wrap(err, "failed to deploy a stack")
 }
@@ -84,23 +85,22 @@ func (manager *ComposeStackManager) Run(ctx context.Context, stack *portainer.St
 		defer proxy.Close()
 	}
 
-	envFilePath, err := createEnvFile(stack)
-	if err != nil {
-		return errors.Wrap(err, "failed to create env file")
-	}
-
-	filePaths := stackutils.GetStackFilePaths(stack, true)
-	err = manager.deployer.Run(ctx, filePaths, serviceName, libstack.RunOptions{
-		Options: libstack.Options{
-			WorkingDir:  stack.ProjectPath,
-			EnvFilePath: envFilePath,
-			Host:        url,
-			ProjectName: stack.Name,
-			Registries:  portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
-		},
-		Remove:   options.Remove,
-		Args:     options.Args,
-		Detached: options.Detached,
+	err = withStackEnvFiles(stack, func(envFilePaths []string) error {
+		filePaths := stackutils.GetStackFilePaths(stack, true)
+
+		return manager.deployer.Run(ctx, filePaths, serviceName, libstack.RunOptions{
+			Options: libstack.Options{
+				WorkingDir:   stack.ProjectPath,
+				EnvFilePaths: envFilePaths,
+				Profiles:     stack.ComposeProfiles,
+				Host:         url,
+				ProjectName:  stack.Name,
+				Registries:   portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
+			},
+			Remove:   options.Remove,
+			Args:     options.Args,
+			Detached: options.Detached,
+		})
 	})
 	return errors.Wrap(err, "failed to deploy a stack")
 }
@@ -118,6 +118,7 @@ func (manager *ComposeStackManager) Down(ctx context.Context, stack *portainer.S
 		Options: libstack.Options{
 			WorkingDir: "",
 			Host:       url,
+			Profiles:   stack.ComposeProfiles,
 		},
 	})
 
@@ -134,20 +135,141 @@ func (manager *ComposeStackManager) Pull(ctx context.Context, stack *portainer.S
 		defer proxy.Close()
 	}
 
-	envFilePath, err := createEnvFile(stack)
+	err = withStackEnvFiles(stack, func(envFilePaths []string) error {
+		filePaths := stackutils.GetStackFilePaths(stack, true)
+
+		return manager.deployer.Pull(ctx, filePaths, libstack.Options{
+			WorkingDir:   stack.ProjectPath,
+			EnvFilePaths: envFilePaths,
+			Profiles:     stack.ComposeProfiles,
+			Host:         url,
+			ProjectName:  stack.Name,
+			Registries:   portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
+		})
+	})
+	return errors.Wrap(err, "failed to pull images of the stack")
+}
+
+// RestartServices restarts the containers of the given services of the stack
+func (manager *ComposeStackManager) RestartServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeOptions) error {
+	err := manager.withServiceOptions(stack, endpoint, options, func(filePaths []string, opts libstack.Options) error {
+		return manager.deployer.Restart(ctx, filePaths, serviceNames, opts)
+	})
+
+	return errors.Wrap(err, "failed to restart the services of the stack")
+}
+
+// StopServices stops the containers of the given services of the stack without removing them
+func (manager *ComposeStackManager) StopServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeOptions) error {
+	err := manager.withServiceOptions(stack, endpoint, options, func(filePaths []string, opts libstack.Options) error {
+		return manager.deployer.Stop(ctx, filePaths, serviceNames, opts)
+	})
+
+	return errors.Wrap(err, "failed to stop the services of the stack")
+}
+
+// RecreateServices recreates the containers of the given services of the stack, pulling their images first if requested
+func (manager *ComposeStackManager) RecreateServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeRecreateOptions) error {
+	err := manager.withServiceOptions(stack, endpoint, options.ComposeOptions, func(filePaths []string, opts libstack.Options) error {
+		return manager.deployer.Recreate(ctx, filePaths, serviceNames, libstack.RecreateOptions{
+			Options:   opts,
+			PullImage: options.PullImage,
+		})
+	})
+
+	return errors.Wrap(err, "failed to recreate the services of the stack")
+}
+
+// ScaleService sets the number of containers of the given service of the stack
+func (manager *ComposeStackManager) ScaleService(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceName string, replicas int, options portainer.ComposeOptions) error {
+	err := manager.withServiceOptions(stack, endpoint, options, func(filePaths []string, opts libstack.Options) error {
+		return manager.deployer.Scale(ctx, filePaths, serviceName, replicas, opts)
+	})
+
+	return errors.Wrap(err, "failed to scale the service of the stack")
+}
+
+// WaitForHealthy waits until all the containers of the stack are running and healthy, it returns
+// the services which failed to do so within the timeout
+func (manager *ComposeStackManager) WaitForHealthy(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeHealthOptions) ([]portainer.ComposeUnhealthyService, error) {
+	url, proxy, err := manager.fetchEndpointProxy(endpoint)
 	if err != nil {
-		return errors.Wrap(err, "failed to create env file")
+		return nil, errors.Wrap(err, "failed to fetch environment proxy")
+	}
+
+	if proxy != nil {
+		defer proxy.Close()
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
 	}
 
-	filePaths := stackutils.GetStackFilePaths(stack, true)
-	err = manager.deployer.Pull(ctx, filePaths, libstack.Options{
-		WorkingDir:  stack.ProjectPath,
-		EnvFilePath: envFilePath,
-		Host:        url,
-		ProjectName: stack.Name,
-		Registries:  portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
+	services, err := manager.deployer.WaitForHealthy(ctx, libstack.WaitForHealthyOptions{
+		Options: libstack.Options{
+			Host:        url,
+			ProjectName: stack.Name,
+		},
+		LogLines: options.LogLines,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wait for the stack to be healthy")
+	}
+
+	unhealthyServices := make([]portainer.ComposeUnhealthyService, 0, len(services))
+	for _, service := range services {
+		unhealthyServices = append(unhealthyServices, portainer.ComposeUnhealthyService{
+			Name:      service.Name,
+			Container: service.Container,
+			State:     service.State,
+			Health:    service.Health,
+			ExitCode:  service.ExitCode,
+			Logs:      service.Logs,
+		})
+	}
+
+	return unhealthyServices, nil
+}
+
+// Config returns the compose file of the stack as rendered by compose, with the env files, the profiles
+// and the includes of the stack applied
+func (manager *ComposeStackManager) Config(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]byte, error) {
+	var config []byte
+
+	err := manager.withServiceOptions(stack, endpoint, portainer.ComposeOptions{}, func(filePaths []string, opts libstack.Options) error {
+		var err error
+		config, err = manager.deployer.Config(ctx, filePaths, opts)
+
+		return err
+	})
+
+	return config, errors.Wrap(err, "failed to render the stack config")
+}
+
+// withServiceOptions calls fn with the stack files and the options used to deploy the stack,
+// so that the services are handled within the same compose project
+func (manager *ComposeStackManager) withServiceOptions(stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeOptions, fn func(filePaths []string, opts libstack.Options) error) error {
+	url, proxy, err := manager.fetchEndpointProxy(endpoint)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch environment proxy")
+	}
+
+	if proxy != nil {
+		defer proxy.Close()
+	}
+
+	return withStackEnvFiles(stack, func(envFilePaths []string) error {
+		return fn(stackutils.GetStackFilePaths(stack, true), libstack.Options{
+			WorkingDir:   stack.ProjectPath,
+			EnvFilePaths: envFilePaths,
+			Profiles:     stack.ComposeProfiles,
+			Host:         url,
+			ProjectName:  stack.Name,
+			Registries:   portainerRegistriesToAuthConfigs(manager.dataStore, options.Registries),
+		})
 	})
-	return errors.Wrap(err, "failed to pull images of the stack")
 }
 
 // NormalizeStackName returns a new stack name with unsupported characters replaced
@@ -168,7 +290,34 @@ func (manager *ComposeStackManager) fetchEndpointProxy(endpoint *portainer.Endpo
 	return fmt.Sprintf("tcp://127.0.0.1:%d", proxy.Port), proxy, nil
 }
 
+// stackEnvFileLocks serializes the operations sharing the stack.env file of a project
+var stackEnvFileLocks sync.Map
+
+// withStackEnvFiles calls fn with the env files of the stack followed by the file holding its
+// "in-place" environment variables, if any. An empty list means the default .env file is used.
+// The "in-place" variables hold the decrypted secrets, their file only exists for the duration of fn
+func withStackEnvFiles(stack *portainer.Stack, fn func(envFilePaths []string) error) error {
+	lock, _ := stackEnvFileLocks.LoadOrStore(stack.ProjectPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	envFilePaths := stackutils.GetStackEnvFilePaths(stack)
+
+	envFilePath, err := createEnvFile(stack)
+	if err != nil {
+		return errors.Wrap(err, "failed to create env file")
+	}
+
+	if envFilePath != "" {
+		defer os.Remove(envFilePath)
+		envFilePaths = append(envFilePaths, envFilePath)
+	}
+
+	return fn(envFilePaths)
+}
+
 // createEnvFile creates a file that would hold both "in-place" and default environment variables.
+// The default .env file is skipped when the stack declares its own env files.
 // It will return the name of the file if the stack has "in-place" env vars, otherwise empty string.
 func createEnvFile(stack *portainer.Stack) (string, error) {
 	if len(stack.Env) == 0 {
@@ -183,13 +332,20 @@ func createEnvFile(stack *portainer.Stack) (string, error) {
 	defer envfile.Close()
 
 	// Copy from default .env file
-	defaultEnvPath := path.Join(stack.ProjectPath, path.Dir(stack.EntryPoint), ".env")
-	if err := copyDefaultEnvFile(envfile, defaultEnvPath); err != nil {
+	if len(stack.EnvFiles) == 0 {
+		defaultEnvPath := path.Join(stack.ProjectPath, path.Dir(stack.EntryPoint), ".env")
+		if err := copyDefaultEnvFile(envfile, defaultEnvPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Copy from stack env vars, the secrets are only decrypted inside the env file
+	env, err := envsecrets.Open(stack.Env)
+	if err != nil {
 		return "", err
 	}
 
-	// Copy from stack env vars
-	if err := copyConfigEnvVars(envfile, stack.Env); err != nil {
+	if err := copyConfigEnvVars(envfile, env); err != nil {
 		return "", err
 	}
 
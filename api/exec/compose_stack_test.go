@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path"
@@ -8,7 +9,10 @@ import (
 	"testing"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_createEnvFile(t *testing.T) {
@@ -86,3 +90,62 @@ func Test_createEnvFile_mergesDefultAndInplaceEnvVars(t *testing.T) {
 
 	assert.Equal(t, []byte("VAR1=VAL1\nVAR2=VAL2\n\nVAR1=NEW_VAL1\nVAR3=VAL3\n"), content)
 }
+
+func Test_createEnvFile_decryptsSecrets(t *testing.T) {
+	require.NoError(t, envsecrets.Init(t.TempDir()))
+
+	dir := t.TempDir()
+
+	env, err := envsecrets.Seal([]portainer.Pair{
+		{Name: "VAR1", Value: "VAL1"},
+		{Name: "TOKEN", Value: "s3cr3t", Secret: true},
+	}, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, "s3cr3t", env[1].Value)
+
+	result, err := createEnvFile(&portainer.Stack{ProjectPath: dir, Env: env})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(result)
+	require.NoError(t, err)
+	assert.Equal(t, "VAR1=VAL1\nTOKEN=s3cr3t\n", string(content))
+}
+
+func Test_withStackEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, ".env"), []byte("VAR1=VAL1\n"), 0600))
+
+	stack := &portainer.Stack{ProjectPath: dir, EntryPoint: "docker-compose.yml"}
+
+	err := withStackEnvFiles(stack, func(envFilePaths []string) error {
+		assert.Empty(t, envFilePaths, "the default .env file must be used when the stack has no env files")
+		return nil
+	})
+	require.NoError(t, err)
+
+	stack.EnvFiles = []string{"common.env", "config/production.env"}
+	stack.Env = []portainer.Pair{{Name: "VAR2", Value: "VAL2"}}
+
+	err = withStackEnvFiles(stack, func(envFilePaths []string) error {
+		assert.Equal(t, []string{
+			filepath.Join(dir, "common.env"),
+			filepath.Join(dir, "config/production.env"),
+			filepath.Join(dir, "stack.env"),
+		}, envFilePaths, "the in-place env vars must override the env files")
+
+		content, err := os.ReadFile(path.Join(dir, "stack.env"))
+		require.NoError(t, err)
+		assert.Equal(t, "VAR2=VAL2\n", string(content), "the default .env file must be skipped when the stack has env files")
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, path.Join(dir, "stack.env"), "the in-place env vars must not be left on disk")
+
+	err = withStackEnvFiles(stack, func(envFilePaths []string) error {
+		return errors.New("deploy failed")
+	})
+	require.Error(t, err)
+	assert.NoFileExists(t, path.Join(dir, "stack.env"), "the in-place env vars must not be left on disk when the operation fails")
+}
@@ -18,3 +18,7 @@ func (deployer *kubernetesMockDeployer) Deploy(userID portainer.UserID, endpoint
 func (deployer *kubernetesMockDeployer) Remove(userID portainer.UserID, endpoint *portainer.Endpoint, manifestFiles []string, namespace string) (string, error) {
 	return "", nil
 }
+
+func (deployer *kubernetesMockDeployer) Prune(userID portainer.UserID, endpoint *portainer.Endpoint, resources []portainer.KubernetesStackResource, namespace string, stackID portainer.StackID) (string, []portainer.KubernetesStackResource, error) {
+	return "", nil, nil
+}
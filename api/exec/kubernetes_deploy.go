@@ -6,7 +6,9 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
@@ -14,9 +16,11 @@ import (
 	"github.com/portainer/portainer/api/http/proxy"
 	"github.com/portainer/portainer/api/http/proxy/factory"
 	"github.com/portainer/portainer/api/http/proxy/factory/kubernetes"
+	k "github.com/portainer/portainer/api/kubernetes"
 	"github.com/portainer/portainer/api/kubernetes/cli"
 
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 // KubernetesDeployer represents a service to deploy resources inside a Kubernetes environment(endpoint).
@@ -87,7 +91,87 @@ func (deployer *KubernetesDeployer) Remove(userID portainer.UserID, endpoint *po
 	return deployer.command("delete", userID, endpoint, manifestFiles, namespace)
 }
 
-func (deployer *KubernetesDeployer) command(operation string, userID portainer.UserID, endpoint *portainer.Endpoint, manifestFiles []string, namespace string) (string, error) {
+// Prune deletes the resources which are still labelled as part of the stack.
+// The resources annotated with the prune protection annotation are kept. The resources are
+// retrieved and deleted kind by kind, the resources whose kind is no longer served by the cluster
+// are dropped and the ones which could not be pruned are returned along with the last error
+func (deployer *KubernetesDeployer) Prune(userID portainer.UserID, endpoint *portainer.Endpoint, resources []portainer.KubernetesStackResource, namespace string, stackID portainer.StackID) (string, []portainer.KubernetesStackResource, error) {
+	tmpDir, err := os.MkdirTemp("", "kub_prune")
+	if err != nil {
+		return "", resources, errors.Wrap(err, "failed to create temp kub prune directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var output strings.Builder
+	var remaining []portainer.KubernetesStackResource
+	var pruneErr error
+
+	for i, kindResources := range k.GroupResourcesByKind(resources) {
+		out, err := deployer.pruneKind(filepath.Join(tmpDir, strconv.Itoa(i)), userID, endpoint, kindResources, namespace, stackID)
+		if k.IsKindNotServedError(err) {
+			log.Info().
+				Str("kind", kindResources[0].Kind).
+				Str("api_version", kindResources[0].APIVersion).
+				Int("stack_id", int(stackID)).
+				Msg("the kind is no longer served by the cluster, its resources are no longer pruned")
+
+			continue
+		} else if err != nil {
+			remaining = append(remaining, kindResources...)
+			pruneErr = err
+
+			continue
+		}
+
+		output.WriteString(out)
+	}
+
+	return output.String(), remaining, pruneErr
+}
+
+// pruneKind deletes the prunable resources of a single kind
+func (deployer *KubernetesDeployer) pruneKind(dir string, userID portainer.UserID, endpoint *portainer.Endpoint, resources []portainer.KubernetesStackResource, namespace string, stackID portainer.StackID) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "failed to create temp kub prune directory")
+	}
+
+	resourcesFile, err := writeResourcesManifest(filepath.Join(dir, "resources.yaml"), resources)
+	if err != nil {
+		return "", err
+	}
+
+	objects, err := deployer.command("get", userID, endpoint, []string{resourcesFile}, namespace, "--output", "json")
+	if err != nil {
+		return "", err
+	}
+
+	prunable, err := k.PrunableResources([]byte(objects), stackID)
+	if err != nil || len(prunable) == 0 {
+		return "", err
+	}
+
+	prunableFile, err := writeResourcesManifest(filepath.Join(dir, "prunable.yaml"), prunable)
+	if err != nil {
+		return "", err
+	}
+
+	return deployer.command("delete", userID, endpoint, []string{prunableFile}, namespace)
+}
+
+func writeResourcesManifest(path string, resources []portainer.KubernetesStackResource) (string, error) {
+	manifest, err := k.ResourcesManifest(resources)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, manifest, 0600); err != nil {
+		return "", errors.Wrap(err, "failed to create temp manifest file")
+	}
+
+	return path, nil
+}
+
+func (deployer *KubernetesDeployer) command(operation string, userID portainer.UserID, endpoint *portainer.Endpoint, manifestFiles []string, namespace string, extraArgs ...string) (string, error) {
 	token, err := deployer.getToken(userID, endpoint, endpoint.Type == portainer.KubernetesLocalEnvironment)
 	if err != nil {
 		return "", errors.Wrap(err, "failed generating a user token")
@@ -114,11 +198,12 @@ func (deployer *KubernetesDeployer) command(operation string, userID portainer.U
 		args = append(args, "--insecure-skip-tls-verify")
 	}
 
-	if operation == "delete" {
+	if operation == "delete" || operation == "get" {
 		args = append(args, "--ignore-not-found=true")
 	}
 
 	args = append(args, operation)
+	args = append(args, extraArgs...)
 	for _, path := range manifestFiles {
 		args = append(args, "-f", strings.TrimSpace(path))
 	}
@@ -11,6 +11,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 
 	"github.com/rs/zerolog/log"
@@ -112,8 +113,13 @@ func (manager *SwarmStackManager) Deploy(stack *portainer.Stack, prune bool, pul
 	args = configureFilePaths(args, filePaths)
 	args = append(args, stack.Name)
 
+	stackEnv, err := envsecrets.Open(stack.Env)
+	if err != nil {
+		return err
+	}
+
 	env := make([]string, 0)
-	for _, envvar := range stack.Env {
+	for _, envvar := range stackEnv {
 		env = append(env, envvar.Name+"="+envvar.Value)
 	}
 
@@ -41,6 +41,10 @@ const (
 	PrivateKeyFile = "portainer.key"
 	// PublicKeyFile represents the name on disk of the file containing the public key.
 	PublicKeyFile = "portainer.pub"
+	// TemplateBundlePrivateKeyFile represents the name on disk of the file containing the private key signing the template bundles.
+	TemplateBundlePrivateKeyFile = "template_bundle.key"
+	// TemplateBundlePublicKeyFile represents the name on disk of the file containing the public key of the template bundles.
+	TemplateBundlePublicKeyFile = "template_bundle.pub"
 	// BinaryStorePath represents the subfolder where binaries are stored in the file store folder.
 	BinaryStorePath = "bin"
 	// EdgeJobStorePath represents the subfolder where schedule files are stored.
@@ -616,13 +620,42 @@ func (service *Service) FileExists(filePath string) (bool, error) {
 
 // KeyPairFilesExist checks for the existence of the key files.
 func (service *Service) KeyPairFilesExist() (bool, error) {
-	privateKeyPath := JoinPaths(service.dataStorePath, PrivateKeyFile)
+	return service.keyPairFilesExist(PrivateKeyFile, PublicKeyFile)
+}
+
+// StoreKeyPair store the specified keys content as PEM files on disk.
+func (service *Service) StoreKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error {
+	return service.storeKeyPair(private, public, privatePEMHeader, publicPEMHeader, PrivateKeyFile, PublicKeyFile)
+}
+
+// LoadKeyPair retrieve the content of both key files on disk.
+func (service *Service) LoadKeyPair() ([]byte, []byte, error) {
+	return service.loadKeyPair(PrivateKeyFile, PublicKeyFile)
+}
+
+// TemplateBundleKeyPairFilesExist checks for the existence of the files of the key pair signing the template bundles.
+func (service *Service) TemplateBundleKeyPairFilesExist() (bool, error) {
+	return service.keyPairFilesExist(TemplateBundlePrivateKeyFile, TemplateBundlePublicKeyFile)
+}
+
+// StoreTemplateBundleKeyPair store the content of the key pair signing the template bundles as PEM files on disk.
+func (service *Service) StoreTemplateBundleKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error {
+	return service.storeKeyPair(private, public, privatePEMHeader, publicPEMHeader, TemplateBundlePrivateKeyFile, TemplateBundlePublicKeyFile)
+}
+
+// LoadTemplateBundleKeyPair retrieve the content of the key pair signing the template bundles.
+func (service *Service) LoadTemplateBundleKeyPair() ([]byte, []byte, error) {
+	return service.loadKeyPair(TemplateBundlePrivateKeyFile, TemplateBundlePublicKeyFile)
+}
+
+func (service *Service) keyPairFilesExist(privateKeyFile, publicKeyFile string) (bool, error) {
+	privateKeyPath := JoinPaths(service.dataStorePath, privateKeyFile)
 	exists, err := service.FileExists(privateKeyPath)
 	if err != nil || !exists {
 		return false, err
 	}
 
-	publicKeyPath := JoinPaths(service.dataStorePath, PublicKeyFile)
+	publicKeyPath := JoinPaths(service.dataStorePath, publicKeyFile)
 	exists, err = service.FileExists(publicKeyPath)
 	if err != nil || !exists {
 		return false, err
@@ -631,24 +664,22 @@ func (service *Service) KeyPairFilesExist() (bool, error) {
 	return true, nil
 }
 
-// StoreKeyPair store the specified keys content as PEM files on disk.
-func (service *Service) StoreKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error {
-	err := service.createPEMFileInStore(private, privatePEMHeader, PrivateKeyFile)
+func (service *Service) storeKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader, privateKeyFile, publicKeyFile string) error {
+	err := service.createPEMFileInStore(private, privatePEMHeader, privateKeyFile)
 	if err != nil {
 		return err
 	}
 
-	return service.createPEMFileInStore(public, publicPEMHeader, PublicKeyFile)
+	return service.createPEMFileInStore(public, publicPEMHeader, publicKeyFile)
 }
 
-// LoadKeyPair retrieve the content of both key files on disk.
-func (service *Service) LoadKeyPair() ([]byte, []byte, error) {
-	privateKey, err := service.getContentFromPEMFile(PrivateKeyFile)
+func (service *Service) loadKeyPair(privateKeyFile, publicKeyFile string) ([]byte, []byte, error) {
+	privateKey, err := service.getContentFromPEMFile(privateKeyFile)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	publicKey, err := service.getContentFromPEMFile(PublicKeyFile)
+	publicKey, err := service.getContentFromPEMFile(publicKeyFile)
 	if err != nil {
 		return nil, nil, err
 	}
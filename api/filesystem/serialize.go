@@ -17,12 +17,13 @@ type DirEntry struct {
 	Permissions os.FileMode
 }
 
-// FilterDirForEntryFile filers the given dirEntries, returns entries of the entryFile and .env file
-func FilterDirForEntryFile(dirEntries []DirEntry, entryFile string) []DirEntry {
+// FilterDirForEntryFile filers the given dirEntries, returns entries of the entryFile and .env file.
+// The extraFiles, such as the env files or the files included by the entryFile, are kept as well
+func FilterDirForEntryFile(dirEntries []DirEntry, entryFile string, extraFiles ...string) []DirEntry {
 	var filteredDirEntries []DirEntry
 
 	dotEnvFile := filepath.Join(filepath.Dir(entryFile), ".env")
-	filters := []string{entryFile, dotEnvFile}
+	filters := append([]string{entryFile, dotEnvFile}, extraFiles...)
 
 	for _, dirEntry := range dirEntries {
 		match := false
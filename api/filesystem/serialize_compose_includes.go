@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// composeInclude is the part of a compose file describing the files it includes
+type composeInclude struct {
+	Include []composeIncludeItem `yaml:"include"`
+}
+
+// composeIncludeItem is an entry of the compose include directive, either a path
+// or an object holding the paths of the included files and of their env files
+type composeIncludeItem struct {
+	Path             stringOrList `yaml:"path"`
+	EnvFile          stringOrList `yaml:"env_file"`
+	ProjectDirectory string       `yaml:"project_directory"`
+}
+
+type stringOrList []string
+
+func (l *stringOrList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = []string{value.Value}
+
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+
+	*l = list
+
+	return nil
+}
+
+func (i *composeIncludeItem) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		i.Path = []string{value.Value}
+
+		return nil
+	}
+
+	type plain composeIncludeItem
+
+	return value.Decode((*plain)(i))
+}
+
+// ComposeIncludedFiles returns the files included, directly or not, by the given compose
+// entry file through the include directive, along with their env files. The paths are relative
+// to the root of the dirEntries, the remote includes and the files outside of it are ignored
+func ComposeIncludedFiles(dirEntries []DirEntry, entryFile string) []string {
+	files := make(map[string]DirEntry)
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsFile {
+			files[filepath.Clean(dirEntry.Name)] = dirEntry
+		}
+	}
+
+	var includedFiles []string
+
+	included := make(map[string]struct{})
+	addIncludedFile := func(file string) {
+		if _, ok := included[file]; !ok {
+			included[file] = struct{}{}
+			includedFiles = append(includedFiles, file)
+		}
+	}
+
+	visited := map[string]struct{}{filepath.Clean(entryFile): {}}
+	queue := []string{filepath.Clean(entryFile)}
+
+	for len(queue) > 0 {
+		composeFile := queue[0]
+		queue = queue[1:]
+
+		dirEntry, ok := files[composeFile]
+		if !ok {
+			continue
+		}
+
+		content, err := DecodeFileContent(dirEntry.Content)
+		if err != nil {
+			log.Warn().Err(err).Str("file", composeFile).Msg("unable to decode the compose file")
+
+			continue
+		}
+
+		var include composeInclude
+		if err := yaml.Unmarshal([]byte(content), &include); err != nil {
+			log.Debug().Err(err).Str("file", composeFile).Msg("unable to parse the include directive of the compose file")
+
+			continue
+		}
+
+		dir := filepath.Dir(composeFile)
+
+		for _, item := range include.Include {
+			for _, path := range item.Path {
+				file, ok := localComposePath(dir, path)
+				if !ok {
+					continue
+				}
+
+				if _, ok := visited[file]; !ok {
+					visited[file] = struct{}{}
+					queue = append(queue, file)
+					addIncludedFile(file)
+				}
+
+				projectDir := filepath.Dir(file)
+				if item.ProjectDirectory != "" {
+					if d, ok := localComposePath(dir, item.ProjectDirectory); ok {
+						projectDir = d
+					}
+				}
+
+				addIncludedFile(filepath.Join(projectDir, ".env"))
+			}
+
+			for _, envFile := range item.EnvFile {
+				if file, ok := localComposePath(dir, envFile); ok {
+					addIncludedFile(file)
+				}
+			}
+		}
+	}
+
+	return includedFiles
+}
+
+// localComposePath resolves the path relative to the dir of the compose file including it,
+// it returns false for the remote resources and the paths escaping the project
+func localComposePath(dir, path string) (string, bool) {
+	if path == "" || strings.Contains(path, "://") || strings.HasPrefix(path, "git@") || filepath.IsAbs(path) {
+		return "", false
+	}
+
+	file := filepath.Join(dir, path)
+	if !filepath.IsLocal(file) {
+		return "", false
+	}
+
+	return file, true
+}
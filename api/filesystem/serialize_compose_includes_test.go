@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodedFile(name, content string) DirEntry {
+	return DirEntry{Name: name, Content: base64.StdEncoding.EncodeToString([]byte(content)), IsFile: true, Permissions: 0o644}
+}
+
+func TestComposeIncludedFiles(t *testing.T) {
+	dirEntries := []DirEntry{
+		encodedFile("docker-compose.yml", `include:
+  - db/compose.yml
+  - path: [cache/compose.yml, cache/override.yml]
+    env_file: cache/cache.env
+  - oci://registry/project:latest
+  - ../outside/compose.yml
+services:
+  web:
+    image: nginx
+`),
+		{"db", "", false, 0o755},
+		encodedFile("db/compose.yml", `include:
+  - ../shared/volumes.yml
+services:
+  db:
+    image: postgres
+`),
+		{"cache", "", false, 0o755},
+		encodedFile("cache/compose.yml", "services: {}"),
+		encodedFile("cache/override.yml", "services: {}"),
+		encodedFile("cache/cache.env", "TAG=7"),
+		{"shared", "", false, 0o755},
+		encodedFile("shared/volumes.yml", `include:
+  - ../docker-compose.yml
+`),
+		encodedFile("unrelated.yml", "services: {}"),
+	}
+
+	assert.ElementsMatch(t, []string{
+		"db/compose.yml", "db/.env",
+		"cache/compose.yml", "cache/override.yml", "cache/.env", "cache/cache.env",
+		"shared/volumes.yml", "shared/.env",
+		".env",
+	}, ComposeIncludedFiles(dirEntries, "docker-compose.yml"))
+
+	filtered := FilterDirForEntryFile(dirEntries, "docker-compose.yml", ComposeIncludedFiles(dirEntries, "docker-compose.yml")...)
+
+	var names []string
+	for _, dirEntry := range filtered {
+		names = append(names, dirEntry.Name)
+	}
+
+	assert.NotContains(t, names, "unrelated.yml")
+	assert.Subset(t, names, []string{"docker-compose.yml", "db", "db/compose.yml", "cache/cache.env", "shared/volumes.yml"})
+}
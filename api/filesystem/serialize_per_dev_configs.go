@@ -26,6 +26,20 @@ func MultiFilterDirForPerDevConfigs(dirEntries []DirEntry, configPath string, mu
 	return deduplicate(filteredDirEntries)
 }
 
+// FilterDirForEntryFileAndPerDevConfigs filters the given dirEntries, returns the entries of the entryFile and .env file
+// along with the entries of the extraFiles and of the configPath dir matching the given device
+func FilterDirForEntryFileAndPerDevConfigs(dirEntries []DirEntry, entryFile, configPath string, multiFilterArgs MultiFilterArgs, extraFiles ...string) []DirEntry {
+	filteredDirEntries := FilterDirForEntryFile(dirEntries, entryFile, extraFiles...)
+
+	for _, dirEntry := range MultiFilterDirForPerDevConfigs(dirEntries, configPath, multiFilterArgs) {
+		if isInConfigDir(dirEntry, configPath) {
+			filteredDirEntries = append(filteredDirEntries, dirEntry)
+		}
+	}
+
+	return deduplicate(filteredDirEntries)
+}
+
 func deduplicate(dirEntries []DirEntry) []DirEntry {
 	var deduplicatedDirEntries []DirEntry
 
@@ -111,3 +111,25 @@ func TestIsInConfigDir(t *testing.T) {
 	f(DirEntry{Name: "edgestacktest/edge-configs/standalone-edge-agent-async"}, "edgestacktest/edge-configs", true)
 	f(DirEntry{Name: "edgestacktest/edge-configs/abc.txt"}, "edgestacktest/edge-configs", true)
 }
+
+func TestFilterDirForEntryFileAndPerDevConfigs(t *testing.T) {
+	dirEntries := []DirEntry{
+		{".env", "", true, 420},
+		{"docker-compose.yaml", "", true, 420},
+		{"README.md", "", true, 420},
+		{"configs", "", false, 420},
+		{"configs/edge-1.conf", "", true, 420},
+		{"configs/edge-2.conf", "", true, 420},
+		{"configs/group-a", "", false, 420},
+		{"configs/group-a/config", "", true, 420},
+		{"configs/group-b", "", false, 420},
+		{"configs/group-b/config", "", true, 420},
+	}
+
+	got := FilterDirForEntryFileAndPerDevConfigs(dirEntries, "docker-compose.yaml", "configs", MultiFilterArgs{
+		{"edge-1", portainer.PerDevConfigsTypeFile},
+		{"group-b", portainer.PerDevConfigsTypeDir},
+	})
+
+	assert.Equal(t, []DirEntry{dirEntries[0], dirEntries[1], dirEntries[4], dirEntries[8], dirEntries[9]}, got)
+}
@@ -80,6 +80,10 @@ func Test_backupHandlerWithoutPassword_shouldCreateATarballArchive(t *testing.T)
 	contains(t, createdFiles, path.Join(tmpdir, "portainer.pub"))
 	contains(t, createdFiles, path.Join(tmpdir, "tls", "file1"))
 	contains(t, createdFiles, path.Join(tmpdir, "tls", "file2"))
+	contains(t, createdFiles, path.Join(tmpdir, "compose", "1", "docker-compose.yml"))
+	assert.NotContains(t, createdFiles, path.Join(tmpdir, "compose", "1", "stack.env"), "the plaintext env vars must not be archived")
+	contains(t, createdFiles, path.Join(tmpdir, "compose", "1", "config", "stack.env"))
+	assert.NotContains(t, createdFiles, path.Join(tmpdir, "stack_env.key"), "the key of the secret env vars must only be archived with a password")
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_file"))
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_folder", "file1"))
 }
@@ -128,6 +132,8 @@ func Test_backupHandlerWithPassword_shouldCreateEncryptedATarballArchive(t *test
 	contains(t, createdFiles, path.Join(tmpdir, "portainer.pub"))
 	contains(t, createdFiles, path.Join(tmpdir, "tls", "file1"))
 	contains(t, createdFiles, path.Join(tmpdir, "tls", "file2"))
+	contains(t, createdFiles, path.Join(tmpdir, "stack_env.key"))
+	assert.NotContains(t, createdFiles, path.Join(tmpdir, "compose", "1", "stack.env"), "the plaintext env vars must not be archived")
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_file"))
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_folder", "file1"))
 }
@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/portainer/portainer/api/crypto"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type encryptionKeyRotatePayload struct {
+	// Name of the secret holding the new encryption key, read from /run/secrets/<SecretKeyName>
+	SecretKeyName string `example:"portainer-2024" validate:"required"`
+}
+
+type encryptionKeyRotateResponse struct {
+	// Path of the database backup taken before the rotation, still encrypted with the previous key
+	BackupPath string `example:"/data/backups/portainer.edb.1717200000.bak"`
+}
+
+func (p *encryptionKeyRotatePayload) Validate(r *http.Request) error {
+	if p.SecretKeyName == "" {
+		return errors.New("invalid secret key name")
+	}
+
+	return nil
+}
+
+// @id EncryptionKeyRotate
+// @summary Re-encrypts the database with a new encryption key
+// @description Re-encrypts every object of the database with the key read from /run/secrets/<SecretKeyName>.
+// @description A backup of the database is taken beforehand and the store is left untouched if any object fails to be re-encrypted.
+// @description The name of the new secret is recorded in the data directory, Portainer refuses to start until --secret-key-name
+// @description is set to the new secret and the new secret must remain mounted.
+// @description **Access policy**: admin
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param body body encryptionKeyRotatePayload true "Name of the secret holding the new key"
+// @success 200 {object} encryptionKeyRotateResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /backup/encryption_key [post]
+func (h *Handler) encryptionKeyRotate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload encryptionKeyRotatePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	newKey, err := crypto.LoadSecretKey(payload.SecretKeyName)
+	if err != nil {
+		return httperror.BadRequest("Unable to read the new encryption key", err)
+	}
+
+	unlock := h.gate.Lock()
+	defer unlock()
+
+	backupPath, err := h.dataStore.RotateEncryptionKey(newKey)
+	if errors.Is(err, dserrors.ErrNotEncrypted) || errors.Is(err, dserrors.ErrSameEncryptionKey) {
+		return httperror.BadRequest("Unable to rotate the encryption key", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to rotate the encryption key", err)
+	}
+
+	if err := crypto.SaveRotatedSecretKeyName(h.filestorePath, payload.SecretKeyName); err != nil {
+		return httperror.InternalServerError("The encryption key was rotated but the name of the new secret could not be recorded, restart Portainer with --secret-key-name set to the new secret", err)
+	}
+
+	return response.JSON(w, encryptionKeyRotateResponse{BackupPath: backupPath})
+}
@@ -44,6 +44,8 @@ func NewHandler(
 	}
 
 	h.Handle("/backup", bouncer.RestrictedAccess(adminAccess(httperror.LoggerHandler(h.backup)))).Methods(http.MethodPost)
+	h.Handle("/backup/encryption_key", bouncer.RestrictedAccess(adminAccess(httperror.LoggerHandler(h.encryptionKeyRotate)))).Methods(http.MethodPost)
+	h.Handle("/backup/integrity", bouncer.RestrictedAccess(adminAccess(httperror.LoggerHandler(h.integrityCheck)))).Methods(http.MethodPost)
 	h.Handle("/restore", bouncer.PublicAccess(httperror.LoggerHandler(h.restore))).Methods(http.MethodPost)
 
 	return h
@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type integrityCheckPayload struct {
+	// Remove the orphaned references
+	Repair bool `example:"false"`
+	// Rewrite the database into a fresh file once checked
+	Compact bool `example:"false"`
+}
+
+func (p *integrityCheckPayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// @id IntegrityCheck
+// @summary Checks the integrity of the database
+// @description Checks that every object of the database can be decoded and looks for orphaned references,
+// @description such as stacks of deleted environments, resource controls of deleted stacks and custom templates
+// @description and environment relations to deleted edge stacks. The orphaned references can be removed and the
+// @description database compacted. Write requests are held while the check runs.
+// @description **Access policy**: admin
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param body body integrityCheckPayload true "Maintenance options"
+// @success 200 {object} dataservices.IntegrityReport "Success"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /backup/integrity [post]
+func (h *Handler) integrityCheck(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload integrityCheckPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	unlock := h.gate.Lock()
+	defer unlock()
+
+	report, err := h.dataStore.Maintain(payload.Repair, payload.Compact)
+	if err != nil {
+		return httperror.InternalServerError("Unable to check the database integrity", err)
+	}
+
+	return response.JSON(w, report)
+}
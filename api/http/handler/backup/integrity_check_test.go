@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/adminmonitor"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_integrityCheckCompactsTheDatabase(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	h := NewHandler(
+		testhelpers.NewTestRequestBouncer(),
+		store,
+		offlinegate.NewOfflineGate(),
+		t.TempDir(),
+		func() {},
+		adminmonitor.New(time.Hour, nil, context.Background()),
+	)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Compact":true}`))
+	w := httptest.NewRecorder()
+
+	require.Nil(t, h.integrityCheck(w, r))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report dataservices.IntegrityReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.NotZero(t, report.DatabaseSize)
+	require.NotZero(t, report.CompactedDatabaseSize)
+
+	// The store is reopened once compacted
+	require.NoError(t, store.Tag().Create(&portainer.Tag{Name: "tag"}))
+}
@@ -0,0 +1,99 @@
+package customtemplates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/templatebundle"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+)
+
+type customTemplateExportPayload struct {
+	// Identifiers of the custom templates to export
+	CustomTemplateIDs []portainer.CustomTemplateID `example:"1,2" validate:"required"`
+}
+
+func (payload *customTemplateExportPayload) Validate(r *http.Request) error {
+	if len(payload.CustomTemplateIDs) == 0 {
+		return errors.New("Invalid custom template identifiers. At least one template must be specified")
+	}
+
+	return nil
+}
+
+// @id CustomTemplateExport
+// @summary Export custom templates
+// @description Export one or many custom templates as a bundle signed by the instance.
+// @description The bundle is a tar archive containing the templates metadata, their variables and their files.
+// @description Git credentials are never exported.
+// @description **Access policy**: authenticated
+// @tags custom_templates
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce application/x-tar
+// @param body body customTemplateExportPayload true "Custom templates to export"
+// @success 200 {file} file "Bundle"
+// @failure 400 "Invalid request"
+// @failure 403 "Access denied to a custom template"
+// @failure 404 "Custom template not found"
+// @failure 500 "Server error"
+// @router /custom_templates/export [post]
+func (handler *Handler) customTemplateExport(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload customTemplateExportPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user info from request context", err)
+	}
+
+	templates := make([]templatebundle.Template, 0, len(payload.CustomTemplateIDs))
+	for _, customTemplateID := range payload.CustomTemplateIDs {
+		customTemplate, err := handler.DataStore.CustomTemplate().Read(customTemplateID)
+		if handler.DataStore.IsErrObjectNotFound(err) {
+			return httperror.NotFound("Unable to find a custom template with the specified identifier inside the database", err)
+		} else if err != nil {
+			return httperror.InternalServerError("Unable to find a custom template with the specified identifier inside the database", err)
+		}
+
+		if !userCanEditTemplate(customTemplate, securityContext) {
+			return httperror.Forbidden("Access denied to resource", httperrors.ErrResourceAccessDenied)
+		}
+
+		template, err := templatebundle.FromCustomTemplate(customTemplate)
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve custom template files from disk", err)
+		}
+
+		templates = append(templates, *template)
+	}
+
+	privateKey, publicKey, err := handler.FileService.LoadTemplateBundleKeyPair()
+	if err != nil {
+		return httperror.InternalServerError("Unable to load the key pair used to sign the bundle", err)
+	}
+
+	var bundle bytes.Buffer
+	if err := templatebundle.Write(&bundle, privateKey, publicKey, templates); err != nil {
+		return httperror.InternalServerError("Unable to create the custom templates bundle", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=portainer-custom-templates_%s.tar", time.Now().Format("2006-01-02_15-04-05")))
+
+	if _, err := w.Write(bundle.Bytes()); err != nil {
+		return httperror.InternalServerError("Unable to write the custom templates bundle", err)
+	}
+
+	return nil
+}
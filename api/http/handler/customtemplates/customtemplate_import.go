@@ -0,0 +1,278 @@
+package customtemplates
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/authorization"
+	"github.com/portainer/portainer/api/internal/templatebundle"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// importConflictRename imports the template under a new title when the title is already used
+	importConflictRename = "rename"
+	// importConflictSkip skips the templates whose title is already used
+	importConflictSkip = "skip"
+	// importConflictFail rejects the whole bundle when a title is already used
+	importConflictFail = "fail"
+)
+
+type customTemplateImportPayload struct {
+	Bundle []byte
+	// Behavior when a template with the same title already exists, rename (default), skip or fail
+	OnConflict string
+	// Hexadecimal encoded public key that must have signed the bundle
+	TrustedPublicKey string
+}
+
+func (payload *customTemplateImportPayload) Validate(r *http.Request) error {
+	bundle, _, err := request.RetrieveMultiPartFormFile(r, "file")
+	if err != nil {
+		return errors.New("Invalid bundle file. Ensure that the bundle is uploaded correctly")
+	}
+	payload.Bundle = bundle
+
+	onConflict, _ := request.RetrieveMultiPartFormValue(r, "OnConflict", true)
+	switch onConflict {
+	case "":
+		onConflict = importConflictRename
+	case importConflictRename, importConflictSkip, importConflictFail:
+	default:
+		return errors.New("Invalid OnConflict value. Valid values are rename, skip or fail")
+	}
+	payload.OnConflict = onConflict
+
+	trustedPublicKey, err := request.RetrieveMultiPartFormValue(r, "TrustedPublicKey", false)
+	if err != nil {
+		return errors.New("Invalid TrustedPublicKey. The public key of the instance that signed the bundle is required")
+	}
+	if _, err := hex.DecodeString(trustedPublicKey); err != nil {
+		return errors.New("Invalid TrustedPublicKey. The public key must be hexadecimal encoded")
+	}
+	payload.TrustedPublicKey = trustedPublicKey
+
+	return nil
+}
+
+type customTemplateImportResponse struct {
+	// Hexadecimal encoded public key of the instance that signed the bundle
+	PublicKey string
+	// True when the bundle was signed by this instance
+	SignedByThisInstance bool
+	// Custom templates created from the bundle
+	CustomTemplates []portainer.CustomTemplate
+	// Titles of the templates skipped because the title is already used
+	Skipped []string
+}
+
+// @id CustomTemplateImport
+// @summary Import custom templates
+// @description Import the custom templates of a bundle created by the export operation.
+// @description The signature of the bundle is verified against the trusted public key and the checksum of every
+// @description file is verified, the templates are then created and owned by the user importing them.
+// @description **Access policy**: authenticated
+// @tags custom_templates
+// @security ApiKeyAuth
+// @security jwt
+// @accept multipart/form-data
+// @produce json
+// @param file formData file true "Bundle"
+// @param OnConflict formData string false "Behavior when a template with the same title already exists" Enums(rename, skip, fail)
+// @param TrustedPublicKey formData string true "Hexadecimal encoded public key that must have signed the bundle"
+// @success 200 {object} customTemplateImportResponse "Success"
+// @failure 400 "Invalid request or invalid bundle"
+// @failure 409 "A template with the same title already exists"
+// @failure 500 "Server error"
+// @router /custom_templates/import [post]
+func (handler *Handler) customTemplateImport(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload customTemplateImportPayload
+	if err := payload.Validate(r); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user details from authentication token", err)
+	}
+
+	manifest, err := templatebundle.Read(bytes.NewReader(payload.Bundle), payload.TrustedPublicKey)
+	if err != nil {
+		return httperror.BadRequest("Invalid bundle", err)
+	}
+
+	for _, template := range manifest.Templates {
+		if err := validateBundleTemplate(template); err != nil {
+			return httperror.BadRequest("Invalid bundle", fmt.Errorf("invalid template %q: %w", template.Title, err))
+		}
+	}
+
+	resp := customTemplateImportResponse{
+		PublicKey:       manifest.PublicKey,
+		CustomTemplates: []portainer.CustomTemplate{},
+		Skipped:         []string{},
+	}
+
+	if _, publicKey, err := handler.FileService.LoadTemplateBundleKeyPair(); err == nil {
+		resp.SignedByThisInstance = manifest.PublicKey == hex.EncodeToString(publicKey)
+	}
+
+	// The templates are imported all at once, the files stored for them are removed when the import fails
+	var projectPaths []string
+
+	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		customTemplates, err := tx.CustomTemplate().ReadAll()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve custom templates from the database", err)
+		}
+
+		titles := map[string]bool{}
+		for _, customTemplate := range customTemplates {
+			titles[customTemplate.Title] = true
+		}
+
+		if payload.OnConflict == importConflictFail {
+			for _, template := range manifest.Templates {
+				if titles[template.Title] {
+					return httperror.Conflict("Template name must be unique", fmt.Errorf("a template named %q already exists", template.Title))
+				}
+			}
+		}
+
+		for _, template := range manifest.Templates {
+			title := template.Title
+			if titles[title] {
+				if payload.OnConflict == importConflictSkip {
+					resp.Skipped = append(resp.Skipped, title)
+
+					continue
+				}
+
+				title = uniqueTitle(title, titles)
+			}
+
+			customTemplate, err := handler.importBundleTemplate(tx, template, title, tokenData.ID, &projectPaths)
+			if err != nil {
+				return httperror.InternalServerError("Unable to import custom template", err)
+			}
+
+			titles[title] = true
+			resp.CustomTemplates = append(resp.CustomTemplates, *customTemplate)
+		}
+
+		return nil
+	})
+	if err != nil {
+		for _, projectPath := range projectPaths {
+			if err := handler.FileService.RemoveDirectory(projectPath); err != nil {
+				log.Warn().Err(err).Msg("unable to remove custom template directory")
+			}
+		}
+
+		var httpErr *httperror.HandlerError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+
+		return httperror.InternalServerError("Unable to import custom templates", err)
+	}
+
+	return response.JSON(w, resp)
+}
+
+// importBundleTemplate creates a custom template from a template of a bundle, the folder of the files stored
+// for the template is added to the projectPaths
+func (handler *Handler) importBundleTemplate(tx dataservices.DataStoreTx, template templatebundle.Template, title string, userID portainer.UserID, projectPaths *[]string) (*portainer.CustomTemplate, error) {
+	customTemplateID := tx.CustomTemplate().GetNextIdentifier()
+	templateFolder := strconv.Itoa(customTemplateID)
+
+	customTemplate := &portainer.CustomTemplate{
+		ID:              portainer.CustomTemplateID(customTemplateID),
+		Title:           title,
+		Description:     template.Description,
+		Note:            template.Note,
+		Logo:            template.Logo,
+		Platform:        template.Platform,
+		Type:            template.Type,
+		EntryPoint:      template.EntryPoint,
+		Variables:       template.Variables,
+		IsComposeFormat: template.IsComposeFormat,
+		EdgeTemplate:    template.EdgeTemplate,
+		CreatedByUserID: userID,
+		ProjectPath:     handler.FileService.GetCustomTemplateProjectPath(templateFolder),
+	}
+
+	if template.GitConfig != nil {
+		customTemplate.EntryPoint = ""
+		customTemplate.GitConfig = template.GitConfig
+		customTemplate.GitConfig.Authentication = nil
+	}
+
+	*projectPaths = append(*projectPaths, customTemplate.ProjectPath)
+
+	for _, file := range template.Files {
+		if err := os.MkdirAll(filepath.Dir(filesystem.JoinPaths(customTemplate.ProjectPath, file.Path)), 0700); err != nil {
+			return nil, err
+		}
+
+		if _, err := handler.FileService.StoreCustomTemplateFileFromBytes(templateFolder, file.Path, file.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.CustomTemplate().Create(customTemplate); err != nil {
+		return nil, err
+	}
+
+	resourceControl := authorization.NewPrivateResourceControl(strconv.Itoa(int(customTemplate.ID)), portainer.CustomTemplateResourceControl, userID)
+	if err := tx.ResourceControl().Create(resourceControl); err != nil {
+		return nil, err
+	}
+
+	customTemplate.ResourceControl = resourceControl
+
+	return customTemplate, nil
+}
+
+func validateBundleTemplate(template templatebundle.Template) error {
+	if len(template.Title) == 0 {
+		return errors.New("Invalid custom template title")
+	}
+	if len(template.Description) == 0 {
+		return errors.New("Invalid custom template description")
+	}
+	if template.Type != portainer.KubernetesStack && template.Platform != portainer.CustomTemplatePlatformLinux && template.Platform != portainer.CustomTemplatePlatformWindows {
+		return errors.New("Invalid custom template platform")
+	}
+	if template.Type != portainer.DockerSwarmStack && template.Type != portainer.DockerComposeStack && template.Type != portainer.KubernetesStack {
+		return errors.New("Invalid custom template type")
+	}
+	if !isValidNote(template.Note) {
+		return errors.New("Invalid note. <img> tag is not supported")
+	}
+
+	return validateVariablesDefinitions(template.Variables)
+}
+
+func uniqueTitle(title string, titles map[string]bool) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", title, i)
+		if !titles[candidate] {
+			return candidate
+		}
+	}
+}
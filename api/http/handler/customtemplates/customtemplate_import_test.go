@@ -0,0 +1,127 @@
+package customtemplates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/templatebundle"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_customTemplateImportRequiresTrustedPublicKey(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer(), store, &TestFileService{}, nil)
+
+	importBundle := func(trustedPublicKey string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+
+		file, err := w.CreateFormFile("file", "bundle.tar")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("bundle"))
+		require.NoError(t, err)
+
+		if trustedPublicKey != "" {
+			require.NoError(t, w.WriteField("TrustedPublicKey", trustedPublicKey))
+		}
+		require.NoError(t, w.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/custom_templates/import", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	rr := importBundle("")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "bundles must not be imported without a trusted public key")
+
+	rr = importBundle("not-hex")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_customTemplateImportIsAtomic(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	fileService, err := filesystem.NewService(t.TempDir(), "")
+	require.NoError(t, err)
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer(), store, fileService, nil)
+
+	private, public, err := crypto.NewECDSAService("").GenerateKeyPair()
+	require.NoError(t, err)
+
+	newTemplate := func(title string, files ...string) templatebundle.Template {
+		template := templatebundle.Template{
+			Title:       title,
+			Description: title,
+			Platform:    portainer.CustomTemplatePlatformLinux,
+			Type:        portainer.DockerComposeStack,
+			EntryPoint:  files[0],
+		}
+
+		content := []byte("services: {}")
+		checksum := sha256.Sum256(content)
+
+		for _, file := range files {
+			template.Files = append(template.Files, templatebundle.File{
+				Path:     file,
+				Checksum: hex.EncodeToString(checksum[:]),
+				Content:  content,
+			})
+		}
+
+		return template
+	}
+
+	// The files of the second template cannot be stored, a file and a folder share the same path
+	var bundle bytes.Buffer
+	require.NoError(t, templatebundle.Write(&bundle, private, public, []templatebundle.Template{
+		newTemplate("web", "docker-compose.yml"),
+		newTemplate("broken", "docker-compose.yml", "docker-compose.yml/override.yml"),
+	}))
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	file, err := w.CreateFormFile("file", "bundle.tar")
+	require.NoError(t, err)
+	_, err = file.Write(bundle.Bytes())
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteField("TrustedPublicKey", hex.EncodeToString(public)))
+	require.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/custom_templates/import", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req = req.WithContext(security.StoreTokenData(req, &portainer.TokenData{ID: 1, Username: "admin", Role: portainer.AdministratorRole}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+
+	customTemplates, err := store.CustomTemplate().ReadAll()
+	require.NoError(t, err)
+	require.Empty(t, customTemplates, "no template must be imported when one of them fails")
+
+	entries, err := os.ReadDir(fileService.GetCustomTemplateProjectPath(""))
+	if !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+	require.Empty(t, entries, "the files of the templates must be removed")
+}
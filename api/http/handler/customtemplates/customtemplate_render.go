@@ -0,0 +1,73 @@
+package customtemplates
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/templatevariables"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type customTemplateRenderPayload struct {
+	// Values of the template variables, the default value is used for the missing ones
+	Variables []templatevariables.Value
+}
+
+func (payload *customTemplateRenderPayload) Validate(r *http.Request) error {
+	return nil
+}
+
+// @id CustomTemplateRender
+// @summary Render a custom template
+// @description Validate the submitted variable values against the template variable definitions
+// @description and retrieve the content of the Stack file rendered with them.
+// @description **Access policy**: authenticated
+// @tags custom_templates
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Template identifier"
+// @param body body customTemplateRenderPayload true "Variable values"
+// @success 200 {object} fileResponse "Success"
+// @failure 400 "Invalid request or invalid variable values"
+// @failure 404 "Custom template not found"
+// @failure 500 "Server error"
+// @router /custom_templates/{id}/render [post]
+func (handler *Handler) customTemplateRender(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	customTemplateID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid custom template identifier route variable", err)
+	}
+
+	var payload customTemplateRenderPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	customTemplate, err := handler.DataStore.CustomTemplate().Read(portainer.CustomTemplateID(customTemplateID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a custom template with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a custom template with the specified identifier inside the database", err)
+	}
+
+	entryPath := customTemplate.EntryPoint
+	if customTemplate.GitConfig != nil {
+		entryPath = customTemplate.GitConfig.ConfigFilePath
+	}
+
+	fileContent, err := handler.FileService.GetFileContent(customTemplate.ProjectPath, entryPath)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve custom template file from disk", err)
+	}
+
+	rendered, err := templatevariables.Render(string(fileContent), customTemplate.Variables, payload.Variables)
+	if err != nil {
+		return httperror.BadRequest("Unable to render the custom template", err)
+	}
+
+	return response.JSON(w, &fileResponse{FileContent: rendered})
+}
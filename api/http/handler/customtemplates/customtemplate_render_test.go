@@ -0,0 +1,57 @@
+package customtemplates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_customTemplateRender(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	projectPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectPath, "docker-compose.yml"), []byte("ports:\n  - {{PORT}}:80\n"), 0600))
+
+	maxPort := float64(65535)
+	require.NoError(t, store.CustomTemplate().Create(&portainer.CustomTemplate{
+		ID:          1,
+		ProjectPath: projectPath,
+		EntryPoint:  "docker-compose.yml",
+		Variables: []portainer.CustomTemplateVariableDefinition{
+			{Name: "PORT", Type: portainer.CustomTemplateVariableTypeNumber, Required: true, Max: &maxPort},
+		},
+	}))
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer(), store, &TestFileService{}, nil)
+
+	render := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/custom_templates/1/render", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	rr := render(`{"Variables": [{"name": "PORT", "value": "8080"}]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var file fileResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&file))
+	require.Equal(t, "ports:\n  - 8080:80\n", file.FileContent)
+
+	rr = render(`{"Variables": [{"name": "PORT", "value": "70000"}]}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "values out of range must be rejected")
+
+	rr = render(`{"Variables": []}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "required values must be provided")
+}
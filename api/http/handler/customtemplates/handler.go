@@ -34,10 +34,16 @@ func NewHandler(bouncer security.BouncerService, dataStore dataservices.DataStor
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateCreate))).Methods(http.MethodPost)
 	h.Handle("/custom_templates",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateList))).Methods(http.MethodGet)
+	h.Handle("/custom_templates/export",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateExport))).Methods(http.MethodPost)
+	h.Handle("/custom_templates/import",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateImport))).Methods(http.MethodPost)
 	h.Handle("/custom_templates/{id}",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateInspect))).Methods(http.MethodGet)
 	h.Handle("/custom_templates/{id}/file",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateFile))).Methods(http.MethodGet)
+	h.Handle("/custom_templates/{id}/render",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateRender))).Methods(http.MethodPost)
 	h.Handle("/custom_templates/{id}",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.customTemplateUpdate))).Methods(http.MethodPut)
 	h.Handle("/custom_templates/{id}",
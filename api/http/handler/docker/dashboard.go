@@ -46,93 +46,56 @@ type dashboardResponse struct {
 // @failure 500 "Internal server error"
 // @router /docker/{environmentId}/dashboard [post]
 func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
-	var resp dashboardResponse
-	err := h.dataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
-		cli, httpErr := utils.GetClient(r, h.dockerClientFactory)
-		if httpErr != nil {
-			return httpErr
-		}
+	environment, err := middlewares.FetchEndpoint(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve environment", err)
+	}
 
-		context, err := security.RetrieveRestrictedRequestContext(r)
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve user details from request context", err)
-		}
+	context, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user details from request context", err)
+	}
 
-		containers, err := cli.ContainerList(r.Context(), container.ListOptions{All: true})
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve Docker containers", err)
-		}
+	resources, httpErr := h.dashboardResources(r, environment.ID)
+	if httpErr != nil {
+		return httpErr
+	}
 
-		containers, err = utils.FilterByResourceControl(tx, containers, portainer.ContainerResourceControl, context, func(c types.Container) string {
+	var resp dashboardResponse
+	err = h.dataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
+		containers, err := utils.FilterByResourceControl(tx, resources.containers, portainer.ContainerResourceControl, context, func(c types.Container) string {
 			return c.ID
 		})
 		if err != nil {
 			return err
 		}
 
-		images, err := cli.ImageList(r.Context(), image.ListOptions{})
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve Docker images", err)
-		}
-
 		var totalSize int64
-		for _, image := range images {
+		for _, image := range resources.images {
 			totalSize += image.Size
 		}
 
-		info, err := cli.Info(r.Context())
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve Docker info", err)
-		}
-
-		isSwarmManager := info.Swarm.ControlAvailable && info.Swarm.NodeID != ""
-
-		var services []swarm.Service
-		if isSwarmManager {
-			servicesRes, err := cli.ServiceList(r.Context(), types.ServiceListOptions{})
-			if err != nil {
-				return httperror.InternalServerError("Unable to retrieve Docker services", err)
-			}
-
-			filteredServices, err := utils.FilterByResourceControl(tx, servicesRes, portainer.ServiceResourceControl, context, func(c swarm.Service) string {
-				return c.ID
-			})
-			if err != nil {
-				return err
-			}
-
-			services = filteredServices
-		}
-
-		volumesRes, err := cli.VolumeList(r.Context(), volume.ListOptions{})
+		services, err := utils.FilterByResourceControl(tx, resources.services, portainer.ServiceResourceControl, context, func(c swarm.Service) string {
+			return c.ID
+		})
 		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve Docker volumes", err)
+			return err
 		}
 
-		volumes, err := utils.FilterByResourceControl(tx, volumesRes.Volumes, portainer.NetworkResourceControl, context, func(c *volume.Volume) string {
+		volumes, err := utils.FilterByResourceControl(tx, resources.volumes, portainer.NetworkResourceControl, context, func(c *volume.Volume) string {
 			return c.Name
 		})
 		if err != nil {
 			return err
 		}
 
-		networks, err := cli.NetworkList(r.Context(), types.NetworkListOptions{})
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve Docker networks", err)
-		}
-
-		networks, err = utils.FilterByResourceControl(tx, networks, portainer.NetworkResourceControl, context, func(c types.NetworkResource) string {
+		networks, err := utils.FilterByResourceControl(tx, resources.networks, portainer.NetworkResourceControl, context, func(c types.NetworkResource) string {
 			return c.Name
 		})
 		if err != nil {
 			return err
 		}
 
-		environment, err := middlewares.FetchEndpoint(r)
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve environment", err)
-		}
-
 		stackCount := 0
 		if environment.SecuritySettings.AllowStackManagementForRegularUsers || context.IsAdmin {
 			stacks, err := utils.GetDockerStacks(tx, context, environment.ID, containers, services)
@@ -145,7 +108,7 @@ func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) *httperror.H
 
 		resp = dashboardResponse{
 			Images: imagesCounters{
-				Total: len(images),
+				Total: len(resources.images),
 				Size:  totalSize,
 			},
 			Services:   len(services),
@@ -162,3 +125,69 @@ func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) *httperror.H
 		return response.JSON(w, resp)
 	})
 }
+
+type dashboardResources struct {
+	containers []types.Container
+	images     []image.Summary
+	services   []swarm.Service
+	volumes    []*volume.Volume
+	networks   []types.NetworkResource
+}
+
+// dashboardResources returns the resources of the environment from the live
+// inventory when it is available, they are retrieved from Docker otherwise.
+func (h *Handler) dashboardResources(r *http.Request, environmentID portainer.EndpointID) (*dashboardResources, *httperror.HandlerError) {
+	if inventory, ok := h.dockerInventory.Inventory(environmentID); ok {
+		return &dashboardResources{
+			containers: inventory.Containers(true),
+			images:     inventory.Images(),
+			services:   inventory.Services(),
+			volumes:    inventory.Volumes(),
+			networks:   inventory.Networks(),
+		}, nil
+	}
+
+	cli, httpErr := utils.GetClient(r, h.dockerClientFactory)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	resources := &dashboardResources{}
+
+	var err error
+	resources.containers, err = cli.ContainerList(r.Context(), container.ListOptions{All: true})
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve Docker containers", err)
+	}
+
+	resources.images, err = cli.ImageList(r.Context(), image.ListOptions{})
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve Docker images", err)
+	}
+
+	info, err := cli.Info(r.Context())
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve Docker info", err)
+	}
+
+	if info.Swarm.ControlAvailable && info.Swarm.NodeID != "" {
+		resources.services, err = cli.ServiceList(r.Context(), types.ServiceListOptions{})
+		if err != nil {
+			return nil, httperror.InternalServerError("Unable to retrieve Docker services", err)
+		}
+	}
+
+	volumes, err := cli.VolumeList(r.Context(), volume.ListOptions{})
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve Docker volumes", err)
+	}
+
+	resources.volumes = volumes.Volumes
+
+	resources.networks, err = cli.NetworkList(r.Context(), types.NetworkListOptions{})
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve Docker networks", err)
+	}
+
+	return resources, nil
+}
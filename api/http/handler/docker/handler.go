@@ -7,6 +7,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/docker"
 	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/docker/inventory"
 	"github.com/portainer/portainer/api/http/handler/docker/containers"
 	"github.com/portainer/portainer/api/http/handler/docker/images"
 	"github.com/portainer/portainer/api/http/middlewares"
@@ -26,10 +27,11 @@ type Handler struct {
 	dockerClientFactory  *dockerclient.ClientFactory
 	authorizationService *authorization.Service
 	containerService     *docker.ContainerService
+	dockerInventory      *inventory.Service
 }
 
 // NewHandler creates a handler to process non-proxied requests to docker APIs directly.
-func NewHandler(bouncer security.BouncerService, authorizationService *authorization.Service, dataStore dataservices.DataStore, dockerClientFactory *dockerclient.ClientFactory, containerService *docker.ContainerService) *Handler {
+func NewHandler(bouncer security.BouncerService, authorizationService *authorization.Service, dataStore dataservices.DataStore, dockerClientFactory *dockerclient.ClientFactory, containerService *docker.ContainerService, dockerInventory *inventory.Service) *Handler {
 	h := &Handler{
 		Router:               mux.NewRouter(),
 		requestBouncer:       bouncer,
@@ -37,6 +39,7 @@ func NewHandler(bouncer security.BouncerService, authorizationService *authoriza
 		dataStore:            dataStore,
 		dockerClientFactory:  dockerClientFactory,
 		containerService:     containerService,
+		dockerInventory:      dockerInventory,
 	}
 
 	// endpoints
@@ -3,6 +3,7 @@ package edgegroups
 import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 )
 
@@ -49,6 +50,43 @@ func GetEndpointsByTags(tx dataservices.DataStoreTx, tagIDs []portainer.TagID, p
 	return results, nil
 }
 
+// GetDynamicEdgeGroupEndpoints returns the trusted environments matching the tags and rules of a dynamic Edge group
+func GetDynamicEdgeGroupEndpoints(tx dataservices.DataStoreTx, edgeGroup *portainer.EdgeGroup) ([]portainer.EndpointID, error) {
+	if len(edgeGroup.Rules) == 0 {
+		return GetEndpointsByTags(tx, edgeGroup.TagIDs, edgeGroup.PartialMatch)
+	}
+
+	endpoints, err := tx.Endpoint().Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	endpointGroups, err := tx.EndpointGroup().ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := edge.FillEdgeGroupsSnapshotData(tx, []portainer.EdgeGroup{*edgeGroup}, endpoints); err != nil {
+		return nil, err
+	}
+
+	trustedSet := endpointSetType{}
+	for _, endpoint := range endpoints {
+		if endpoint.UserTrusted {
+			trustedSet[endpoint.ID] = true
+		}
+	}
+
+	results := []portainer.EndpointID{}
+	for _, endpointID := range edge.EdgeGroupRelatedEndpoints(edgeGroup, endpoints, endpointGroups) {
+		if trustedSet[endpointID] {
+			results = append(results, endpointID)
+		}
+	}
+
+	return results, nil
+}
+
 func getTrustedEndpoints(tx dataservices.DataStoreTx, endpointIDs []portainer.EndpointID) ([]portainer.EndpointID, error) {
 	results := []portainer.EndpointID{}
 	for _, endpointID := range endpointIDs {
@@ -6,6 +6,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -16,6 +17,7 @@ type edgeGroupCreatePayload struct {
 	Dynamic      bool
 	TagIDs       []portainer.TagID
 	Endpoints    []portainer.EndpointID
+	Rules        []portainer.EdgeGroupRule
 	PartialMatch bool
 }
 
@@ -24,16 +26,23 @@ func (payload *edgeGroupCreatePayload) Validate(r *http.Request) error {
 		return errors.New("invalid Edge group name")
 	}
 
-	if payload.Dynamic && len(payload.TagIDs) == 0 {
-		return errors.New("tagIDs is mandatory for a dynamic Edge group")
+	if payload.Dynamic && len(payload.TagIDs) == 0 && len(payload.Rules) == 0 {
+		return errors.New("tagIDs or rules are mandatory for a dynamic Edge group")
+	}
+
+	if payload.Dynamic {
+		if err := edge.ValidateEdgeGroupRules(payload.Rules); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func calculateEndpointsOrTags(tx dataservices.DataStoreTx, edgeGroup *portainer.EdgeGroup, endpoints []portainer.EndpointID, tagIDs []portainer.TagID) error {
+func calculateEndpointsOrTags(tx dataservices.DataStoreTx, edgeGroup *portainer.EdgeGroup, endpoints []portainer.EndpointID, tagIDs []portainer.TagID, rules []portainer.EdgeGroupRule) error {
 	if edgeGroup.Dynamic {
 		edgeGroup.TagIDs = tagIDs
+		edgeGroup.Rules = rules
 
 		return nil
 	}
@@ -94,10 +103,11 @@ func (handler *Handler) edgeGroupCreate(w http.ResponseWriter, r *http.Request)
 			Dynamic:      payload.Dynamic,
 			TagIDs:       []portainer.TagID{},
 			Endpoints:    []portainer.EndpointID{},
+			Rules:        []portainer.EdgeGroupRule{},
 			PartialMatch: payload.PartialMatch,
 		}
 
-		if err := calculateEndpointsOrTags(tx, edgeGroup, payload.Endpoints, payload.TagIDs); err != nil {
+		if err := calculateEndpointsOrTags(tx, edgeGroup, payload.Endpoints, payload.TagIDs, payload.Rules); err != nil {
 			return err
 		}
 
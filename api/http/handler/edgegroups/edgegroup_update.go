@@ -8,7 +8,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge"
-	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/slicesx"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -20,6 +20,7 @@ type edgeGroupUpdatePayload struct {
 	Dynamic      bool
 	TagIDs       []portainer.TagID
 	Endpoints    []portainer.EndpointID
+	Rules        []portainer.EdgeGroupRule
 	PartialMatch *bool
 }
 
@@ -28,8 +29,14 @@ func (payload *edgeGroupUpdatePayload) Validate(r *http.Request) error {
 		return errors.New("invalid Edge group name")
 	}
 
-	if payload.Dynamic && len(payload.TagIDs) == 0 {
-		return errors.New("tagIDs is mandatory for a dynamic Edge group")
+	if payload.Dynamic && len(payload.TagIDs) == 0 && len(payload.Rules) == 0 {
+		return errors.New("tagIDs or rules are mandatory for a dynamic Edge group")
+	}
+
+	if payload.Dynamic {
+		if err := edge.ValidateEdgeGroupRules(payload.Rules); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -94,10 +101,14 @@ func (handler *Handler) edgeGroupUpdate(w http.ResponseWriter, r *http.Request)
 			return httperror.InternalServerError("Unable to retrieve environment groups from database", err)
 		}
 
+		if err := edge.FillEdgeGroupsSnapshotData(tx, []portainer.EdgeGroup{*edgeGroup}, endpoints); err != nil {
+			return httperror.InternalServerError("Unable to retrieve environment snapshots from database", err)
+		}
+
 		oldRelatedEndpoints := edge.EdgeGroupRelatedEndpoints(edgeGroup, endpoints, endpointGroups)
 
 		edgeGroup.Dynamic = payload.Dynamic
-		if err := calculateEndpointsOrTags(tx, edgeGroup, payload.Endpoints, payload.TagIDs); err != nil {
+		if err := calculateEndpointsOrTags(tx, edgeGroup, payload.Endpoints, payload.TagIDs, payload.Rules); err != nil {
 			return err
 		}
 
@@ -105,10 +116,27 @@ func (handler *Handler) edgeGroupUpdate(w http.ResponseWriter, r *http.Request)
 			edgeGroup.PartialMatch = *payload.PartialMatch
 		}
 
+		edgeStacks, err := tx.EdgeStack().EdgeStacks()
+		if err != nil {
+			return err
+		}
+
+		if edge.EdgeGroupHasTimeBasedRules(edgeGroup) {
+			for _, edgeStack := range edgeStacks {
+				if slices.Contains(edgeStack.EdgeGroups, edgeGroup.ID) {
+					return httperror.BadRequest("Invalid Edge group rules", edge.ErrEdgeGroupTimeBasedRules)
+				}
+			}
+		}
+
 		if err := tx.EdgeGroup().Update(edgeGroup.ID, edgeGroup); err != nil {
 			return httperror.InternalServerError("Unable to persist Edge group changes inside the database", err)
 		}
 
+		if err := edge.FillEdgeGroupsSnapshotData(tx, []portainer.EdgeGroup{*edgeGroup}, endpoints); err != nil {
+			return httperror.InternalServerError("Unable to retrieve environment snapshots from database", err)
+		}
+
 		newRelatedEndpoints := edge.EdgeGroupRelatedEndpoints(edgeGroup, endpoints, endpointGroups)
 		endpointsToUpdate := slicesx.Unique(append(newRelatedEndpoints, oldRelatedEndpoints...))
 
@@ -117,11 +145,6 @@ func (handler *Handler) edgeGroupUpdate(w http.ResponseWriter, r *http.Request)
 			return httperror.InternalServerError("Unable to fetch Edge jobs", err)
 		}
 
-		edgeStacks, err := tx.EdgeStack().EdgeStacks()
-		if err != nil {
-			return err
-		}
-
 		// Update the edgeGroups with the modified edgeGroup for updateEndpointStacks()
 		for i := range edgeGroups {
 			if edgeGroups[i].ID == edgeGroup.ID {
@@ -154,7 +177,7 @@ func (handler *Handler) edgeGroupUpdate(w http.ResponseWriter, r *http.Request)
 				continue
 			}
 
-			if err := handler.updateEndpointEdgeJobs(edgeGroup.ID, endpoint, edgeJobs, operation); err != nil {
+			if err := handler.updateEndpointEdgeJobs(tx, edgeGroup.ID, endpoint, edgeJobs, operation); err != nil {
 				return httperror.InternalServerError("Unable to persist Environment Edge Jobs changes inside the database", err)
 			}
 		}
@@ -178,7 +201,11 @@ func (handler *Handler) updateEndpointStacks(tx dataservices.DataStoreTx, endpoi
 
 	edgeStackSet := map[portainer.EdgeStackID]bool{}
 
-	endpointEdgeStacks := edge.EndpointRelatedEdgeStacks(endpoint, endpointGroup, edgeGroups, edgeStacks)
+	endpointEdgeStacks, err := edge.EndpointRelatedEdgeStacks(tx, endpoint, endpointGroup, edgeGroups, edgeStacks)
+	if err != nil {
+		return err
+	}
+
 	for _, edgeStackID := range endpointEdgeStacks {
 		edgeStackSet[edgeStackID] = true
 	}
@@ -194,7 +221,7 @@ func (handler *Handler) updateEndpointStacks(tx dataservices.DataStoreTx, endpoi
 	return tx.EndpointRelation().UpdateEndpointRelation(endpoint.ID, relation)
 }
 
-func (handler *Handler) updateEndpointEdgeJobs(edgeGroupID portainer.EdgeGroupID, endpoint *portainer.Endpoint, edgeJobs []portainer.EdgeJob, operation string) error {
+func (handler *Handler) updateEndpointEdgeJobs(tx dataservices.DataStoreTx, edgeGroupID portainer.EdgeGroupID, endpoint *portainer.Endpoint, edgeJobs []portainer.EdgeJob, operation string) error {
 	for _, edgeJob := range edgeJobs {
 		if !slices.Contains(edgeJob.EdgeGroups, edgeGroupID) {
 			continue
@@ -202,7 +229,7 @@ func (handler *Handler) updateEndpointEdgeJobs(edgeGroupID portainer.EdgeGroupID
 
 		switch operation {
 		case "add", "remove":
-			cache.Del(endpoint.ID)
+			notify.SendOnCommit(tx, endpoint.ID, notify.JobScheduled)
 		}
 	}
 
@@ -11,7 +11,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge"
-	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -119,7 +119,7 @@ func (handler *Handler) createEdgeJob(tx dataservices.DataStoreTx, payload *edge
 	}
 
 	for _, endpointID := range endpoints {
-		cache.Del(endpointID)
+		notify.SendOnCommit(tx, endpointID, notify.JobScheduled)
 	}
 
 	return edgeJob, nil
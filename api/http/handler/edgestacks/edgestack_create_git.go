@@ -2,13 +2,18 @@ package edgestacks
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
+	"path/filepath"
+	"slices"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/filesystem"
 	gittypes "github.com/portainer/portainer/api/git/types"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
+	"github.com/portainer/portainer/api/stacks/stackutils"
 	"github.com/portainer/portainer/pkg/edge"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 
@@ -48,6 +53,25 @@ type edgeStackFromGitRepositoryPayload struct {
 	UseManifestNamespaces bool
 	// TLSSkipVerify skips SSL verification when cloning the Git repository
 	TLSSkipVerify bool `example:"false"`
+	// Deliver to each environment only its own configs from PerDeviceConfigsPath
+	SupportPerDeviceConfigs bool `example:"false"`
+	// Path, relative to the root of the repository, of the folder holding the per-device configs
+	PerDeviceConfigsPath string `example:"configs"`
+	// Attribute of the environment the per-device configs are named after
+	// Valid values are: 'edgeID', 'endpointName' or 'edgeGroupName'
+	PerDeviceConfigsMatchKey portainer.PerDevConfigsMatchKey `example:"edgeID" enums:"edgeID,endpointName,edgeGroupName" default:"edgeID"`
+	// Whether a per-device config is a single file or a folder
+	// Valid values are: 'file' or 'dir'
+	PerDeviceConfigsMatchType portainer.PerDevConfigsFilterType `example:"file" enums:"file,dir" default:"file"`
+	// Compose profiles to enable when deploying the stack
+	ComposeProfiles []string `example:"debug"`
+	// Paths, relative to the root of the repository, of the env files loaded in order when deploying the stack
+	EnvFiles []string `example:"common.env,production.env"`
+	// Path, relative to the root of the repository, of a kustomization folder rendered instead of the manifest file.
+	// Only available for the kubernetes deployment type
+	KustomizePath string `example:"overlays/production"`
+	// Kustomization folders overriding KustomizePath for some environments, indexed by environment identifier
+	KustomizeOverlayPaths map[portainer.EndpointID]string
 }
 
 func (payload *edgeStackFromGitRepositoryPayload) Validate(r *http.Request) error {
@@ -71,6 +95,10 @@ func (payload *edgeStackFromGitRepositoryPayload) Validate(r *http.Request) erro
 		return httperrors.NewInvalidPayloadError("Invalid deployment type")
 	}
 
+	if err := payload.validateKustomization(); err != nil {
+		return err
+	}
+
 	if len(payload.FilePathInRepository) == 0 {
 		switch payload.DeploymentType {
 		case portainer.EdgeStackDeploymentCompose:
@@ -84,6 +112,75 @@ func (payload *edgeStackFromGitRepositoryPayload) Validate(r *http.Request) erro
 		return httperrors.NewInvalidPayloadError("Invalid edge groups. At least one edge group must be specified")
 	}
 
+	if err := stackutils.ValidateComposeProfiles(payload.ComposeProfiles); err != nil {
+		return httperrors.NewInvalidPayloadError(err.Error())
+	}
+
+	envFiles, err := stackutils.CleanEnvFilePaths(payload.EnvFiles)
+	if err != nil {
+		return httperrors.NewInvalidPayloadError(err.Error())
+	}
+	payload.EnvFiles = envFiles
+
+	if payload.SupportPerDeviceConfigs {
+		return payload.validatePerDeviceConfigs()
+	}
+
+	return nil
+}
+
+func (payload *edgeStackFromGitRepositoryPayload) validateKustomization() error {
+	if payload.KustomizePath == "" {
+		if len(payload.KustomizeOverlayPaths) > 0 {
+			return httperrors.NewInvalidPayloadError("Invalid kustomization overlay paths. A kustomization path must be specified")
+		}
+
+		return nil
+	}
+
+	if payload.DeploymentType != portainer.EdgeStackDeploymentKubernetes {
+		return httperrors.NewInvalidPayloadError("Invalid kustomization path. Only available for the kubernetes deployment type")
+	}
+
+	kustomizePath := filepath.Clean(payload.KustomizePath)
+	if !filepath.IsLocal(kustomizePath) {
+		return httperrors.NewInvalidPayloadError("Invalid kustomization path. Must be a folder inside the repository")
+	}
+	payload.KustomizePath = kustomizePath
+
+	if err := validateKustomizeOverlayPaths(payload.KustomizeOverlayPaths); err != nil {
+		return err
+	}
+
+	// The kustomization folder is stored as the manifest path of the stack
+	payload.FilePathInRepository = kustomizePath
+
+	return nil
+}
+
+func (payload *edgeStackFromGitRepositoryPayload) validatePerDeviceConfigs() error {
+	configsPath := filepath.Clean(payload.PerDeviceConfigsPath)
+	if payload.PerDeviceConfigsPath == "" || configsPath == "." || !filepath.IsLocal(configsPath) {
+		return httperrors.NewInvalidPayloadError("Invalid per-device configs path. Must be a folder inside the repository")
+	}
+	payload.PerDeviceConfigsPath = configsPath
+
+	switch payload.PerDeviceConfigsMatchKey {
+	case "":
+		payload.PerDeviceConfigsMatchKey = portainer.PerDevConfigsMatchEdgeID
+	case portainer.PerDevConfigsMatchEdgeID, portainer.PerDevConfigsMatchEndpointName, portainer.PerDevConfigsMatchEdgeGroupName:
+	default:
+		return httperrors.NewInvalidPayloadError("Invalid per-device configs match key")
+	}
+
+	switch payload.PerDeviceConfigsMatchType {
+	case "":
+		payload.PerDeviceConfigsMatchType = portainer.PerDevConfigsTypeFile
+	case portainer.PerDevConfigsTypeFile, portainer.PerDevConfigsTypeDir:
+	default:
+		return httperrors.NewInvalidPayloadError("Invalid per-device configs match type")
+	}
+
 	return nil
 }
 
@@ -113,6 +210,18 @@ func (handler *Handler) createEdgeStackFromGitRepository(r *http.Request, tx dat
 		return nil, errors.Wrap(err, "failed to create edge stack object")
 	}
 
+	if payload.SupportPerDeviceConfigs {
+		stack.SupportPerDeviceConfigs = true
+		stack.PerDeviceConfigsPath = payload.PerDeviceConfigsPath
+		stack.PerDeviceConfigsMatchKey = payload.PerDeviceConfigsMatchKey
+		stack.PerDeviceConfigsMatchType = payload.PerDeviceConfigsMatchType
+	}
+
+	stack.ComposeProfiles = payload.ComposeProfiles
+	stack.EnvFiles = payload.EnvFiles
+	stack.KustomizePath = payload.KustomizePath
+	stack.KustomizeOverlayPaths = payload.KustomizeOverlayPaths
+
 	if dryrun {
 		return stack, nil
 	}
@@ -132,10 +241,41 @@ func (handler *Handler) createEdgeStackFromGitRepository(r *http.Request, tx dat
 	}
 
 	return handler.edgeStacksService.PersistEdgeStack(tx, stack, func(stackFolder string, relatedEndpointIds []portainer.EndpointID) (composePath string, manifestPath string, projectPath string, err error) {
-		return handler.storeManifestFromGitRepository(tx, stackFolder, relatedEndpointIds, payload.DeploymentType, userID, repoConfig)
+		composePath, manifestPath, projectPath, err = handler.storeManifestFromGitRepository(tx, stackFolder, relatedEndpointIds, payload.DeploymentType, userID, repoConfig)
+		if err != nil || stack.KustomizePath == "" {
+			return composePath, manifestPath, projectPath, err
+		}
+
+		return composePath, manifestPath, projectPath, validateKustomizations(stack, projectPath)
 	})
 }
 
+// validateKustomizeOverlayPaths verifies the overlays are folders inside the repository
+func validateKustomizeOverlayPaths(overlayPaths map[portainer.EndpointID]string) error {
+	for endpointID, overlayPath := range overlayPaths {
+		overlayPath = filepath.Clean(overlayPath)
+		if !filepath.IsLocal(overlayPath) {
+			return httperrors.NewInvalidPayloadError(fmt.Sprintf("Invalid kustomization overlay path for environment %d. Must be a folder inside the repository", endpointID))
+		}
+
+		overlayPaths[endpointID] = overlayPath
+	}
+
+	return nil
+}
+
+// validateKustomizations renders the kustomization and the overlays of the edge stack to report
+// the errors on creation rather than on deployment
+func validateKustomizations(stack *portainer.EdgeStack, projectPath string) error {
+	for _, kustomizePath := range append([]string{stack.KustomizePath}, slices.Collect(maps.Values(stack.KustomizeOverlayPaths))...) {
+		if _, err := kustomize.Build(projectPath, kustomizePath); err != nil {
+			return httperrors.NewInvalidPayloadError(err.Error())
+		}
+	}
+
+	return nil
+}
+
 func (handler *Handler) storeManifestFromGitRepository(tx dataservices.DataStoreTx, stackFolder string, relatedEndpointIds []portainer.EndpointID, deploymentType portainer.EdgeStackDeploymentType, currentUserID portainer.UserID, repositoryConfig gittypes.RepoConfig) (composePath, manifestPath, projectPath string, err error) {
 	hasWrongType, err := hasWrongEnvironmentType(tx.Endpoint(), relatedEndpointIds, deploymentType)
 	if err != nil {
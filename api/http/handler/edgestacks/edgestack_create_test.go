@@ -196,6 +196,33 @@ func TestCreateWithInvalidPayload(t *testing.T) {
 			Method:             "repository",
 			ExpectedStatusCode: 500,
 		},
+		{
+			Name: "Kustomization with compose deployment type",
+			Payload: edgeStackFromGitRepositoryPayload{
+				Name:           "stack-name",
+				RepositoryURL:  "https://github.com/portainer/portainer",
+				EdgeGroups:     []portainer.EdgeGroupID{1},
+				DeploymentType: portainer.EdgeStackDeploymentCompose,
+				KustomizePath:  "overlays/production",
+			},
+			Method:             "repository",
+			ExpectedStatusCode: 400,
+		},
+		{
+			Name: "Kustomization outside of the repository",
+			Payload: edgeStackFromGitRepositoryPayload{
+				Name:           "stack-name",
+				RepositoryURL:  "https://github.com/portainer/portainer",
+				EdgeGroups:     []portainer.EdgeGroupID{1},
+				DeploymentType: portainer.EdgeStackDeploymentKubernetes,
+				KustomizePath:  "base",
+				KustomizeOverlayPaths: map[portainer.EndpointID]string{
+					endpoint.ID: "../overlays",
+				},
+			},
+			Method:             "repository",
+			ExpectedStatusCode: 400,
+		},
 	}
 
 	for _, tc := range cases {
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -15,6 +16,7 @@ type stackFileResponse struct {
 
 // @id EdgeStackFile
 // @summary Fetches the stack file for an EdgeStack
+// @description The manifests rendered from the kustomization of the stack are returned for the stacks deployed from a kustomization.
 // @description **Access policy**: administrator
 // @tags edge_stacks
 // @security ApiKeyAuth
@@ -37,6 +39,15 @@ func (handler *Handler) edgeStackFile(w http.ResponseWriter, r *http.Request) *h
 		return handlerDBErr(err, "Unable to find an edge stack with the specified identifier inside the database")
 	}
 
+	if stack.KustomizePath != "" {
+		manifest, err := kustomize.Build(stack.ProjectPath, stack.KustomizePath)
+		if err != nil {
+			return httperror.InternalServerError("Unable to render the kustomization of the stack", err)
+		}
+
+		return response.JSON(w, &stackFileResponse{StackFileContent: string(manifest)})
+	}
+
 	fileName := stack.EntryPoint
 	if stack.DeploymentType == portainer.EdgeStackDeploymentKubernetes {
 		fileName = stack.ManifestPath
@@ -6,6 +6,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge"
+	edgestackutils "github.com/portainer/portainer/api/internal/edge/edgestacks"
 	"github.com/portainer/portainer/api/set"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -21,6 +22,9 @@ type updateEdgeStackPayload struct {
 	DeploymentType   portainer.EdgeStackDeploymentType
 	// Uses the manifest's namespaces instead of the default one
 	UseManifestNamespaces bool
+	// Kustomization folders overriding the kustomization path of the stack for some environments.
+	// Only applicable to the stacks deployed from a kustomization, the stack file content is ignored for them
+	KustomizeOverlayPaths map[portainer.EndpointID]string
 }
 
 func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
@@ -32,7 +36,7 @@ func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
 		return errors.New("edge Groups are mandatory for an Edge stack")
 	}
 
-	return nil
+	return validateKustomizeOverlayPaths(payload.KustomizeOverlayPaths)
 }
 
 // @id EdgeStackUpdate
@@ -95,6 +99,10 @@ func (handler *Handler) updateEdgeStack(tx dataservices.DataStoreTx, stackID por
 
 	groupsIds := stack.EdgeGroups
 	if payload.EdgeGroups != nil {
+		if err := edge.ValidateEdgeStackEdgeGroups(payload.EdgeGroups, relationConfig.EdgeGroups); err != nil {
+			return nil, httperror.BadRequest("Invalid Edge groups", err)
+		}
+
 		newRelated, _, err := handler.handleChangeEdgeGroups(tx, stack.ID, payload.EdgeGroups, relatedEndpointIds, relationConfig)
 		if err != nil {
 			return nil, httperror.InternalServerError("Unable to handle edge groups change", err)
@@ -119,7 +127,25 @@ func (handler *Handler) updateEdgeStack(tx dataservices.DataStoreTx, stackID por
 
 	stack.EdgeGroups = groupsIds
 
-	if payload.UpdateVersion {
+	if stack.KustomizePath != "" {
+		if payload.DeploymentType != portainer.EdgeStackDeploymentKubernetes {
+			return nil, httperror.BadRequest("Invalid deployment type, the stack is deployed from a kustomization", errors.New("kustomization edge stacks are only available for Kubernetes"))
+		}
+
+		if payload.KustomizeOverlayPaths != nil {
+			stack.KustomizeOverlayPaths = payload.KustomizeOverlayPaths
+		}
+
+		if err := validateKustomizations(stack, stack.ProjectPath); err != nil {
+			return nil, httperror.BadRequest("Invalid kustomization", err)
+		}
+
+		// The manifests are rendered from the repository, the environments are only notified of the new version
+		if payload.UpdateVersion {
+			stack.Version++
+			stack.Status = edgestackutils.NewStatus(stack.Status, relatedEndpointIds)
+		}
+	} else if payload.UpdateVersion {
 		if err := handler.updateStackVersion(stack, payload.DeploymentType, []byte(payload.StackFileContent), "", relatedEndpointIds); err != nil {
 			return nil, httperror.InternalServerError("Unable to update stack version", err)
 		}
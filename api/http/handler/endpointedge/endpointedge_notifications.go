@@ -0,0 +1,113 @@
+package endpointedge
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/edge/notify"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	notificationsWriteWait  = 10 * time.Second
+	notificationsPongWait   = 60 * time.Second
+	notificationsPingPeriod = notificationsPongWait * 9 / 10
+)
+
+// @id EndpointEdgeNotifications
+// @summary Listen for Edge notifications
+// @description Upgrades the connection to the websocket protocol and pushes a notification to the edge agent
+// @description each time its stacks, jobs or tunnel change, so that it does not need to wait for its next check-in.
+// @description The agent is expected to fall back to polling the status endpoint when the connection is closed.
+// @description **Access policy**: restricted only to Edge environments(endpoints)
+// @tags endpoints
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "Environment(Endpoint) identifier"
+// @success 101 {object} notify.Notification "Switching protocols"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied to access environment(endpoint)"
+// @failure 500 "Server error"
+// @router /endpoints/{id}/edge/notifications [get]
+func (handler *Handler) endpointEdgeNotifications(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid environment identifier route variable", err)
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if err != nil {
+		return httperror.Forbidden("Permission denied to access environment. The device has not been trusted yet", fmt.Errorf("unable to retrieve endpoint from database: %w. Environment ID: %d", err, endpointID))
+	}
+
+	if err := handler.requestBouncer.AuthorizedEdgeEndpointOperation(r, endpoint); err != nil {
+		return httperror.Forbidden("Permission denied to access environment. The device has not been trusted yet", fmt.Errorf("unauthorized Edge endpoint operation: %w. Environment name: %s", err, endpoint.Name))
+	}
+
+	if err := handler.requestBouncer.TrustedEdgeEnvironmentAccess(handler.DataStore, endpoint); err != nil {
+		return httperror.Forbidden("Permission denied to access environment. The device has not been trusted yet", fmt.Errorf("untrusted Edge environment access: %w. Environment name: %s", err, endpoint.Name))
+	}
+
+	if endpoint.Edge.AsyncMode {
+		return httperror.BadRequest("Notifications are not available for async Edge environments", fmt.Errorf("environment %s is in async mode", endpoint.Name))
+	}
+
+	conn, err := handler.connectionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug().Err(err).Int("endpoint_id", endpointID).Msg("unable to upgrade the Edge notification channel")
+
+		return nil
+	}
+	defer conn.Close()
+
+	notifications, unsubscribe := notify.Subscribe(endpoint.ID)
+	defer unsubscribe()
+
+	// Consume the control messages sent by the agent and detect when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		conn.SetReadDeadline(time.Now().Add(notificationsPongWait))
+		conn.SetPongHandler(func(string) error {
+			handler.DataStore.Endpoint().UpdateHeartbeat(endpoint.ID)
+
+			return conn.SetReadDeadline(time.Now().Add(notificationsPongWait))
+		})
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(notificationsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return nil
+		case <-r.Context().Done():
+			return nil
+		case n := <-notifications:
+			conn.SetWriteDeadline(time.Now().Add(notificationsWriteWait))
+			if err := conn.WriteJSON(n); err != nil {
+				log.Debug().Err(err).Int("endpoint_id", endpointID).Msg("unable to push Edge notification")
+
+				return nil
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(notificationsWriteWait)); err != nil {
+				return nil
+			}
+		}
+	}
+}
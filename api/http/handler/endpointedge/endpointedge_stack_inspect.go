@@ -1,17 +1,22 @@
 package endpointedge
 
 import (
+	"cmp"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/edge"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/http/middlewares"
+	edgeutils "github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/kubernetes"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -93,18 +98,97 @@ func (handler *Handler) endpointEdgeStackInspect(w http.ResponseWriter, r *http.
 		return httperror.InternalServerError("Unable to load repository", fmt.Errorf("failed to load project directory: %w. Environment name: %s", err, endpoint.Name))
 	}
 
+	if endpointutils.IsKubernetesEndpoint(endpoint) && edgeStack.KustomizePath != "" {
+		// The kustomization is rendered here, the environment only receives the resulting manifest
+		manifest, err := kustomize.Build(edgeStack.ProjectPath, cmp.Or(edgeStack.KustomizeOverlayPaths[endpoint.ID], edgeStack.KustomizePath))
+		if err != nil {
+			return httperror.InternalServerError("Unable to render the kustomization", fmt.Errorf("failed to render the kustomization: %w. Environment name: %s", err, endpoint.Name))
+		}
+
+		fileName = kustomize.ManifestFileName
+		dirEntries = []filesystem.DirEntry{{
+			Name:        fileName,
+			Content:     base64.StdEncoding.EncodeToString(manifest),
+			IsFile:      true,
+			Permissions: 0644,
+		}}
+	}
+
 	fileContent, err := filesystem.FilterDirForCompatibility(dirEntries, fileName, endpoint.Agent.Version)
 	if err != nil {
 		return httperror.InternalServerError("File not found", fmt.Errorf("unable to find file: %w. Environment name: %s", err, endpoint.Name))
 	}
 
-	dirEntries = filesystem.FilterDirForEntryFile(dirEntries, fileName)
+	var deployerOptions edge.DeployerOptionsPayload
+
+	// The files included by the compose file and the env files must be shipped along with it
+	var extraFiles []string
+	if endpointutils.IsDockerEndpoint(endpoint) {
+		extraFiles = append(filesystem.ComposeIncludedFiles(dirEntries, fileName), edgeStack.EnvFiles...)
+
+		deployerOptions.Profiles = edgeStack.ComposeProfiles
+		deployerOptions.EnvFiles = edgeStack.EnvFiles
+	}
+
+	if edgeStack.SupportPerDeviceConfigs {
+		filterArgs, err := handler.perDeviceConfigsFilterArgs(endpoint, edgeStack)
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve the per-device configs of the environment", fmt.Errorf("failed to build the per-device configs filter: %w. Environment name: %s", err, endpoint.Name))
+		}
+
+		dirEntries = filesystem.FilterDirForEntryFileAndPerDevConfigs(dirEntries, fileName, edgeStack.PerDeviceConfigsPath, filterArgs, extraFiles...)
+	} else {
+		dirEntries = filesystem.FilterDirForEntryFile(dirEntries, fileName, extraFiles...)
+	}
 
 	return response.JSON(w, edge.StackPayload{
-		DirEntries:       dirEntries,
-		EntryFileName:    fileName,
-		StackFileContent: fileContent,
-		Name:             edgeStack.Name,
-		Namespace:        namespace,
+		DirEntries:             dirEntries,
+		EntryFileName:          fileName,
+		StackFileContent:       fileContent,
+		Name:                   edgeStack.Name,
+		Namespace:              namespace,
+		DeployerOptionsPayload: deployerOptions,
 	})
 }
+
+// perDeviceConfigsFilterArgs returns the names the per-device configs of the
+// environment are matched against
+func (handler *Handler) perDeviceConfigsFilterArgs(endpoint *portainer.Endpoint, edgeStack *portainer.EdgeStack) (filesystem.MultiFilterArgs, error) {
+	var keys []string
+
+	switch edgeStack.PerDeviceConfigsMatchKey {
+	case portainer.PerDevConfigsMatchEndpointName:
+		keys = append(keys, endpoint.Name)
+	case portainer.PerDevConfigsMatchEdgeGroupName:
+		err := handler.DataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
+			for _, edgeGroupID := range edgeStack.EdgeGroups {
+				inGroup, edgeGroupName, err := edgeutils.EndpointInEdgeGroup(tx, endpoint.ID, edgeGroupID)
+				if err != nil {
+					return err
+				}
+
+				if inGroup {
+					keys = append(keys, edgeGroupName)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		keys = append(keys, endpoint.EdgeID)
+	}
+
+	var filterArgs filesystem.MultiFilterArgs
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		filterArgs = append(filterArgs, filesystem.MultiFilterArgs{{FilterKey: key, FilterType: edgeStack.PerDeviceConfigsMatchType}}...)
+	}
+
+	return filterArgs, nil
+}
@@ -0,0 +1,171 @@
+package endpointedge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/edge"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEdgeStackInspectPerDeviceConfigs(t *testing.T) {
+	handler := mustSetupHandler(t)
+
+	projectPath := t.TempDir()
+	for _, name := range []string{
+		"docker-compose.yml",
+		"README.md",
+		"configs/edge-1.env",
+		"configs/edge-2.env",
+		"configs/production/app.conf",
+		"configs/staging/app.conf",
+	} {
+		path := filepath.Join(projectPath, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+	}
+
+	endpoint := portainer.Endpoint{
+		ID:     1,
+		Name:   "device-1",
+		Type:   portainer.EdgeAgentOnDockerEnvironment,
+		EdgeID: "edge-1",
+	}
+	endpoint.Agent.Version = "2.20.0"
+	require.NoError(t, createEndpoint(handler, endpoint, portainer.EndpointRelation{EndpointID: endpoint.ID}))
+
+	require.NoError(t, handler.DataStore.EdgeGroup().Create(&portainer.EdgeGroup{ID: 1, Name: "production", Endpoints: []portainer.EndpointID{endpoint.ID}}))
+	require.NoError(t, handler.DataStore.EdgeGroup().Create(&portainer.EdgeGroup{ID: 2, Name: "staging", Endpoints: []portainer.EndpointID{}}))
+
+	tests := []struct {
+		name      string
+		matchKey  portainer.PerDevConfigsMatchKey
+		matchType portainer.PerDevConfigsFilterType
+		expected  []string
+	}{
+		{
+			name:      "edge ID file",
+			matchKey:  portainer.PerDevConfigsMatchEdgeID,
+			matchType: portainer.PerDevConfigsTypeFile,
+			expected:  []string{"configs/edge-1.env", "docker-compose.yml"},
+		},
+		{
+			name:      "environment name file",
+			matchKey:  portainer.PerDevConfigsMatchEndpointName,
+			matchType: portainer.PerDevConfigsTypeFile,
+			expected:  []string{"docker-compose.yml"},
+		},
+		{
+			name:      "edge group name dir",
+			matchKey:  portainer.PerDevConfigsMatchEdgeGroupName,
+			matchType: portainer.PerDevConfigsTypeDir,
+			expected:  []string{"configs/production", "configs/production/app.conf", "docker-compose.yml"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			edgeStack := &portainer.EdgeStack{
+				ID:                        portainer.EdgeStackID(i + 1),
+				Name:                      "stack",
+				EdgeGroups:                []portainer.EdgeGroupID{1, 2},
+				ProjectPath:               projectPath,
+				EntryPoint:                "docker-compose.yml",
+				SupportPerDeviceConfigs:   true,
+				PerDeviceConfigsPath:      "configs",
+				PerDeviceConfigsMatchKey:  test.matchKey,
+				PerDeviceConfigsMatchType: test.matchType,
+			}
+			require.NoError(t, handler.DataStore.EdgeStack().Create(edgeStack.ID, edgeStack))
+
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/endpoints/%d/edge/stacks/%d", endpoint.ID, edgeStack.ID), nil)
+			require.NoError(t, err)
+			req.Header.Set(portainer.PortainerAgentEdgeIDHeader, endpoint.EdgeID)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var payload edge.StackPayload
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&payload))
+
+			names := []string{}
+			for _, dirEntry := range payload.DirEntries {
+				names = append(names, dirEntry.Name)
+			}
+
+			require.ElementsMatch(t, test.expected, names)
+		})
+	}
+}
+
+func TestEdgeStackInspectKustomization(t *testing.T) {
+	handler := mustSetupHandler(t)
+
+	projectPath := t.TempDir()
+	for name, content := range map[string]string{
+		"base/kustomization.yaml":    "resources:\n  - deployment.yaml\n",
+		"base/deployment.yaml":       "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n",
+		"staging/kustomization.yaml": "resources:\n  - ../base\nnamePrefix: staging-\n",
+	} {
+		path := filepath.Join(projectPath, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	endpoints := []portainer.Endpoint{
+		{ID: 1, Name: "cluster-1", Type: portainer.EdgeAgentOnKubernetesEnvironment, EdgeID: "edge-1"},
+		{ID: 2, Name: "cluster-2", Type: portainer.EdgeAgentOnKubernetesEnvironment, EdgeID: "edge-2"},
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Agent.Version = "2.20.0"
+		require.NoError(t, createEndpoint(handler, endpoint, portainer.EndpointRelation{EndpointID: endpoint.ID}))
+	}
+
+	edgeStack := &portainer.EdgeStack{
+		ID:                    1,
+		Name:                  "stack",
+		ProjectPath:           projectPath,
+		ManifestPath:          "base",
+		DeploymentType:        portainer.EdgeStackDeploymentKubernetes,
+		KustomizePath:         "base",
+		KustomizeOverlayPaths: map[portainer.EndpointID]string{2: "staging"},
+	}
+	require.NoError(t, handler.DataStore.EdgeStack().Create(edgeStack.ID, edgeStack))
+
+	for _, test := range []struct {
+		endpoint portainer.Endpoint
+		expected string
+	}{
+		{endpoints[0], "name: web"},
+		{endpoints[1], "name: staging-web"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/api/endpoints/%d/edge/stacks/%d", test.endpoint.ID, edgeStack.ID), nil)
+		require.NoError(t, err)
+		req.Header.Set(portainer.PortainerAgentEdgeIDHeader, test.endpoint.EdgeID)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var payload edge.StackPayload
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&payload))
+
+		require.Equal(t, kustomize.ManifestFileName, payload.EntryFileName)
+		require.Len(t, payload.DirEntries, 1)
+
+		manifest, err := filesystem.DecodeFileContent(payload.DirEntries[0].Content)
+		require.NoError(t, err)
+		require.Contains(t, manifest, test.expected)
+	}
+}
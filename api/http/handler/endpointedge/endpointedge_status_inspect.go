@@ -18,6 +18,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/internal/edge/notify"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -57,6 +58,8 @@ type endpointEdgeStatusInspectResponse struct {
 	Credentials string `json:"credentials"`
 	// List of stacks to be deployed on the environments(endpoints)
 	Stacks []stackStatusResponse `json:"stacks"`
+	// Whether the agent is listening on the notification channel
+	NotificationChannel bool `json:"notificationChannel" example:"true"`
 }
 
 // @id EndpointEdgeStatusInspect
@@ -144,6 +147,8 @@ func (handler *Handler) inspectStatus(tx dataservices.DataStoreTx, r *http.Reque
 		return nil, err
 	}
 
+	previousType, previousAgentVersion := endpoint.Type, endpoint.Agent.Version
+
 	if err := handler.parseHeaders(r, endpoint); err != nil {
 		return nil, err
 	}
@@ -161,13 +166,21 @@ func (handler *Handler) inspectStatus(tx dataservices.DataStoreTx, r *http.Reque
 		return nil, httperror.InternalServerError("Unable to persist environment changes inside the database", err)
 	}
 
+	// The rules of the dynamic Edge groups can target the platform and the version of the agent, which change when it is upgraded
+	if endpoint.Type != previousType || endpoint.Agent.Version != previousAgentVersion {
+		if err := edge.UpdateEndpointRelation(tx, endpoint); err != nil {
+			return nil, httperror.InternalServerError("Unable to update the edge stacks related to the environment", err)
+		}
+	}
+
 	tunnel := handler.ReverseTunnelService.Config(endpoint.ID)
 
 	statusResponse := endpointEdgeStatusInspectResponse{
-		Status:          tunnel.Status,
-		Port:            tunnel.Port,
-		CheckinInterval: edge.EffectiveCheckinInterval(tx, endpoint),
-		Credentials:     tunnel.Credentials,
+		Status:              tunnel.Status,
+		Port:                tunnel.Port,
+		CheckinInterval:     edge.EffectiveCheckinInterval(tx, endpoint),
+		Credentials:         tunnel.Credentials,
+		NotificationChannel: notify.Connected(endpoint.ID),
 	}
 
 	schedules, handlerErr := handler.buildSchedules(tx, endpoint.ID)
@@ -10,6 +10,7 @@ import (
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
 // Handler is the HTTP handler used to handle edge environment(endpoint) operations.
@@ -19,6 +20,7 @@ type Handler struct {
 	DataStore            dataservices.DataStore
 	FileService          portainer.FileService
 	ReverseTunnelService portainer.ReverseTunnelService
+	connectionUpgrader   websocket.Upgrader
 }
 
 // NewHandler creates a handler to manage environment(endpoint) operations.
@@ -32,6 +34,7 @@ func NewHandler(bouncer security.BouncerService, dataStore dataservices.DataStor
 	}
 
 	h.Handle("/api/endpoints/{id}/edge/status", bouncer.PublicAccess(httperror.LoggerHandler(h.endpointEdgeStatusInspect))).Methods(http.MethodGet)
+	h.Handle("/api/endpoints/{id}/edge/notifications", bouncer.PublicAccess(httperror.LoggerHandler(h.endpointEdgeNotifications))).Methods(http.MethodGet)
 
 	endpointRouter := h.PathPrefix("/api/endpoints/{id}").Subrouter()
 	endpointRouter.Use(middlewares.WithEndpoint(dataStore.Endpoint(), "id"))
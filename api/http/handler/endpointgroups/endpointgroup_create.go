@@ -6,6 +6,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/docker/admission"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -20,6 +21,8 @@ type endpointGroupCreatePayload struct {
 	AssociatedEndpoints []portainer.EndpointID `example:"1,3"`
 	// List of tag identifiers to which this environment(endpoint) group is associated
 	TagIDs []portainer.TagID `example:"1,2"`
+	// Admission policy applied to the Docker workloads deployed on the environments(endpoints) of the group
+	AdmissionPolicy *portainer.AdmissionPolicy
 }
 
 func (payload *endpointGroupCreatePayload) Validate(r *http.Request) error {
@@ -31,7 +34,7 @@ func (payload *endpointGroupCreatePayload) Validate(r *http.Request) error {
 		payload.TagIDs = []portainer.TagID{}
 	}
 
-	return nil
+	return admission.ValidatePolicy(payload.AdmissionPolicy)
 }
 
 // @summary Create an Environment(Endpoint) Group
@@ -78,6 +81,7 @@ func (handler *Handler) createEndpointGroup(tx dataservices.DataStoreTx, payload
 		UserAccessPolicies: portainer.UserAccessPolicies{},
 		TeamAccessPolicies: portainer.TeamAccessPolicies{},
 		TagIDs:             payload.TagIDs,
+		AdmissionPolicy:    payload.AdmissionPolicy,
 	}
 
 	err := tx.EndpointGroup().Create(endpointGroup)
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"reflect"
+	"strings"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/docker/admission"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/pendingactions/handlers"
 	"github.com/portainer/portainer/api/tag"
@@ -15,6 +17,7 @@ import (
 	"github.com/portainer/portainer/pkg/libhttp/response"
 
 	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
 )
 
 type endpointGroupUpdatePayload struct {
@@ -26,12 +29,39 @@ type endpointGroupUpdatePayload struct {
 	TagIDs             []portainer.TagID `example:"3,4"`
 	UserAccessPolicies portainer.UserAccessPolicies
 	TeamAccessPolicies portainer.TeamAccessPolicies
+	// Admission policy applied to the Docker workloads deployed on the environments(endpoints) of the group,
+	// the policy is kept when omitted and removed when set to null
+	AdmissionPolicy *portainer.AdmissionPolicy
+
+	admissionPolicySet bool
 }
 
-func (payload *endpointGroupUpdatePayload) Validate(r *http.Request) error {
+// UnmarshalJSON records whether the admission policy is part of the payload, to tell an explicit null
+// removing the policy from an omitted policy
+func (payload *endpointGroupUpdatePayload) UnmarshalJSON(data []byte) error {
+	type plainPayload endpointGroupUpdatePayload
+	if err := json.Unmarshal(data, (*plainPayload)(payload)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for name := range fields {
+		if strings.EqualFold(name, "AdmissionPolicy") {
+			payload.admissionPolicySet = true
+		}
+	}
+
 	return nil
 }
 
+func (payload *endpointGroupUpdatePayload) Validate(r *http.Request) error {
+	return admission.ValidatePolicy(payload.AdmissionPolicy)
+}
+
 // @id EndpointGroupUpdate
 // @summary Update an environment(endpoint) group
 // @description Update an environment(endpoint) group.
@@ -93,6 +123,10 @@ func (handler *Handler) updateEndpointGroup(tx dataservices.DataStoreTx, endpoin
 		endpointGroup.Description = payload.Description
 	}
 
+	if payload.admissionPolicySet {
+		endpointGroup.AdmissionPolicy = payload.AdmissionPolicy
+	}
+
 	tagsChanged := false
 	if payload.TagIDs != nil {
 		payloadTagSet := tag.Set(payload.TagIDs)
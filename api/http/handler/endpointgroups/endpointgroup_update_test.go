@@ -0,0 +1,49 @@
+package endpointgroups
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointGroupUpdateAdmissionPolicy(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	require.NoError(t, store.EndpointGroup().Create(&portainer.EndpointGroup{
+		ID:              2,
+		Name:            "production",
+		AdmissionPolicy: &portainer.AdmissionPolicy{Enabled: true, Mode: portainer.AdmissionPolicyModeEnforce, ForbidLatestTag: true},
+	}))
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+
+	update := func(body string) *portainer.EndpointGroup {
+		req := httptest.NewRequest(http.MethodPut, "/endpoint_groups/2", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		endpointGroup, err := store.EndpointGroup().Read(2)
+		require.NoError(t, err)
+
+		return endpointGroup
+	}
+
+	// the policy is kept when it is omitted
+	endpointGroup := update(`{"Description": "production environments"}`)
+	require.NotNil(t, endpointGroup.AdmissionPolicy)
+	require.True(t, endpointGroup.AdmissionPolicy.ForbidLatestTag)
+
+	// the policy is removed by an explicit null
+	endpointGroup = update(`{"AdmissionPolicy": null}`)
+	require.Nil(t, endpointGroup.AdmissionPolicy)
+	require.Equal(t, "production environments", endpointGroup.Description)
+}
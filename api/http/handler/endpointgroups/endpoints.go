@@ -45,7 +45,11 @@ func (handler *Handler) updateEndpointRelations(tx dataservices.DataStoreTx, end
 		return err
 	}
 
-	endpointStacks := edge.EndpointRelatedEdgeStacks(endpoint, endpointGroup, edgeGroups, edgeStacks)
+	endpointStacks, err := edge.EndpointRelatedEdgeStacks(tx, endpoint, endpointGroup, edgeGroups, edgeStacks)
+	if err != nil {
+		return err
+	}
+
 	stacksSet := map[portainer.EdgeStackID]bool{}
 	for _, edgeStackID := range endpointStacks {
 		stacksSet[edgeStackID] = true
@@ -255,7 +255,11 @@ func (handler *Handler) endpointCreate(w http.ResponseWriter, r *http.Request) *
 	}
 
 	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment || endpoint.Type == portainer.EdgeAgentOnKubernetesEnvironment {
-		relatedEdgeStacks := edge.EndpointRelatedEdgeStacks(endpoint, endpointGroup, edgeGroups, edgeStacks)
+		relatedEdgeStacks, err := edge.EndpointRelatedEdgeStacks(handler.DataStore, endpoint, endpointGroup, edgeGroups, edgeStacks)
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve the edge stacks related to the environment", err)
+		}
+
 		for _, stackID := range relatedEdgeStacks {
 			relationObject.EdgeStacks[stackID] = true
 		}
@@ -0,0 +1,23 @@
+package endpoints
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id EndpointSnapshotStats
+// @summary List the snapshot statistics of the environments(endpoints)
+// @description List the duration and failure statistics of the snapshots of each environment(endpoint) since Portainer started.
+// @description **Access policy**: administrator
+// @tags endpoints
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {array} portainer.SnapshotStats "Success"
+// @failure 500 "Server error"
+// @router /endpoints/snapshot/stats [get]
+func (handler *Handler) endpointSnapshotStats(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return response.JSON(w, handler.SnapshotService.SnapshotStats())
+}
@@ -19,7 +19,7 @@ import (
 func hideFields(endpoint *portainer.Endpoint) {
 	endpoint.AzureCredentials = portainer.AzureCredentials{}
 	if len(endpoint.Snapshots) > 0 {
-		endpoint.Snapshots[0].SnapshotRaw = portainer.DockerSnapshotRaw{}
+		endpoint.Snapshots[0].SnapshotRaw = nil
 	}
 }
 
@@ -56,6 +56,8 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointAssociationDelete))).Methods(http.MethodDelete)
 	h.Handle("/endpoints/snapshot",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointSnapshots))).Methods(http.MethodPost)
+	h.Handle("/endpoints/snapshot/stats",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointSnapshotStats))).Methods(http.MethodGet)
 	h.Handle("/endpoints",
 		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointList))).Methods(http.MethodGet)
 	h.Handle("/endpoints/agent_versions",
@@ -0,0 +1,32 @@
+package kubernetes
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/http/middlewares"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id GetKubernetesResourceCacheStatus
+// @summary Get the status of the resource cache of an environment
+// @description Get the sync status of the informers backing the application listings of an environment.
+// @description The informers are started by the first listing and stopped after some idle time.
+// @description **Access policy**: Administrator.
+// @tags kubernetes
+// @security ApiKeyAuth || jwt
+// @produce json
+// @param id path int true "Environment(Endpoint) identifier"
+// @success 200 {object} cli.ResourceCacheStatus "Success"
+// @failure 401 "Unauthorized access - the user is not authenticated or does not have the necessary permissions. Ensure that you have provided a valid API key or JWT token, and that you have the required permissions."
+// @failure 403 "Permission denied - the user is authenticated but does not have the necessary permissions to access the requested resource or perform the specified operation. Check your user roles and permissions."
+// @failure 404 "Unable to find an environment with the specified identifier."
+// @router /kubernetes/{id}/resource_cache [get]
+func (handler *Handler) getKubernetesResourceCacheStatus(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpoint, err := middlewares.FetchEndpoint(r)
+	if err != nil {
+		return httperror.NotFound("Unable to find an environment on request context", err)
+	}
+
+	return response.JSON(w, handler.KubernetesClientFactory.GetResourceCacheStatus(endpoint.ID))
+}
@@ -64,9 +64,14 @@ func (handler *Handler) initRouter(bouncer accessGuard) {
 	adminRouter.Handle("/registries", httperror.LoggerHandler(handler.registryCreate)).Methods(http.MethodPost)
 	adminRouter.Handle("/registries/{id}", httperror.LoggerHandler(handler.registryUpdate)).Methods(http.MethodPut)
 	adminRouter.Handle("/registries/{id}/configure", httperror.LoggerHandler(handler.registryConfigure)).Methods(http.MethodPost)
+	adminRouter.Handle("/registries/{id}/copy", httperror.LoggerHandler(handler.registryCopy)).Methods(http.MethodPost)
 	adminRouter.Handle("/registries/{id}", httperror.LoggerHandler(handler.registryDelete)).Methods(http.MethodDelete)
+	adminRouter.Handle("/registries/{id}/repositories/{repository:.+}/tags/{tag}", httperror.LoggerHandler(handler.registryRepositoryTagDelete)).Methods(http.MethodDelete)
 
 	authenticatedRouter.Handle("/registries/{id}", httperror.LoggerHandler(handler.registryInspect)).Methods(http.MethodGet)
+	authenticatedRouter.Handle("/registries/{id}/repositories", httperror.LoggerHandler(handler.registryRepositoryList)).Methods(http.MethodGet)
+	authenticatedRouter.Handle("/registries/{id}/repositories/{repository:.+}/tags", httperror.LoggerHandler(handler.registryRepositoryTagList)).Methods(http.MethodGet)
+	authenticatedRouter.Handle("/registries/{id}/repositories/{repository:.+}/tags/{tag}", httperror.LoggerHandler(handler.registryRepositoryTagInspect)).Methods(http.MethodGet)
 	authenticatedRouter.PathPrefix("/registries/proxies/gitlab").Handler(httperror.LoggerHandler(handler.proxyRequestsToGitlabAPIWithoutRegistry))
 }
 
@@ -0,0 +1,144 @@
+package registries
+
+import (
+	"errors"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/images"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+type registryCopyPayload struct {
+	// Identifier of the registry the images are copied to
+	DestinationRegistryID portainer.RegistryID `example:"2" validate:"required"`
+	// Repository of the images inside the source registry
+	SourceRepository string `example:"team/app" validate:"required"`
+	// Repository the images are copied to inside the destination registry, defaults to the source repository
+	DestinationRepository string `example:"production/app"`
+	// Tags to copy
+	Tags []string `example:"1.0.0"`
+	// Glob pattern matching the tags to copy
+	TagPattern string `example:"1.*"`
+	// Tag the image is copied to, only valid when a single tag is copied
+	DestinationTag string `example:"stable"`
+	// Platform copied out of a multi-arch image, all the platforms are copied when empty
+	Platform string `example:"linux/amd64"`
+}
+
+func (payload *registryCopyPayload) Validate(r *http.Request) error {
+	if payload.DestinationRegistryID == 0 {
+		return errors.New("invalid destination registry identifier")
+	}
+
+	if payload.SourceRepository == "" {
+		return errors.New("invalid source repository")
+	}
+
+	if len(payload.Tags) == 0 && payload.TagPattern == "" {
+		return errors.New("at least one tag or a tag pattern must be specified")
+	}
+
+	if payload.DestinationTag != "" && (len(payload.Tags) != 1 || payload.TagPattern != "") {
+		return errors.New("a destination tag can only be specified when a single tag is copied")
+	}
+
+	return nil
+}
+
+// registryCopyMessage is a line of the streamed response of a copy
+type registryCopyMessage struct {
+	Progress *images.CopyProgress `json:"progress,omitempty"`
+	Results  []images.CopyResult  `json:"results,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// @id RegistryCopy
+// @summary Copy images to another registry
+// @description Copy a tag, the tags matching a pattern or a multi-arch image from the registry to another registry,
+// @description using the credentials of both registries.
+// @description The progress is streamed as newline delimited JSON messages, the last one contains the copied images or the error.
+// @description **Access policy**: administrator
+// @tags registries
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce application/x-ndjson
+// @param id path int true "Source registry identifier"
+// @param body body registryCopyPayload true "Images to copy"
+// @success 200 {object} registryCopyMessage "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Registry not found"
+// @failure 500 "Server error"
+// @router /registries/{id}/copy [post]
+func (handler *Handler) registryCopy(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	registryID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid registry identifier route variable", err)
+	}
+
+	var payload registryCopyPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	source, err := handler.DataStore.Registry().Read(portainer.RegistryID(registryID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a registry with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a registry with the specified identifier inside the database", err)
+	}
+
+	destination, err := handler.DataStore.Registry().Read(payload.DestinationRegistryID)
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find the destination registry inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find the destination registry inside the database", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	write := func(message registryCopyMessage) {
+		if err := encoder.Encode(message); err != nil {
+			log.Debug().Err(err).Msg("unable to write the copy progress")
+
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	copier := images.NewImageCopier(images.NewRegistryClient(handler.DataStore))
+
+	results, err := copier.Copy(r.Context(), source, destination, images.CopyOptions{
+		SourceRepository:      payload.SourceRepository,
+		DestinationRepository: payload.DestinationRepository,
+		Tags:                  payload.Tags,
+		TagPattern:            payload.TagPattern,
+		DestinationTag:        payload.DestinationTag,
+		Platform:              payload.Platform,
+	}, func(progress images.CopyProgress) {
+		write(registryCopyMessage{Progress: &progress})
+	})
+	if err != nil {
+		log.Warn().Err(err).Int("registry_id", registryID).Msg("unable to copy the images")
+
+		write(registryCopyMessage{Results: results, Error: err.Error()})
+
+		return nil
+	}
+
+	write(registryCopyMessage{Results: results})
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package registries
+
+import (
+	"errors"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/images"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/internal/registryclient"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RegistryRepositoryList
+// @summary List the repositories of a registry
+// @description List the repositories of the registry catalog.
+// @description Non-administrator users must specify the environment they access the registry from.
+// @description **Access policy**: restricted
+// @tags registries
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Registry identifier"
+// @param endpointId query int false "Identifier of the environment the registry is accessed from"
+// @success 200 {array} string "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied to access registry"
+// @failure 404 "Registry not found"
+// @failure 500 "Server error"
+// @router /registries/{id}/repositories [get]
+func (handler *Handler) registryRepositoryList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	client, httpErr := handler.registryBrowserClient(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	repositories, err := client.Repositories(r.Context())
+	if err != nil {
+		return registryBrowserError("Unable to retrieve the repositories of the registry", err)
+	}
+
+	if repositories == nil {
+		repositories = []string{}
+	}
+
+	return response.JSON(w, repositories)
+}
+
+// registryBrowserClient returns a client of the registry of the request after validating the access of the user
+func (handler *Handler) registryBrowserClient(r *http.Request) (*registryclient.Client, *httperror.HandlerError) {
+	registryID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid registry identifier route variable", err)
+	}
+
+	registry, err := handler.DataStore.Registry().Read(portainer.RegistryID(registryID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a registry with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a registry with the specified identifier inside the database", err)
+	}
+
+	hasAccess, _, err := handler.userHasRegistryAccess(r, registry)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+	if !hasAccess {
+		return nil, httperror.Forbidden("Access denied to resource", httperrors.ErrResourceAccessDenied)
+	}
+
+	var username, password string
+	if registry.Authentication {
+		username, password, err = images.NewRegistryClient(handler.DataStore).CertainRegistryAuth(registry)
+		if err != nil {
+			return nil, httperror.InternalServerError("Unable to retrieve the registry credentials", err)
+		}
+	}
+
+	client, err := registryclient.NewClient(registry, username, password)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to create the registry client", err)
+	}
+
+	return client, nil
+}
+
+func registryBrowserError(message string, err error) *httperror.HandlerError {
+	switch {
+	case errors.Is(err, registryclient.ErrInvalidRepository), errors.Is(err, registryclient.ErrInvalidReference):
+		return httperror.BadRequest(message, err)
+	case errors.Is(err, registryclient.ErrNotFound):
+		return httperror.NotFound(message, err)
+	case errors.Is(err, registryclient.ErrDeleteNotSupported):
+		return httperror.NewError(http.StatusMethodNotAllowed, message, err)
+	}
+
+	return httperror.InternalServerError(message, err)
+}
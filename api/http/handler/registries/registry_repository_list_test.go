@@ -0,0 +1,52 @@
+package registries
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryRepositoryListAnonymousRegistry(t *testing.T) {
+	registryServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"repositories": ["library/nginx"]}`))
+	}))
+	defer registryServer.Close()
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.User().Create(&portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, store.Registry().Create(&portainer.Registry{
+		ID:             1,
+		Type:           portainer.CustomRegistry,
+		URL:            registryServer.Listener.Addr().String(),
+		Authentication: false,
+		ManagementConfiguration: &portainer.RegistryManagementConfiguration{
+			TLSConfig: portainer.TLSConfiguration{TLS: true, TLSSkipVerify: true},
+		},
+	}))
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+
+	req := httptest.NewRequest(http.MethodGet, "/registries/1/repositories", nil)
+	req = req.WithContext(security.StoreRestrictedRequestContext(req, &security.RestrictedRequestContext{IsAdmin: true, UserID: 1}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.JSONEq(t, `["library/nginx"]`, rr.Body.String())
+}
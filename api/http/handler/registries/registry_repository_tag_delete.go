@@ -0,0 +1,56 @@
+package registries
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/internal/registryclient"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RegistryRepositoryTagDelete
+// @summary Delete a tag of a repository
+// @description Delete the manifest referenced by the tag, the other tags referencing the same manifest are removed as well.
+// @description The registry must allow deletions.
+// @description **Access policy**: administrator
+// @tags registries
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "Registry identifier"
+// @param repository path string true "Repository name"
+// @param tag path string true "Tag"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Registry, repository or tag not found"
+// @failure 405 "The registry does not support deletions"
+// @failure 500 "Server error"
+// @router /registries/{id}/repositories/{repository}/tags/{tag} [delete]
+func (handler *Handler) registryRepositoryTagDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	repository, err := request.RetrieveRouteVariableValue(r, "repository")
+	if err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+	if err := registryclient.ValidateRepository(repository); err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+
+	tag, err := request.RetrieveRouteVariableValue(r, "tag")
+	if err != nil {
+		return httperror.BadRequest("Invalid tag route variable", err)
+	}
+	if err := registryclient.ValidateReference(tag); err != nil {
+		return httperror.BadRequest("Invalid tag route variable", err)
+	}
+
+	client, httpErr := handler.registryBrowserClient(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := client.DeleteTag(r.Context(), repository, tag); err != nil {
+		return registryBrowserError("Unable to delete the tag", err)
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,59 @@
+package registries
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/internal/registryclient"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RegistryRepositoryTagInspect
+// @summary Inspect a tag of a repository
+// @description Retrieve the manifest details of a tag: digest, size, layers, platforms and creation date.
+// @description Non-administrator users must specify the environment they access the registry from.
+// @description **Access policy**: restricted
+// @tags registries
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Registry identifier"
+// @param repository path string true "Repository name"
+// @param tag path string true "Tag"
+// @param endpointId query int false "Identifier of the environment the registry is accessed from"
+// @success 200 {object} registryclient.Manifest "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied to access registry"
+// @failure 404 "Registry, repository or tag not found"
+// @failure 500 "Server error"
+// @router /registries/{id}/repositories/{repository}/tags/{tag} [get]
+func (handler *Handler) registryRepositoryTagInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	repository, err := request.RetrieveRouteVariableValue(r, "repository")
+	if err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+	if err := registryclient.ValidateRepository(repository); err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+
+	tag, err := request.RetrieveRouteVariableValue(r, "tag")
+	if err != nil {
+		return httperror.BadRequest("Invalid tag route variable", err)
+	}
+	if err := registryclient.ValidateReference(tag); err != nil {
+		return httperror.BadRequest("Invalid tag route variable", err)
+	}
+
+	client, httpErr := handler.registryBrowserClient(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	manifest, err := client.Manifest(r.Context(), repository, tag)
+	if err != nil {
+		return registryBrowserError("Unable to retrieve the manifest of the tag", err)
+	}
+
+	return response.JSON(w, manifest)
+}
@@ -0,0 +1,54 @@
+package registries
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/internal/registryclient"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RegistryRepositoryTagList
+// @summary List the tags of a repository
+// @description List the tags of a repository of the registry.
+// @description Non-administrator users must specify the environment they access the registry from.
+// @description **Access policy**: restricted
+// @tags registries
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Registry identifier"
+// @param repository path string true "Repository name"
+// @param endpointId query int false "Identifier of the environment the registry is accessed from"
+// @success 200 {array} string "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied to access registry"
+// @failure 404 "Registry or repository not found"
+// @failure 500 "Server error"
+// @router /registries/{id}/repositories/{repository}/tags [get]
+func (handler *Handler) registryRepositoryTagList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	repository, err := request.RetrieveRouteVariableValue(r, "repository")
+	if err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+	if err := registryclient.ValidateRepository(repository); err != nil {
+		return httperror.BadRequest("Invalid repository route variable", err)
+	}
+
+	client, httpErr := handler.registryBrowserClient(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	tags, err := client.Tags(r.Context(), repository)
+	if err != nil {
+		return registryBrowserError("Unable to retrieve the tags of the repository", err)
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return response.JSON(w, tags)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apptemplates"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/security"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -20,11 +21,12 @@ func hideFields(settings *portainer.Settings) {
 // Handler is the HTTP handler used to handle settings operations.
 type Handler struct {
 	*mux.Router
-	DataStore       dataservices.DataStore
-	FileService     portainer.FileService
-	JWTService      portainer.JWTService
-	LDAPService     portainer.LDAPService
-	SnapshotService portainer.SnapshotService
+	DataStore        dataservices.DataStore
+	FileService      portainer.FileService
+	JWTService       portainer.JWTService
+	LDAPService      portainer.LDAPService
+	SnapshotService  portainer.SnapshotService
+	TemplatesService *apptemplates.Service
 }
 
 // NewHandler creates a handler to manage settings operations.
@@ -2,6 +2,7 @@ package settings
 
 import (
 	"cmp"
+	"context"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/asaskevich/govalidator"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
 )
 
@@ -136,6 +138,14 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unexpected error", err)
 	}
 
+	if payload.TemplatesURL != nil && handler.TemplatesService != nil {
+		go func() {
+			if err := handler.TemplatesService.RefreshAll(context.Background()); err != nil {
+				log.Warn().Err(err).Msg("unable to refresh the application templates")
+			}
+		}()
+	}
+
 	hideFields(settings)
 	return response.JSON(w, settings)
 }
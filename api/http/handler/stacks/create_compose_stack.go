@@ -2,6 +2,8 @@ package stacks
 
 import (
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/git/update"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/templatevariables"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackbuilders"
 	"github.com/portainer/portainer/api/stacks/stackutils"
@@ -29,6 +32,15 @@ type composeStackFromFileContentPayload struct {
 	Env []portainer.Pair
 	// Whether the stack is from a app template
 	FromAppTemplate bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against
+	// its definitions and the stack file of the template rendered with them is used when no stack file content is given
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
+	// Compose profiles to enable when deploying the stack
+	ComposeProfiles []string `example:"debug"`
+	// Env files stored next to the stack file and loaded in order when deploying the stack
+	EnvFiles []stackbuilders.EnvFilePayload
 }
 
 func (payload *composeStackFromFileContentPayload) Validate(r *http.Request) error {
@@ -36,9 +48,30 @@ func (payload *composeStackFromFileContentPayload) Validate(r *http.Request) err
 		return errors.New("Invalid stack name")
 	}
 
-	if len(payload.StackFileContent) == 0 {
+	if len(payload.StackFileContent) == 0 && payload.CustomTemplateID == 0 {
 		return errors.New("Invalid stack file content")
 	}
+
+	if err := stackutils.ValidateComposeProfiles(payload.ComposeProfiles); err != nil {
+		return err
+	}
+
+	return validateUploadedEnvFiles(payload.EnvFiles)
+}
+
+func validateUploadedEnvFiles(envFiles []stackbuilders.EnvFilePayload) error {
+	names := make(map[string]struct{}, len(envFiles))
+	for _, envFile := range envFiles {
+		if err := envFile.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := names[envFile.Name]; ok {
+			return fmt.Errorf("Invalid env files. The name %s is used more than once", envFile.Name)
+		}
+		names[envFile.Name] = struct{}{}
+	}
+
 	return nil
 }
 
@@ -110,6 +143,12 @@ func (handler *Handler) createComposeStackFromFileContent(w http.ResponseWriter,
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	fileContent, httpErr := handler.customTemplateFileContent(payload.CustomTemplateID, payload.CustomTemplateVariables, payload.StackFileContent)
+	if httpErr != nil {
+		return httpErr
+	}
+	payload.StackFileContent = fileContent
+
 	payload.Name = handler.ComposeStackManager.NormalizeStackName(payload.Name)
 
 	isUnique, err := handler.checkUniqueStackNameInDocker(endpoint, payload.Name, 0, false)
@@ -140,6 +179,8 @@ func (handler *Handler) createComposeStackFromFileContent(w http.ResponseWriter,
 	}
 
 	stackPayload := createStackPayloadFromComposeFileContentPayload(payload.Name, payload.StackFileContent, payload.Env, payload.FromAppTemplate)
+	stackPayload.ComposeProfiles = payload.ComposeProfiles
+	stackPayload.UploadedEnvFiles = payload.EnvFiles
 
 	composeStackBuilder := stackbuilders.CreateComposeStackFileContentBuilder(securityContext,
 		handler.DataStore,
@@ -178,8 +219,16 @@ type composeStackFromGitRepositoryPayload struct {
 	Env []portainer.Pair
 	// Whether the stack is from a app template
 	FromAppTemplate bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against its definitions
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
 	// TLSSkipVerify skips SSL verification when cloning the Git repository
 	TLSSkipVerify bool `example:"false"`
+	// Compose profiles to enable when deploying the stack
+	ComposeProfiles []string `example:"debug"`
+	// Paths to the env files inside the Git repository, loaded in order when deploying the stack
+	EnvFiles []string `example:"common.env,production.env"`
 }
 
 func createStackPayloadFromComposeGitPayload(name, repoUrl, repoReference, repoUsername, repoPassword string, repoAuthentication bool, composeFile string, additionalFiles []string, autoUpdate *portainer.AutoUpdateSettings, env []portainer.Pair, fromAppTemplate bool, repoSkipSSLVerify bool) stackbuilders.StackPayload {
@@ -214,6 +263,16 @@ func (payload *composeStackFromGitRepositoryPayload) Validate(r *http.Request) e
 	if err := update.ValidateAutoUpdateSettings(payload.AutoUpdate); err != nil {
 		return err
 	}
+	if err := stackutils.ValidateComposeProfiles(payload.ComposeProfiles); err != nil {
+		return err
+	}
+
+	envFiles, err := stackutils.CleanEnvFilePaths(payload.EnvFiles)
+	if err != nil {
+		return err
+	}
+	payload.EnvFiles = envFiles
+
 	return nil
 }
 
@@ -240,6 +299,10 @@ func (handler *Handler) createComposeStackFromGitRepository(w http.ResponseWrite
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	if httpErr := handler.validateCustomTemplateValues(payload.CustomTemplateID, payload.CustomTemplateVariables); httpErr != nil {
+		return httpErr
+	}
+
 	payload.Name = handler.ComposeStackManager.NormalizeStackName(payload.Name)
 	if payload.ComposeFile == "" {
 		payload.ComposeFile = filesystem.ComposeFileDefaultName
@@ -296,6 +359,8 @@ func (handler *Handler) createComposeStackFromGitRepository(w http.ResponseWrite
 		payload.FromAppTemplate,
 		payload.TLSSkipVerify,
 	)
+	stackPayload.ComposeProfiles = payload.ComposeProfiles
+	stackPayload.EnvFiles = payload.EnvFiles
 
 	composeStackBuilder := stackbuilders.CreateComposeStackGitBuilder(securityContext,
 		handler.DataStore,
@@ -317,6 +382,8 @@ type composeStackFromFileUploadPayload struct {
 	Name             string
 	StackFileContent []byte
 	Env              []portainer.Pair
+	ComposeProfiles  []string
+	EnvFiles         []stackbuilders.EnvFilePayload
 }
 
 func createStackPayloadFromComposeFileUploadPayload(name string, fileContentBytes []byte, env []portainer.Pair) stackbuilders.StackPayload {
@@ -347,9 +414,44 @@ func decodeRequestForm(r *http.Request) (*composeStackFromFileUploadPayload, err
 		return nil, errors.New("Invalid Env parameter")
 	}
 	payload.Env = env
+
+	var composeProfiles []string
+	if err := request.RetrieveMultiPartFormJSONValue(r, "ComposeProfiles", &composeProfiles, true); err != nil {
+		return nil, errors.New("Invalid ComposeProfiles parameter")
+	}
+	if err := stackutils.ValidateComposeProfiles(composeProfiles); err != nil {
+		return nil, err
+	}
+	payload.ComposeProfiles = composeProfiles
+
+	if r.MultipartForm != nil {
+		for _, fileHeader := range r.MultipartForm.File["EnvFiles"] {
+			content, err := readMultipartFile(fileHeader)
+			if err != nil {
+				return nil, errors.New("Invalid env file. Ensure that the env files are uploaded correctly")
+			}
+
+			payload.EnvFiles = append(payload.EnvFiles, stackbuilders.EnvFilePayload{Name: fileHeader.Filename, Content: string(content)})
+		}
+	}
+
+	if err := validateUploadedEnvFiles(payload.EnvFiles); err != nil {
+		return nil, err
+	}
+
 	return payload, nil
 }
 
+func readMultipartFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
 // @id StackCreateDockerStandaloneFile
 // @summary Deploy a new compose stack from a file
 // @description Deploy a new stack into a Docker environment specified via the environment identifier.
@@ -362,6 +464,8 @@ func decodeRequestForm(r *http.Request) (*composeStackFromFileUploadPayload, err
 // @param Name formData string true "Name of the stack"
 // @param Env formData string false "Environment variables passed during deployment, represented as a JSON array [{'name': 'name', 'value': 'value'}]."
 // @param file formData file false "Stack file"
+// @param ComposeProfiles formData string false "Compose profiles to enable, represented as a JSON array ['debug']"
+// @param EnvFiles formData file false "Env files stored next to the stack file and loaded in the upload order"
 // @param endpointId query int true "Identifier of the environment that will be used to deploy the stack"
 // @success 200 {object} portainer.Stack
 // @failure 400 "Invalid request"
@@ -403,6 +507,8 @@ func (handler *Handler) createComposeStackFromFileUpload(w http.ResponseWriter,
 	}
 
 	stackPayload := createStackPayloadFromComposeFileUploadPayload(payload.Name, payload.StackFileContent, payload.Env)
+	stackPayload.ComposeProfiles = payload.ComposeProfiles
+	stackPayload.UploadedEnvFiles = payload.EnvFiles
 
 	composeStackBuilder := stackbuilders.CreateComposeStackFileUploadBuilder(securityContext,
 		handler.DataStore,
@@ -3,11 +3,13 @@ package stacks
 import (
 	"fmt"
 	"net/http"
+	"path/filepath"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/git/update"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/internal/registryutils"
+	"github.com/portainer/portainer/api/internal/templatevariables"
 	k "github.com/portainer/portainer/api/kubernetes"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackbuilders"
@@ -27,6 +29,11 @@ type kubernetesStringDeploymentPayload struct {
 	StackFileContent string
 	// Whether the stack is from a app template
 	FromAppTemplate bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against
+	// its definitions and the stack file of the template rendered with them is used when no stack file content is given
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
 }
 
 func createStackPayloadFromK8sFileContentPayload(name, namespace, fileContent string, composeFormat, fromAppTemplate bool) stackbuilders.StackPayload {
@@ -49,9 +56,15 @@ type kubernetesGitDeploymentPayload struct {
 	RepositoryPassword       string
 	ManifestFile             string
 	AdditionalFiles          []string
-	AutoUpdate               *portainer.AutoUpdateSettings
+	// Path to a kustomization folder inside the repository, rendered and deployed instead of the manifest files
+	KustomizePath string `example:"overlays/production"`
+	AutoUpdate    *portainer.AutoUpdateSettings
 	// TLSSkipVerify skips SSL verification when cloning the Git repository
 	TLSSkipVerify bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against its definitions
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
 }
 
 func createStackPayloadFromK8sGitPayload(name, repoUrl, repoReference, repoUsername, repoPassword string, repoAuthentication, composeFormat bool, namespace, manifest string, additionalFiles []string, autoUpdate *portainer.AutoUpdateSettings, repoSkipSSLVerify bool) stackbuilders.StackPayload {
@@ -88,7 +101,7 @@ func createStackPayloadFromK8sUrlPayload(name, namespace, manifestUrl string, co
 }
 
 func (payload *kubernetesStringDeploymentPayload) Validate(r *http.Request) error {
-	if len(payload.StackFileContent) == 0 {
+	if len(payload.StackFileContent) == 0 && payload.CustomTemplateID == 0 {
 		return errors.New("Invalid stack file content")
 	}
 
@@ -104,7 +117,12 @@ func (payload *kubernetesGitDeploymentPayload) Validate(r *http.Request) error {
 		return errors.New("Invalid repository credentials. Password must be specified when authentication is enabled")
 	}
 
-	if len(payload.ManifestFile) == 0 {
+	if len(payload.KustomizePath) > 0 {
+		payload.KustomizePath = filepath.Clean(payload.KustomizePath)
+		if !filepath.IsLocal(payload.KustomizePath) {
+			return errors.New("Invalid kustomization path. Must be a folder inside the repository")
+		}
+	} else if len(payload.ManifestFile) == 0 {
 		return errors.New("Invalid manifest file in repository")
 	}
 
@@ -147,6 +165,12 @@ func (handler *Handler) createKubernetesStackFromFileContent(w http.ResponseWrit
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	fileContent, httpErr := handler.customTemplateFileContent(payload.CustomTemplateID, payload.CustomTemplateVariables, payload.StackFileContent)
+	if httpErr != nil {
+		return httpErr
+	}
+	payload.StackFileContent = fileContent
+
 	user, err := handler.DataStore.User().Read(userID)
 	if err != nil {
 		return httperror.InternalServerError("Unable to load user information from the database", err)
@@ -183,6 +207,7 @@ func (handler *Handler) createKubernetesStackFromFileContent(w http.ResponseWrit
 // @id StackCreateKubernetesGit
 // @summary Deploy a new kubernetes stack from a git repository
 // @description Deploy a new stack into a Docker environment specified via the environment identifier.
+// @description When a kustomization path is specified, the kustomization is rendered and deployed instead of the manifest files.
 // @description **Access policy**: authenticated
 // @tags stacks
 // @security ApiKeyAuth
@@ -205,6 +230,10 @@ func (handler *Handler) createKubernetesStackFromGitRepository(w http.ResponseWr
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	if httpErr := handler.validateCustomTemplateValues(payload.CustomTemplateID, payload.CustomTemplateVariables); httpErr != nil {
+		return httpErr
+	}
+
 	user, err := handler.DataStore.User().Read(userID)
 	if err != nil {
 		return httperror.InternalServerError("Unable to load user information from the database", err)
@@ -232,6 +261,7 @@ func (handler *Handler) createKubernetesStackFromGitRepository(w http.ResponseWr
 		payload.AutoUpdate,
 		payload.TLSSkipVerify,
 	)
+	stackPayload.KustomizePath = payload.KustomizePath
 
 	k8sStackBuilder := stackbuilders.CreateKubernetesStackGitBuilder(handler.DataStore,
 		handler.FileService,
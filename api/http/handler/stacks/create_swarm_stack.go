@@ -7,6 +7,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/git/update"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/templatevariables"
 	"github.com/portainer/portainer/api/stacks/stackbuilders"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -27,6 +28,11 @@ type swarmStackFromFileContentPayload struct {
 	Env []portainer.Pair
 	// Whether the stack is from a app template
 	FromAppTemplate bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against
+	// its definitions and the stack file of the template rendered with them is used when no stack file content is given
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
 }
 
 func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error {
@@ -36,7 +42,7 @@ func (payload *swarmStackFromFileContentPayload) Validate(r *http.Request) error
 	if len(payload.SwarmID) == 0 {
 		return errors.New("Invalid Swarm ID")
 	}
-	if len(payload.StackFileContent) == 0 {
+	if len(payload.StackFileContent) == 0 && payload.CustomTemplateID == 0 {
 		return errors.New("Invalid stack file content")
 	}
 	return nil
@@ -74,6 +80,12 @@ func (handler *Handler) createSwarmStackFromFileContent(w http.ResponseWriter, r
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	fileContent, httpErr := handler.customTemplateFileContent(payload.CustomTemplateID, payload.CustomTemplateVariables, payload.StackFileContent)
+	if httpErr != nil {
+		return httpErr
+	}
+	payload.StackFileContent = fileContent
+
 	payload.Name = handler.SwarmStackManager.NormalizeStackName(payload.Name)
 
 	isUnique, err := handler.checkUniqueStackNameInDocker(endpoint, payload.Name, 0, true)
@@ -125,6 +137,10 @@ type swarmStackFromGitRepositoryPayload struct {
 	RepositoryPassword string `example:"myGitPassword"`
 	// Whether the stack is from a app template
 	FromAppTemplate bool `example:"false"`
+	// Identifier of the custom template the stack is created from, the values of its variables are validated against its definitions
+	CustomTemplateID portainer.CustomTemplateID `example:"1"`
+	// Values of the variables of the custom template, the default value is used for the missing ones
+	CustomTemplateVariables []templatevariables.Value
 	// Path to the Stack file inside the Git repository
 	ComposeFile string `example:"docker-compose.yml" default:"docker-compose.yml"`
 	// Applicable when deploying with multiple stack files
@@ -197,6 +213,10 @@ func (handler *Handler) createSwarmStackFromGitRepository(w http.ResponseWriter,
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	if httpErr := handler.validateCustomTemplateValues(payload.CustomTemplateID, payload.CustomTemplateVariables); httpErr != nil {
+		return httpErr
+	}
+
 	payload.Name = handler.SwarmStackManager.NormalizeStackName(payload.Name)
 
 	isUnique, err := handler.checkUniqueStackNameInDocker(endpoint, payload.Name, 0, true)
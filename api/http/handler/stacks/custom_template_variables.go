@@ -0,0 +1,69 @@
+package stacks
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/templatevariables"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+)
+
+// customTemplateFileContent validates the variable values against the definitions of the custom template the
+// stack is created from. The stack file content is returned as is, or when it is empty, the stack file of the
+// template rendered with the values
+func (handler *Handler) customTemplateFileContent(customTemplateID portainer.CustomTemplateID, values []templatevariables.Value, fileContent string) (string, *httperror.HandlerError) {
+	if customTemplateID == 0 {
+		return fileContent, nil
+	}
+
+	customTemplate, err := handler.DataStore.CustomTemplate().Read(customTemplateID)
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return "", httperror.BadRequest("Unable to find a custom template with the specified identifier inside the database", err)
+	} else if err != nil {
+		return "", httperror.InternalServerError("Unable to find a custom template with the specified identifier inside the database", err)
+	}
+
+	if fileContent != "" {
+		if _, err := templatevariables.ResolveValues(customTemplate.Variables, values); err != nil {
+			return "", httperror.BadRequest("Invalid custom template variable values", err)
+		}
+
+		return fileContent, nil
+	}
+
+	entryPath := customTemplate.EntryPoint
+	if customTemplate.GitConfig != nil {
+		entryPath = customTemplate.GitConfig.ConfigFilePath
+	}
+
+	templateContent, err := handler.FileService.GetFileContent(customTemplate.ProjectPath, entryPath)
+	if err != nil {
+		return "", httperror.InternalServerError("Unable to retrieve custom template file from disk", err)
+	}
+
+	rendered, err := templatevariables.Render(string(templateContent), customTemplate.Variables, values)
+	if err != nil {
+		return "", httperror.BadRequest("Invalid custom template variable values", err)
+	}
+
+	return rendered, nil
+}
+
+// validateCustomTemplateValues validates the variable values against the definitions of the custom template
+// a Git stack is created from
+func (handler *Handler) validateCustomTemplateValues(customTemplateID portainer.CustomTemplateID, values []templatevariables.Value) *httperror.HandlerError {
+	if customTemplateID == 0 {
+		return nil
+	}
+
+	customTemplate, err := handler.DataStore.CustomTemplate().Read(customTemplateID)
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.BadRequest("Unable to find a custom template with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a custom template with the specified identifier inside the database", err)
+	}
+
+	if _, err := templatevariables.ResolveValues(customTemplate.Variables, values); err != nil {
+		return httperror.BadRequest("Invalid custom template variable values", err)
+	}
+
+	return nil
+}
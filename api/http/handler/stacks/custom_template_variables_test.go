@@ -0,0 +1,71 @@
+package stacks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/internal/templatevariables"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackCreateValidatesCustomTemplateValues(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	fileService, err := filesystem.NewService(t.TempDir(), "")
+	require.NoError(t, err)
+
+	projectPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectPath, "docker-compose.yml"), []byte("ports:\n  - {{PORT}}:80\n"), 0600))
+
+	maxPort := float64(65535)
+	require.NoError(t, store.CustomTemplate().Create(&portainer.CustomTemplate{
+		ID:          1,
+		ProjectPath: projectPath,
+		EntryPoint:  "docker-compose.yml",
+		Variables: []portainer.CustomTemplateVariableDefinition{
+			{Name: "PORT", Type: portainer.CustomTemplateVariableTypeNumber, Required: true, Max: &maxPort},
+		},
+	}))
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+	h.FileService = fileService
+
+	endpoint := &portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment}
+
+	newRequest := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/stacks/create/standalone/string?endpointId=1", strings.NewReader(body))
+	}
+
+	httpErr := h.createComposeStackFromFileContent(httptest.NewRecorder(), newRequest(`{"Name": "web", "CustomTemplateID": 1, "CustomTemplateVariables": [{"name": "PORT", "value": "70000"}]}`), endpoint, 1)
+	require.NotNil(t, httpErr)
+	require.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+
+	httpErr = h.createComposeStackFromFileContent(httptest.NewRecorder(), newRequest(`{"Name": "web", "StackFileContent": "ports:\n  - 70000:80\n", "CustomTemplateID": 1, "CustomTemplateVariables": [{"name": "PORT", "value": "70000"}]}`), endpoint, 1)
+	require.NotNil(t, httpErr)
+	require.Equal(t, http.StatusBadRequest, httpErr.StatusCode, "the values must be validated when the content was rendered by the client")
+
+	httpErr = h.createComposeStackFromGitRepository(httptest.NewRecorder(), newRequest(`{"Name": "web", "RepositoryURL": "https://github.com/portainer/portainer", "CustomTemplateID": 1, "CustomTemplateVariables": []}`), endpoint, 1)
+	require.NotNil(t, httpErr)
+	require.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+
+	httpErr = h.createComposeStackFromFileContent(httptest.NewRecorder(), newRequest(`{"Name": "web", "CustomTemplateID": 2}`), endpoint, 1)
+	require.NotNil(t, httpErr)
+	require.Equal(t, http.StatusBadRequest, httpErr.StatusCode, "the custom template must exist")
+
+	_, httpErr = h.customTemplateFileContent(1, nil, "")
+	require.NotNil(t, httpErr)
+
+	fileContent, httpErr := h.customTemplateFileContent(1, []templatevariables.Value{{Name: "PORT", Value: "8080"}}, "")
+	require.Nil(t, httpErr)
+	require.Equal(t, "ports:\n  - 8080:80\n", fileContent)
+}
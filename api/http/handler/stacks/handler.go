@@ -42,6 +42,8 @@ type Handler struct {
 	KubernetesClientFactory *cli.ClientFactory
 	Scheduler               *scheduler.Scheduler
 	StackDeployer           deployments.StackDeployer
+	ImageWatcher            *deployments.ImageWatcher
+	DriftDetector           *deployments.DriftDetector
 }
 
 func stackExistsError(name string) *httperror.HandlerError {
@@ -77,6 +79,14 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackUpdateGit))).Methods(http.MethodPost)
 	h.Handle("/stacks/{id}/git/redeploy",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackGitRedeploy))).Methods(http.MethodPut)
+	h.Handle("/stacks/{id}/image_watch",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackUpdateImageWatch))).Methods(http.MethodPut)
+	h.Handle("/stacks/{id}/drift_check",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackUpdateDriftCheck))).Methods(http.MethodPut)
+	h.Handle("/stacks/{id}/drift",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackDriftInspect))).Methods(http.MethodGet)
+	h.Handle("/stacks/{id}/drift/reconcile",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackDriftReconcile))).Methods(http.MethodPost)
 	h.Handle("/stacks/{id}/file",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackFile))).Methods(http.MethodGet)
 	h.Handle("/stacks/{id}/migrate",
@@ -85,6 +95,14 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackStart))).Methods(http.MethodPost)
 	h.Handle("/stacks/{id}/stop",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackStop))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/services/{serviceName}/restart",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackServiceRestart))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/services/{serviceName}/stop",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackServiceStop))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/services/{serviceName}/recreate",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackServiceRecreate))).Methods(http.MethodPost)
+	h.Handle("/stacks/{id}/services/{serviceName}/scale",
+		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.stackServiceScale))).Methods(http.MethodPost)
 	h.Handle("/stacks/webhooks/{webhookID}",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.webhookInvoke))).Methods(http.MethodPost)
 
@@ -8,6 +8,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -125,5 +126,7 @@ func (handler *Handler) stackAssociate(w http.ResponseWriter, r *http.Request) *
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
@@ -6,6 +6,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/authorization"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -138,5 +139,7 @@ func (handler *Handler) decorateStackResponse(w http.ResponseWriter, stack *port
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
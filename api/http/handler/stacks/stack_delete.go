@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/filesystem"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	k "github.com/portainer/portainer/api/kubernetes"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -117,6 +119,14 @@ func (handler *Handler) stackDelete(w http.ResponseWriter, r *http.Request) *htt
 		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
 	}
 
+	if stack.ImageWatch != nil {
+		handler.ImageWatcher.Stop(stack.ID, stack.ImageWatch.JobID)
+	}
+
+	if stack.DriftCheck != nil {
+		handler.DriftDetector.Stop(stack.ID, stack.DriftCheck.JobID)
+	}
+
 	if err := handler.deleteStack(securityContext.UserID, stack, endpoint); err != nil {
 		return httperror.InternalServerError(err.Error(), err)
 	}
@@ -203,6 +213,21 @@ func (handler *Handler) deleteStack(userID portainer.UserID, stack *portainer.St
 	if stack.Type == portainer.KubernetesStack {
 		manifestFiles := stackutils.GetStackFilePaths(stack, true)
 
+		if stack.KustomizePath != "" {
+			tmpDir, err := os.MkdirTemp("", "kub_removal")
+			if err != nil {
+				return errors.Wrap(err, "failed to create temp kub removal directory")
+			}
+			defer os.RemoveAll(tmpDir)
+
+			manifestFile, err := kustomizeRemovalManifest(stack, tmpDir)
+			if err != nil {
+				return err
+			}
+
+			manifestFiles = []string{manifestFile}
+		}
+
 		out, err := handler.KubernetesDeployer.Remove(userID, endpoint, manifestFiles, stack.Namespace)
 		if err != nil {
 			for _, manifest := range manifestFiles {
@@ -221,6 +246,38 @@ func (handler *Handler) deleteStack(userID portainer.UserID, stack *portainer.St
 	return fmt.Errorf("unsupported stack type: %v", stack.Type)
 }
 
+// kustomizeRemovalManifest writes inside dir the manifest of the resources to remove for a kustomize stack.
+// The kustomization is rendered again, or the recorded inventory of the stack is used when it cannot be
+// rendered. The stack is kept when neither is available so that its resources are not left behind in the cluster
+func kustomizeRemovalManifest(stack *portainer.Stack, dir string) (string, error) {
+	manifestFile := filesystem.JoinPaths(dir, "resources.yaml")
+
+	var content []byte
+
+	manifests, renderErr := stackutils.GetKubernetesStackManifests(stack)
+	if renderErr == nil {
+		manifestFile = filesystem.JoinPaths(dir, manifests[0].Name)
+		content = manifests[0].Content
+	} else if len(stack.KubernetesInventory) > 0 {
+		log.Warn().Err(renderErr).Int("stack_id", int(stack.ID)).Msg("failed to render the kustomization of the stack, removing the resources of its inventory")
+
+		manifest, err := k.ResourcesManifest(stack.KubernetesInventory)
+		if err != nil {
+			return "", err
+		}
+
+		content = manifest
+	} else {
+		return "", errors.Wrap(renderErr, "failed to render the kustomization of the stack and no inventory of its resources is recorded")
+	}
+
+	if err := filesystem.WriteToFile(manifestFile, content); err != nil {
+		return "", errors.Wrap(err, "failed to create temp manifest file")
+	}
+
+	return manifestFile, nil
+}
+
 // @id StackDeleteKubernetesByName
 // @summary Remove Kubernetes stacks by name
 // @description Remove a stack.
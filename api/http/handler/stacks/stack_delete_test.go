@@ -0,0 +1,30 @@
+package stacks
+
+import (
+	"os"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_kustomizeRemovalManifest(t *testing.T) {
+	stack := &portainer.Stack{ID: 1, ProjectPath: t.TempDir(), KustomizePath: "missing"}
+
+	_, err := kustomizeRemovalManifest(stack, t.TempDir())
+	require.Error(t, err, "the stack is kept when it can neither be rendered nor has an inventory")
+
+	stack.KubernetesInventory = []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "apps", Name: "web"},
+	}
+
+	manifestFile, err := kustomizeRemovalManifest(stack, t.TempDir())
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(manifestFile)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "kind: Deployment")
+	require.Contains(t, string(content), "name: web")
+	require.Contains(t, string(content), "namespace: apps")
+}
@@ -0,0 +1,221 @@
+package stacks
+
+import (
+	"context"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+	"github.com/portainer/portainer/api/stacks/deployments"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+
+	"github.com/pkg/errors"
+)
+
+type stackDriftCheckUpdatePayload struct {
+	// Interval between two drift checks, the periodic check is disabled when empty
+	Interval string `example:"15m"`
+	// Redeploy the stack when a drift is detected
+	AutoReconcile bool `example:"false"`
+}
+
+func (payload *stackDriftCheckUpdatePayload) Validate(r *http.Request) error {
+	return deployments.ValidateDriftCheckSettings(&portainer.StackDriftCheckSettings{Interval: payload.Interval})
+}
+
+// @id StackUpdateDriftCheck
+// @summary Update the drift check policy of a stack
+// @description Periodically compare the desired state of a stack with the resources running on its environment,
+// @description and optionally redeploy the stack when they differ.
+// @description The periodic check is disabled when the interval is empty, the latest report is kept.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Stack identifier"
+// @param body body stackDriftCheckUpdatePayload true "Drift check policy"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/drift_check [put]
+func (handler *Handler) stackUpdateDriftCheck(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload stackDriftCheckUpdatePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	stack, _, httpErr := handler.prepareStackDriftOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if stack.DriftCheck == nil {
+		stack.DriftCheck = &portainer.StackDriftCheckSettings{}
+	}
+
+	handler.DriftDetector.Stop(stack.ID, stack.DriftCheck.JobID)
+
+	stack.DriftCheck.Interval = payload.Interval
+	stack.DriftCheck.AutoReconcile = payload.AutoReconcile
+	stack.DriftCheck.JobID = ""
+
+	if payload.Interval != "" && stack.Status != portainer.StackStatusInactive {
+		jobID, err := handler.DriftDetector.Start(stack.ID, payload.Interval)
+		if err != nil {
+			return httperror.BadRequest("Unable to parse stack's drift check interval", err)
+		}
+
+		stack.DriftCheck.JobID = jobID
+	}
+
+	if err := handler.DataStore.Stack().Update(stack.ID, stack); err != nil {
+		return httperror.InternalServerError("Unable to persist the stack changes inside the database", err)
+	}
+
+	if stack.GitConfig != nil && stack.GitConfig.Authentication != nil && stack.GitConfig.Authentication.Password != "" {
+		// sanitize password in the http response to minimise possible security leaks
+		stack.GitConfig.Authentication.Password = ""
+	}
+
+	stack.Env = envsecrets.Mask(stack.Env)
+
+	return response.JSON(w, stack)
+}
+
+// @id StackDriftInspect
+// @summary Check the drift of a stack
+// @description Compare the desired state of a stack with the resources running on its environment and list the differences:
+// @description missing or unexpected services, containers and workloads, changed images, environment variables and replicas.
+// @description The values of the environment variables are never reported. The report is kept as the latest report of the stack.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Stack identifier"
+// @success 200 {object} portainer.StackDriftReport "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/drift [get]
+func (handler *Handler) stackDriftInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return handler.runStackDriftCheck(w, r, false)
+}
+
+// @id StackDriftReconcile
+// @summary Reconcile the drift of a stack
+// @description Check the drift of a stack and, when differences are found, redeploy the stack: the containers of a compose stack are recreated,
+// @description the services of a swarm stack which are not part of it are pruned and the manifests of a Kubernetes stack are applied again.
+// @description Containers started by hand alongside a compose stack are reported but left untouched.
+// @description The stack is redeployed on behalf of the user making the request.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Stack identifier"
+// @success 200 {object} portainer.StackDriftReport "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/drift/reconcile [post]
+func (handler *Handler) stackDriftReconcile(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return handler.runStackDriftCheck(w, r, true)
+}
+
+func (handler *Handler) runStackDriftCheck(w http.ResponseWriter, r *http.Request, reconcile bool) *httperror.HandlerError {
+	stack, endpoint, httpErr := handler.prepareStackDriftOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if stack.Status != portainer.StackStatusActive {
+		return httperror.BadRequest("Stack is inactive", errors.New("stack is inactive"))
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+
+	user, err := handler.DataStore.User().Read(securityContext.UserID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to load user information from the database", err)
+	}
+
+	report, driftErr := handler.DriftDetector.Run(context.TODO(), stack, endpoint, reconcile, user)
+
+	if err := handler.DriftDetector.SaveReport(stack, report); err != nil {
+		return httperror.InternalServerError("Unable to persist the stack changes inside the database", err)
+	}
+
+	if driftErr != nil {
+		return httperror.InternalServerError("Unable to check the drift of the stack", driftErr)
+	}
+
+	return response.JSON(w, report)
+}
+
+// prepareStackDriftOperation retrieves the stack and its environment, and verifies the user can manage the stack
+func (handler *Handler) prepareStackDriftOperation(r *http.Request) (*portainer.Stack, *portainer.Endpoint, *httperror.HandlerError) {
+	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, nil, httperror.BadRequest("Invalid stack identifier route variable", err)
+	}
+
+	stack, err := handler.DataStore.Stack().Read(portainer.StackID(stackID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, nil, httperror.NotFound("Unable to find a stack with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to find a stack with the specified identifier inside the database", err)
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(stack.EndpointID)
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, nil, httperror.NotFound("Unable to find the environment associated to the stack inside the database", err)
+	} else if err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to find the environment associated to the stack inside the database", err)
+	}
+
+	if err := handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint); err != nil {
+		return nil, nil, httperror.Forbidden("Permission denied to access environment", err)
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+
+	if stack.Type == portainer.DockerSwarmStack || stack.Type == portainer.DockerComposeStack {
+		resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(stackutils.ResourceControlID(stack.EndpointID, stack.Name), portainer.StackResourceControl)
+		if err != nil {
+			return nil, nil, httperror.InternalServerError("Unable to retrieve a resource control associated to the stack", err)
+		}
+
+		if access, err := handler.userCanAccessStack(securityContext, endpoint.ID, resourceControl); err != nil {
+			return nil, nil, httperror.InternalServerError("Unable to verify user authorizations to validate stack access", err)
+		} else if !access {
+			return nil, nil, httperror.Forbidden("Access denied to resource", httperrors.ErrResourceAccessDenied)
+		}
+	}
+
+	if canManage, err := handler.userCanManageStacks(securityContext, endpoint); err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to verify user authorizations to validate stack management", err)
+	} else if !canManage {
+		errMsg := "Stack management is disabled for non-admin users"
+		return nil, nil, httperror.Forbidden(errMsg, errors.New(errMsg))
+	}
+
+	return stack, endpoint, nil
+}
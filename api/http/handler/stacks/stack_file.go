@@ -22,6 +22,7 @@ type stackFileResponse struct {
 // @id StackFileInspect
 // @summary Retrieve the content of the Stack file for the specified stack
 // @description Get Stack file content.
+// @description The manifests rendered from the kustomization of a Kubernetes stack are returned instead of the kustomization file.
 // @description **Access policy**: restricted
 // @tags stacks
 // @security ApiKeyAuth
@@ -92,6 +93,15 @@ func (handler *Handler) stackFile(w http.ResponseWriter, r *http.Request) *httpe
 		}
 	}
 
+	if stack.KustomizePath != "" {
+		manifests, err := stackutils.GetKubernetesStackManifests(stack)
+		if err != nil {
+			return httperror.InternalServerError("Unable to render the kustomization of the stack", err)
+		}
+
+		return response.JSON(w, &stackFileResponse{StackFileContent: string(manifests[0].Content)})
+	}
+
 	stackFileContent, err := handler.FileService.GetFileContent(stack.ProjectPath, stack.EntryPoint)
 	if err != nil {
 		return httperror.InternalServerError("Unable to retrieve Compose file from disk", err)
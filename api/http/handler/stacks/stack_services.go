@@ -0,0 +1,290 @@
+package stacks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type stackServiceRecreatePayload struct {
+	// Pull the image of the service before recreating it
+	PullImage bool `example:"false"`
+}
+
+func (payload *stackServiceRecreatePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+type stackServiceScalePayload struct {
+	// Number of containers of the service
+	Replicas int `example:"3"`
+}
+
+func (payload *stackServiceScalePayload) Validate(r *http.Request) error {
+	if payload.Replicas < 0 {
+		return errors.New("Invalid replicas. Must be a positive number")
+	}
+
+	return nil
+}
+
+// stackServiceOperation holds what is needed to run an operation on a service of a compose stack
+type stackServiceOperation struct {
+	stack       *portainer.Stack
+	endpoint    *portainer.Endpoint
+	serviceName string
+	options     portainer.ComposeOptions
+}
+
+// @id StackServiceRestart
+// @summary Restart a service of a stack
+// @description Restart the containers of a service of a compose stack, the other services are left untouched.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Stack identifier"
+// @param serviceName path string true "Name of the service in the stack file"
+// @param endpointId query int true "Environment identifier"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/services/{serviceName}/restart [post]
+func (handler *Handler) stackServiceRestart(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	operation, httpErr := handler.prepareStackServiceOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := handler.ComposeStackManager.RestartServices(context.TODO(), operation.stack, operation.endpoint, []string{operation.serviceName}, operation.options); err != nil {
+		return httperror.InternalServerError("Unable to restart the service", err)
+	}
+
+	return stackServiceResponse(w, operation.stack)
+}
+
+// @id StackServiceStop
+// @summary Stop a service of a stack
+// @description Stop the containers of a service of a compose stack without removing them, the other services are left untouched.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Stack identifier"
+// @param serviceName path string true "Name of the service in the stack file"
+// @param endpointId query int true "Environment identifier"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/services/{serviceName}/stop [post]
+func (handler *Handler) stackServiceStop(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	operation, httpErr := handler.prepareStackServiceOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := handler.ComposeStackManager.StopServices(context.TODO(), operation.stack, operation.endpoint, []string{operation.serviceName}, operation.options); err != nil {
+		return httperror.InternalServerError("Unable to stop the service", err)
+	}
+
+	return stackServiceResponse(w, operation.stack)
+}
+
+// @id StackServiceRecreate
+// @summary Recreate a service of a stack
+// @description Recreate the containers of a service of a compose stack, optionally pulling its image first.
+// @description The other services are left untouched.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Stack identifier"
+// @param serviceName path string true "Name of the service in the stack file"
+// @param endpointId query int true "Environment identifier"
+// @param body body stackServiceRecreatePayload false "Recreate options"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/services/{serviceName}/recreate [post]
+func (handler *Handler) stackServiceRecreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload stackServiceRecreatePayload
+	if r.ContentLength > 0 {
+		if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+			return httperror.BadRequest("Invalid request payload", err)
+		}
+	}
+
+	operation, httpErr := handler.prepareStackServiceOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := handler.ComposeStackManager.RecreateServices(context.TODO(), operation.stack, operation.endpoint, []string{operation.serviceName}, portainer.ComposeRecreateOptions{
+		ComposeOptions: operation.options,
+		PullImage:      payload.PullImage,
+	}); err != nil {
+		return httperror.InternalServerError("Unable to recreate the service", err)
+	}
+
+	return stackServiceResponse(w, operation.stack)
+}
+
+// @id StackServiceScale
+// @summary Scale a service of a stack
+// @description Set the number of containers of a service of a compose stack, the other services are left untouched.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Stack identifier"
+// @param serviceName path string true "Name of the service in the stack file"
+// @param endpointId query int true "Environment identifier"
+// @param body body stackServiceScalePayload true "Scale options"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/services/{serviceName}/scale [post]
+func (handler *Handler) stackServiceScale(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload stackServiceScalePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	operation, httpErr := handler.prepareStackServiceOperation(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := handler.ComposeStackManager.ScaleService(context.TODO(), operation.stack, operation.endpoint, operation.serviceName, payload.Replicas, operation.options); err != nil {
+		return httperror.InternalServerError("Unable to scale the service", err)
+	}
+
+	return stackServiceResponse(w, operation.stack)
+}
+
+// prepareStackServiceOperation loads the stack and the environment of the request and
+// checks the user is allowed to manage the stack
+func (handler *Handler) prepareStackServiceOperation(r *http.Request) (*stackServiceOperation, *httperror.HandlerError) {
+	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid stack identifier route variable", err)
+	}
+
+	serviceName, err := request.RetrieveRouteVariableValue(r, "serviceName")
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid service name route variable", err)
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+
+	stack, err := handler.DataStore.Stack().Read(portainer.StackID(stackID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a stack with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a stack with the specified identifier inside the database", err)
+	}
+
+	if stack.Type != portainer.DockerComposeStack {
+		return nil, httperror.BadRequest("Service operations are only supported for compose stacks", errors.New("not a compose stack"))
+	}
+
+	if stackutils.IsRelativePathStack(stack) {
+		return nil, httperror.BadRequest("Service operations are not supported for stacks using relative paths", errors.New("relative path stack"))
+	}
+
+	if stack.Status != portainer.StackStatusActive {
+		return nil, httperror.BadRequest("Stack is inactive", errors.New("stack is inactive"))
+	}
+
+	endpointID, err := request.RetrieveNumericQueryParameter(r, "endpointId", false)
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid query parameter: endpointId", err)
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
+	}
+
+	if err := handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint); err != nil {
+		return nil, httperror.Forbidden("Permission denied to access environment", err)
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(stackutils.ResourceControlID(stack.EndpointID, stack.Name), portainer.StackResourceControl)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve a resource control associated to the stack", err)
+	}
+
+	if access, err := handler.userCanAccessStack(securityContext, endpoint.ID, resourceControl); err != nil {
+		return nil, httperror.InternalServerError("Unable to verify user authorizations to validate stack access", err)
+	} else if !access {
+		return nil, httperror.Forbidden("Access denied to resource", httperrors.ErrResourceAccessDenied)
+	}
+
+	if canManage, err := handler.userCanManageStacks(securityContext, endpoint); err != nil {
+		return nil, httperror.InternalServerError("Unable to verify user authorizations to validate stack management", err)
+	} else if !canManage {
+		errMsg := "stack management is disabled for non-admin users"
+		return nil, httperror.Forbidden(errMsg, errors.New(errMsg))
+	}
+
+	user, err := handler.DataStore.User().Read(securityContext.UserID)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to load user information from the database", err)
+	}
+
+	registries, err := handler.DataStore.Registry().ReadAll()
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve registries from the database", err)
+	}
+
+	stack.Name = handler.ComposeStackManager.NormalizeStackName(stack.Name)
+
+	return &stackServiceOperation{
+		stack:       stack,
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		options: portainer.ComposeOptions{
+			Registries: security.FilterRegistries(registries, user, securityContext.UserMemberships, endpoint.ID),
+		},
+	}, nil
+}
+
+func stackServiceResponse(w http.ResponseWriter, stack *portainer.Stack) *httperror.HandlerError {
+	if stack.GitConfig != nil && stack.GitConfig.Authentication != nil && stack.GitConfig.Authentication.Password != "" {
+		// sanitize password in the http response to minimise possible security leaks
+		stack.GitConfig.Authentication.Password = ""
+	}
+
+	stack.Env = envsecrets.Mask(stack.Env)
+
+	return response.JSON(w, stack)
+}
@@ -0,0 +1,67 @@
+package stacks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+type scaleRecorder struct {
+	portainer.ComposeStackManager
+
+	stackName   string
+	serviceName string
+	replicas    int
+}
+
+func (r *scaleRecorder) ScaleService(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceName string, replicas int, options portainer.ComposeOptions) error {
+	r.stackName = stack.Name
+	r.serviceName = serviceName
+	r.replicas = replicas
+
+	return nil
+}
+
+func TestStackServiceScale(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.User().Create(&portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment, URL: "unix:///var/run/docker.sock"}))
+	require.NoError(t, store.Stack().Create(&portainer.Stack{ID: 1, Name: "web", EndpointID: 1, Type: portainer.DockerComposeStack, Status: portainer.StackStatusActive}))
+	require.NoError(t, store.Stack().Create(&portainer.Stack{ID: 2, Name: "swarm", EndpointID: 1, Type: portainer.DockerSwarmStack, Status: portainer.StackStatusActive}))
+
+	recorder := &scaleRecorder{ComposeStackManager: testhelpers.NewComposeStackManager()}
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+	h.ComposeStackManager = recorder
+
+	newScaleRequest := func(stackID, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/stacks/"+stackID+"/services/worker/scale?endpointId=1", strings.NewReader(body))
+		return req.WithContext(security.StoreRestrictedRequestContext(req, &security.RestrictedRequestContext{IsAdmin: true, UserID: 1}))
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, newScaleRequest("1", `{"Replicas": 3}`))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, "web", recorder.stackName)
+	require.Equal(t, "worker", recorder.serviceName)
+	require.Equal(t, 3, recorder.replicas)
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, newScaleRequest("1", `{"Replicas": -1}`))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, newScaleRequest("2", `{"Replicas": 1}`))
+	require.Equal(t, http.StatusBadRequest, rr.Code, "only compose stacks support service operations")
+}
@@ -9,6 +9,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -117,6 +118,28 @@ func (handler *Handler) stackStart(w http.ResponseWriter, r *http.Request) *http
 		stack.AutoUpdate.JobID = jobID
 	}
 
+	if stack.ImageWatch != nil && stack.ImageWatch.Interval != "" {
+		handler.ImageWatcher.Stop(stack.ID, stack.ImageWatch.JobID)
+
+		jobID, err := handler.ImageWatcher.Start(stack.ID, stack.ImageWatch.Interval)
+		if err != nil {
+			return httperror.BadRequest("Unable to parse stack's image watch interval", err)
+		}
+
+		stack.ImageWatch.JobID = jobID
+	}
+
+	if stack.DriftCheck != nil && stack.DriftCheck.Interval != "" {
+		handler.DriftDetector.Stop(stack.ID, stack.DriftCheck.JobID)
+
+		jobID, err := handler.DriftDetector.Start(stack.ID, stack.DriftCheck.Interval)
+		if err != nil {
+			return httperror.BadRequest("Unable to parse stack's drift check interval", err)
+		}
+
+		stack.DriftCheck.JobID = jobID
+	}
+
 	err = handler.startStack(stack, endpoint, securityContext)
 	if err != nil {
 		return httperror.InternalServerError("Unable to start stack", err)
@@ -133,6 +156,8 @@ func (handler *Handler) stackStart(w http.ResponseWriter, r *http.Request) *http
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
 
@@ -8,6 +8,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -101,6 +102,16 @@ func (handler *Handler) stackStop(w http.ResponseWriter, r *http.Request) *httpe
 		stack.AutoUpdate.JobID = ""
 	}
 
+	if stack.ImageWatch != nil && stack.ImageWatch.JobID != "" {
+		handler.ImageWatcher.Stop(stack.ID, stack.ImageWatch.JobID)
+		stack.ImageWatch.JobID = ""
+	}
+
+	if stack.DriftCheck != nil && stack.DriftCheck.JobID != "" {
+		handler.DriftDetector.Stop(stack.ID, stack.DriftCheck.JobID)
+		stack.DriftCheck.JobID = ""
+	}
+
 	err = handler.stopStack(stack, endpoint)
 	if err != nil {
 		return httperror.InternalServerError("Unable to stop stack", err)
@@ -117,6 +128,8 @@ func (handler *Handler) stackStop(w http.ResponseWriter, r *http.Request) *httpe
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
 
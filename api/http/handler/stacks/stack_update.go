@@ -8,6 +8,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -25,6 +26,11 @@ type updateComposeStackPayload struct {
 	Env []portainer.Pair
 	// Force a pulling to current image with the original tag though the image is already the latest
 	PullImage bool `example:"false"`
+	// Wait for all the services to be running and healthy after the update, the previous
+	// stack file and environment variables are redeployed when they are not
+	WaitForHealthy bool `example:"false"`
+	// Time, in seconds, the services have to become healthy. Defaults to 120
+	HealthTimeout int `example:"120"`
 }
 
 func (payload *updateComposeStackPayload) Validate(r *http.Request) error {
@@ -32,6 +38,10 @@ func (payload *updateComposeStackPayload) Validate(r *http.Request) error {
 		return errors.New("Invalid stack file content")
 	}
 
+	if payload.HealthTimeout < 0 {
+		return errors.New("Invalid health timeout. Must be a positive number of seconds")
+	}
+
 	return nil
 }
 
@@ -70,6 +80,7 @@ func (payload *updateSwarmStackPayload) Validate(r *http.Request) error {
 // @failure 400 "Invalid request"
 // @failure 403 "Permission denied"
 // @failure 404 "Not found"
+// @failure 422 "The services of a compose stack failed to become healthy, the previous version was redeployed"
 // @failure 500 "Server error"
 // @router /stacks/{id} [put]
 func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -156,6 +167,8 @@ func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *htt
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
 
@@ -191,7 +204,13 @@ func (handler *Handler) updateComposeStack(r *http.Request, stack *portainer.Sta
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
-	stack.Env = payload.Env
+	previousEnv := stack.Env
+
+	env, err := envsecrets.Seal(payload.Env, stack.Env)
+	if err != nil {
+		return httperror.BadRequest("Invalid secret environment variables", err)
+	}
+	stack.Env = env
 
 	if stack.GitConfig != nil {
 		// detach from git
@@ -238,6 +257,14 @@ func (handler *Handler) updateComposeStack(r *http.Request, stack *portainer.Sta
 		return httperror.InternalServerError(err.Error(), err)
 	}
 
+	if payload.WaitForHealthy {
+		if err := handler.waitForHealthyComposeStack(stack, endpoint, time.Duration(payload.HealthTimeout)*time.Second); err != nil {
+			stack.Env = previousEnv
+
+			return handler.rollbackComposeStack(r, stack, endpoint, stackFolder, err)
+		}
+	}
+
 	handler.FileService.RemoveStackFileBackup(stackFolder, stack.EntryPoint)
 
 	return nil
@@ -258,7 +285,11 @@ func (handler *Handler) updateSwarmStack(r *http.Request, stack *portainer.Stack
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
-	stack.Env = payload.Env
+	env, err := envsecrets.Seal(payload.Env, stack.Env)
+	if err != nil {
+		return httperror.BadRequest("Invalid secret environment variables", err)
+	}
+	stack.Env = env
 
 	if stack.GitConfig != nil {
 		// detach from git
@@ -9,6 +9,7 @@ import (
 	"github.com/portainer/portainer/api/git/update"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -27,10 +28,31 @@ type stackGitUpdatePayload struct {
 	RepositoryUsername       string
 	RepositoryPassword       string
 	TLSSkipVerify            bool
+	// Compose profiles to enable when deploying the stack, the current ones are kept when omitted
+	ComposeProfiles []string `example:"debug"`
+	// Paths to the env files inside the Git repository, the current ones are kept when omitted
+	EnvFiles []string `example:"common.env,production.env"`
 }
 
 func (payload *stackGitUpdatePayload) Validate(r *http.Request) error {
-	return update.ValidateAutoUpdateSettings(payload.AutoUpdate)
+	if err := update.ValidateAutoUpdateSettings(payload.AutoUpdate); err != nil {
+		return err
+	}
+
+	if err := stackutils.ValidateComposeProfiles(payload.ComposeProfiles); err != nil {
+		return err
+	}
+
+	if payload.EnvFiles != nil {
+		envFiles, err := stackutils.CleanEnvFilePaths(payload.EnvFiles)
+		if err != nil {
+			return err
+		}
+		// Keep an empty list non nil, it clears the env files of the stack
+		payload.EnvFiles = append([]string{}, envFiles...)
+	}
+
+	return nil
 }
 
 // @id StackUpdateGit
@@ -133,7 +155,11 @@ func (handler *Handler) stackUpdateGit(w http.ResponseWriter, r *http.Request) *
 	stack.GitConfig.ReferenceName = payload.RepositoryReferenceName
 	stack.GitConfig.TLSSkipVerify = payload.TLSSkipVerify
 	stack.AutoUpdate = payload.AutoUpdate
-	stack.Env = payload.Env
+	env, err := envsecrets.Seal(payload.Env, stack.Env)
+	if err != nil {
+		return httperror.BadRequest("Invalid secret environment variables", err)
+	}
+	stack.Env = env
 	stack.UpdatedBy = user.Username
 	stack.UpdateDate = time.Now().Unix()
 
@@ -141,6 +167,16 @@ func (handler *Handler) stackUpdateGit(w http.ResponseWriter, r *http.Request) *
 		stack.Option = &portainer.StackOption{Prune: payload.Prune}
 	}
 
+	if stack.Type == portainer.DockerComposeStack {
+		if payload.ComposeProfiles != nil {
+			stack.ComposeProfiles = payload.ComposeProfiles
+		}
+
+		if payload.EnvFiles != nil {
+			stack.EnvFiles = payload.EnvFiles
+		}
+	}
+
 	if payload.RepositoryAuthentication {
 		password := payload.RepositoryPassword
 
@@ -180,5 +216,7 @@ func (handler *Handler) stackUpdateGit(w http.ResponseWriter, r *http.Request) *
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
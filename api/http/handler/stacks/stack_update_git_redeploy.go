@@ -8,6 +8,7 @@ import (
 	"github.com/portainer/portainer/api/git"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	k "github.com/portainer/portainer/api/kubernetes"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	"github.com/portainer/portainer/api/stacks/stackutils"
@@ -38,6 +39,8 @@ func (payload *stackGitRedployPayload) Validate(r *http.Request) error {
 // @id StackGitRedeploy
 // @summary Redeploy a stack
 // @description Pull and redeploy a stack via Git
+// @description The Kubernetes objects removed from the manifests of a Kubernetes stack are deleted, unless they are annotated with
+// @description "io.portainer.kubernetes.application.prune-protection: true" or no longer labelled as part of the stack.
 // @description **Access policy**: authenticated
 // @tags stacks
 // @security ApiKeyAuth
@@ -124,7 +127,11 @@ func (handler *Handler) stackGitRedeploy(w http.ResponseWriter, r *http.Request)
 	}
 
 	stack.GitConfig.ReferenceName = payload.RepositoryReferenceName
-	stack.Env = payload.Env
+	env, err := envsecrets.Seal(payload.Env, stack.Env)
+	if err != nil {
+		return httperror.BadRequest("Invalid secret environment variables", err)
+	}
+	stack.Env = env
 	if stack.Type == portainer.DockerSwarmStack {
 		stack.Option = &portainer.StackOption{Prune: payload.Prune}
 	}
@@ -189,6 +196,8 @@ func (handler *Handler) stackGitRedeploy(w http.ResponseWriter, r *http.Request)
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	stack.Env = envsecrets.Mask(stack.Env)
+
 	return response.JSON(w, stack)
 }
 
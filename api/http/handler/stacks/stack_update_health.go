@@ -0,0 +1,113 @@
+package stacks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/stacks/deployments"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultHealthTimeout is the time the services of a stack have to become healthy when no timeout is provided
+	defaultHealthTimeout = 2 * time.Minute
+	// unhealthyServiceLogLines is the number of log lines reported for each unhealthy service
+	unhealthyServiceLogLines = 20
+)
+
+// unhealthyStackError describes the services which failed to become running and healthy after a deployment
+type unhealthyStackError struct {
+	services []portainer.ComposeUnhealthyService
+}
+
+func (e *unhealthyStackError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("unhealthy services:")
+
+	for _, service := range e.services {
+		fmt.Fprintf(&sb, "\n- %s (container: %s, state: %s", service.Name, service.Container, service.State)
+
+		if service.Health != "" {
+			fmt.Fprintf(&sb, ", health: %s", service.Health)
+		}
+
+		if service.State == "exited" || service.State == "dead" {
+			fmt.Fprintf(&sb, ", exit code: %d", service.ExitCode)
+		}
+
+		sb.WriteString(")")
+
+		for _, line := range service.Logs {
+			sb.WriteString("\n    ")
+			sb.WriteString(line)
+		}
+	}
+
+	return sb.String()
+}
+
+// waitForHealthyComposeStack waits for all the services of the stack to be running and healthy
+// within the timeout, it returns an unhealthyStackError otherwise
+func (handler *Handler) waitForHealthyComposeStack(stack *portainer.Stack, endpoint *portainer.Endpoint, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	services, err := handler.ComposeStackManager.WaitForHealthy(context.TODO(), stack, endpoint, portainer.ComposeHealthOptions{
+		Timeout:  timeout,
+		LogLines: unhealthyServiceLogLines,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(services) > 0 {
+		return &unhealthyStackError{services: services}
+	}
+
+	return nil
+}
+
+// rollbackComposeStack restores the previous revision of the stack file and redeploys it after the
+// services of the updated stack failed to become healthy. The stack env must already be restored
+func (handler *Handler) rollbackComposeStack(r *http.Request, stack *portainer.Stack, endpoint *portainer.Endpoint, stackFolder string, healthErr error) *httperror.HandlerError {
+	log.Warn().
+		Err(healthErr).
+		Int("stack_id", int(stack.ID)).
+		Msg("the stack services failed to become healthy, rolling back to the previous version")
+
+	if err := handler.FileService.RollbackStackFile(stackFolder, stack.EntryPoint); err != nil {
+		return httperror.InternalServerError("Stack services failed to become healthy and the previous stack file could not be restored", fmt.Errorf("%w\n%w", err, healthErr))
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+
+	composeDeploymentConfig, err := deployments.CreateComposeStackDeploymentConfig(securityContext,
+		stack,
+		endpoint,
+		handler.DataStore,
+		handler.FileService,
+		handler.StackDeployer,
+		false,
+		false)
+	if err == nil {
+		err = composeDeploymentConfig.Deploy()
+	}
+
+	if err != nil {
+		return httperror.InternalServerError("Stack services failed to become healthy and the previous version of the stack could not be redeployed", fmt.Errorf("%w\n%w", err, healthErr))
+	}
+
+	return httperror.NewError(http.StatusUnprocessableEntity, "Stack services failed to become healthy, the previous version of the stack was redeployed", healthErr)
+}
@@ -0,0 +1,114 @@
+package stacks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+	"github.com/portainer/portainer/api/stacks/deployments"
+
+	"github.com/stretchr/testify/require"
+)
+
+type healthGateRecorder struct {
+	portainer.ComposeStackManager
+
+	deployedFiles []string
+	deployedEnvs  [][]portainer.Pair
+	unhealthy     []portainer.ComposeUnhealthyService
+}
+
+func (r *healthGateRecorder) Up(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeUpOptions) error {
+	content, err := os.ReadFile(filepath.Join(stack.ProjectPath, stack.EntryPoint))
+	if err != nil {
+		return err
+	}
+
+	r.deployedFiles = append(r.deployedFiles, string(content))
+	r.deployedEnvs = append(r.deployedEnvs, stack.Env)
+
+	return nil
+}
+
+func (r *healthGateRecorder) WaitForHealthy(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeHealthOptions) ([]portainer.ComposeUnhealthyService, error) {
+	return r.unhealthy, nil
+}
+
+func TestUpdateComposeStackHealthGate(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	fileService, err := filesystem.NewService(t.TempDir(), "")
+	require.NoError(t, err)
+
+	projectPath, err := fileService.StoreStackFileFromBytes("1", "docker-compose.yml", []byte("services: {v1: {image: nginx}}"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.User().Create(&portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment, URL: "unix:///var/run/docker.sock"}))
+	require.NoError(t, store.Stack().Create(&portainer.Stack{
+		ID:          1,
+		Name:        "web",
+		EndpointID:  1,
+		Type:        portainer.DockerComposeStack,
+		Status:      portainer.StackStatusActive,
+		EntryPoint:  "docker-compose.yml",
+		ProjectPath: projectPath,
+		Env:         []portainer.Pair{{Name: "TAG", Value: "v1"}},
+	}))
+
+	recorder := &healthGateRecorder{
+		ComposeStackManager: testhelpers.NewComposeStackManager(),
+		unhealthy: []portainer.ComposeUnhealthyService{
+			{Name: "v2", Container: "web-v2-1", State: "exited", ExitCode: 1, Logs: []string{"panic: missing config"}},
+		},
+	}
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+	h.FileService = fileService
+	h.ComposeStackManager = recorder
+	h.StackDeployer = deployments.NewStackDeployer(nil, recorder, nil, nil, store)
+
+	newUpdateRequest := func() *http.Request {
+		body := `{"StackFileContent": "services: {v2: {image: nginx}}", "Env": [{"name": "TAG", "value": "v2"}], "WaitForHealthy": true, "HealthTimeout": 5}`
+		req := httptest.NewRequest(http.MethodPut, "/stacks/1?endpointId=1", strings.NewReader(body))
+
+		return req.WithContext(security.StoreRestrictedRequestContext(req, &security.RestrictedRequestContext{IsAdmin: true, UserID: 1}))
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, newUpdateRequest())
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	require.Contains(t, rr.Body.String(), "web-v2-1")
+	require.Contains(t, rr.Body.String(), "panic: missing config")
+
+	require.Equal(t, []string{"services: {v2: {image: nginx}}", "services: {v1: {image: nginx}}"}, recorder.deployedFiles, "the previous stack file must be redeployed")
+	require.Equal(t, "v1", recorder.deployedEnvs[1][0].Value, "the previous env must be redeployed")
+
+	content, err := os.ReadFile(filepath.Join(projectPath, "docker-compose.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "services: {v1: {image: nginx}}", string(content))
+
+	stack, err := store.Stack().Read(1)
+	require.NoError(t, err)
+	require.Equal(t, "v1", stack.Env[0].Value)
+
+	recorder.unhealthy = nil
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, newUpdateRequest())
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	content, err = os.ReadFile(filepath.Join(projectPath, "docker-compose.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "services: {v2: {image: nginx}}", string(content))
+}
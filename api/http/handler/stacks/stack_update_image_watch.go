@@ -0,0 +1,142 @@
+package stacks
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+	"github.com/portainer/portainer/api/stacks/deployments"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+
+	"github.com/pkg/errors"
+)
+
+type stackImageWatchUpdatePayload struct {
+	// Interval between two checks of the image digests, the image watch is disabled when empty
+	Interval string `example:"1h"`
+	// Only record the changes, without redeploying the stack
+	NotifyOnly bool `example:"false"`
+	// Window the redeployments are restricted to, the stack can be redeployed at any time when empty
+	MaintenanceWindow *portainer.StackMaintenanceWindow
+}
+
+func (payload *stackImageWatchUpdatePayload) Validate(r *http.Request) error {
+	return deployments.ValidateImageWatchSettings(&portainer.StackImageWatchSettings{
+		Interval:          payload.Interval,
+		MaintenanceWindow: payload.MaintenanceWindow,
+	})
+}
+
+// @id StackUpdateImageWatch
+// @summary Update the image watch policy of a stack
+// @description Periodically check the images of a compose or swarm stack and, when a newer image digest is published,
+// @description pull the images and redeploy the stack, or only record the changes.
+// @description The image watch is disabled when the interval is empty, the history of the changes is kept.
+// @description **Access policy**: authenticated
+// @tags stacks
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Stack identifier"
+// @param body body stackImageWatchUpdatePayload true "Image watch policy"
+// @success 200 {object} portainer.Stack "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /stacks/{id}/image_watch [put]
+func (handler *Handler) stackUpdateImageWatch(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	stackID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid stack identifier route variable", err)
+	}
+
+	var payload stackImageWatchUpdatePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	stack, err := handler.DataStore.Stack().Read(portainer.StackID(stackID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a stack with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a stack with the specified identifier inside the database", err)
+	}
+
+	if stack.Type != portainer.DockerComposeStack && stack.Type != portainer.DockerSwarmStack {
+		return httperror.BadRequest("The image watch is only supported by compose and swarm stacks", errors.New("unsupported stack type"))
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(stack.EndpointID)
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find the environment associated to the stack inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find the environment associated to the stack inside the database", err)
+	}
+
+	if err := handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint); err != nil {
+		return httperror.Forbidden("Permission denied to access environment", err)
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve info from request context", err)
+	}
+
+	resourceControl, err := handler.DataStore.ResourceControl().ResourceControlByResourceIDAndType(stackutils.ResourceControlID(stack.EndpointID, stack.Name), portainer.StackResourceControl)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve a resource control associated to the stack", err)
+	}
+
+	if access, err := handler.userCanAccessStack(securityContext, endpoint.ID, resourceControl); err != nil {
+		return httperror.InternalServerError("Unable to verify user authorizations to validate stack access", err)
+	} else if !access {
+		return httperror.Forbidden("Access denied to resource", httperrors.ErrResourceAccessDenied)
+	}
+
+	if canManage, err := handler.userCanManageStacks(securityContext, endpoint); err != nil {
+		return httperror.InternalServerError("Unable to verify user authorizations to validate stack management", err)
+	} else if !canManage {
+		errMsg := "Stack editing is disabled for non-admin users"
+		return httperror.Forbidden(errMsg, errors.New(errMsg))
+	}
+
+	if stack.ImageWatch == nil {
+		stack.ImageWatch = &portainer.StackImageWatchSettings{}
+	}
+
+	handler.ImageWatcher.Stop(stack.ID, stack.ImageWatch.JobID)
+
+	stack.ImageWatch.Interval = payload.Interval
+	stack.ImageWatch.NotifyOnly = payload.NotifyOnly
+	stack.ImageWatch.MaintenanceWindow = payload.MaintenanceWindow
+	stack.ImageWatch.JobID = ""
+
+	if payload.Interval != "" && stack.Status != portainer.StackStatusInactive {
+		jobID, err := handler.ImageWatcher.Start(stack.ID, payload.Interval)
+		if err != nil {
+			return httperror.BadRequest("Unable to parse stack's image watch interval", err)
+		}
+
+		stack.ImageWatch.JobID = jobID
+	}
+
+	if err := handler.DataStore.Stack().Update(stack.ID, stack); err != nil {
+		return httperror.InternalServerError("Unable to persist the stack changes inside the database", err)
+	}
+
+	if stack.GitConfig != nil && stack.GitConfig.Authentication != nil && stack.GitConfig.Authentication.Password != "" {
+		// sanitize password in the http response to minimise possible security leaks
+		stack.GitConfig.Authentication.Password = ""
+	}
+
+	stack.Env = envsecrets.Mask(stack.Env)
+
+	return response.JSON(w, stack)
+}
@@ -3,6 +3,7 @@ package stacks
 import (
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	portainer "github.com/portainer/portainer/api"
@@ -33,6 +34,9 @@ type kubernetesGitStackUpdatePayload struct {
 	RepositoryPassword       string
 	AutoUpdate               *portainer.AutoUpdateSettings
 	TLSSkipVerify            bool
+	// Path to the kustomization folder inside the repository, only applicable to the stacks deployed from a kustomization.
+	// The current path is kept when empty
+	KustomizePath string `example:"overlays/staging"`
 }
 
 func (payload *kubernetesFileStackUpdatePayload) Validate(r *http.Request) error {
@@ -44,6 +48,13 @@ func (payload *kubernetesFileStackUpdatePayload) Validate(r *http.Request) error
 }
 
 func (payload *kubernetesGitStackUpdatePayload) Validate(r *http.Request) error {
+	if len(payload.KustomizePath) > 0 {
+		payload.KustomizePath = filepath.Clean(payload.KustomizePath)
+		if !filepath.IsLocal(payload.KustomizePath) {
+			return errors.New("Invalid kustomization path. Must be a folder inside the repository")
+		}
+	}
+
 	if err := update.ValidateAutoUpdateSettings(payload.AutoUpdate); err != nil {
 		return err
 	}
@@ -69,6 +80,17 @@ func (handler *Handler) updateKubernetesStack(r *http.Request, stack *portainer.
 		stack.GitConfig.Authentication = nil
 		stack.AutoUpdate = payload.AutoUpdate
 
+		if payload.KustomizePath != "" {
+			if stack.KustomizePath == "" {
+				return httperror.BadRequest("Invalid request payload", errors.New("the stack is not deployed from a kustomization"))
+			}
+
+			// The new kustomization is deployed on the next redeployment of the stack
+			stack.KustomizePath = payload.KustomizePath
+			stack.EntryPoint = payload.KustomizePath
+			stack.GitConfig.ConfigFilePath = payload.KustomizePath
+		}
+
 		if payload.RepositoryAuthentication {
 			password := payload.RepositoryPassword
 			if password == "" && stack.GitConfig != nil && stack.GitConfig.Authentication != nil {
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/portainer/portainer/api/configascode"
+	"github.com/portainer/portainer/api/http/security"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id systemConfigApply
+// @summary Apply a configuration
+// @description Reconcile teams, users, environment groups, tags, edge groups, registries, settings and custom templates
+// @description with a YAML configuration document. Sections present in the document are managed: objects missing
+// @description from them are deleted. Use dryRun to only compute the changes.
+// @description **Access policy**: administrator
+// @tags system
+// @security ApiKeyAuth
+// @security jwt
+// @accept text/yaml
+// @produce json
+// @param dryRun query boolean false "Compute the changes without applying them"
+// @param body body string true "Configuration document"
+// @success 200 {object} configascode.Plan "Success"
+// @failure 400 "Invalid configuration"
+// @failure 409 "An edge group removed by the configuration is still in use"
+// @failure 500 "Server error"
+// @router /system/config [post]
+func (handler *Handler) systemConfigApply(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	dryRun, _ := request.RetrieveBooleanQueryParameter(r, "dryRun", true)
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user details from authentication token", err)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return httperror.BadRequest("Unable to read the configuration", err)
+	}
+
+	config, err := configascode.Parse(data)
+	if err != nil {
+		return httperror.BadRequest("Invalid configuration", err)
+	}
+
+	plan, err := handler.configService.Apply(config, configascode.ApplyOptions{
+		DryRun: dryRun,
+		UserID: tokenData.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, configascode.ErrInvalidConfig):
+			return httperror.BadRequest("Invalid configuration", err)
+		case errors.Is(err, configascode.ErrEdgeGroupInUse):
+			return httperror.Conflict("Unable to apply the configuration", err)
+		}
+
+		return httperror.InternalServerError("Unable to apply the configuration", err)
+	}
+
+	return response.JSON(w, plan)
+}
@@ -0,0 +1,35 @@
+package system
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/configascode"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id systemConfigExport
+// @summary Export the configuration
+// @description Export teams, users, environment groups, tags, edge groups, registries, settings and custom templates
+// @description as a YAML document that can be applied to another instance. Registry credentials are not exported.
+// @description **Access policy**: administrator
+// @tags system
+// @security ApiKeyAuth
+// @security jwt
+// @produce text/yaml
+// @success 200 {string} string "Success"
+// @failure 500 "Server error"
+// @router /system/config [get]
+func (handler *Handler) systemConfigExport(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	config, err := handler.configService.Export()
+	if err != nil {
+		return httperror.InternalServerError("Unable to export the configuration", err)
+	}
+
+	data, err := configascode.Marshal(config)
+	if err != nil {
+		return httperror.InternalServerError("Unable to encode the configuration", err)
+	}
+
+	return response.YAML(w, string(data))
+}
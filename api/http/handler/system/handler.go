@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/configascode"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/upgrade"
@@ -20,6 +21,7 @@ type Handler struct {
 	dataStore       dataservices.DataStore
 	upgradeService  upgrade.Service
 	platformService platform.Service
+	configService   *configascode.Service
 }
 
 // NewHandler creates a handler to manage status operations.
@@ -27,7 +29,8 @@ func NewHandler(bouncer security.BouncerService,
 	status *portainer.Status,
 	dataStore dataservices.DataStore,
 	platformService platform.Service,
-	upgradeService upgrade.Service) *Handler {
+	upgradeService upgrade.Service,
+	configService *configascode.Service) *Handler {
 
 	h := &Handler{
 		Router:          mux.NewRouter(),
@@ -35,6 +38,7 @@ func NewHandler(bouncer security.BouncerService,
 		status:          status,
 		upgradeService:  upgradeService,
 		platformService: platformService,
+		configService:   configService,
 	}
 
 	router := h.PathPrefix("/system").Subrouter()
@@ -43,6 +47,8 @@ func NewHandler(bouncer security.BouncerService,
 	adminRouter.Use(bouncer.AdminAccess)
 
 	adminRouter.Handle("/upgrade", httperror.LoggerHandler(h.systemUpgrade)).Methods(http.MethodPost)
+	adminRouter.Handle("/config", httperror.LoggerHandler(h.systemConfigExport)).Methods(http.MethodGet)
+	adminRouter.Handle("/config", httperror.LoggerHandler(h.systemConfigApply)).Methods(http.MethodPost)
 
 	authenticatedRouter := router.PathPrefix("/").Subrouter()
 	authenticatedRouter.Use(bouncer.AuthenticatedAccess)
@@ -149,7 +149,11 @@ func updateEndpointRelations(tx dataservices.DataStoreTx, endpoint portainer.End
 		return err
 	}
 
-	endpointStacks := edge.EndpointRelatedEdgeStacks(&endpoint, endpointGroup, edgeGroups, edgeStacks)
+	endpointStacks, err := edge.EndpointRelatedEdgeStacks(tx, &endpoint, endpointGroup, edgeGroups, edgeStacks)
+	if err != nil {
+		return err
+	}
+
 	stacksSet := map[portainer.EdgeStackID]bool{}
 	for _, edgeStackID := range endpointStacks {
 		stacksSet[edgeStackID] = true
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apptemplates"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/security"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -14,9 +15,10 @@ import (
 // Handler represents an HTTP API handler for managing templates.
 type Handler struct {
 	*mux.Router
-	DataStore   dataservices.DataStore
-	GitService  portainer.GitService
-	FileService portainer.FileService
+	DataStore        dataservices.DataStore
+	GitService       portainer.GitService
+	FileService      portainer.FileService
+	TemplatesService *apptemplates.Service
 }
 
 // NewHandler returns a new instance of Handler.
@@ -29,5 +31,15 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.templateList))).Methods(http.MethodGet)
 	h.Handle("/templates/{id}/file",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.templateFile))).Methods(http.MethodPost)
+	h.Handle("/templates/sources",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceList))).Methods(http.MethodGet)
+	h.Handle("/templates/sources",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceCreate))).Methods(http.MethodPost)
+	h.Handle("/templates/sources/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceUpdate))).Methods(http.MethodPut)
+	h.Handle("/templates/sources/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceDelete))).Methods(http.MethodDelete)
+	h.Handle("/templates/sources/{id}/refresh",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.templateSourceRefresh))).Methods(http.MethodPost)
 	return h
 }
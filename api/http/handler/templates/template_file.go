@@ -37,7 +37,7 @@ func (handler *Handler) templateFile(w http.ResponseWriter, r *http.Request) *ht
 		return httperror.BadRequest("Invalid template identifier", err)
 	}
 
-	templatesResponse, httpErr := handler.fetchTemplates()
+	templatesResponse, httpErr := handler.fetchTemplates(r.Context())
 	if httpErr != nil {
 		return httpErr
 	}
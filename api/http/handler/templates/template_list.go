@@ -19,7 +19,7 @@ import (
 // @failure 500 "Server error"
 // @router /templates [get]
 func (handler *Handler) templateList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
-	templates, httpErr := handler.fetchTemplates()
+	templates, httpErr := handler.fetchTemplates(r.Context())
 	if httpErr != nil {
 		return httpErr
 	}
@@ -0,0 +1,171 @@
+package templates
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apptemplates"
+	gittypes "github.com/portainer/portainer/api/git/types"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/rs/zerolog/log"
+)
+
+type templateSourcePayload struct {
+	// Name of the source
+	Name string `example:"Internal templates" validate:"required"`
+	// Type of the source. Valid values are: 1 (URL), 2 (git repository), 3 (uploaded file)
+	Type portainer.TemplateSourceType `example:"1" enums:"1,2,3" validate:"required"`
+	// Sources with a lower priority value win when two sources provide the same template
+	Priority int `example:"0"`
+
+	// URL of the templates file. Leave empty to use the URL defined in the settings
+	URL string `example:"https://templates.mydomain.tld/templates.json"`
+
+	// URL of a Git repository hosting the templates file
+	RepositoryURL string `example:"https://github.com/portainer/templates"`
+	// Reference name of a Git repository hosting the templates file
+	RepositoryReferenceName string `example:"refs/heads/master"`
+	// Use basic authentication to clone the Git repository
+	RepositoryAuthentication bool `example:"true"`
+	// Username used in basic authentication. Required when RepositoryAuthentication is true.
+	RepositoryUsername string `example:"myGitUsername"`
+	// Password used in basic authentication. Required when RepositoryAuthentication is true.
+	RepositoryPassword string `example:"myGitPassword"`
+	// Path to the templates file inside the Git repository
+	TemplatesFilePathInRepository string `example:"templates.json" default:"templates.json"`
+	// TLSSkipVerify skips SSL verification when cloning the Git repository
+	TLSSkipVerify bool `example:"false"`
+
+	// Content of the templates file for an uploaded file source
+	FileContent string
+}
+
+func (payload *templateSourcePayload) Validate(r *http.Request) error {
+	if len(payload.Name) == 0 {
+		return errors.New("invalid template source name")
+	}
+
+	switch payload.Type {
+	case portainer.TemplateSourceURL:
+		if payload.URL != "" && !govalidator.IsURL(payload.URL) {
+			return errors.New("invalid template source URL")
+		}
+	case portainer.TemplateSourceGit:
+		if len(payload.RepositoryURL) == 0 || !govalidator.IsURL(payload.RepositoryURL) {
+			return errors.New("invalid repository URL. Must correspond to a valid URL format")
+		}
+
+		if len(payload.TemplatesFilePathInRepository) == 0 {
+			payload.TemplatesFilePathInRepository = "templates.json"
+		}
+	case portainer.TemplateSourceFile:
+		if _, err := apptemplates.Parse([]byte(payload.FileContent)); err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid template source type. Valid values are: 1 (URL), 2 (git repository), 3 (uploaded file)")
+	}
+
+	return nil
+}
+
+// apply copies the payload into the source, the git password is kept when none is provided
+func (payload *templateSourcePayload) apply(source *portainer.TemplateSource) {
+	previousGitConfig := source.GitConfig
+
+	source.Name = payload.Name
+	source.Type = payload.Type
+	source.Priority = payload.Priority
+	source.URL = ""
+	source.GitConfig = nil
+
+	switch payload.Type {
+	case portainer.TemplateSourceURL:
+		source.URL = payload.URL
+	case portainer.TemplateSourceGit:
+		source.GitConfig = &gittypes.RepoConfig{
+			URL:            payload.RepositoryURL,
+			ReferenceName:  payload.RepositoryReferenceName,
+			ConfigFilePath: payload.TemplatesFilePathInRepository,
+			TLSSkipVerify:  payload.TLSSkipVerify,
+		}
+
+		if payload.RepositoryAuthentication {
+			password := payload.RepositoryPassword
+			if password == "" && previousGitConfig != nil && previousGitConfig.Authentication != nil {
+				password = previousGitConfig.Authentication.Password
+			}
+
+			source.GitConfig.Authentication = &gittypes.GitAuthentication{
+				Username: payload.RepositoryUsername,
+				Password: password,
+			}
+		}
+	}
+
+	// The cached copy belongs to the previous location
+	source.Version = ""
+	source.Templates = nil
+	source.ETag = ""
+	source.LastModified = ""
+	source.FetchedURL = ""
+	source.LastRefresh = 0
+	source.LastError = ""
+
+	if payload.Type == portainer.TemplateSourceFile {
+		collection, _ := apptemplates.Parse([]byte(payload.FileContent))
+
+		source.Version = collection.Version
+		source.Templates = collection.Templates
+		source.LastRefresh = time.Now().Unix()
+	}
+}
+
+// @id TemplateSourceCreate
+// @summary Create a template source
+// @description Create a location application templates are retrieved from.
+// @description **Access policy**: administrator
+// @tags templates
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param body body templateSourcePayload true "Template source details"
+// @success 200 {object} portainer.TemplateSource "Success"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /templates/sources [post]
+func (handler *Handler) templateSourceCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload templateSourcePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	if payload.Type == portainer.TemplateSourceGit && payload.RepositoryAuthentication && len(payload.RepositoryPassword) == 0 {
+		return httperror.BadRequest("Invalid repository credentials. Password must be specified when authentication is enabled", errors.New("missing repository password"))
+	}
+
+	source := &portainer.TemplateSource{}
+	payload.apply(source)
+
+	if err := handler.DataStore.TemplateSource().Create(source); err != nil {
+		return httperror.InternalServerError("Unable to persist the template source inside the database", err)
+	}
+
+	if err := handler.TemplatesService.Refresh(r.Context(), source.ID); err != nil {
+		log.Warn().Err(err).Str("source", source.Name).Msg("unable to retrieve the templates of the new source")
+	}
+
+	source, err := handler.DataStore.TemplateSource().Read(source.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the template source from the database", err)
+	}
+
+	return response.JSON(w, decorateTemplateSource(*source))
+}
@@ -0,0 +1,42 @@
+package templates
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id TemplateSourceDelete
+// @summary Remove a template source
+// @description Remove a location application templates are retrieved from, along with its templates.
+// @description **Access policy**: administrator
+// @tags templates
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "Template source identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Template source not found"
+// @failure 500 "Server error"
+// @router /templates/sources/{id} [delete]
+func (handler *Handler) templateSourceDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	sourceID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid template source identifier route variable", err)
+	}
+
+	if _, err := handler.DataStore.TemplateSource().Read(portainer.TemplateSourceID(sourceID)); handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a template source with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a template source with the specified identifier inside the database", err)
+	}
+
+	if err := handler.DataStore.TemplateSource().Delete(portainer.TemplateSourceID(sourceID)); err != nil {
+		return httperror.InternalServerError("Unable to remove the template source from the database", err)
+	}
+
+	return response.Empty(w)
+}
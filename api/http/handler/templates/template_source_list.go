@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type decoratedTemplateSource struct {
+	portainer.TemplateSource
+	// Number of templates provided by the source
+	TemplateCount int `json:"TemplateCount" example:"42"`
+}
+
+// @id TemplateSourceList
+// @summary List template sources
+// @description List the locations application templates are retrieved from, along with the state of their last retrieval.
+// @description **Access policy**: administrator
+// @tags templates
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {array} decoratedTemplateSource "Success"
+// @failure 500 "Server error"
+// @router /templates/sources [get]
+func (handler *Handler) templateSourceList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	sources, err := handler.DataStore.TemplateSource().ReadAll()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve template sources from the database", err)
+	}
+
+	decoratedSources := make([]decoratedTemplateSource, 0, len(sources))
+	for _, source := range sources {
+		decoratedSources = append(decoratedSources, decorateTemplateSource(source))
+	}
+
+	return response.JSON(w, decoratedSources)
+}
+
+func decorateTemplateSource(source portainer.TemplateSource) decoratedTemplateSource {
+	decorated := decoratedTemplateSource{
+		TemplateSource: source,
+		TemplateCount:  len(source.Templates),
+	}
+
+	decorated.Templates = nil
+
+	if source.GitConfig != nil && source.GitConfig.Authentication != nil {
+		gitConfig := *source.GitConfig
+		authentication := *gitConfig.Authentication
+		authentication.Password = ""
+		gitConfig.Authentication = &authentication
+		decorated.GitConfig = &gitConfig
+	}
+
+	return decorated
+}
@@ -0,0 +1,49 @@
+package templates
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id TemplateSourceRefresh
+// @summary Refresh a template source
+// @description Retrieve the templates of a source immediately instead of waiting for the background refresh.
+// @description The last good copy is kept when the retrieval fails.
+// @description **Access policy**: administrator
+// @tags templates
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Template source identifier"
+// @success 200 {object} portainer.TemplateSource "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Template source not found"
+// @failure 500 "Server error"
+// @router /templates/sources/{id}/refresh [post]
+func (handler *Handler) templateSourceRefresh(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	sourceID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid template source identifier route variable", err)
+	}
+
+	if _, err := handler.DataStore.TemplateSource().Read(portainer.TemplateSourceID(sourceID)); handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a template source with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a template source with the specified identifier inside the database", err)
+	}
+
+	if err := handler.TemplatesService.Refresh(r.Context(), portainer.TemplateSourceID(sourceID)); err != nil {
+		return httperror.InternalServerError("Unable to retrieve the templates of the source", err)
+	}
+
+	source, err := handler.DataStore.TemplateSource().Read(portainer.TemplateSourceID(sourceID))
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the template source from the database", err)
+	}
+
+	return response.JSON(w, decorateTemplateSource(*source))
+}
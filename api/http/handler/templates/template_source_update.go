@@ -0,0 +1,65 @@
+package templates
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+
+	"github.com/rs/zerolog/log"
+)
+
+// @id TemplateSourceUpdate
+// @summary Update a template source
+// @description Update a location application templates are retrieved from.
+// @description The git password is kept when none is provided.
+// @description **Access policy**: administrator
+// @tags templates
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Template source identifier"
+// @param body body templateSourcePayload true "Template source details"
+// @success 200 {object} portainer.TemplateSource "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Template source not found"
+// @failure 500 "Server error"
+// @router /templates/sources/{id} [put]
+func (handler *Handler) templateSourceUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	sourceID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid template source identifier route variable", err)
+	}
+
+	var payload templateSourcePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	source, err := handler.DataStore.TemplateSource().Read(portainer.TemplateSourceID(sourceID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a template source with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a template source with the specified identifier inside the database", err)
+	}
+
+	payload.apply(source)
+
+	if err := handler.DataStore.TemplateSource().Update(source.ID, source); err != nil {
+		return httperror.InternalServerError("Unable to persist the template source changes inside the database", err)
+	}
+
+	if err := handler.TemplatesService.Refresh(r.Context(), source.ID); err != nil {
+		log.Warn().Err(err).Str("source", source.Name).Msg("unable to retrieve the templates of the updated source")
+	}
+
+	source, err = handler.DataStore.TemplateSource().Read(source.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the template source from the database", err)
+	}
+
+	return response.JSON(w, decorateTemplateSource(*source))
+}
@@ -65,6 +65,7 @@ func (factory *ProxyFactory) newDockerHTTPProxy(endpoint *portainer.Endpoint) (h
 		ReverseTunnelService: factory.reverseTunnelService,
 		SignatureService:     factory.signatureService,
 		DockerClientFactory:  factory.dockerClientFactory,
+		DockerInventory:      factory.dockerInventoryService,
 	}
 
 	dockerTransport, err := docker.NewTransport(transportParameters, httpTransport, factory.gitService, factory.snapshotService)
@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/admission"
+	"github.com/portainer/portainer/api/http/proxy/factory/utils"
+	"github.com/portainer/portainer/api/http/security"
+)
+
+// enforceAdmissionPolicy evaluates the workload described by the request body against the admission
+// policy of the environment(endpoint) group. A forbidden response listing the violations is returned
+// when the request is rejected, the request body can be read again afterwards
+func (transport *Transport) enforceAdmissionPolicy(request *http.Request, isAdminOrEndpointAdmin bool, parse func(body []byte) (admission.Workload, error)) (*http.Response, error) {
+	policy, err := admission.PolicyForEndpoint(transport.dataStore, transport.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if !admission.Applies(policy, isAdminOrEndpointAdmin) {
+		return nil, nil
+	}
+
+	tokenData, err := security.RetrieveTokenData(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	workload, err := parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = admission.Enforce(policy, transport.endpoint, tokenData.Username, workload)
+
+	var violationsErr *admission.ViolationsError
+	if errors.As(err, &violationsErr) {
+		return utils.WriteErrorResponse(violationsErr.Error(), http.StatusForbidden)
+	}
+
+	return nil, err
+}
+
+func (transport *Transport) decorateContainerUpdateOperation(request *http.Request, containerID string) (*http.Response, error) {
+	isAdminOrEndpointAdmin, err := transport.isAdminOrEndpointAdmin(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.enforceAdmissionPolicy(request, isAdminOrEndpointAdmin, func(body []byte) (admission.Workload, error) {
+		cli, err := transport.dockerClientFactory.CreateClient(transport.endpoint, request.Header.Get(portainer.PortainerAgentTargetHeader), nil)
+		if err != nil {
+			return admission.Workload{}, err
+		}
+		defer cli.Close()
+
+		container, err := cli.ContainerInspect(context.Background(), containerID)
+		if err != nil {
+			return admission.Workload{}, err
+		}
+
+		return admission.FromContainerUpdate(strings.TrimPrefix(container.Name, "/"), body, container.HostConfig.Resources)
+	})
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	return transport.restrictedResourceOperation(request, containerID, containerID, portainer.ContainerResourceControl, false)
+}
+
+func (transport *Transport) decorateServiceUpdateOperation(request *http.Request, serviceID string) (*http.Response, error) {
+	isAdminOrEndpointAdmin, err := transport.isAdminOrEndpointAdmin(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.enforceAdmissionPolicy(request, isAdminOrEndpointAdmin, admission.FromServiceSpec)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	return transport.restrictedResourceOperation(request, serviceID, serviceID, portainer.ServiceResourceControl, false)
+}
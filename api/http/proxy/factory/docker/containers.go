@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/admission"
 	"github.com/portainer/portainer/api/http/proxy/factory/utils"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/authorization"
@@ -193,17 +194,17 @@ func (transport *Transport) decorateContainerCreationOperation(request *http.Req
 		return nil, err
 	}
 
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
 	if !isAdminOrEndpointAdmin {
 		securitySettings, err := transport.fetchEndpointSecuritySettings()
 		if err != nil {
 			return nil, err
 		}
 
-		body, err := io.ReadAll(request.Body)
-		if err != nil {
-			return nil, err
-		}
-
 		partialContainer := &PartialContainer{}
 		if err := json.Unmarshal(body, partialContainer); err != nil {
 			return nil, err
@@ -236,8 +237,15 @@ func (transport *Transport) decorateContainerCreationOperation(request *http.Req
 				}
 			}
 		}
+	}
+
+	request.Body = io.NopCloser(bytes.NewBuffer(body))
 
-		request.Body = io.NopCloser(bytes.NewBuffer(body))
+	admissionResponse, err := transport.enforceAdmissionPolicy(request, isAdminOrEndpointAdmin, func(body []byte) (admission.Workload, error) {
+		return admission.FromContainerCreate(request.URL.Query().Get("name"), body)
+	})
+	if admissionResponse != nil || err != nil {
+		return admissionResponse, err
 	}
 
 	response, err := transport.executeDockerRequest(request)
@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/segmentio/encoding/json"
+)
+
+// inventoryResponse builds the response of a list request from the live
+// inventory of the environment. It returns nil when the inventory is not
+// ready or when the request uses parameters the inventory cannot honour, the
+// request must then be sent to the environment.
+func (transport *Transport) inventoryResponse(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet || request.Header.Get(portainer.PortainerAgentTargetHeader) != "" {
+		return nil, nil
+	}
+
+	inventory, ok := transport.dockerInventory.Inventory(transport.endpoint.ID)
+	if !ok {
+		return nil, nil
+	}
+
+	query := request.URL.Query()
+
+	var body any
+	switch apiVersionRe.ReplaceAllString(request.URL.Path, "") {
+	case "/containers/json":
+		for key := range query {
+			if key != "all" {
+				return nil, nil
+			}
+		}
+
+		body = inventory.Containers(boolValue(query.Get("all")))
+
+	case "/services":
+		if len(query) > 0 || !inventory.SwarmManager() {
+			return nil, nil
+		}
+
+		body = inventory.Services()
+
+	case "/volumes":
+		if len(query) > 0 {
+			return nil, nil
+		}
+
+		body = volume.ListResponse{Volumes: inventory.Volumes()}
+
+	case "/networks":
+		if len(query) > 0 {
+			return nil, nil
+		}
+
+		body = inventory.Networks()
+
+	default:
+		return nil, nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(http.StatusOK),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       request,
+	}, nil
+}
+
+// boolValue parses a boolean query parameter the same way the Docker API does.
+func boolValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "no", "false", "none":
+		return false
+	}
+
+	return true
+}
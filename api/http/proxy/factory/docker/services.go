@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/admission"
 	"github.com/portainer/portainer/api/http/proxy/factory/utils"
 	"github.com/portainer/portainer/api/internal/authorization"
 
@@ -109,6 +110,11 @@ func (transport *Transport) decorateServiceCreationOperation(request *http.Reque
 		return nil, err
 	}
 
+	admissionResponse, err := transport.enforceAdmissionPolicy(request, isAdminOrEndpointAdmin, admission.FromServiceSpec)
+	if admissionResponse != nil || err != nil {
+		return admissionResponse, err
+	}
+
 	if isAdminOrEndpointAdmin {
 		return transport.replaceRegistryAuthenticationHeader(request)
 	}
@@ -20,6 +20,7 @@ import (
 	"github.com/portainer/portainer/api/internal/authorization"
 
 	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/docker/inventory"
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/encoding/json"
 )
@@ -38,6 +39,7 @@ type (
 		dockerClientFactory  *dockerclient.ClientFactory
 		gitService           portainer.GitService
 		snapshotService      portainer.SnapshotService
+		dockerInventory      *inventory.Service
 		dockerID             string
 		mu                   sync.Mutex
 	}
@@ -49,6 +51,7 @@ type (
 		SignatureService     portainer.DigitalSignatureService
 		ReverseTunnelService portainer.ReverseTunnelService
 		DockerClientFactory  *dockerclient.ClientFactory
+		DockerInventory      *inventory.Service
 	}
 
 	restrictedDockerOperationContext struct {
@@ -75,6 +78,7 @@ func NewTransport(parameters *TransportParameters, httpTransport *http.Transport
 		signatureService:     parameters.SignatureService,
 		reverseTunnelService: parameters.ReverseTunnelService,
 		dockerClientFactory:  parameters.DockerClientFactory,
+		dockerInventory:      parameters.DockerInventory,
 		HTTPTransport:        httpTransport,
 		gitService:           gitService,
 		snapshotService:      snapshotService,
@@ -248,6 +252,10 @@ func (transport *Transport) proxyContainerRequest(request *http.Request, unversi
 				return transport.rewriteOperation(request, transport.containerInspectOperation)
 			}
 
+			if action == "update" && request.Method == http.MethodPost {
+				return transport.decorateContainerUpdateOperation(request, containerID)
+			}
+
 			return transport.restrictedResourceOperation(request, containerID, containerID, portainer.ContainerResourceControl, false)
 		} else if match, _ := path.Match("/containers/*", requestPath); match {
 			// Handle /containers/{id} requests
@@ -281,6 +289,10 @@ func (transport *Transport) proxyServiceRequest(request *http.Request, unversion
 			serviceID := path.Base(path.Dir(requestPath))
 			transport.decorateRegistryAuthenticationHeader(request)
 
+			if path.Base(requestPath) == "update" && request.Method == http.MethodPost {
+				return transport.decorateServiceUpdateOperation(request, serviceID)
+			}
+
 			return transport.restrictedResourceOperation(request, serviceID, serviceID, portainer.ServiceResourceControl, false)
 		} else if match, _ := path.Match("/services/*", requestPath); match {
 			// Handle /services/{id} requests
@@ -689,7 +701,15 @@ func (transport *Transport) executeGenericResourceDeletionOperation(request *htt
 }
 
 func (transport *Transport) executeRequestAndRewriteResponse(request *http.Request, operation restrictedOperationRequest, executor *operationExecutor) (*http.Response, error) {
-	response, err := transport.executeDockerRequest(request)
+	response, err := transport.inventoryResponse(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response == nil {
+		response, err = transport.executeDockerRequest(request)
+	}
+
 	if err != nil {
 		return response, err
 	}
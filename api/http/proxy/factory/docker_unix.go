@@ -18,6 +18,7 @@ func (factory ProxyFactory) newOSBasedLocalProxy(path string, endpoint *portaine
 		ReverseTunnelService: factory.reverseTunnelService,
 		SignatureService:     factory.signatureService,
 		DockerClientFactory:  factory.dockerClientFactory,
+		DockerInventory:      factory.dockerInventoryService,
 	}
 
 	proxy := &dockerLocalProxy{}
@@ -11,10 +11,13 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/adminmonitor"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/apptemplates"
+	"github.com/portainer/portainer/api/configascode"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/docker"
 	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/docker/inventory"
 	"github.com/portainer/portainer/api/http/csrf"
 	"github.com/portainer/portainer/api/http/handler"
 	"github.com/portainer/portainer/api/http/handler/auth"
@@ -101,6 +104,7 @@ type Server struct {
 	Handler                     *handler.Handler
 	SSLService                  *ssl.Service
 	DockerClientFactory         *dockerclient.ClientFactory
+	DockerInventoryService      *inventory.Service
 	KubernetesClientFactory     *cli.ClientFactory
 	KubernetesDeployer          portainer.KubernetesDeployer
 	HelmPackageManager          libhelm.HelmPackageManager
@@ -108,10 +112,13 @@ type Server struct {
 	ShutdownCtx                 context.Context
 	ShutdownTrigger             context.CancelFunc
 	StackDeployer               deployments.StackDeployer
+	ImageWatcher                *deployments.ImageWatcher
+	DriftDetector               *deployments.DriftDetector
 	UpgradeService              upgrade.Service
 	AdminCreationDone           chan struct{}
 	PendingActionsService       *pendingactions.PendingActionsService
 	PlatformService             platform.Service
+	TemplatesService            *apptemplates.Service
 }
 
 // Start starts the HTTP server
@@ -198,7 +205,7 @@ func (server *Server) Start() error {
 
 	containerService := docker.NewContainerService(server.DockerClientFactory, server.DataStore)
 
-	var dockerHandler = dockerhandler.NewHandler(requestBouncer, server.AuthorizationService, server.DataStore, server.DockerClientFactory, containerService)
+	var dockerHandler = dockerhandler.NewHandler(requestBouncer, server.AuthorizationService, server.DataStore, server.DockerClientFactory, containerService, server.DockerInventoryService)
 
 	var fileHandler = file.NewHandler(filepath.Join(server.AssetsPath, "public"), adminMonitor.WasInstanceDisabled)
 
@@ -230,6 +237,7 @@ func (server *Server) Start() error {
 	settingsHandler.JWTService = server.JWTService
 	settingsHandler.LDAPService = server.LDAPService
 	settingsHandler.SnapshotService = server.SnapshotService
+	settingsHandler.TemplatesService = server.TemplatesService
 
 	var sslHandler = sslhandler.NewHandler(requestBouncer)
 	sslHandler.SSLService = server.SSLService
@@ -250,6 +258,8 @@ func (server *Server) Start() error {
 	stackHandler.SwarmStackManager = server.SwarmStackManager
 	stackHandler.ComposeStackManager = server.ComposeStackManager
 	stackHandler.StackDeployer = server.StackDeployer
+	stackHandler.ImageWatcher = server.ImageWatcher
+	stackHandler.DriftDetector = server.DriftDetector
 
 	var storybookHandler = storybook.NewHandler(server.AssetsPath)
 
@@ -267,12 +277,14 @@ func (server *Server) Start() error {
 		server.Status,
 		server.DataStore,
 		server.PlatformService,
-		server.UpgradeService)
+		server.UpgradeService,
+		configascode.NewService(server.DataStore, server.FileService, server.APIKeyService))
 
 	var templatesHandler = templates.NewHandler(requestBouncer)
 	templatesHandler.DataStore = server.DataStore
 	templatesHandler.FileService = server.FileService
 	templatesHandler.GitService = server.GitService
+	templatesHandler.TemplatesService = server.TemplatesService
 
 	var uploadHandler = upload.NewHandler(requestBouncer)
 	uploadHandler.FileService = server.FileService
@@ -56,14 +56,23 @@ func GetEndpointsFromEdgeGroups(edgeGroupIDs []portainer.EdgeGroupID, datastore
 		return nil, err
 	}
 
-	var response []portainer.EndpointID
+	edgeGroups := make([]portainer.EdgeGroup, 0, len(edgeGroupIDs))
 	for _, edgeGroupID := range edgeGroupIDs {
 		edgeGroup, err := datastore.EdgeGroup().Read(edgeGroupID)
 		if err != nil {
 			return nil, err
 		}
 
-		response = append(response, EdgeGroupRelatedEndpoints(edgeGroup, endpoints, endpointGroups)...)
+		edgeGroups = append(edgeGroups, *edgeGroup)
+	}
+
+	if err := FillEdgeGroupsSnapshotData(datastore, edgeGroups, endpoints); err != nil {
+		return nil, err
+	}
+
+	var response []portainer.EndpointID
+	for _, edgeGroup := range edgeGroups {
+		response = append(response, EdgeGroupRelatedEndpoints(&edgeGroup, endpoints, endpointGroups)...)
 	}
 
 	return response, nil
@@ -81,6 +90,14 @@ func edgeGroupRelatedToEndpoint(edgeGroup *portainer.EdgeGroup, endpoint *portai
 		return false
 	}
 
+	if len(edgeGroup.TagIDs) > 0 && !edgeGroupTagsMatch(edgeGroup, endpoint, endpointGroup) {
+		return false
+	}
+
+	return edgeGroupRulesMatch(edgeGroup.Rules, endpoint, endpointGroup)
+}
+
+func edgeGroupTagsMatch(edgeGroup *portainer.EdgeGroup, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup) bool {
 	endpointTags := tag.Set(endpoint.TagIDs)
 	if endpointGroup.TagIDs != nil {
 		endpointTags = tag.Union(endpointTags, tag.Set(endpointGroup.TagIDs))
@@ -0,0 +1,334 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+
+	"github.com/Masterminds/semver"
+)
+
+const (
+	platformDocker     = "docker"
+	platformPodman     = "podman"
+	platformKubernetes = "kubernetes"
+)
+
+var ruleFieldOperators = map[portainer.EdgeGroupRuleField][]portainer.EdgeGroupRuleOperator{
+	portainer.EdgeGroupRuleFieldName:              stringOperators(),
+	portainer.EdgeGroupRuleFieldEndpointGroup:     stringOperators(),
+	portainer.EdgeGroupRuleFieldArchitecture:      stringOperators(),
+	portainer.EdgeGroupRuleFieldAgentVersion:      append(stringOperators(), portainer.EdgeGroupRuleOperatorSemver),
+	portainer.EdgeGroupRuleFieldDockerVersion:     append(stringOperators(), portainer.EdgeGroupRuleOperatorSemver),
+	portainer.EdgeGroupRuleFieldKubernetesVersion: append(stringOperators(), portainer.EdgeGroupRuleOperatorSemver),
+	portainer.EdgeGroupRuleFieldPlatform:          {portainer.EdgeGroupRuleOperatorEquals, portainer.EdgeGroupRuleOperatorNotEquals},
+	portainer.EdgeGroupRuleFieldAsyncMode:         {portainer.EdgeGroupRuleOperatorEquals, portainer.EdgeGroupRuleOperatorNotEquals},
+	portainer.EdgeGroupRuleFieldLastCheckInAge:    {portainer.EdgeGroupRuleOperatorLessThan, portainer.EdgeGroupRuleOperatorGreaterThan},
+}
+
+// ErrEdgeGroupTimeBasedRules is returned when an Edge stack targets an Edge group whose membership depends on
+// the time, the environments of an Edge stack are only computed when the stack, the groups or the environments change
+var ErrEdgeGroupTimeBasedRules = errors.New("edge groups using the last check-in age rule cannot be targeted by edge stacks")
+
+func stringOperators() []portainer.EdgeGroupRuleOperator {
+	return []portainer.EdgeGroupRuleOperator{
+		portainer.EdgeGroupRuleOperatorEquals,
+		portainer.EdgeGroupRuleOperatorNotEquals,
+		portainer.EdgeGroupRuleOperatorGlob,
+		portainer.EdgeGroupRuleOperatorRegex,
+	}
+}
+
+// ValidateEdgeGroupRules returns an error if one of the rules targets an unknown field,
+// uses an operator that is not supported by its field or holds an invalid value
+func ValidateEdgeGroupRules(rules []portainer.EdgeGroupRule) error {
+	for i, rule := range rules {
+		if err := validateEdgeGroupRule(rule); err != nil {
+			return fmt.Errorf("invalid rule #%d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateEdgeGroupRule(rule portainer.EdgeGroupRule) error {
+	operators, ok := ruleFieldOperators[rule.Field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", rule.Field)
+	}
+
+	supported := false
+	for _, operator := range operators {
+		if operator == rule.Operator {
+			supported = true
+
+			break
+		}
+	}
+
+	if !supported {
+		return fmt.Errorf("operator %q is not supported for field %q", rule.Operator, rule.Field)
+	}
+
+	switch rule.Operator {
+	case portainer.EdgeGroupRuleOperatorGlob:
+		if _, err := path.Match(rule.Value, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern: %w", err)
+		}
+	case portainer.EdgeGroupRuleOperatorRegex:
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+	case portainer.EdgeGroupRuleOperatorSemver:
+		if _, err := semver.NewConstraint(rule.Value); err != nil {
+			return fmt.Errorf("invalid semver range: %w", err)
+		}
+	case portainer.EdgeGroupRuleOperatorLessThan, portainer.EdgeGroupRuleOperatorGreaterThan:
+		if _, err := time.ParseDuration(rule.Value); err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+
+	switch rule.Field {
+	case portainer.EdgeGroupRuleFieldPlatform:
+		switch rule.Value {
+		case platformDocker, platformPodman, platformKubernetes:
+		default:
+			return fmt.Errorf("invalid platform %q", rule.Value)
+		}
+	case portainer.EdgeGroupRuleFieldAsyncMode:
+		if _, err := strconv.ParseBool(rule.Value); err != nil {
+			return errors.New("async mode value must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// EdgeGroupHasTimeBasedRules returns true when the membership of the dynamic Edge group depends on the time
+func EdgeGroupHasTimeBasedRules(edgeGroup *portainer.EdgeGroup) bool {
+	if !edgeGroup.Dynamic {
+		return false
+	}
+
+	for _, rule := range edgeGroup.Rules {
+		if rule.Field == portainer.EdgeGroupRuleFieldLastCheckInAge {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateEdgeStackEdgeGroups returns ErrEdgeGroupTimeBasedRules if one of the Edge groups targeted by an Edge stack
+// has time based rules
+func ValidateEdgeStackEdgeGroups(edgeGroupIDs []portainer.EdgeGroupID, edgeGroups []portainer.EdgeGroup) error {
+	for i := range edgeGroups {
+		if slices.Contains(edgeGroupIDs, edgeGroups[i].ID) && EdgeGroupHasTimeBasedRules(&edgeGroups[i]) {
+			return fmt.Errorf("%w: %q", ErrEdgeGroupTimeBasedRules, edgeGroups[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// edgeGroupRulesMatch returns true if the environment(endpoint) satisfies all the rules
+func edgeGroupRulesMatch(rules []portainer.EdgeGroupRule, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup) bool {
+	for _, rule := range rules {
+		if !edgeGroupRuleMatch(rule, endpoint, endpointGroup) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func edgeGroupRuleMatch(rule portainer.EdgeGroupRule, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup) bool {
+	if rule.Field == portainer.EdgeGroupRuleFieldLastCheckInAge {
+		if endpoint.LastCheckInDate == 0 {
+			return false
+		}
+
+		threshold, err := time.ParseDuration(rule.Value)
+		if err != nil {
+			return false
+		}
+
+		age := time.Since(time.Unix(endpoint.LastCheckInDate, 0))
+		if rule.Operator == portainer.EdgeGroupRuleOperatorLessThan {
+			return age < threshold
+		}
+
+		return age > threshold
+	}
+
+	value, ok := edgeGroupRuleFieldValue(rule.Field, endpoint, endpointGroup)
+	if !ok {
+		return false
+	}
+
+	switch rule.Operator {
+	case portainer.EdgeGroupRuleOperatorEquals:
+		return strings.EqualFold(value, rule.Value)
+	case portainer.EdgeGroupRuleOperatorNotEquals:
+		return !strings.EqualFold(value, rule.Value)
+	case portainer.EdgeGroupRuleOperatorGlob:
+		matched, err := path.Match(rule.Value, value)
+
+		return err == nil && matched
+	case portainer.EdgeGroupRuleOperatorRegex:
+		re, err := regexp.Compile(rule.Value)
+
+		return err == nil && re.MatchString(value)
+	case portainer.EdgeGroupRuleOperatorSemver:
+		constraint, err := semver.NewConstraint(rule.Value)
+		if err != nil {
+			return false
+		}
+
+		version, err := semver.NewVersion(value)
+
+		return err == nil && constraint.Check(version)
+	}
+
+	return false
+}
+
+// edgeGroupRuleFieldValue returns the value of the environment attribute targeted by the field,
+// and false when it is unknown, e.g. when no snapshot is available yet
+func edgeGroupRuleFieldValue(field portainer.EdgeGroupRuleField, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup) (string, bool) {
+	switch field {
+	case portainer.EdgeGroupRuleFieldName:
+		return endpoint.Name, true
+	case portainer.EdgeGroupRuleFieldEndpointGroup:
+		return endpointGroup.Name, endpointGroup.ID != 0
+	case portainer.EdgeGroupRuleFieldAgentVersion:
+		return endpoint.Agent.Version, endpoint.Agent.Version != ""
+	case portainer.EdgeGroupRuleFieldAsyncMode:
+		return strconv.FormatBool(endpoint.Edge.AsyncMode), true
+	case portainer.EdgeGroupRuleFieldPlatform:
+		if endpointutils.IsKubernetesEndpoint(endpoint) {
+			return platformKubernetes, true
+		}
+
+		if len(endpoint.Snapshots) > 0 && endpoint.Snapshots[0].IsPodman {
+			return platformPodman, true
+		}
+
+		return platformDocker, true
+	case portainer.EdgeGroupRuleFieldArchitecture:
+		if len(endpoint.Snapshots) > 0 && endpoint.Snapshots[0].SnapshotRaw != nil && endpoint.Snapshots[0].SnapshotRaw.Info.Architecture != "" {
+			return endpoint.Snapshots[0].SnapshotRaw.Info.Architecture, true
+		}
+
+		return "", false
+	case portainer.EdgeGroupRuleFieldDockerVersion:
+		if len(endpoint.Snapshots) > 0 && endpoint.Snapshots[0].DockerVersion != "" {
+			return endpoint.Snapshots[0].DockerVersion, true
+		}
+
+		return "", false
+	case portainer.EdgeGroupRuleFieldKubernetesVersion:
+		if len(endpoint.Kubernetes.Snapshots) > 0 && endpoint.Kubernetes.Snapshots[0].KubernetesVersion != "" {
+			return endpoint.Kubernetes.Snapshots[0].KubernetesVersion, true
+		}
+
+		return "", false
+	}
+
+	return "", false
+}
+
+func edgeGroupRequiresSnapshot(edgeGroup *portainer.EdgeGroup) bool {
+	if !edgeGroup.Dynamic {
+		return false
+	}
+
+	for _, rule := range edgeGroup.Rules {
+		switch rule.Field {
+		case portainer.EdgeGroupRuleFieldPlatform,
+			portainer.EdgeGroupRuleFieldArchitecture,
+			portainer.EdgeGroupRuleFieldDockerVersion,
+			portainer.EdgeGroupRuleFieldKubernetesVersion:
+			return true
+		}
+	}
+
+	return false
+}
+
+func edgeGroupRequiresSnapshotRaw(edgeGroup *portainer.EdgeGroup) bool {
+	if !edgeGroup.Dynamic {
+		return false
+	}
+
+	for _, rule := range edgeGroup.Rules {
+		if rule.Field == portainer.EdgeGroupRuleFieldArchitecture {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FillEdgeGroupsSnapshotData loads the last snapshot of the environments(endpoints) when at least
+// one of the Edge groups has rules that rely on snapshot facts. The raw Docker data is only loaded
+// for the rules that need it.
+func FillEdgeGroupsSnapshotData(tx dataservices.DataStoreTx, edgeGroups []portainer.EdgeGroup, endpoints []portainer.Endpoint) error {
+	required, requiredRaw := false, false
+	for i := range edgeGroups {
+		required = required || edgeGroupRequiresSnapshot(&edgeGroups[i])
+		requiredRaw = requiredRaw || edgeGroupRequiresSnapshotRaw(&edgeGroups[i])
+	}
+
+	if !required {
+		return nil
+	}
+
+	for i := range endpoints {
+		if !endpointutils.IsEdgeEndpoint(&endpoints[i]) {
+			continue
+		}
+
+		if len(endpoints[i].Snapshots) == 0 && len(endpoints[i].Kubernetes.Snapshots) == 0 {
+			snapshot, err := tx.Snapshot().Read(endpoints[i].ID)
+			if tx.IsErrObjectNotFound(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			if snapshot.Docker != nil {
+				endpoints[i].Snapshots = []portainer.DockerSnapshot{*snapshot.Docker}
+			}
+
+			if snapshot.Kubernetes != nil {
+				endpoints[i].Kubernetes.Snapshots = []portainer.KubernetesSnapshot{*snapshot.Kubernetes}
+			}
+		}
+
+		if !requiredRaw || len(endpoints[i].Snapshots) == 0 || endpoints[i].Snapshots[0].SnapshotRaw != nil {
+			continue
+		}
+
+		raw, err := tx.Snapshot().ReadRaw(endpoints[i].ID)
+		if tx.IsErrObjectNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		endpoints[i].Snapshots[0].SnapshotRaw = raw
+	}
+
+	return nil
+}
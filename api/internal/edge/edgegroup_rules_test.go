@@ -0,0 +1,171 @@
+package edge
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEdgeGroupRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    portainer.EdgeGroupRule
+		isValid bool
+	}{
+		{
+			name:    "valid glob on name",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorGlob, Value: "store-*"},
+			isValid: true,
+		},
+		{
+			name:    "invalid regular expression",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorRegex, Value: "store-("},
+			isValid: false,
+		},
+		{
+			name:    "semver is not supported on name",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: ">=2.0.0"},
+			isValid: false,
+		},
+		{
+			name:    "valid semver range on agent version",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldAgentVersion, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: ">=2.19.0, <3.0.0"},
+			isValid: true,
+		},
+		{
+			name:    "unknown platform",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldPlatform, Operator: portainer.EdgeGroupRuleOperatorEquals, Value: "nomad"},
+			isValid: false,
+		},
+		{
+			name:    "invalid check-in age",
+			rule:    portainer.EdgeGroupRule{Field: portainer.EdgeGroupRuleFieldLastCheckInAge, Operator: portainer.EdgeGroupRuleOperatorLessThan, Value: "ten minutes"},
+			isValid: false,
+		},
+		{
+			name:    "unknown field",
+			rule:    portainer.EdgeGroupRule{Field: "hostname", Operator: portainer.EdgeGroupRuleOperatorEquals, Value: "a"},
+			isValid: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateEdgeGroupRules([]portainer.EdgeGroupRule{tc.rule})
+			if tc.isValid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestEdgeGroupRelatedEndpointsWithRules(t *testing.T) {
+	now := time.Now()
+
+	endpoints := []portainer.Endpoint{
+		{
+			ID:              1,
+			Name:            "store-paris",
+			Type:            portainer.EdgeAgentOnDockerEnvironment,
+			GroupID:         1,
+			LastCheckInDate: now.Unix(),
+			Snapshots:       []portainer.DockerSnapshot{{DockerVersion: "24.0.7"}},
+		},
+		{
+			ID:              2,
+			Name:            "store-london",
+			Type:            portainer.EdgeAgentOnDockerEnvironment,
+			GroupID:         2,
+			LastCheckInDate: now.Add(-time.Hour).Unix(),
+			Snapshots:       []portainer.DockerSnapshot{{DockerVersion: "20.10.21", IsPodman: true}},
+		},
+		{
+			ID:              3,
+			Name:            "warehouse-berlin",
+			Type:            portainer.EdgeAgentOnKubernetesEnvironment,
+			GroupID:         2,
+			LastCheckInDate: now.Unix(),
+		},
+		{
+			ID:   4,
+			Name: "store-local",
+			Type: portainer.DockerEnvironment,
+		},
+	}
+
+	endpoints[0].Agent.Version = "2.21.0"
+	endpoints[1].Agent.Version = "2.18.4"
+	endpoints[2].Agent.Version = "2.21.0"
+
+	endpointGroups := []portainer.EndpointGroup{
+		{ID: 1, Name: "france"},
+		{ID: 2, Name: "europe"},
+	}
+
+	cases := []struct {
+		name     string
+		rules    []portainer.EdgeGroupRule
+		expected []portainer.EndpointID
+	}{
+		{
+			name:     "name glob",
+			rules:    []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorGlob, Value: "store-*"}},
+			expected: []portainer.EndpointID{1, 2},
+		},
+		{
+			name:     "endpoint group",
+			rules:    []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldEndpointGroup, Operator: portainer.EdgeGroupRuleOperatorEquals, Value: "europe"}},
+			expected: []portainer.EndpointID{2, 3},
+		},
+		{
+			name:     "agent version range",
+			rules:    []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldAgentVersion, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: ">=2.20.0"}},
+			expected: []portainer.EndpointID{1, 3},
+		},
+		{
+			name:     "platform",
+			rules:    []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldPlatform, Operator: portainer.EdgeGroupRuleOperatorEquals, Value: "podman"}},
+			expected: []portainer.EndpointID{2},
+		},
+		{
+			name:     "last check-in age",
+			rules:    []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldLastCheckInAge, Operator: portainer.EdgeGroupRuleOperatorGreaterThan, Value: "10m"}},
+			expected: []portainer.EndpointID{2},
+		},
+		{
+			name: "all rules must match",
+			rules: []portainer.EdgeGroupRule{
+				{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorRegex, Value: "^store-"},
+				{Field: portainer.EdgeGroupRuleFieldDockerVersion, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: "^24"},
+			},
+			expected: []portainer.EndpointID{1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			edgeGroup := &portainer.EdgeGroup{Dynamic: true, Rules: tc.rules}
+
+			assert.ElementsMatch(t, tc.expected, EdgeGroupRelatedEndpoints(edgeGroup, endpoints, endpointGroups))
+		})
+	}
+}
+
+func TestValidateEdgeStackEdgeGroups(t *testing.T) {
+	lastCheckInAge := []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldLastCheckInAge, Operator: portainer.EdgeGroupRuleOperatorLessThan, Value: "10m"}}
+
+	edgeGroups := []portainer.EdgeGroup{
+		{ID: 1, Name: "stores", Dynamic: true, Rules: []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldName, Operator: portainer.EdgeGroupRuleOperatorGlob, Value: "store-*"}}},
+		{ID: 2, Name: "online", Dynamic: true, Rules: lastCheckInAge},
+		{ID: 3, Name: "static", Rules: lastCheckInAge},
+	}
+
+	require.NoError(t, ValidateEdgeStackEdgeGroups([]portainer.EdgeGroupID{1, 3}, edgeGroups))
+	require.ErrorIs(t, ValidateEdgeStackEdgeGroups([]portainer.EdgeGroupID{1, 2}, edgeGroups), ErrEdgeGroupTimeBasedRules)
+}
@@ -59,6 +59,10 @@ func FetchEndpointRelationsConfig(tx dataservices.DataStoreTx) (*EndpointRelatio
 		return nil, fmt.Errorf("unable to retrieve edge groups from database: %w", err)
 	}
 
+	if err := FillEdgeGroupsSnapshotData(tx, edgeGroups, endpoints); err != nil {
+		return nil, fmt.Errorf("unable to retrieve environment snapshots from database: %w", err)
+	}
+
 	return &EndpointRelationsConfig{
 		Endpoints:      endpoints,
 		EndpointGroups: endpointGroups,
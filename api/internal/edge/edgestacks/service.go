@@ -81,6 +81,10 @@ func (service *Service) PersistEdgeStack(
 		return nil, fmt.Errorf("unable to find environment relations in database: %w", err)
 	}
 
+	if err := edge.ValidateEdgeStackEdgeGroups(stack.EdgeGroups, relationConfig.EdgeGroups); err != nil {
+		return nil, httperrors.NewInvalidPayloadError(err.Error())
+	}
+
 	relatedEndpointIds, err := edge.EdgeStackRelatedEndpoints(stack.EdgeGroups, relationConfig.Endpoints, relationConfig.EndpointGroups, relationConfig.EdgeGroups)
 	if err != nil {
 		if errors.Is(err, edge.ErrEdgeGroupNotFound) {
@@ -1,20 +1,32 @@
 package edge
 
 import (
+	"maps"
 	"slices"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+	"github.com/portainer/portainer/api/set"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
-// EndpointRelatedEdgeStacks returns a list of Edge stacks related to this Environment(Endpoint)
-func EndpointRelatedEdgeStacks(endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup, edgeGroups []portainer.EdgeGroup, edgeStacks []portainer.EdgeStack) []portainer.EdgeStackID {
+// EndpointRelatedEdgeStacks returns a list of Edge stacks related to this Environment(Endpoint).
+// The snapshot of the environment is loaded when the rules of the Edge groups rely on it
+func EndpointRelatedEdgeStacks(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint, endpointGroup *portainer.EndpointGroup, edgeGroups []portainer.EdgeGroup, edgeStacks []portainer.EdgeStack) ([]portainer.EdgeStackID, error) {
+	// The snapshot data is loaded into a copy so that it is never persisted with the environment
+	endpoints := []portainer.Endpoint{*endpoint}
+	endpoints[0].Snapshots = slices.Clone(endpoint.Snapshots)
+	if err := FillEdgeGroupsSnapshotData(tx, edgeGroups, endpoints); err != nil {
+		return nil, err
+	}
+
 	relatedEdgeGroupsSet := map[portainer.EdgeGroupID]bool{}
 
 	for _, edgeGroup := range edgeGroups {
-		if edgeGroupRelatedToEndpoint(&edgeGroup, endpoint, endpointGroup) {
+		if edgeGroupRelatedToEndpoint(&edgeGroup, &endpoints[0], endpointGroup) {
 			relatedEdgeGroupsSet[edgeGroup.ID] = true
 		}
 	}
@@ -29,7 +41,63 @@ func EndpointRelatedEdgeStacks(endpoint *portainer.Endpoint, endpointGroup *port
 		}
 	}
 
-	return relatedEdgeStacks
+	return relatedEdgeStacks, nil
+}
+
+// UpdateEndpointRelation re-evaluates the Edge stacks related to an Edge environment(endpoint),
+// e.g. after its tags, group or snapshot changed, and persists its relation when they differ
+func UpdateEndpointRelation(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint) error {
+	if !endpointutils.IsEdgeEndpoint(endpoint) {
+		return nil
+	}
+
+	relation, err := tx.EndpointRelation().EndpointRelation(endpoint.ID)
+	if err != nil {
+		if !tx.IsErrObjectNotFound(err) {
+			return errors.WithMessage(err, "Unable to retrieve environment relation inside the database")
+		}
+
+		relation = &portainer.EndpointRelation{
+			EndpointID: endpoint.ID,
+			EdgeStacks: map[portainer.EdgeStackID]bool{},
+		}
+		if err := tx.EndpointRelation().Create(relation); err != nil {
+			return errors.WithMessage(err, "Unable to create environment relation inside the database")
+		}
+	}
+
+	endpointGroup, err := tx.EndpointGroup().Read(endpoint.GroupID)
+	if err != nil {
+		return errors.WithMessage(err, "Unable to find environment group inside the database")
+	}
+
+	edgeGroups, err := tx.EdgeGroup().ReadAll()
+	if err != nil {
+		return errors.WithMessage(err, "Unable to retrieve edge groups from the database")
+	}
+
+	edgeStacks, err := tx.EdgeStack().EdgeStacks()
+	if err != nil {
+		return errors.WithMessage(err, "Unable to retrieve edge stacks from the database")
+	}
+
+	relatedEdgeStacks, err := EndpointRelatedEdgeStacks(tx, endpoint, endpointGroup, edgeGroups, edgeStacks)
+	if err != nil {
+		return errors.WithMessage(err, "Unable to retrieve the edge stacks related to the environment")
+	}
+
+	edgeStackSet := set.ToSet(relatedEdgeStacks)
+	if maps.Equal(relation.EdgeStacks, edgeStackSet) {
+		return nil
+	}
+
+	relation.EdgeStacks = edgeStackSet
+
+	if err := tx.EndpointRelation().UpdateEndpointRelation(endpoint.ID, relation); err != nil {
+		return errors.WithMessage(err, "Unable to persist environment relation changes inside the database")
+	}
+
+	return nil
 }
 
 func EffectiveCheckinInterval(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint) int {
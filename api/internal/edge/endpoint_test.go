@@ -0,0 +1,62 @@
+package edge_test
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/edge"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointRelatedEdgeStacksLoadsSnapshot(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	endpoint := &portainer.Endpoint{ID: 1, Name: "store-paris", Type: portainer.EdgeAgentOnDockerEnvironment, GroupID: 1}
+	require.NoError(t, store.Endpoint().Create(endpoint))
+	require.NoError(t, store.Snapshot().Create(&portainer.Snapshot{EndpointID: endpoint.ID, Docker: &portainer.DockerSnapshot{DockerVersion: "24.0.7"}}))
+
+	endpointGroup := &portainer.EndpointGroup{ID: 1, Name: "Unassigned"}
+	edgeGroups := []portainer.EdgeGroup{{
+		ID:      1,
+		Dynamic: true,
+		Rules:   []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldDockerVersion, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: "^24"}},
+	}}
+	edgeStacks := []portainer.EdgeStack{{ID: 1, EdgeGroups: []portainer.EdgeGroupID{1}}}
+
+	edgeStackIDs, err := edge.EndpointRelatedEdgeStacks(store, endpoint, endpointGroup, edgeGroups, edgeStacks)
+	require.NoError(t, err)
+	require.Equal(t, []portainer.EdgeStackID{1}, edgeStackIDs)
+	require.Empty(t, endpoint.Snapshots, "the snapshot must not be loaded into the environment")
+}
+
+func TestUpdateEndpointRelation(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	endpoint := &portainer.Endpoint{ID: 1, Name: "store-paris", Type: portainer.EdgeAgentOnDockerEnvironment, GroupID: 1}
+	endpoint.Agent.Version = "2.18.4"
+	require.NoError(t, store.Endpoint().Create(endpoint))
+	require.NoError(t, store.EndpointGroup().Create(&portainer.EndpointGroup{ID: 1, Name: "Unassigned"}))
+	require.NoError(t, store.EdgeGroup().Create(&portainer.EdgeGroup{
+		ID:      1,
+		Name:    "recent agents",
+		Dynamic: true,
+		Rules:   []portainer.EdgeGroupRule{{Field: portainer.EdgeGroupRuleFieldAgentVersion, Operator: portainer.EdgeGroupRuleOperatorSemver, Value: ">=2.20.0"}},
+	}))
+	require.NoError(t, store.EdgeStack().Create(1, &portainer.EdgeStack{ID: 1, Name: "app", EdgeGroups: []portainer.EdgeGroupID{1}}))
+
+	require.NoError(t, edge.UpdateEndpointRelation(store, endpoint))
+
+	relation, err := store.EndpointRelation().EndpointRelation(endpoint.ID)
+	require.NoError(t, err)
+	require.Empty(t, relation.EdgeStacks)
+
+	// the agent is upgraded
+	endpoint.Agent.Version = "2.21.0"
+	require.NoError(t, edge.UpdateEndpointRelation(store, endpoint))
+
+	relation, err = store.EndpointRelation().EndpointRelation(endpoint.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[portainer.EdgeStackID]bool{1: true}, relation.EdgeStacks)
+}
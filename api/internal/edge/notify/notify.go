@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"sync"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/edge/cache"
+)
+
+// Type represents the kind of change pushed to an Edge agent
+type Type string
+
+const (
+	// StackVersionChanged is sent when the Edge stacks related to the environment changed
+	StackVersionChanged Type = "stackVersionChanged"
+	// JobScheduled is sent when the Edge jobs related to the environment changed
+	JobScheduled Type = "jobScheduled"
+	// JobLogsRequested is sent when the logs of an Edge job must be collected from the environment
+	JobLogsRequested Type = "jobLogsRequested"
+	// TunnelRequested is sent when the server needs the agent to open its reverse tunnel
+	TunnelRequested Type = "tunnelRequested"
+)
+
+// subscriberBufferSize is the number of notifications kept for a slow subscriber
+// before new ones are dropped, the agent picks up the changes on its next poll anyway
+const subscriberBufferSize = 16
+
+// Notification represents a change pushed to an Edge agent
+type Notification struct {
+	Type       Type                 `json:"type" example:"stackVersionChanged"`
+	EndpointID portainer.EndpointID `json:"endpointId" example:"1"`
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[portainer.EndpointID]map[chan Notification]struct{}{}
+)
+
+// Send invalidates the cached Edge status of the environment(endpoint) and pushes
+// the notification to the agents listening on the notification channel
+func Send(endpointID portainer.EndpointID, notificationType Type) {
+	cache.Del(endpointID)
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	n := Notification{Type: notificationType, EndpointID: endpointID}
+
+	for ch := range subscribers[endpointID] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
+
+// Transaction is a database transaction able to run a function once it is committed
+type Transaction interface {
+	OnCommit(fn func())
+}
+
+// SendOnCommit sends the notification once the transaction is committed, so that the agents
+// never fetch a state that does not include the change yet. Nothing is sent on rollback
+func SendOnCommit(tx Transaction, endpointID portainer.EndpointID, notificationType Type) {
+	tx.OnCommit(func() {
+		Send(endpointID, notificationType)
+	})
+}
+
+// Subscribe registers a listener for the notifications of the environment(endpoint),
+// the returned function must be called to release it
+func Subscribe(endpointID portainer.EndpointID) (<-chan Notification, func()) {
+	ch := make(chan Notification, subscriberBufferSize)
+
+	mu.Lock()
+	if subscribers[endpointID] == nil {
+		subscribers[endpointID] = map[chan Notification]struct{}{}
+	}
+	subscribers[endpointID][ch] = struct{}{}
+	mu.Unlock()
+
+	// The Edge status exposes whether the channel is connected
+	cache.Del(endpointID)
+
+	var once sync.Once
+
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			delete(subscribers[endpointID], ch)
+			if len(subscribers[endpointID]) == 0 {
+				delete(subscribers, endpointID)
+			}
+
+			cache.Del(endpointID)
+		})
+	}
+}
+
+// Connected returns true if at least one agent of the environment(endpoint) is listening
+func Connected(endpointID portainer.EndpointID) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return len(subscribers[endpointID]) > 0
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/edge/cache"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendDeliversToSubscribers(t *testing.T) {
+	endpointID := portainer.EndpointID(1)
+
+	notifications, unsubscribe := Subscribe(endpointID)
+	require.True(t, Connected(endpointID))
+
+	cache.Set(endpointID, []byte("etag"))
+
+	Send(endpointID, StackVersionChanged)
+	Send(portainer.EndpointID(2), JobScheduled)
+
+	_, ok := cache.Get(endpointID)
+	require.False(t, ok, "the cached status should be invalidated")
+
+	require.Equal(t, Notification{Type: StackVersionChanged, EndpointID: endpointID}, <-notifications)
+	require.Empty(t, notifications)
+
+	unsubscribe()
+	unsubscribe()
+
+	require.False(t, Connected(endpointID))
+}
+
+func TestSendDoesNotBlockOnSlowSubscribers(t *testing.T) {
+	endpointID := portainer.EndpointID(3)
+
+	notifications, unsubscribe := Subscribe(endpointID)
+	defer unsubscribe()
+
+	for range subscriberBufferSize * 2 {
+		Send(endpointID, TunnelRequested)
+	}
+
+	require.Len(t, notifications, subscriberBufferSize)
+}
+
+type testTransaction struct {
+	onCommit []func()
+}
+
+func (tx *testTransaction) OnCommit(fn func()) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+func TestSendOnCommit(t *testing.T) {
+	endpointID := portainer.EndpointID(4)
+
+	notifications, unsubscribe := Subscribe(endpointID)
+	defer unsubscribe()
+
+	tx := &testTransaction{}
+	SendOnCommit(tx, endpointID, JobLogsRequested)
+	require.Empty(t, notifications, "nothing must be sent before the commit")
+
+	for _, fn := range tx.onCommit {
+		fn()
+	}
+
+	require.Equal(t, Notification{Type: JobLogsRequested, EndpointID: endpointID}, <-notifications)
+}
@@ -0,0 +1,277 @@
+// Package envsecrets encrypts the values of the stack environment variables flagged as secret.
+// The values are encrypted with a key stored next to the database, independently of the store encryption,
+// and are only decrypted when the stack is deployed.
+package envsecrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+const (
+	// KeyFileName is the name of the file holding the encryption key inside the data directory
+	KeyFileName = "stack_env.key"
+
+	keySize = 32
+	// prefix identifies the encrypted values and the version of the encryption
+	prefix = "enc:v1:"
+)
+
+// ErrNotInitialized is returned when a secret is sealed or opened before the key is loaded
+var ErrNotInitialized = errors.New("the secret environment variables encryption key is not loaded")
+
+var (
+	mu            sync.RWMutex
+	defaultCipher *Cipher
+)
+
+// Cipher encrypts and decrypts values with AES-256-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a 32 bytes key
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key size %d, expected %d", len(key), keySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts the value, the result is prefixed so it can be told apart from a plain value
+func (c *Cipher) Encrypt(value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), nil)
+
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("the value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt the value: %w", err)
+	}
+
+	return string(plain), nil
+}
+
+// IsEncrypted returns true when the value was returned by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// LoadOrCreateKey reads the key from the file, the key is generated when the file does not exist
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("invalid key size %d in %s", len(key), path)
+		}
+
+		return key, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Init loads the key stored inside the data directory, or generates it, and uses it for Seal and Open
+func Init(dataStorePath string) error {
+	key, err := LoadOrCreateKey(filepath.Join(dataStorePath, KeyFileName))
+	if err != nil {
+		return fmt.Errorf("unable to load the secret environment variables encryption key: %w", err)
+	}
+
+	c, err := NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	SetCipher(c)
+
+	return nil
+}
+
+// SetCipher sets the cipher used by Seal and Open
+func SetCipher(c *Cipher) {
+	mu.Lock()
+	defaultCipher = c
+	mu.Unlock()
+}
+
+func currentCipher() (*Cipher, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if defaultCipher == nil {
+		return nil, ErrNotInitialized
+	}
+
+	return defaultCipher, nil
+}
+
+// Seal returns a copy of the environment variables with the values of the secrets encrypted.
+// The secret values are write-only: a secret sent with an empty value keeps its previous value.
+func Seal(env, previous []portainer.Pair) ([]portainer.Pair, error) {
+	if !hasSecret(env) {
+		return env, nil
+	}
+
+	c, err := currentCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]portainer.Pair, len(env))
+	for i, pair := range env {
+		sealed[i] = pair
+
+		if !pair.Secret || IsEncrypted(pair.Value) && pair.Value == previousValue(previous, pair.Name) {
+			continue
+		}
+
+		if pair.Value == "" {
+			value := previousValue(previous, pair.Name)
+			if value == "" {
+				return nil, fmt.Errorf("a value is required for the secret environment variable %s", pair.Name)
+			}
+
+			if !IsEncrypted(value) {
+				if value, err = c.Encrypt(value); err != nil {
+					return nil, err
+				}
+			}
+
+			sealed[i].Value = value
+
+			continue
+		}
+
+		if sealed[i].Value, err = c.Encrypt(pair.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return sealed, nil
+}
+
+// Open returns a copy of the environment variables with the values of the secrets decrypted
+func Open(env []portainer.Pair) ([]portainer.Pair, error) {
+	if !hasSecret(env) {
+		return env, nil
+	}
+
+	c, err := currentCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	opened := make([]portainer.Pair, len(env))
+	for i, pair := range env {
+		opened[i] = pair
+
+		if !pair.Secret || !IsEncrypted(pair.Value) {
+			continue
+		}
+
+		if opened[i].Value, err = c.Decrypt(pair.Value); err != nil {
+			return nil, fmt.Errorf("unable to decrypt the secret environment variable %s: %w", pair.Name, err)
+		}
+	}
+
+	return opened, nil
+}
+
+// Mask returns a copy of the environment variables with the values of the secrets removed
+func Mask(env []portainer.Pair) []portainer.Pair {
+	if !hasSecret(env) {
+		return env
+	}
+
+	masked := make([]portainer.Pair, len(env))
+	for i, pair := range env {
+		masked[i] = pair
+
+		if pair.Secret {
+			masked[i].Value = ""
+		}
+	}
+
+	return masked
+}
+
+func hasSecret(env []portainer.Pair) bool {
+	for _, pair := range env {
+		if pair.Secret {
+			return true
+		}
+	}
+
+	return false
+}
+
+func previousValue(previous []portainer.Pair, name string) string {
+	for _, pair := range previous {
+		if pair.Name == name && pair.Secret {
+			return pair.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,118 @@
+package envsecrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), KeyFileName)
+
+	key, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	require.Len(t, key, keySize)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	require.Equal(t, key, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("short"), 0600))
+	_, err = LoadOrCreateKey(path)
+	require.Error(t, err)
+}
+
+func TestCipher(t *testing.T) {
+	key, err := LoadOrCreateKey(filepath.Join(t.TempDir(), KeyFileName))
+	require.NoError(t, err)
+
+	c, err := NewCipher(key)
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("value")
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(encrypted))
+	require.NotContains(t, encrypted, "value")
+
+	other, err := c.Encrypt("value")
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted, other, "the nonce must be random")
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "value", decrypted)
+
+	otherKey, err := LoadOrCreateKey(filepath.Join(t.TempDir(), KeyFileName))
+	require.NoError(t, err)
+
+	otherCipher, err := NewCipher(otherKey)
+	require.NoError(t, err)
+
+	_, err = otherCipher.Decrypt(encrypted)
+	require.Error(t, err)
+}
+
+func TestSealOpenMask(t *testing.T) {
+	require.NoError(t, Init(t.TempDir()))
+
+	env := []portainer.Pair{
+		{Name: "PLAIN", Value: "plain"},
+		{Name: "TOKEN", Value: "token", Secret: true},
+	}
+
+	sealed, err := Seal(env, nil)
+	require.NoError(t, err)
+	require.Equal(t, "plain", sealed[0].Value)
+	require.True(t, IsEncrypted(sealed[1].Value))
+	require.Equal(t, "token", env[1].Value, "the input must not be modified")
+
+	opened, err := Open(sealed)
+	require.NoError(t, err)
+	require.Equal(t, env, opened)
+
+	masked := Mask(sealed)
+	require.Equal(t, []portainer.Pair{
+		{Name: "PLAIN", Value: "plain"},
+		{Name: "TOKEN", Value: "", Secret: true},
+	}, masked)
+	require.NotEmpty(t, sealed[1].Value, "the input must not be modified")
+
+	t.Run("an empty secret keeps the previous value", func(t *testing.T) {
+		updated, err := Seal(masked, sealed)
+		require.NoError(t, err)
+		require.Equal(t, sealed[1].Value, updated[1].Value)
+	})
+
+	t.Run("a new secret value replaces the previous value", func(t *testing.T) {
+		updated, err := Seal([]portainer.Pair{{Name: "TOKEN", Value: "new", Secret: true}}, sealed)
+		require.NoError(t, err)
+
+		opened, err := Open(updated)
+		require.NoError(t, err)
+		require.Equal(t, "new", opened[0].Value)
+	})
+
+	t.Run("a plain value flagged as secret is encrypted", func(t *testing.T) {
+		updated, err := Seal([]portainer.Pair{{Name: "PLAIN", Secret: true}}, []portainer.Pair{{Name: "PLAIN", Value: "plain", Secret: true}})
+		require.NoError(t, err)
+		require.True(t, IsEncrypted(updated[0].Value))
+	})
+
+	t.Run("a new secret requires a value", func(t *testing.T) {
+		_, err := Seal([]portainer.Pair{{Name: "OTHER", Secret: true}}, sealed)
+		require.Error(t, err)
+	})
+
+	t.Run("the previous value of a plain variable is not reused", func(t *testing.T) {
+		_, err := Seal([]portainer.Pair{{Name: "PLAIN", Secret: true}}, sealed)
+		require.Error(t, err)
+	})
+}
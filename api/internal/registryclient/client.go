@@ -0,0 +1,397 @@
+package registryclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	// pageSize is the number of entries requested for each page of the catalog and tag lists
+	pageSize = 100
+	// maxEntries is the maximum number of entries retrieved from the catalog or a tag list
+	maxEntries = 10000
+)
+
+// ErrDeleteNotSupported is returned when the registry does not allow the deletion of manifests
+var ErrDeleteNotSupported = errors.New("the registry does not support the deletion of images")
+
+// ErrNotFound is returned when the repository or the tag does not exist
+var ErrNotFound = errors.New("not found")
+
+// Client is a client of the Docker Registry HTTP API V2
+type Client struct {
+	host          string
+	scheme        string
+	allowInsecure bool
+	username      string
+	password      string
+	httpClient    *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a client of the registry, the credentials are optional
+func NewClient(registry *portainer.Registry, username, password string) (*Client, error) {
+	tlsConfig := crypto.CreateTLSConfiguration()
+	allowInsecure := false
+
+	if registry.ManagementConfiguration != nil {
+		config := registry.ManagementConfiguration.TLSConfig
+
+		if config.TLS && !config.TLSSkipVerify && config.TLSCACertPath != "" {
+			var err error
+			if tlsConfig, err = crypto.CreateTLSConfigurationFromDisk(config.TLSCACertPath, config.TLSCertPath, config.TLSKeyPath, false); err != nil {
+				return nil, err
+			}
+		}
+
+		if config.TLSSkipVerify {
+			tlsConfig.InsecureSkipVerify = true
+			allowInsecure = true
+		}
+	}
+
+	return &Client{
+		host:          apiHost(registry),
+		scheme:        "https",
+		allowInsecure: allowInsecure,
+		username:      username,
+		password:      password,
+		httpClient: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		tokens: map[string]string{},
+	}, nil
+}
+
+func apiHost(registry *portainer.Registry) string {
+	if registry.Type == portainer.DockerHubRegistry {
+		return "registry-1.docker.io"
+	}
+
+	host := registry.URL
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	host, _, _ = strings.Cut(host, "/")
+
+	return host
+}
+
+// Repositories returns the repositories listed by the catalog of the registry
+func (c *Client) Repositories(ctx context.Context) ([]string, error) {
+	var repositories []string
+
+	err := c.paginate(ctx, "/v2/_catalog", "registry:catalog:*", func(body io.Reader) (int, error) {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return 0, err
+		}
+
+		repositories = append(repositories, page.Repositories...)
+
+		return len(repositories), nil
+	})
+
+	return repositories, err
+}
+
+// Tags returns the tags of the repository
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	if err := ValidateRepository(repository); err != nil {
+		return nil, err
+	}
+
+	var tags []string
+
+	err := c.paginate(ctx, "/v2/"+repository+"/tags/list", pullScope(repository), func(body io.Reader) (int, error) {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return 0, err
+		}
+
+		tags = append(tags, page.Tags...)
+
+		return len(tags), nil
+	})
+
+	return tags, err
+}
+
+// paginate retrieves the pages of a list following the Link headers
+func (c *Client) paginate(ctx context.Context, path, scope string, decode func(body io.Reader) (int, error)) error {
+	next := fmt.Sprintf("%s?n=%d", path, pageSize)
+
+	for next != "" {
+		resp, err := c.do(ctx, http.MethodGet, next, scope, nil)
+		if err != nil {
+			return err
+		}
+
+		count, err := decode(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to decode the registry response: %w", err)
+		}
+
+		if count >= maxEntries {
+			return nil
+		}
+
+		next = nextLink(resp.Header.Get("Link"))
+	}
+
+	return nil
+}
+
+// nextLink parses a Link header such as </v2/_catalog?last=b&n=100>; rel="next"
+func nextLink(header string) string {
+	if header == "" || !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+
+	start := strings.Index(header, "<")
+	end := strings.Index(header, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	link := header[start+1 : end]
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		return u.RequestURI()
+	}
+
+	return link
+}
+
+// do sends a request to the registry and handles the authentication challenges
+func (c *Client) do(ctx context.Context, method, path, scope string, header http.Header) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, scope, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if err := c.authenticate(ctx, challenge, scope); err != nil {
+			return nil, err
+		}
+
+		if resp, err = c.send(ctx, method, path, scope, header); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+
+		return nil, responseError(resp)
+	}
+
+	return resp, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path, scope string, header http.Header) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, c.scheme+"://"+c.host+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		c.mu.Lock()
+		token, ok := c.tokens[scope]
+		c.mu.Unlock()
+
+		if ok && token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if ok && c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil && c.scheme == "https" && c.allowInsecure && ctx.Err() == nil {
+		// Registries without TLS are only reachable when the TLS verification is skipped
+		c.scheme = "http"
+
+		if req, err = newRequest(); err != nil {
+			return nil, err
+		}
+
+		return c.httpClient.Do(req)
+	}
+
+	return resp, err
+}
+
+// authenticate answers a Basic or Bearer authentication challenge for the scope
+func (c *Client) authenticate(ctx context.Context, challenge, scope string) error {
+	authType, params := parseChallenge(challenge)
+
+	switch authType {
+	case "basic":
+		if c.username == "" {
+			return errors.New("the registry requires authentication")
+		}
+
+		c.mu.Lock()
+		c.tokens[scope] = ""
+		c.mu.Unlock()
+
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid authentication realm %q", params["realm"])
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	if s := params["scope"]; s != "" {
+		query.Set("scope", s)
+	} else if scope != "" {
+		query.Set("scope", scope)
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to authenticate against the registry: unexpected status code %d", resp.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("unable to decode the registry token: %w", err)
+	}
+
+	t := token.Token
+	if t == "" {
+		t = token.AccessToken
+	}
+
+	if t == "" {
+		return errors.New("the registry did not return any token")
+	}
+
+	c.mu.Lock()
+	c.tokens[scope] = t
+	c.mu.Unlock()
+
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as Bearer realm="https://auth",service="registry"
+func parseChallenge(challenge string) (string, map[string]string) {
+	authType, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var key, value string
+
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			params[key] = value
+		}
+	}
+
+	return strings.ToLower(authType), params
+}
+
+func responseError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrDeleteNotSupported
+	}
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil && len(body.Errors) > 0 {
+		if body.Errors[0].Code == "UNSUPPORTED" {
+			return ErrDeleteNotSupported
+		}
+
+		return fmt.Errorf("registry error: %s: %s", body.Errors[0].Code, body.Errors[0].Message)
+	}
+
+	return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+}
+
+func pullScope(repository string) string {
+	return "repository:" + repository + ":pull"
+}
+
+func deleteScope(repository string) string {
+	return "repository:" + repository + ":delete"
+}
@@ -0,0 +1,205 @@
+package registryclient
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T, handler http.Handler) *portainer.Registry {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	return &portainer.Registry{
+		Type: portainer.CustomRegistry,
+		URL:  u.Host,
+		ManagementConfiguration: &portainer.RegistryManagementConfiguration{
+			TLSConfig: portainer.TLSConfiguration{TLSSkipVerify: true},
+		},
+	}
+}
+
+func newRegistry() http.Handler {
+	return registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+}
+
+func push(t *testing.T, registry *portainer.Registry, repository string, tags ...string) {
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+
+	for _, tag := range tags {
+		ref, err := name.ParseReference(registry.URL+"/"+repository+":"+tag, name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+}
+
+func TestClient(t *testing.T) {
+	reg := newTestRegistry(t, newRegistry())
+
+	push(t, reg, "team/app", "1.0", "2.0")
+	push(t, reg, "team/other", "latest")
+
+	index, err := random.Index(512, 1, 2)
+	require.NoError(t, err)
+
+	indexRef, err := name.ParseReference(reg.URL+"/team/multi:1.0", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(indexRef, index))
+
+	client, err := NewClient(reg, "", "")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	repositories, err := client.Repositories(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"team/app", "team/other", "team/multi"}, repositories)
+
+	tags, err := client.Tags(ctx, "team/app")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"1.0", "2.0"}, tags)
+
+	m, err := client.Manifest(ctx, "team/app", "1.0")
+	require.NoError(t, err)
+	require.NotEmpty(t, m.Digest)
+	require.Len(t, m.Platforms, 1)
+	require.Len(t, m.Platforms[0].Layers, 2)
+	require.Equal(t, layersSize(m.Platforms...), m.Size)
+	require.NotZero(t, m.Size)
+
+	m, err = client.Manifest(ctx, "team/multi", "1.0")
+	require.NoError(t, err)
+	require.Len(t, m.Platforms, 2)
+	require.Equal(t, layersSize(m.Platforms...), m.Size)
+
+	_, err = client.Manifest(ctx, "team/app", "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, client.DeleteTag(ctx, "team/other", "latest"))
+	require.ErrorIs(t, client.DeleteTag(ctx, "team/other", "missing"), ErrNotFound)
+}
+
+func layersSize(platforms ...Platform) int64 {
+	var size int64
+	for _, p := range platforms {
+		for _, l := range p.Layers {
+			size += l.Size
+		}
+	}
+
+	return size
+}
+
+func TestClientDeleteNotSupported(t *testing.T) {
+	inner := newRegistry()
+	reg := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		inner.ServeHTTP(w, r)
+	}))
+
+	push(t, reg, "team/app", "1.0")
+
+	client, err := NewClient(reg, "", "")
+	require.NoError(t, err)
+
+	require.ErrorIs(t, client.DeleteTag(context.Background(), "team/app", "1.0"), ErrDeleteNotSupported)
+}
+
+func TestClientBearerAuthentication(t *testing.T) {
+	const token = "secret-token"
+
+	var realm string
+	inner := newRegistry()
+	reg := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			w.Write([]byte(`{"token":"` + token + `"}`))
+
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+token && strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		inner.ServeHTTP(w, r)
+	}))
+	realm = "http://" + reg.URL + "/token"
+
+	// Only the tag list is authenticated by the test server
+	push(t, reg, "team/app", "1.0")
+
+	client, err := NewClient(reg, "user", "pass")
+	require.NoError(t, err)
+
+	tags, err := client.Tags(context.Background(), "team/app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.0"}, tags)
+
+	client, err = NewClient(reg, "user", "wrong")
+	require.NoError(t, err)
+
+	_, err = client.Tags(context.Background(), "team/app")
+	require.Error(t, err)
+}
+
+func TestParseChallenge(t *testing.T) {
+	authType, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`)
+	require.Equal(t, "bearer", authType)
+	require.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/alpine:pull",
+	}, params)
+
+	authType, params = parseChallenge(`Basic realm="Registry"`)
+	require.Equal(t, "basic", authType)
+	require.Equal(t, "Registry", params["realm"])
+}
+
+func TestNextLink(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: ""},
+		{header: `</v2/_catalog?last=b&n=100>; rel="next"`, expected: "/v2/_catalog?last=b&n=100"},
+		{header: `<https://registry.example.com/v2/app/tags/list?last=1.0&n=100>; rel="next"`, expected: "/v2/app/tags/list?last=1.0&n=100"},
+		{header: `</v2/_catalog?last=b&n=100>; rel="prev"`, expected: ""},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, nextLink(tc.header), strings.TrimSpace(tc.header))
+	}
+}
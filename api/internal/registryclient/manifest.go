@@ -0,0 +1,219 @@
+package registryclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+
+	// maxManifestSize is the maximum size of a manifest or an image configuration read from the registry
+	maxManifestSize = 4 << 20
+)
+
+var manifestAcceptHeader = http.Header{
+	"Accept": []string{mediaTypeDockerManifestList, mediaTypeOCIIndex, mediaTypeDockerManifest, mediaTypeOCIManifest},
+}
+
+// Layer is a layer of an image
+type Layer struct {
+	Digest    string `json:"Digest" example:"sha256:2d473b07cdd5f0912cd6f1a703352c82b512407db6b05b43f2553732b55df3bc"`
+	MediaType string `json:"MediaType" example:"application/vnd.docker.image.rootfs.diff.tar.gzip"`
+	Size      int64  `json:"Size" example:"3370706"`
+}
+
+// Platform is an image of a multi-arch manifest
+type Platform struct {
+	OS           string    `json:"OS" example:"linux"`
+	Architecture string    `json:"Architecture" example:"amd64"`
+	Variant      string    `json:"Variant,omitempty" example:"v8"`
+	Digest       string    `json:"Digest" example:"sha256:6457d53fb065d6f250e1504b9bc42d5b6c65941d57532c072d929dd0628977d0"`
+	Size         int64     `json:"Size" example:"3370706"`
+	Created      time.Time `json:"Created,omitempty"`
+	Layers       []Layer   `json:"Layers"`
+}
+
+// Manifest is the description of a tag
+type Manifest struct {
+	Repository string `json:"Repository" example:"team/app"`
+	Tag        string `json:"Tag" example:"1.0.0"`
+	Digest     string `json:"Digest" example:"sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b"`
+	MediaType  string `json:"MediaType" example:"application/vnd.docker.distribution.manifest.v2+json"`
+	// Total size of the layers, summed over all the platforms of a multi-arch image
+	Size      int64      `json:"Size" example:"3370706"`
+	Platforms []Platform `json:"Platforms"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform,omitempty"`
+}
+
+type manifestDocument struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+type imageConfig struct {
+	Created      time.Time `json:"created"`
+	OS           string    `json:"os"`
+	Architecture string    `json:"architecture"`
+	Variant      string    `json:"variant"`
+}
+
+// Manifest returns the description of the tag of the repository
+func (c *Client) Manifest(ctx context.Context, repository, tag string) (*Manifest, error) {
+	document, digest, err := c.manifest(ctx, repository, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Repository: repository,
+		Tag:        tag,
+		Digest:     digest,
+		MediaType:  document.MediaType,
+	}
+
+	if len(document.Manifests) == 0 {
+		platform, err := c.platform(ctx, repository, document)
+		if err != nil {
+			return nil, err
+		}
+		platform.Digest = digest
+
+		m.Platforms = []Platform{*platform}
+		m.Size = platform.Size
+
+		return m, nil
+	}
+
+	for _, d := range document.Manifests {
+		// Skip the attestation manifests added by buildkit
+		if d.Platform != nil && d.Platform.OS == "unknown" {
+			continue
+		}
+
+		child, _, err := c.manifest(ctx, repository, d.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		platform, err := c.platform(ctx, repository, child)
+		if err != nil {
+			return nil, err
+		}
+
+		platform.Digest = d.Digest
+		if d.Platform != nil {
+			platform.OS = d.Platform.OS
+			platform.Architecture = d.Platform.Architecture
+			platform.Variant = d.Platform.Variant
+		}
+
+		m.Platforms = append(m.Platforms, *platform)
+		m.Size += platform.Size
+	}
+
+	return m, nil
+}
+
+// DeleteTag deletes the manifest referenced by the tag, which also removes the other tags referencing the same manifest
+func (c *Client) DeleteTag(ctx context.Context, repository, tag string) error {
+	if err := validateNames(repository, tag); err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repository+"/manifests/"+tag, deleteScope(repository), manifestAcceptHeader)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !validDigest(digest) {
+		return fmt.Errorf("unable to resolve the digest of %s:%s", repository, tag)
+	}
+
+	resp, err = c.do(ctx, http.MethodDelete, "/v2/"+repository+"/manifests/"+digest, deleteScope(repository), nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (c *Client) manifest(ctx context.Context, repository, reference string) (*manifestDocument, string, error) {
+	if err := validateNames(repository, reference); err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, pullScope(repository), manifestAcceptHeader)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var document manifestDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&document); err != nil {
+		return nil, "", fmt.Errorf("unable to decode the manifest: %w", err)
+	}
+
+	if document.MediaType == "" {
+		document.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	return &document, resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+func (c *Client) platform(ctx context.Context, repository string, document *manifestDocument) (*Platform, error) {
+	platform := &Platform{Layers: make([]Layer, 0, len(document.Layers))}
+
+	for _, l := range document.Layers {
+		platform.Layers = append(platform.Layers, Layer{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+		platform.Size += l.Size
+	}
+
+	if document.Config.Digest == "" {
+		return platform, nil
+	}
+
+	if !validDigest(document.Config.Digest) {
+		return nil, fmt.Errorf("invalid image configuration digest %q", document.Config.Digest)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/blobs/"+document.Config.Digest, pullScope(repository), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the image configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var config imageConfig
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&config); err != nil {
+		return nil, fmt.Errorf("unable to decode the image configuration: %w", err)
+	}
+
+	platform.Created = config.Created
+	platform.OS = config.OS
+	platform.Architecture = config.Architecture
+	platform.Variant = config.Variant
+
+	return platform, nil
+}
@@ -0,0 +1,53 @@
+package registryclient
+
+import (
+	"errors"
+	"regexp"
+)
+
+// The grammars of the OCI distribution specification, the names are concatenated into the API paths
+// and must never contain path traversals, queries or fragments
+var (
+	repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+	tagPattern        = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+	digestPattern     = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+)
+
+// maxRepositoryLength is the maximum length of a repository name
+const maxRepositoryLength = 255
+
+// ErrInvalidRepository is returned when a repository name does not match the OCI grammar
+var ErrInvalidRepository = errors.New("invalid repository name")
+
+// ErrInvalidReference is returned when a reference is neither a valid tag nor a valid digest
+var ErrInvalidReference = errors.New("invalid tag or digest")
+
+// ValidateRepository returns ErrInvalidRepository when the repository name does not match the OCI grammar
+func ValidateRepository(repository string) error {
+	if len(repository) > maxRepositoryLength || !repositoryPattern.MatchString(repository) {
+		return ErrInvalidRepository
+	}
+
+	return nil
+}
+
+// ValidateReference returns ErrInvalidReference when the reference is neither a tag nor a digest
+func ValidateReference(reference string) error {
+	if !tagPattern.MatchString(reference) && !validDigest(reference) {
+		return ErrInvalidReference
+	}
+
+	return nil
+}
+
+func validateNames(repository, reference string) error {
+	if err := ValidateRepository(repository); err != nil {
+		return err
+	}
+
+	return ValidateReference(reference)
+}
+
+func validDigest(digest string) bool {
+	return digestPattern.MatchString(digest)
+}
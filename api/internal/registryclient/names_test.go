@@ -0,0 +1,27 @@
+package registryclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRepository(t *testing.T) {
+	for _, repository := range []string{"nginx", "library/nginx", "my-org/my_app", "a/b/c.d", "app__v2"} {
+		require.NoError(t, ValidateRepository(repository), repository)
+	}
+
+	for _, repository := range []string{"", "..", "../_catalog", "library/../nginx", "nginx?n=1", "nginx#tags", "Nginx", "/nginx", "nginx/", "a//b", "nginx/tags/list?x", "a..b"} {
+		require.ErrorIs(t, ValidateRepository(repository), ErrInvalidRepository, repository)
+	}
+}
+
+func TestValidateReference(t *testing.T) {
+	for _, reference := range []string{"latest", "1.25.3-alpine", "v1_RC", "sha256:2cf1b4bf2b2b0ab6a5c1f8e0d1a1b7d3c5b6e8f9a0b1c2d3e4f5a6b7c8d9e0f1"} {
+		require.NoError(t, ValidateReference(reference), reference)
+	}
+
+	for _, reference := range []string{"", ".latest", "../blobs", "latest?x=1", "latest#x", "sha256:"} {
+		require.ErrorIs(t, ValidateReference(reference), ErrInvalidReference, reference)
+	}
+}
@@ -0,0 +1,4 @@
+package snapshot
+
+// SnapshotEndpoints exposes snapshotEndpoints to the external tests
+var SnapshotEndpoints = (*Service).snapshotEndpoints
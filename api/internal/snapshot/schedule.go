@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// jitterRatio is the fraction of the snapshot interval over which the snapshots of a run are spread
+const jitterRatio = 10
+
+// schedule sends the environments(endpoints) to jobs in a random order, each one after a random
+// delay within the jitter window so that large fleets are not all snapshotted at the same time.
+// It returns early when ctx is done.
+func schedule(ctx context.Context, endpoints []portainer.Endpoint, jitter time.Duration, jobs chan<- portainer.Endpoint) {
+	delays := make([]time.Duration, len(endpoints))
+	order := make([]int, len(endpoints))
+
+	for i := range endpoints {
+		order[i] = i
+
+		if jitter > 0 {
+			delays[i] = rand.N(jitter)
+		}
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		return int(delays[a] - delays[b])
+	})
+
+	start := time.Now()
+
+	for _, i := range order {
+		if wait := time.Until(start.Add(delays[i])); wait > 0 {
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+
+				return
+			}
+		}
+
+		select {
+		case jobs <- endpoints[i]:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
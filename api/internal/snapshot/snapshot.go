@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"sync"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/agent"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/pendingactions"
 	endpointsutils "github.com/portainer/portainer/pkg/endpoints"
 
@@ -23,15 +25,22 @@ type Service struct {
 	dataStore                 dataservices.DataStore
 	snapshotIntervalCh        chan time.Duration
 	snapshotIntervalInSeconds float64
+	concurrency               int
+	timeout                   time.Duration
 	dockerSnapshotter         portainer.DockerSnapshotter
 	kubernetesSnapshotter     portainer.KubernetesSnapshotter
 	shutdownCtx               context.Context
 	pendingActionsService     *pendingactions.PendingActionsService
+	stats                     *statsRecorder
 }
 
-// NewService creates a new instance of a service
+// NewService creates a new instance of a service.
+// At most concurrency environments are snapshotted at the same time and each
+// snapshot is cancelled after timeout, the defaults are used for zero values.
 func NewService(
 	snapshotIntervalFromFlag string,
+	concurrency int,
+	timeout time.Duration,
 	dataStore dataservices.DataStore,
 	dockerSnapshotter portainer.DockerSnapshotter,
 	kubernetesSnapshotter portainer.KubernetesSnapshotter,
@@ -43,14 +52,29 @@ func NewService(
 		return nil, err
 	}
 
+	if concurrency <= 0 {
+		concurrency = portainer.DefaultSnapshotConcurrency
+	}
+
+	if timeout <= 0 {
+		timeout = portainer.DefaultSnapshotTimeout
+	}
+
+	if shutdownCtx == nil {
+		shutdownCtx = context.Background()
+	}
+
 	return &Service{
 		dataStore:                 dataStore,
 		snapshotIntervalCh:        make(chan time.Duration),
 		snapshotIntervalInSeconds: interval,
+		concurrency:               concurrency,
+		timeout:                   timeout,
 		dockerSnapshotter:         dockerSnapshotter,
 		kubernetesSnapshotter:     kubernetesSnapshotter,
 		shutdownCtx:               shutdownCtx,
 		pendingActionsService:     pendingActionsService,
+		stats:                     newStatsRecorder(),
 	}, nil
 }
 
@@ -136,7 +160,32 @@ func SupportDirectSnapshot(endpoint *portainer.Endpoint) bool {
 
 // SnapshotEndpoint will create a snapshot of the environment(endpoint) based on the environment(endpoint) type.
 // If the snapshot is a success, it will be associated to the environment(endpoint).
+// The snapshot is cancelled when it takes longer than the configured timeout.
 func (service *Service) SnapshotEndpoint(endpoint *portainer.Endpoint) error {
+	ctx, cancel := context.WithTimeout(service.shutdownCtx, service.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := service.snapshotEndpoint(ctx, endpoint)
+	service.stats.record(endpoint.ID, start, time.Since(start), err)
+
+	if err != nil || !endpointsutils.IsEdgeEndpoint(endpoint) {
+		return err
+	}
+
+	// The rules of the dynamic Edge groups can rely on the facts of the new snapshot
+	return service.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		return edge.UpdateEndpointRelation(tx, endpoint)
+	})
+}
+
+// SnapshotStats returns the snapshot statistics of the environments(endpoints)
+// snapshotted since Portainer started
+func (service *Service) SnapshotStats() []portainer.SnapshotStats {
+	return service.stats.list()
+}
+
+func (service *Service) snapshotEndpoint(ctx context.Context, endpoint *portainer.Endpoint) error {
 	if endpoint.Type == portainer.AgentOnDockerEnvironment || endpoint.Type == portainer.AgentOnKubernetesEnvironment {
 		var err error
 		var tlsConfig *tls.Config
@@ -160,10 +209,10 @@ func (service *Service) SnapshotEndpoint(endpoint *portainer.Endpoint) error {
 	case portainer.AzureEnvironment:
 		return nil
 	case portainer.KubernetesLocalEnvironment, portainer.AgentOnKubernetesEnvironment, portainer.EdgeAgentOnKubernetesEnvironment:
-		return service.snapshotKubernetesEndpoint(endpoint)
+		return service.snapshotKubernetesEndpoint(ctx, endpoint)
 	}
 
-	return service.snapshotDockerEndpoint(endpoint)
+	return service.snapshotDockerEndpoint(ctx, endpoint)
 }
 
 func (service *Service) Create(snapshot portainer.Snapshot) error {
@@ -174,8 +223,8 @@ func (service *Service) FillSnapshotData(endpoint *portainer.Endpoint) error {
 	return FillSnapshotData(service.dataStore, endpoint)
 }
 
-func (service *Service) snapshotKubernetesEndpoint(endpoint *portainer.Endpoint) error {
-	kubernetesSnapshot, err := service.kubernetesSnapshotter.CreateSnapshot(endpoint)
+func (service *Service) snapshotKubernetesEndpoint(ctx context.Context, endpoint *portainer.Endpoint) error {
+	kubernetesSnapshot, err := service.kubernetesSnapshotter.CreateSnapshot(ctx, endpoint)
 	if err != nil {
 		return err
 	}
@@ -189,8 +238,8 @@ func (service *Service) snapshotKubernetesEndpoint(endpoint *portainer.Endpoint)
 	return nil
 }
 
-func (service *Service) snapshotDockerEndpoint(endpoint *portainer.Endpoint) error {
-	dockerSnapshot, err := service.dockerSnapshotter.CreateSnapshot(endpoint)
+func (service *Service) snapshotDockerEndpoint(ctx context.Context, endpoint *portainer.Endpoint) error {
+	dockerSnapshot, err := service.dockerSnapshotter.CreateSnapshot(ctx, endpoint)
 	if err != nil {
 		return err
 	}
@@ -243,39 +292,75 @@ func (service *Service) startSnapshotLoop() {
 
 			return
 		case interval := <-service.snapshotIntervalCh:
+			service.snapshotIntervalInSeconds = interval.Seconds()
 			ticker.Reset(interval)
 		}
 	}
 }
 
+// snapshotEndpoints snapshots every environment(endpoint) that supports direct snapshots.
+// The snapshots are spread over the jitter window derived from the snapshot interval
+// and run on a pool of workers so that a slow environment does not delay the others.
 func (service *Service) snapshotEndpoints() error {
 	endpoints, err := service.dataStore.Endpoint().Endpoints()
 	if err != nil {
 		return err
 	}
 
+	ids := make(map[portainer.EndpointID]struct{}, len(endpoints))
+	candidates := make([]portainer.Endpoint, 0, len(endpoints))
+
 	for _, endpoint := range endpoints {
+		ids[endpoint.ID] = struct{}{}
+
 		if !SupportDirectSnapshot(&endpoint) || endpoint.URL == "" {
 			continue
 		}
 
-		snapshotError := service.SnapshotEndpoint(&endpoint)
+		candidates = append(candidates, endpoint)
+	}
+
+	service.stats.retain(ids)
 
-		if err := service.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-			updateEndpointStatus(tx, &endpoint, snapshotError, service.pendingActionsService)
+	jobs := make(chan portainer.Endpoint)
 
-			return nil
-		}); err != nil {
-			log.Error().
-				Err(err).
-				Int("endpoint_id", int(endpoint.ID)).
-				Msg("unable to update environment status")
-		}
+	var wg sync.WaitGroup
+	for range min(service.concurrency, len(candidates)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for endpoint := range jobs {
+				service.snapshotAndUpdateEndpoint(&endpoint)
+			}
+		}()
 	}
 
+	jitter := time.Duration(service.snapshotIntervalInSeconds*float64(time.Second)) / jitterRatio
+	schedule(service.shutdownCtx, candidates, jitter, jobs)
+
+	close(jobs)
+	wg.Wait()
+
 	return nil
 }
 
+func (service *Service) snapshotAndUpdateEndpoint(endpoint *portainer.Endpoint) {
+	snapshotError := service.SnapshotEndpoint(endpoint)
+
+	if err := service.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		updateEndpointStatus(tx, endpoint, snapshotError, service.pendingActionsService)
+
+		return nil
+	}); err != nil {
+		log.Error().
+			Err(err).
+			Int("endpoint_id", int(endpoint.ID)).
+			Msg("unable to update environment status")
+	}
+}
+
 func updateEndpointStatus(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint, snapshotError error, pendingActionsService *pendingactions.PendingActionsService) {
 	latestEndpointReference, err := tx.Endpoint().Endpoint(endpoint.ID)
 	if latestEndpointReference == nil {
@@ -314,7 +399,12 @@ func updateEndpointStatus(tx dataservices.DataStoreTx, endpoint *portainer.Endpo
 }
 
 // FetchDockerID fetches info.Swarm.Cluster.ID if environment(endpoint) is swarm and info.ID otherwise
+// The raw data of the snapshot must be loaded, see FillSnapshotRawData
 func FetchDockerID(snapshot portainer.DockerSnapshot) (string, error) {
+	if snapshot.SnapshotRaw == nil {
+		return "", errors.New("snapshot is missing its raw data")
+	}
+
 	info := snapshot.SnapshotRaw.Info
 
 	if !snapshot.Swarm {
@@ -349,3 +439,22 @@ func FillSnapshotData(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint)
 
 	return nil
 }
+
+// FillSnapshotRawData loads the raw Docker data of the snapshot previously filled by FillSnapshotData.
+// It is kept apart as it can be large and is only needed by a few consumers.
+func FillSnapshotRawData(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint) error {
+	if len(endpoint.Snapshots) == 0 || endpoint.Snapshots[0].SnapshotRaw != nil {
+		return nil
+	}
+
+	raw, err := tx.Snapshot().ReadRaw(endpoint.ID)
+	if tx.IsErrObjectNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	endpoint.Snapshots[0].SnapshotRaw = raw
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package snapshot_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/snapshot"
+	"github.com/portainer/portainer/api/pendingactions"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDockerSnapshotter struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	slow    portainer.EndpointID
+}
+
+func (snapshotter *testDockerSnapshotter) CreateSnapshot(ctx context.Context, endpoint *portainer.Endpoint) (*portainer.DockerSnapshot, error) {
+	snapshotter.mu.Lock()
+	snapshotter.running++
+	snapshotter.peak = max(snapshotter.peak, snapshotter.running)
+	snapshotter.mu.Unlock()
+
+	defer func() {
+		snapshotter.mu.Lock()
+		snapshotter.running--
+		snapshotter.mu.Unlock()
+	}()
+
+	if endpoint.ID == snapshotter.slow {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	return &portainer.DockerSnapshot{Time: time.Now().Unix()}, nil
+}
+
+func TestSnapshotEndpoints(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{
+			ID:   portainer.EndpointID(i),
+			Name: "docker",
+			Type: portainer.DockerEnvironment,
+			URL:  "tcp://127.0.0.1:2375",
+		}))
+	}
+
+	// edge environments are not snapshotted directly
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{
+		ID:   7,
+		Name: "edge",
+		Type: portainer.EdgeAgentOnDockerEnvironment,
+		URL:  "tcp://127.0.0.1:2375",
+	}))
+
+	snapshotter := &testDockerSnapshotter{slow: 1}
+
+	service, err := snapshot.NewService("1s", 2, 200*time.Millisecond, store, snapshotter, nil, context.Background(), pendingactions.NewService(store, nil))
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, snapshot.SnapshotEndpoints(service))
+	require.Less(t, time.Since(start), time.Second, "an unreachable environment must not delay the others")
+
+	require.LessOrEqual(t, snapshotter.peak, 2)
+
+	stats := service.SnapshotStats()
+	require.Len(t, stats, 6)
+
+	for _, s := range stats {
+		if s.EndpointID == snapshotter.slow {
+			require.True(t, s.TimedOut)
+			require.Equal(t, 1, s.FailureCount)
+			require.Equal(t, 1, s.ConsecutiveFailures)
+
+			continue
+		}
+
+		require.False(t, s.TimedOut)
+		require.Empty(t, s.LastError)
+		require.Equal(t, 1, s.SuccessCount)
+		require.GreaterOrEqual(t, s.LastDuration, int64(20))
+	}
+
+	endpoint, err := store.Endpoint().Endpoint(snapshotter.slow)
+	require.NoError(t, err)
+	require.Equal(t, portainer.EndpointStatusDown, endpoint.Status)
+
+	_, err = store.Snapshot().Read(2)
+	require.NoError(t, err)
+}
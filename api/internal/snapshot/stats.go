@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+type endpointStats struct {
+	portainer.SnapshotStats
+	successDuration time.Duration
+}
+
+// statsRecorder keeps the snapshot statistics of each environment(endpoint) in memory
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats map[portainer.EndpointID]*endpointStats
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{
+		stats: make(map[portainer.EndpointID]*endpointStats),
+	}
+}
+
+func (recorder *statsRecorder) record(endpointID portainer.EndpointID, start time.Time, duration time.Duration, err error) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	s, ok := recorder.stats[endpointID]
+	if !ok {
+		s = &endpointStats{SnapshotStats: portainer.SnapshotStats{EndpointID: endpointID}}
+		recorder.stats[endpointID] = s
+	}
+
+	s.LastAttempt = start.Unix()
+	s.LastDuration = duration.Milliseconds()
+	s.TimedOut = errors.Is(err, context.DeadlineExceeded)
+	s.LastError = ""
+
+	if err != nil {
+		s.LastError = err.Error()
+		s.FailureCount++
+		s.ConsecutiveFailures++
+
+		return
+	}
+
+	s.SuccessCount++
+	s.ConsecutiveFailures = 0
+	s.successDuration += duration
+	s.AverageDuration = (s.successDuration / time.Duration(s.SuccessCount)).Milliseconds()
+}
+
+// retain drops the statistics of the environments(endpoints) that are not part of ids anymore
+func (recorder *statsRecorder) retain(ids map[portainer.EndpointID]struct{}) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for endpointID := range recorder.stats {
+		if _, ok := ids[endpointID]; !ok {
+			delete(recorder.stats, endpointID)
+		}
+	}
+}
+
+func (recorder *statsRecorder) list() []portainer.SnapshotStats {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	list := make([]portainer.SnapshotStats, 0, len(recorder.stats))
+	for _, s := range recorder.stats {
+		list = append(list, s.SnapshotStats)
+	}
+
+	slices.SortFunc(list, func(a, b portainer.SnapshotStats) int {
+		return int(a.EndpointID - b.EndpointID)
+	})
+
+	return list
+}
@@ -0,0 +1,345 @@
+package templatebundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	gittypes "github.com/portainer/portainer/api/git/types"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	// Version is the version of the bundle format
+	Version = 1
+	// ManifestFileName is the name of the manifest inside the bundle
+	ManifestFileName = "manifest.json"
+	// SignatureFileName is the name of the manifest signature inside the bundle
+	SignatureFileName = "manifest.sig"
+
+	templatesDirectory = "templates"
+)
+
+// MaxBundleSize is the maximum size of the files read from a bundle
+const MaxBundleSize = 32 << 20
+
+// Manifest describes the content of a bundle, it is signed by the exporting instance
+type Manifest struct {
+	Version    int        `json:"version"`
+	ExportedAt int64      `json:"exportedAt"`
+	PublicKey  string     `json:"publicKey"`
+	Templates  []Template `json:"templates"`
+}
+
+// Template represents an exported custom template
+type Template struct {
+	Title           string                                       `json:"title"`
+	Description     string                                       `json:"description"`
+	Note            string                                       `json:"note,omitempty"`
+	Logo            string                                       `json:"logo,omitempty"`
+	Platform        portainer.CustomTemplatePlatform             `json:"platform"`
+	Type            portainer.StackType                          `json:"type"`
+	EntryPoint      string                                       `json:"entryPoint"`
+	Variables       []portainer.CustomTemplateVariableDefinition `json:"variables,omitempty"`
+	GitConfig       *gittypes.RepoConfig                         `json:"gitConfig,omitempty"`
+	IsComposeFormat bool                                         `json:"isComposeFormat,omitempty"`
+	EdgeTemplate    bool                                         `json:"edgeTemplate,omitempty"`
+	Files           []File                                       `json:"files"`
+}
+
+// File represents a file of an exported custom template
+type File struct {
+	Path     string `json:"path"`
+	Checksum string `json:"sha256"`
+	Content  []byte `json:"-"`
+}
+
+// FromCustomTemplate returns the exported version of a custom template, the files are read
+// from its project path. The git credentials are never exported
+func FromCustomTemplate(customTemplate *portainer.CustomTemplate) (*Template, error) {
+	template := &Template{
+		Title:           customTemplate.Title,
+		Description:     customTemplate.Description,
+		Note:            customTemplate.Note,
+		Logo:            customTemplate.Logo,
+		Platform:        customTemplate.Platform,
+		Type:            customTemplate.Type,
+		EntryPoint:      customTemplate.EntryPoint,
+		Variables:       customTemplate.Variables,
+		IsComposeFormat: customTemplate.IsComposeFormat,
+		EdgeTemplate:    customTemplate.EdgeTemplate,
+	}
+
+	if customTemplate.GitConfig != nil {
+		gitConfig := *customTemplate.GitConfig
+		gitConfig.Authentication = nil
+		template.GitConfig = &gitConfig
+		template.EntryPoint = gitConfig.ConfigFilePath
+	}
+
+	err := filepath.WalkDir(customTemplate.ProjectPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(customTemplate.ProjectPath, p)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		template.Files = append(template.Files, newFile(filepath.ToSlash(rel), content))
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the files of the custom template %q: %w", customTemplate.Title, err)
+	}
+
+	return template, nil
+}
+
+// Write writes the bundle containing the templates to w, the manifest is signed with the
+// private key of the instance
+func Write(w io.Writer, privateKey, publicKey []byte, templates []Template) error {
+	key, err := x509.ParseECPrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("unable to parse the private key: %w", err)
+	}
+
+	manifest := Manifest{
+		Version:    Version,
+		ExportedAt: time.Now().Unix(),
+		PublicKey:  hex.EncodeToString(publicKey),
+		Templates:  templates,
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	if err := writeEntry(tw, ManifestFileName, data); err != nil {
+		return err
+	}
+
+	if err := writeEntry(tw, SignatureFileName, []byte(base64.StdEncoding.EncodeToString(signature))); err != nil {
+		return err
+	}
+
+	for i, template := range templates {
+		for _, file := range template.Files {
+			if err := writeEntry(tw, filePath(i, file.Path), file.Content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tw.Close()
+}
+
+// Read reads a bundle, verifies its signature against the trusted public key and the checksum of
+// every file. The content of the files is attached to the returned manifest
+func Read(r io.Reader, trustedPublicKey string) (*Manifest, error) {
+	if trustedPublicKey == "" {
+		return nil, errors.New("a trusted public key is required to read a bundle")
+	}
+
+	entries := map[string][]byte{}
+	size := 0
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		size += int(header.Size)
+		if header.Size > MaxBundleSize || size > MaxBundleSize {
+			return nil, errors.New("the bundle is too large")
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+
+		entries[path.Clean(header.Name)] = content
+	}
+
+	data, ok := entries[ManifestFileName]
+	if !ok {
+		return nil, errors.New("the bundle does not contain any manifest")
+	}
+
+	signature, ok := entries[SignatureFileName]
+	if !ok {
+		return nil, errors.New("the bundle is not signed")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+
+	if err := verify(trustedPublicKey, data, signature); err != nil {
+		return nil, err
+	}
+
+	if manifest.PublicKey != trustedPublicKey {
+		return nil, errors.New("the bundle was not signed by the trusted public key")
+	}
+
+	for i := range manifest.Templates {
+		template := &manifest.Templates[i]
+
+		for j := range template.Files {
+			file := &template.Files[j]
+
+			if !validPath(file.Path) {
+				return nil, fmt.Errorf("invalid file path %q in the template %q", file.Path, template.Title)
+			}
+
+			content, ok := entries[filePath(i, file.Path)]
+			if !ok {
+				return nil, fmt.Errorf("the file %q of the template %q is missing", file.Path, template.Title)
+			}
+
+			if checksum(content) != file.Checksum {
+				return nil, fmt.Errorf("the checksum of the file %q of the template %q does not match", file.Path, template.Title)
+			}
+
+			file.Content = content
+		}
+
+		if !template.hasFile(template.EntryPoint) {
+			return nil, fmt.Errorf("the entry point of the template %q is missing", template.Title)
+		}
+	}
+
+	return &manifest, nil
+}
+
+func (template *Template) hasFile(p string) bool {
+	for _, file := range template.Files {
+		if file.Path == path.Clean(filepath.ToSlash(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func verify(encodedPublicKey string, data, encodedSignature []byte) error {
+	publicKey, err := hex.DecodeString(encodedPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid trusted public key: %w", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid trusted public key: %w", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("unsupported trusted public key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedSignature)))
+	if err != nil {
+		return fmt.Errorf("invalid bundle signature: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(ecdsaKey, hash[:], signature) {
+		return errors.New("the bundle signature is invalid or was not made with the trusted public key")
+	}
+
+	return nil
+}
+
+func validPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+func filePath(index int, p string) string {
+	return path.Join(templatesDirectory, strconv.Itoa(index), p)
+}
+
+func newFile(p string, content []byte) File {
+	return File{Path: p, Checksum: checksum(content), Content: content}
+}
+
+func checksum(content []byte) string {
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:])
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, bytes.NewReader(content))
+
+	return err
+}
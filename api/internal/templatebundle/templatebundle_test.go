@@ -0,0 +1,175 @@
+package templatebundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+	gittypes "github.com/portainer/portainer/api/git/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyPair(t *testing.T) ([]byte, []byte) {
+	private, public, err := crypto.NewECDSAService("").GenerateKeyPair()
+	require.NoError(t, err)
+
+	return private, public
+}
+
+// newBundle returns a bundle containing the templates and the hex encoded public key that signed it
+func newBundle(t *testing.T, templates []Template) ([]byte, string) {
+	private, public := newKeyPair(t)
+
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, private, public, templates))
+
+	return b.Bytes(), hex.EncodeToString(public)
+}
+
+// rewrite applies fn to every entry of the bundle
+func rewrite(t *testing.T, bundle []byte, fn func(name string, content []byte) (string, []byte)) []byte {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	tr := tar.NewReader(bytes.NewReader(bundle))
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		name, content := fn(header.Name, content)
+		require.NoError(t, writeEntry(tw, name, content))
+	}
+
+	require.NoError(t, tw.Close())
+
+	return b.Bytes()
+}
+
+func TestFromCustomTemplate(t *testing.T) {
+	projectPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectPath, "docker-compose.yml"), []byte("services: {}"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(projectPath, ".git"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(projectPath, ".git", "HEAD"), []byte("ref"), 0600))
+
+	template, err := FromCustomTemplate(&portainer.CustomTemplate{
+		Title:       "nginx",
+		ProjectPath: projectPath,
+		GitConfig: &gittypes.RepoConfig{
+			URL:            "https://github.com/portainer/templates",
+			ConfigFilePath: "docker-compose.yml",
+			Authentication: &gittypes.GitAuthentication{Username: "user", Password: "secret"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Nil(t, template.GitConfig.Authentication)
+	require.Equal(t, "docker-compose.yml", template.EntryPoint)
+	require.Len(t, template.Files, 1)
+	require.Equal(t, "docker-compose.yml", template.Files[0].Path)
+}
+
+func TestWriteRead(t *testing.T) {
+	templates := []Template{
+		{
+			Title:      "nginx",
+			Type:       portainer.DockerComposeStack,
+			EntryPoint: "docker-compose.yml",
+			Files: []File{
+				newFile("docker-compose.yml", []byte("services: {}")),
+				newFile("config/nginx.conf", []byte("server {}")),
+			},
+		},
+	}
+
+	bundle, trustedPublicKey := newBundle(t, templates)
+
+	manifest, err := Read(bytes.NewReader(bundle), trustedPublicKey)
+	require.NoError(t, err)
+	require.Len(t, manifest.Templates, 1)
+	require.Equal(t, []byte("server {}"), manifest.Templates[0].Files[1].Content)
+
+	t.Run("tampered file", func(t *testing.T) {
+		tampered := rewrite(t, bundle, func(name string, content []byte) (string, []byte) {
+			if name == "templates/0/docker-compose.yml" {
+				return name, []byte("services: {evil: {}}")
+			}
+
+			return name, content
+		})
+
+		_, err := Read(bytes.NewReader(tampered), trustedPublicKey)
+		require.ErrorContains(t, err, "checksum")
+	})
+
+	t.Run("tampered manifest", func(t *testing.T) {
+		tampered := rewrite(t, bundle, func(name string, content []byte) (string, []byte) {
+			if name == ManifestFileName {
+				return name, bytes.Replace(content, []byte("nginx"), []byte("nginy"), 1)
+			}
+
+			return name, content
+		})
+
+		_, err := Read(bytes.NewReader(tampered), trustedPublicKey)
+		require.ErrorContains(t, err, "signature")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		unsigned := rewrite(t, bundle, func(name string, content []byte) (string, []byte) {
+			if name == SignatureFileName {
+				return "unused", content
+			}
+
+			return name, content
+		})
+
+		_, err := Read(bytes.NewReader(unsigned), trustedPublicKey)
+		require.ErrorContains(t, err, "not signed")
+	})
+
+	t.Run("no trusted public key", func(t *testing.T) {
+		_, err := Read(bytes.NewReader(bundle), "")
+		require.Error(t, err)
+	})
+
+	t.Run("untrusted public key", func(t *testing.T) {
+		_, public := newKeyPair(t)
+
+		_, err := Read(bytes.NewReader(bundle), hex.EncodeToString(public))
+		require.ErrorContains(t, err, "trusted public key")
+	})
+
+	t.Run("resigned with an untrusted key", func(t *testing.T) {
+		// a bundle re-signed by another key embeds that key in its manifest, it must not be trusted
+		resigned, _ := newBundle(t, templates)
+
+		_, err := Read(bytes.NewReader(resigned), trustedPublicKey)
+		require.ErrorContains(t, err, "trusted public key")
+	})
+}
+
+func TestReadRejectsInvalidPaths(t *testing.T) {
+	for _, p := range []string{"../docker-compose.yml", "/etc/passwd", "a/../../b"} {
+		bundle, trustedPublicKey := newBundle(t, []Template{{
+			Title:      "nginx",
+			EntryPoint: p,
+			Files:      []File{newFile(p, []byte("services: {}"))},
+		}})
+
+		_, err := Read(bytes.NewReader(bundle), trustedPublicKey)
+		require.Error(t, err, p)
+	}
+}
@@ -0,0 +1,208 @@
+package templatevariables
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/cbroglie/mustache"
+)
+
+// Value represents a value submitted for a custom template variable
+type Value struct {
+	// Name of the variable
+	Name string `json:"name" example:"MY_VAR"`
+	// Value of the variable
+	Value string `json:"value" example:"value"`
+}
+
+// ValidateDefinitions returns an error if a variable definition is inconsistent
+func ValidateDefinitions(definitions []portainer.CustomTemplateVariableDefinition) error {
+	names := map[string]bool{}
+
+	for _, definition := range definitions {
+		if definition.Name == "" {
+			return errors.New("variable name is required")
+		}
+
+		if definition.Label == "" {
+			return errors.New("variable label is required")
+		}
+
+		if names[definition.Name] {
+			return fmt.Errorf("variable %q is defined more than once", definition.Name)
+		}
+		names[definition.Name] = true
+
+		if err := validateDefinition(definition); err != nil {
+			return fmt.Errorf("invalid variable %q: %w", definition.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateDefinition(definition portainer.CustomTemplateVariableDefinition) error {
+	switch variableType(definition) {
+	case portainer.CustomTemplateVariableTypeString,
+		portainer.CustomTemplateVariableTypeSecret,
+		portainer.CustomTemplateVariableTypeMultiline,
+		portainer.CustomTemplateVariableTypeNumber,
+		portainer.CustomTemplateVariableTypeBoolean:
+	case portainer.CustomTemplateVariableTypeSelect:
+		if len(definition.Options) == 0 {
+			return errors.New("options are required for a select variable")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", definition.Type)
+	}
+
+	if definition.Pattern != "" {
+		if _, err := regexp.Compile(definition.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	if definition.Min != nil && definition.Max != nil && *definition.Min > *definition.Max {
+		return errors.New("min must be lower than or equal to max")
+	}
+
+	if definition.DefaultValue != "" {
+		if err := validateValue(definition, definition.DefaultValue); err != nil {
+			return fmt.Errorf("invalid default value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ResolveValues validates the submitted values against the definitions and returns the value
+// of every defined variable, falling back to the default value when none was submitted
+func ResolveValues(definitions []portainer.CustomTemplateVariableDefinition, values []Value) (map[string]string, error) {
+	submitted := map[string]string{}
+	for _, value := range values {
+		submitted[value.Name] = value.Value
+	}
+
+	resolved := map[string]string{}
+	for _, definition := range definitions {
+		value, ok := submitted[definition.Name]
+		if !ok || value == "" {
+			value = definition.DefaultValue
+		}
+
+		if value == "" {
+			if definition.Required {
+				return nil, fmt.Errorf("variable %q is required", displayName(definition))
+			}
+
+			resolved[definition.Name] = ""
+
+			continue
+		}
+
+		if err := validateValue(definition, value); err != nil {
+			return nil, fmt.Errorf("invalid value for variable %q: %w", displayName(definition), err)
+		}
+
+		resolved[definition.Name] = value
+	}
+
+	return resolved, nil
+}
+
+// Render validates the submitted values and renders the template content with them
+func Render(content string, definitions []portainer.CustomTemplateVariableDefinition, values []Value) (string, error) {
+	resolved, err := ResolveValues(definitions, values)
+	if err != nil {
+		return "", err
+	}
+
+	return mustache.RenderRaw(content, true, resolved)
+}
+
+func validateValue(definition portainer.CustomTemplateVariableDefinition, value string) error {
+	switch variableType(definition) {
+	case portainer.CustomTemplateVariableTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("value must be a number")
+		}
+
+		if definition.Min != nil && number < *definition.Min {
+			return fmt.Errorf("value must be greater than or equal to %s", formatFloat(*definition.Min))
+		}
+
+		if definition.Max != nil && number > *definition.Max {
+			return fmt.Errorf("value must be lower than or equal to %s", formatFloat(*definition.Max))
+		}
+
+		return nil
+	case portainer.CustomTemplateVariableTypeBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("value must be true or false")
+		}
+
+		return nil
+	case portainer.CustomTemplateVariableTypeSelect:
+		for _, option := range definition.Options {
+			if option.Value == value {
+				return nil
+			}
+		}
+
+		return errors.New("value must be one of the options")
+	}
+
+	if variableType(definition) != portainer.CustomTemplateVariableTypeMultiline && strings.ContainsAny(value, "\r\n") {
+		return errors.New("value must be a single line")
+	}
+
+	length := float64(utf8.RuneCountInString(value))
+
+	if definition.Min != nil && length < *definition.Min {
+		return fmt.Errorf("value must be at least %s characters long", formatFloat(*definition.Min))
+	}
+
+	if definition.Max != nil && length > *definition.Max {
+		return fmt.Errorf("value must be at most %s characters long", formatFloat(*definition.Max))
+	}
+
+	if definition.Pattern != "" {
+		re, err := regexp.Compile(definition.Pattern)
+		if err != nil {
+			return err
+		}
+
+		if !re.MatchString(value) {
+			return fmt.Errorf("value must match %s", definition.Pattern)
+		}
+	}
+
+	return nil
+}
+
+func variableType(definition portainer.CustomTemplateVariableDefinition) portainer.CustomTemplateVariableType {
+	if definition.Type == "" {
+		return portainer.CustomTemplateVariableTypeString
+	}
+
+	return definition.Type
+}
+
+func displayName(definition portainer.CustomTemplateVariableDefinition) string {
+	if definition.Label != "" {
+		return definition.Label
+	}
+
+	return definition.Name
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,142 @@
+package templatevariables
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestValidateDefinitions(t *testing.T) {
+	cases := []struct {
+		name        string
+		definitions []portainer.CustomTemplateVariableDefinition
+		valid       bool
+	}{
+		{
+			name:        "untyped variable",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a"}},
+			valid:       true,
+		},
+		{
+			name:        "missing label",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A"}},
+		},
+		{
+			name: "duplicated name",
+			definitions: []portainer.CustomTemplateVariableDefinition{
+				{Name: "A", Label: "a"},
+				{Name: "A", Label: "b"},
+			},
+		},
+		{
+			name:        "unknown type",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a", Type: "date"}},
+		},
+		{
+			name:        "select without options",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a", Type: portainer.CustomTemplateVariableTypeSelect}},
+		},
+		{
+			name:        "invalid pattern",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a", Pattern: "("}},
+		},
+		{
+			name:        "min greater than max",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a", Type: portainer.CustomTemplateVariableTypeNumber, Min: float(2), Max: float(1)}},
+		},
+		{
+			name:        "invalid default value",
+			definitions: []portainer.CustomTemplateVariableDefinition{{Name: "A", Label: "a", Type: portainer.CustomTemplateVariableTypeNumber, DefaultValue: "abc"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDefinitions(tc.definitions)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestResolveValues(t *testing.T) {
+	definitions := []portainer.CustomTemplateVariableDefinition{
+		{Name: "PORT", Label: "Port", Type: portainer.CustomTemplateVariableTypeNumber, Min: float(1), Max: float(65535), DefaultValue: "80"},
+		{Name: "DEBUG", Label: "Debug", Type: portainer.CustomTemplateVariableTypeBoolean},
+		{Name: "TIER", Label: "Tier", Type: portainer.CustomTemplateVariableTypeSelect, Options: []portainer.CustomTemplateVariableOption{{Label: "Gold", Value: "gold"}}},
+		{Name: "NAME", Label: "Name", Required: true, Pattern: "^[a-z]+$"},
+	}
+
+	cases := []struct {
+		name     string
+		values   []Value
+		expected map[string]string
+	}{
+		{
+			name:     "defaults",
+			values:   []Value{{Name: "NAME", Value: "web"}},
+			expected: map[string]string{"PORT": "80", "DEBUG": "", "TIER": "", "NAME": "web"},
+		},
+		{
+			name:   "missing required value",
+			values: []Value{{Name: "PORT", Value: "8080"}},
+		},
+		{
+			name:   "number out of range",
+			values: []Value{{Name: "NAME", Value: "web"}, {Name: "PORT", Value: "70000"}},
+		},
+		{
+			name:   "invalid boolean",
+			values: []Value{{Name: "NAME", Value: "web"}, {Name: "DEBUG", Value: "maybe"}},
+		},
+		{
+			name:   "unknown option",
+			values: []Value{{Name: "NAME", Value: "web"}, {Name: "TIER", Value: "silver"}},
+		},
+		{
+			name:   "pattern mismatch",
+			values: []Value{{Name: "NAME", Value: "Web"}},
+		},
+		{
+			name:   "multiple lines",
+			values: []Value{{Name: "NAME", Value: "web\nserver"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, err := ResolveValues(definitions, tc.values)
+			if tc.expected == nil {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, resolved)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	definitions := []portainer.CustomTemplateVariableDefinition{
+		{Name: "IMAGE", Label: "Image", DefaultValue: "nginx"},
+		{Name: "SECRET", Label: "Secret", Type: portainer.CustomTemplateVariableTypeSecret, Required: true},
+	}
+
+	rendered, err := Render("image: {{ IMAGE }}\npassword: {{ SECRET }}", definitions, []Value{{Name: "SECRET", Value: "<s3cr&t>"}})
+	require.NoError(t, err)
+	require.Equal(t, "image: nginx\npassword: <s3cr&t>", rendered)
+
+	_, err = Render("image: {{ IMAGE }}", definitions, nil)
+	require.Error(t, err)
+}
@@ -36,3 +36,27 @@ func (manager *composeStackManager) Down(ctx context.Context, stack *portainer.S
 func (manager *composeStackManager) Pull(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeOptions) error {
 	return nil
 }
+
+func (manager *composeStackManager) RestartServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeOptions) error {
+	return nil
+}
+
+func (manager *composeStackManager) StopServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeOptions) error {
+	return nil
+}
+
+func (manager *composeStackManager) RecreateServices(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceNames []string, options portainer.ComposeRecreateOptions) error {
+	return nil
+}
+
+func (manager *composeStackManager) ScaleService(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, serviceName string, replicas int, options portainer.ComposeOptions) error {
+	return nil
+}
+
+func (manager *composeStackManager) WaitForHealthy(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, options portainer.ComposeHealthOptions) ([]portainer.ComposeUnhealthyService, error) {
+	return nil, nil
+}
+
+func (manager *composeStackManager) Config(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]byte, error) {
+	return nil, nil
+}
@@ -31,6 +31,7 @@ type testDatastore struct {
 	tag                     dataservices.TagService
 	teamMembership          dataservices.TeamMembershipService
 	team                    dataservices.TeamService
+	templateSource          dataservices.TemplateSourceService
 	tunnelServer            dataservices.TunnelServerService
 	user                    dataservices.UserService
 	version                 dataservices.VersionService
@@ -39,7 +40,12 @@ type testDatastore struct {
 	connection              portainer.Connection
 }
 
-func (d *testDatastore) Backup(path string) (string, error)                  { return "", nil }
+func (d *testDatastore) Backup(path string) (string, error)                { return "", nil }
+func (d *testDatastore) RotateEncryptionKey(newKey []byte) (string, error) { return "", nil }
+func (d *testDatastore) Maintain(repair, compact bool) (*dataservices.IntegrityReport, error) {
+	return &dataservices.IntegrityReport{}, nil
+}
+
 func (d *testDatastore) Open() (bool, error)                                 { return false, nil }
 func (d *testDatastore) Init() error                                         { return nil }
 func (d *testDatastore) Close() error                                        { return nil }
@@ -78,6 +84,7 @@ func (d *testDatastore) Stack() dataservices.StackService                   { re
 func (d *testDatastore) Tag() dataservices.TagService                       { return d.tag }
 func (d *testDatastore) TeamMembership() dataservices.TeamMembershipService { return d.teamMembership }
 func (d *testDatastore) Team() dataservices.TeamService                     { return d.team }
+func (d *testDatastore) TemplateSource() dataservices.TemplateSourceService { return d.templateSource }
 func (d *testDatastore) TunnelServer() dataservices.TunnelServerService     { return d.tunnelServer }
 func (d *testDatastore) User() dataservices.UserService                     { return d.user }
 func (d *testDatastore) Version() dataservices.VersionService               { return d.version }
@@ -91,6 +98,10 @@ func (d *testDatastore) Connection() portainer.Connection {
 	return d.connection
 }
 
+func (d *testDatastore) OnCommit(fn func()) {
+	fn()
+}
+
 func (d *testDatastore) IsErrObjectNotFound(e error) bool {
 	return false
 }
@@ -419,6 +430,28 @@ func (s *stubStacksService) RefreshableStacks() ([]portainer.Stack, error) {
 	return result, nil
 }
 
+func (s *stubStacksService) ImageWatchedStacks() ([]portainer.Stack, error) {
+	result := make([]portainer.Stack, 0)
+
+	for _, stack := range s.stacks {
+		if stack.ImageWatch != nil {
+			result = append(result, stack)
+		}
+	}
+	return result, nil
+}
+
+func (s *stubStacksService) DriftCheckedStacks() ([]portainer.Stack, error) {
+	result := make([]portainer.Stack, 0)
+
+	for _, stack := range s.stacks {
+		if stack.DriftCheck != nil {
+			result = append(result, stack)
+		}
+	}
+	return result, nil
+}
+
 func (s *stubStacksService) StackByName(name string) (*portainer.Stack, error) {
 	for _, stack := range s.stacks {
 		if stack.Name == name {
@@ -35,6 +35,9 @@ type (
 		instanceID           string
 		endpointProxyClients *cache.Cache
 		AddrHTTPS            string
+		resourceCaches       map[portainer.EndpointID]*ResourceCache
+		resourceCachesMu     sync.Mutex
+		idleCheckOnce        sync.Once
 	}
 
 	// KubeClient represent a service used to execute Kubernetes operations
@@ -44,6 +47,7 @@ type (
 		mu                 sync.Mutex
 		IsKubeAdmin        bool
 		NonAdminNamespaces []string
+		resourceCache      func() *ResourceCache
 	}
 )
 
@@ -71,6 +75,7 @@ func NewClientFactory(signatureService portainer.DigitalSignatureService, revers
 		instanceID:           instanceID,
 		endpointProxyClients: cache.New(timeout, timeout),
 		AddrHTTPS:            addrHTTPS,
+		resourceCaches:       make(map[portainer.EndpointID]*ResourceCache),
 	}, nil
 }
 
@@ -84,9 +89,10 @@ func (factory *ClientFactory) ClearClientCache() {
 	factory.endpointProxyClients.Flush()
 }
 
-// Remove the cached kube client so a new one can be created
+// Remove the cached kube client so a new one can be created, the resource cache of the environment is stopped as well
 func (factory *ClientFactory) RemoveKubeClient(endpointID portainer.EndpointID) {
 	factory.endpointProxyClients.Delete(strconv.Itoa(int(endpointID)))
+	factory.StopResourceCache(endpointID)
 }
 
 // GetPrivilegedKubeClient checks if an existing client is already registered for the environment(endpoint) and returns it if one is found.
@@ -158,10 +164,14 @@ func (factory *ClientFactory) createCachedPrivilegedKubeClient(endpoint *portain
 		return nil, err
 	}
 
-	return &KubeClient{
+	kcl := &KubeClient{
 		cli:        cli,
 		instanceID: factory.instanceID,
-	}, nil
+	}
+
+	factory.UseResourceCache(kcl, endpoint)
+
+	return kcl, nil
 }
 
 // CreateClient returns a pointer to a new Clientset instance.
@@ -396,6 +396,16 @@ func (kcl *KubeClient) buildNonAdminNamespacesMap() map[string]struct{} {
 	return nonAdminNamespaceSet
 }
 
+// restrictedNamespaces returns the set of namespaces a non-admin user can access,
+// nil is returned when the client is not restricted to some namespaces.
+func (kcl *KubeClient) restrictedNamespaces() map[string]struct{} {
+	if kcl.IsKubeAdmin || kcl.NonAdminNamespaces == nil {
+		return nil
+	}
+
+	return kcl.buildNonAdminNamespacesMap()
+}
+
 // ConvertNamespaceMapToSlice converts the namespace map to a slice of namespaces.
 // this is used to for the API response.
 func (kcl *KubeClient) ConvertNamespaceMapToSlice(namespaces map[string]portainer.K8sNamespaceInfo) []portainer.K8sNamespaceInfo {
@@ -184,7 +184,17 @@ func (kcl *KubeClient) fetchAllApplicationsListResources(namespace string, podLi
 }
 
 // fetchResourcesWithOwnerReferences fetches pods and other resources based on owner references
+// The resources are read from the resource cache of the environment once it is synced.
 func (kcl *KubeClient) fetchResourcesWithOwnerReferences(namespace string, podListOptions metav1.ListOptions, includeStatefulSets, includeDaemonSets bool) ([]corev1.Pod, []appsv1.ReplicaSet, []appsv1.Deployment, []appsv1.StatefulSet, []appsv1.DaemonSet, []corev1.Service, []autoscalingv2.HorizontalPodAutoscaler, error) {
+	if kcl.resourceCache != nil {
+		if resourceCache := kcl.resourceCache(); resourceCache != nil && resourceCache.Synced() {
+			pods, replicaSets, deployments, statefulSets, daemonSets, services, hpas, err := resourceCache.fetchResourcesWithOwnerReferences(namespace, podListOptions, includeStatefulSets, includeDaemonSets, kcl.restrictedNamespaces())
+			if !errors.Is(err, errUnsupportedSelector) {
+				return pods, replicaSets, deployments, statefulSets, daemonSets, services, hpas, err
+			}
+		}
+	}
+
 	pods, err := kcl.cli.CoreV1().Pods(namespace).List(context.Background(), podListOptions)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
@@ -0,0 +1,394 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resourceCacheIdleTimeout   = 10 * time.Minute
+	resourceCacheCheckInterval = time.Minute
+)
+
+var errUnsupportedSelector = errors.New("the selector is not supported by the resource cache")
+
+// podFieldSelectors are the pod fields that can be used in the field selector of a cached listing.
+var podFieldSelectors = map[string]bool{
+	"metadata.name":      true,
+	"metadata.namespace": true,
+	"spec.nodeName":      true,
+	"status.phase":       true,
+}
+
+type (
+	// ResourceCache holds the shared informers of an environment that back the
+	// listings of the workloads and their related resources.
+	ResourceCache struct {
+		endpointID  portainer.EndpointID
+		factory     informers.SharedInformerFactory
+		informers   map[string]cache.SharedIndexInformer
+		stopCh      chan struct{}
+		stopOnce    sync.Once
+		startedAt   time.Time
+		syncedAt    atomic.Pointer[time.Time]
+		lastUsed    atomic.Int64
+		errorsMu    sync.Mutex
+		watchErrors map[string]string
+	}
+
+	// ResourceCacheStatus represents the sync status of the resource cache of an environment
+	ResourceCacheStatus struct {
+		EndpointID portainer.EndpointID `json:"EndpointId" example:"1"`
+		// Running is false when the informers are not started, the listings are then made against the API server
+		Running bool `json:"Running" example:"true"`
+		// Synced is true once all the informers completed their initial listing
+		Synced    bool       `json:"Synced" example:"true"`
+		StartedAt *time.Time `json:"StartedAt,omitempty"`
+		SyncedAt  *time.Time `json:"SyncedAt,omitempty"`
+		LastUsed  *time.Time `json:"LastUsed,omitempty"`
+		// Resources reports the sync status of each cached resource
+		Resources map[string]bool `json:"Resources,omitempty"`
+		// Errors holds the last watch error of each resource, if any
+		Errors map[string]string `json:"Errors,omitempty"`
+	}
+)
+
+func newResourceCache(endpointID portainer.EndpointID, cli kubernetes.Interface) *ResourceCache {
+	factory := informers.NewSharedInformerFactoryWithOptions(cli, 0, informers.WithTransform(stripManagedFields))
+
+	c := &ResourceCache{
+		endpointID: endpointID,
+		factory:    factory,
+		informers: map[string]cache.SharedIndexInformer{
+			"pods":                     factory.Core().V1().Pods().Informer(),
+			"services":                 factory.Core().V1().Services().Informer(),
+			"replicasets":              factory.Apps().V1().ReplicaSets().Informer(),
+			"deployments":              factory.Apps().V1().Deployments().Informer(),
+			"statefulsets":             factory.Apps().V1().StatefulSets().Informer(),
+			"daemonsets":               factory.Apps().V1().DaemonSets().Informer(),
+			"horizontalpodautoscalers": factory.Autoscaling().V2().HorizontalPodAutoscalers().Informer(),
+		},
+		stopCh:      make(chan struct{}),
+		watchErrors: map[string]string{},
+	}
+
+	for name, informer := range c.informers {
+		_ = informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			c.errorsMu.Lock()
+			c.watchErrors[name] = err.Error()
+			c.errorsMu.Unlock()
+
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+	}
+
+	return c
+}
+
+// start starts the informers and waits for their initial sync in the background.
+func (c *ResourceCache) start() {
+	c.startedAt = time.Now()
+	c.touch()
+
+	c.factory.Start(c.stopCh)
+
+	go func() {
+		hasSynced := make([]cache.InformerSynced, 0, len(c.informers))
+		for _, informer := range c.informers {
+			hasSynced = append(hasSynced, informer.HasSynced)
+		}
+
+		if !cache.WaitForCacheSync(c.stopCh, hasSynced...) {
+			return
+		}
+
+		now := time.Now()
+		c.syncedAt.Store(&now)
+
+		log.Debug().
+			Int("environment_id", int(c.endpointID)).
+			Dur("duration", now.Sub(c.startedAt)).
+			Msg("the Kubernetes resource cache is synced")
+	}()
+}
+
+func (c *ResourceCache) stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+		c.factory.Shutdown()
+	})
+}
+
+func (c *ResourceCache) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (c *ResourceCache) idleSince() time.Time {
+	return time.Unix(0, c.lastUsed.Load())
+}
+
+// Synced returns true once the informers completed their initial listing.
+func (c *ResourceCache) Synced() bool {
+	return c.syncedAt.Load() != nil
+}
+
+// Status returns the sync status of the cache.
+func (c *ResourceCache) Status() ResourceCacheStatus {
+	startedAt := c.startedAt
+	lastUsed := c.idleSince()
+
+	status := ResourceCacheStatus{
+		EndpointID: c.endpointID,
+		Running:    true,
+		Synced:     c.Synced(),
+		StartedAt:  &startedAt,
+		SyncedAt:   c.syncedAt.Load(),
+		LastUsed:   &lastUsed,
+		Resources:  make(map[string]bool, len(c.informers)),
+	}
+
+	for name, informer := range c.informers {
+		status.Resources[name] = informer.HasSynced()
+	}
+
+	c.errorsMu.Lock()
+	defer c.errorsMu.Unlock()
+
+	if len(c.watchErrors) > 0 {
+		status.Errors = make(map[string]string, len(c.watchErrors))
+		for name, err := range c.watchErrors {
+			status.Errors[name] = err
+		}
+	}
+
+	return status
+}
+
+// fetchResourcesWithOwnerReferences is the cached counterpart of KubeClient.fetchResourcesWithOwnerReferences.
+// The resources outside of the allowed namespaces are left out unless allowedNamespaces is nil.
+func (c *ResourceCache) fetchResourcesWithOwnerReferences(namespace string, podListOptions metav1.ListOptions, includeStatefulSets, includeDaemonSets bool, allowedNamespaces map[string]struct{}) ([]corev1.Pod, []appsv1.ReplicaSet, []appsv1.Deployment, []appsv1.StatefulSet, []appsv1.DaemonSet, []corev1.Service, []autoscalingv2.HorizontalPodAutoscaler, error) {
+	c.touch()
+
+	labelSelector, err := labels.Parse(podListOptions.LabelSelector)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("%w: %w", errUnsupportedSelector, err)
+	}
+
+	fieldSelector, err := fields.ParseSelector(podListOptions.FieldSelector)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("%w: %w", errUnsupportedSelector, err)
+	}
+
+	for _, requirement := range fieldSelector.Requirements() {
+		if !podFieldSelectors[requirement.Field] {
+			return nil, nil, nil, nil, nil, nil, nil, errUnsupportedSelector
+		}
+	}
+
+	allowed := func(obj metav1.Object) bool {
+		if allowedNamespaces == nil {
+			return true
+		}
+
+		_, ok := allowedNamespaces[obj.GetNamespace()]
+
+		return ok
+	}
+
+	pods, err := listCached[corev1.Pod](c.informers["pods"], namespace, labelSelector, func(pod *corev1.Pod) bool {
+		return allowed(pod) && fieldSelector.Matches(fields.Set{
+			"metadata.name":      pod.Name,
+			"metadata.namespace": pod.Namespace,
+			"spec.nodeName":      pod.Spec.NodeName,
+			"status.phase":       string(pod.Status.Phase),
+		})
+	})
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	podList := &corev1.PodList{Items: pods}
+
+	replicaSets := []appsv1.ReplicaSet{}
+	deployments := []appsv1.Deployment{}
+	if containsReplicaSetOwnerReference(podList) {
+		if replicaSets, err = listCached(c.informers["replicasets"], namespace, labels.Everything(), func(rs *appsv1.ReplicaSet) bool { return allowed(rs) }); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+
+		if deployments, err = listCached(c.informers["deployments"], namespace, labels.Everything(), func(d *appsv1.Deployment) bool { return allowed(d) }); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+	}
+
+	statefulSets := []appsv1.StatefulSet{}
+	if includeStatefulSets && containsStatefulSetOwnerReference(podList) {
+		if statefulSets, err = listCached(c.informers["statefulsets"], namespace, labels.Everything(), func(s *appsv1.StatefulSet) bool { return allowed(s) }); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+	}
+
+	daemonSets := []appsv1.DaemonSet{}
+	if includeDaemonSets && containsDaemonSetOwnerReference(podList) {
+		if daemonSets, err = listCached(c.informers["daemonsets"], namespace, labels.Everything(), func(d *appsv1.DaemonSet) bool { return allowed(d) }); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+	}
+
+	services, err := listCached(c.informers["services"], namespace, labels.Everything(), func(s *corev1.Service) bool { return allowed(s) })
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	hpas, err := listCached(c.informers["horizontalpodautoscalers"], namespace, labels.Everything(), func(h *autoscalingv2.HorizontalPodAutoscaler) bool { return allowed(h) })
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	return pods, replicaSets, deployments, statefulSets, daemonSets, services, hpas, nil
+}
+
+// listCached returns shallow copies of the cached objects, they must not be modified.
+func listCached[T any](informer cache.SharedIndexInformer, namespace string, selector labels.Selector, keep func(*T) bool) ([]T, error) {
+	items := []T{}
+	err := cache.ListAllByNamespace(informer.GetIndexer(), namespace, selector, func(obj any) {
+		if item, ok := obj.(*T); ok && keep(item) {
+			items = append(items, *item)
+		}
+	})
+
+	return items, err
+}
+
+// stripManagedFields drops the managed fields of the cached objects as they
+// account for a large part of their size and are never used.
+func stripManagedFields(obj any) (any, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+
+	return obj, nil
+}
+
+// supportsResourceCache returns true for the environments whose informers
+// can keep a long lived connection to the API server. Edge environments are
+// left out as their tunnel is closed when inactive.
+func supportsResourceCache(endpoint *portainer.Endpoint) bool {
+	return endpoint.Type == portainer.KubernetesLocalEnvironment || endpoint.Type == portainer.AgentOnKubernetesEnvironment
+}
+
+// UseResourceCache backs the workload listings of the client with the resource
+// cache of the environment. The informers are only started by the first listing.
+func (factory *ClientFactory) UseResourceCache(kcl *KubeClient, endpoint *portainer.Endpoint) {
+	if !supportsResourceCache(endpoint) {
+		return
+	}
+
+	environment := *endpoint
+	kcl.resourceCache = func() *ResourceCache {
+		return factory.GetResourceCache(&environment)
+	}
+}
+
+// GetResourceCache returns the resource cache of an environment and starts its
+// informers when they are not running. Nil is returned when the environment
+// does not support it or when the informers cannot be created.
+func (factory *ClientFactory) GetResourceCache(endpoint *portainer.Endpoint) *ResourceCache {
+	if !supportsResourceCache(endpoint) {
+		return nil
+	}
+
+	factory.resourceCachesMu.Lock()
+	defer factory.resourceCachesMu.Unlock()
+
+	if c, ok := factory.resourceCaches[endpoint.ID]; ok {
+		return c
+	}
+
+	cli, err := factory.CreateClient(endpoint)
+	if err != nil {
+		log.Warn().Err(err).Int("environment_id", int(endpoint.ID)).Msg("unable to create the Kubernetes resource cache")
+
+		return nil
+	}
+
+	c := newResourceCache(endpoint.ID, cli)
+	c.start()
+
+	if factory.resourceCaches == nil {
+		factory.resourceCaches = make(map[portainer.EndpointID]*ResourceCache)
+	}
+
+	factory.resourceCaches[endpoint.ID] = c
+
+	factory.idleCheckOnce.Do(func() {
+		go factory.watchIdleResourceCaches()
+	})
+
+	return c
+}
+
+// GetResourceCacheStatus returns the status of the resource cache of an environment.
+func (factory *ClientFactory) GetResourceCacheStatus(endpointID portainer.EndpointID) ResourceCacheStatus {
+	factory.resourceCachesMu.Lock()
+	c, ok := factory.resourceCaches[endpointID]
+	factory.resourceCachesMu.Unlock()
+
+	if !ok {
+		return ResourceCacheStatus{EndpointID: endpointID}
+	}
+
+	return c.Status()
+}
+
+// StopResourceCache stops the informers of an environment, if any.
+func (factory *ClientFactory) StopResourceCache(endpointID portainer.EndpointID) {
+	factory.resourceCachesMu.Lock()
+	defer factory.resourceCachesMu.Unlock()
+
+	if c, ok := factory.resourceCaches[endpointID]; ok {
+		c.stop()
+		delete(factory.resourceCaches, endpointID)
+	}
+}
+
+func (factory *ClientFactory) watchIdleResourceCaches() {
+	ticker := time.NewTicker(resourceCacheCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		factory.stopIdleResourceCaches(time.Now().Add(-resourceCacheIdleTimeout))
+	}
+}
+
+// stopIdleResourceCaches stops the caches that were not used since the given time.
+func (factory *ClientFactory) stopIdleResourceCaches(since time.Time) {
+	factory.resourceCachesMu.Lock()
+	defer factory.resourceCachesMu.Unlock()
+
+	for endpointID, c := range factory.resourceCaches {
+		if c.idleSince().Before(since) {
+			log.Debug().Int("environment_id", int(endpointID)).Msg("stopping the idle Kubernetes resource cache")
+
+			c.stop()
+			delete(factory.resourceCaches, endpointID)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newOwnedPod(namespace, name, nodeName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "web-rs", UID: types.UID("rs-" + namespace)},
+			},
+		},
+		Spec: corev1.PodSpec{NodeName: nodeName},
+	}
+}
+
+func podNames(pods []corev1.Pod) []string {
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+
+	return names
+}
+
+func TestResourceCache(t *testing.T) {
+	cli := kfake.NewSimpleClientset(
+		newOwnedPod("a", "web-1", "node-1"),
+		newOwnedPod("b", "web-2", "node-2"),
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-rs", Namespace: "a"}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-rs", Namespace: "b"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "b"}},
+	)
+
+	resourceCache := newResourceCache(1, cli)
+	resourceCache.start()
+	defer resourceCache.stop()
+
+	require.Eventually(t, resourceCache.Synced, 5*time.Second, 10*time.Millisecond)
+
+	status := resourceCache.Status()
+	require.True(t, status.Running)
+	require.True(t, status.Synced)
+	require.Len(t, status.Resources, 7)
+
+	admin := &KubeClient{cli: cli, IsKubeAdmin: true, resourceCache: func() *ResourceCache { return resourceCache }}
+	pods, replicaSets, deployments, _, _, services, _, err := admin.fetchAllApplicationsListResources("", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a/web-1", "b/web-2"}, podNames(pods))
+	require.Len(t, replicaSets, 2)
+	require.Len(t, deployments, 1)
+	require.Len(t, services, 1)
+
+	pods, _, _, _, _, _, _, err = admin.fetchAllApplicationsListResources("", metav1.ListOptions{FieldSelector: "spec.nodeName=node-2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b/web-2"}, podNames(pods))
+
+	pods, _, _, _, _, _, _, err = admin.fetchAllApplicationsListResources("b", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b/web-2"}, podNames(pods))
+
+	restricted := &KubeClient{cli: cli, NonAdminNamespaces: []string{"a"}, resourceCache: func() *ResourceCache { return resourceCache }}
+	pods, replicaSets, deployments, _, _, services, _, err = restricted.fetchAllApplicationsListResources("", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a/web-1"}, podNames(pods))
+	require.Len(t, replicaSets, 1)
+	require.Len(t, deployments, 1)
+	require.Empty(t, services, "the resources outside of the allowed namespaces must be left out")
+
+	_, err = cli.CoreV1().Pods("a").Create(context.Background(), newOwnedPod("a", "web-3", "node-1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		pods, _, _, _, _, _, _, err := restricted.fetchAllApplicationsListResources("", metav1.ListOptions{})
+
+		return err == nil && len(pods) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, _, _, _, _, _, _, err = resourceCache.fetchResourcesWithOwnerReferences("", metav1.ListOptions{FieldSelector: "spec.restartPolicy=Always"}, true, true, nil)
+	require.ErrorIs(t, err, errUnsupportedSelector)
+}
+
+func TestStopIdleResourceCaches(t *testing.T) {
+	resourceCache := newResourceCache(1, kfake.NewSimpleClientset())
+	resourceCache.start()
+
+	factory := &ClientFactory{resourceCaches: map[portainer.EndpointID]*ResourceCache{1: resourceCache}}
+
+	factory.stopIdleResourceCaches(time.Now().Add(-time.Hour))
+	require.True(t, factory.GetResourceCacheStatus(1).Running, "a recently used cache must be kept")
+
+	factory.stopIdleResourceCaches(time.Now().Add(time.Hour))
+	require.False(t, factory.GetResourceCacheStatus(1).Running)
+
+	select {
+	case <-resourceCache.stopCh:
+	default:
+		t.Fatal("the informers of an idle cache must be stopped")
+	}
+}
@@ -0,0 +1,245 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// AnnotationPruneProtection opts an object out of the pruning of its stack when set to "true"
+const AnnotationPruneProtection = "io.portainer.kubernetes.application.prune-protection"
+
+// kubernetesObject holds the fields identifying a Kubernetes object
+type kubernetesObject struct {
+	APIVersion string `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string `yaml:"kind" json:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name" json:"name"`
+		Namespace   string            `yaml:"namespace" json:"namespace"`
+		Labels      map[string]string `yaml:"labels" json:"labels"`
+		Annotations map[string]string `yaml:"annotations" json:"annotations"`
+	} `yaml:"metadata" json:"metadata"`
+	Items []kubernetesObject `yaml:"items" json:"items"`
+}
+
+// GetResources returns the objects declared by a manifest. The items of the lists are returned instead of the lists,
+// the objects without a name, such as the ones using generateName, are ignored
+func GetResources(manifestYaml []byte) ([]portainer.KubernetesStackResource, error) {
+	var resources []portainer.KubernetesStackResource
+
+	decoder := yaml.NewDecoder(bytes.NewReader(manifestYaml))
+	for {
+		var object kubernetesObject
+		if err := decoder.Decode(&object); errors.Is(err, io.EOF) {
+			return resources, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal yaml manifest")
+		}
+
+		resources = appendResources(resources, object)
+	}
+}
+
+func appendResources(resources []portainer.KubernetesStackResource, object kubernetesObject) []portainer.KubernetesStackResource {
+	if strings.HasSuffix(object.Kind, "List") {
+		for _, item := range object.Items {
+			resources = appendResources(resources, item)
+		}
+
+		return resources
+	}
+
+	if object.Kind == "" || object.Metadata.Name == "" {
+		return resources
+	}
+
+	return append(resources, portainer.KubernetesStackResource{
+		APIVersion: object.APIVersion,
+		Kind:       object.Kind,
+		Namespace:  object.Metadata.Namespace,
+		Name:       object.Metadata.Name,
+	})
+}
+
+// clusterScopedKinds are the built-in kinds whose objects do not belong to a namespace
+var clusterScopedKinds = map[string]bool{
+	"APIService":                       true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"CertificateSigningRequest":        true,
+	"ClusterRole":                      true,
+	"ClusterRoleBinding":               true,
+	"CustomResourceDefinition":         true,
+	"FlowSchema":                       true,
+	"IngressClass":                     true,
+	"MutatingWebhookConfiguration":     true,
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"PriorityClass":                    true,
+	"PriorityLevelConfiguration":       true,
+	"RuntimeClass":                     true,
+	"StorageClass":                     true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"ValidatingWebhookConfiguration":   true,
+	"VolumeAttachment":                 true,
+}
+
+// WithDefaultNamespace returns the resources with the given namespace set on the namespaced objects
+// which do not declare one, as they are created in the namespace the manifests are applied to
+func WithDefaultNamespace(resources []portainer.KubernetesStackResource, namespace string) []portainer.KubernetesStackResource {
+	if namespace == "" {
+		return resources
+	}
+
+	normalized := make([]portainer.KubernetesStackResource, 0, len(resources))
+	for _, resource := range resources {
+		if resource.Namespace == "" && !clusterScopedKinds[resource.Kind] {
+			resource.Namespace = namespace
+		}
+
+		normalized = append(normalized, resource)
+	}
+
+	return normalized
+}
+
+// StaleResources returns the previous resources which are no longer part of the current ones.
+// The API version of an object can change between two deployments, only its group is compared
+func StaleResources(previous, current []portainer.KubernetesStackResource) []portainer.KubernetesStackResource {
+	currentKeys := make(map[string]bool, len(current))
+	for _, resource := range current {
+		currentKeys[resourceKey(resource)] = true
+	}
+
+	var stale []portainer.KubernetesStackResource
+	for _, resource := range previous {
+		if !currentKeys[resourceKey(resource)] {
+			currentKeys[resourceKey(resource)] = true
+			stale = append(stale, resource)
+		}
+	}
+
+	return stale
+}
+
+func resourceKey(resource portainer.KubernetesStackResource) string {
+	return strings.Join([]string{resourceGroup(resource), resource.Kind, resource.Namespace, resource.Name}, "/")
+}
+
+func resourceGroup(resource portainer.KubernetesStackResource) string {
+	group, _, found := strings.Cut(resource.APIVersion, "/")
+	if !found {
+		// The core group has no name
+		return ""
+	}
+
+	return group
+}
+
+// GroupResourcesByKind splits the resources by group and kind, in the order of their first occurrence
+func GroupResourcesByKind(resources []portainer.KubernetesStackResource) [][]portainer.KubernetesStackResource {
+	var groups [][]portainer.KubernetesStackResource
+	indexes := map[string]int{}
+
+	for _, resource := range resources {
+		key := resourceGroup(resource) + "/" + resource.Kind
+
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], resource)
+	}
+
+	return groups
+}
+
+// IsKindNotServedError returns true when kubectl failed because the kind of the objects is no longer
+// served by the cluster, such as the kinds of a removed custom resource definition
+func IsKindNotServedError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+
+	return strings.Contains(msg, "no matches for kind") || strings.Contains(msg, "the server doesn't have a resource type")
+}
+
+// ResourcesManifest returns a manifest identifying the resources, to be used to get or delete them
+func ResourcesManifest(resources []portainer.KubernetesStackResource) ([]byte, error) {
+	docs := make([][]byte, 0, len(resources))
+
+	for _, resource := range resources {
+		metadata := map[string]string{"name": resource.Name}
+		if resource.Namespace != "" {
+			metadata["namespace"] = resource.Namespace
+		}
+
+		doc, err := yaml.Marshal(map[string]any{
+			"apiVersion": resource.APIVersion,
+			"kind":       resource.Kind,
+			"metadata":   metadata,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal yaml manifest")
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// PrunableResources returns the objects, as output in JSON by kubectl get, which are still labelled as part of
+// the stack and are not protected by the prune protection annotation
+func PrunableResources(objectsJSON []byte, stackID portainer.StackID) ([]portainer.KubernetesStackResource, error) {
+	var prunable []portainer.KubernetesStackResource
+
+	// kubectl outputs nothing when none of the objects exist, a single object or a list of objects otherwise
+	decoder := json.NewDecoder(bytes.NewReader(objectsJSON))
+	for {
+		var object kubernetesObject
+		if err := decoder.Decode(&object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal the objects")
+		}
+
+		objects := []kubernetesObject{object}
+		if strings.HasSuffix(object.Kind, "List") {
+			objects = object.Items
+		}
+
+		for _, o := range objects {
+			if o.Metadata.Labels[labelPortainerAppStackID] != strconv.Itoa(int(stackID)) {
+				continue
+			}
+
+			if protected, _ := strconv.ParseBool(o.Metadata.Annotations[AnnotationPruneProtection]); protected {
+				continue
+			}
+
+			prunable = append(prunable, portainer.KubernetesStackResource{
+				APIVersion: o.APIVersion,
+				Kind:       o.Kind,
+				Namespace:  o.Metadata.Namespace,
+				Name:       o.Metadata.Name,
+			})
+		}
+	}
+
+	return prunable, nil
+}
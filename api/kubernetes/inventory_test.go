@@ -0,0 +1,146 @@
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetResources(t *testing.T) {
+	resources, err := GetResources([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    io.portainer.kubernetes.application.stackid: 12
+---
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: apps
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      generateName: config-
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+`))
+	require.NoError(t, err)
+
+	require.Equal(t, []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		{APIVersion: "v1", Kind: "Service", Namespace: "apps", Name: "web"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "apps"},
+	}, resources)
+
+	manifest, err := ResourcesManifest(resources)
+	require.NoError(t, err)
+
+	roundTrip, err := GetResources(manifest)
+	require.NoError(t, err)
+	require.Equal(t, resources, roundTrip)
+}
+
+func TestStaleResources(t *testing.T) {
+	previous := []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		{APIVersion: "v1", Kind: "Service", Name: "web"},
+		{APIVersion: "autoscaling/v1", Kind: "HorizontalPodAutoscaler", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "apps", Name: "web"},
+	}
+
+	current := []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "other", Name: "web"},
+	}
+
+	require.Equal(t, []portainer.KubernetesStackResource{
+		{APIVersion: "v1", Kind: "Service", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "apps", Name: "web"},
+	}, StaleResources(previous, current))
+
+	require.Empty(t, StaleResources(nil, current))
+	require.Empty(t, StaleResources(current, current))
+}
+
+func TestWithDefaultNamespace(t *testing.T) {
+	resources := []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "other", Name: "web"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "apps"},
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding", Name: "web"},
+	}
+
+	require.Equal(t, []portainer.KubernetesStackResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "apps", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "other", Name: "web"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "apps"},
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding", Name: "web"},
+	}, WithDefaultNamespace(resources, "apps"))
+
+	require.Equal(t, resources, WithDefaultNamespace(resources, ""))
+}
+
+func TestGroupResourcesByKind(t *testing.T) {
+	web := portainer.KubernetesStackResource{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}
+	worker := portainer.KubernetesStackResource{APIVersion: "apps/v1beta1", Kind: "Deployment", Name: "worker"}
+	service := portainer.KubernetesStackResource{APIVersion: "v1", Kind: "Service", Name: "web"}
+	route := portainer.KubernetesStackResource{APIVersion: "route.openshift.io/v1", Kind: "Route", Name: "web"}
+
+	groups := GroupResourcesByKind([]portainer.KubernetesStackResource{web, service, worker, route})
+	require.Equal(t, [][]portainer.KubernetesStackResource{{web, worker}, {service}, {route}}, groups)
+}
+
+func TestIsKindNotServedError(t *testing.T) {
+	require.False(t, IsKindNotServedError(nil))
+	require.False(t, IsKindNotServedError(errors.New(`failed to execute kubectl command: "Error from server (Forbidden)"`)))
+	require.True(t, IsKindNotServedError(errors.New(`failed to execute kubectl command: "error: resource mapping not found for name: \"web\" namespace: \"\" from \"resources.yaml\": no matches for kind \"Route\" in version \"route.openshift.io/v1\""`)))
+}
+
+func TestPrunableResources(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  string
+		expected []portainer.KubernetesStackResource
+	}{
+		{
+			name:    "no objects",
+			objects: "",
+		},
+		{
+			name:     "single object",
+			objects:  `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web","namespace":"apps","labels":{"io.portainer.kubernetes.application.stackid":"12"}}}`,
+			expected: []portainer.KubernetesStackResource{{APIVersion: "v1", Kind: "Service", Namespace: "apps", Name: "web"}},
+		},
+		{
+			name: "list of objects",
+			objects: `{"apiVersion":"v1","kind":"List","items":[
+				{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"apps","labels":{"io.portainer.kubernetes.application.stackid":"12"}}},
+				{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"protected","namespace":"apps","labels":{"io.portainer.kubernetes.application.stackid":"12"},"annotations":{"io.portainer.kubernetes.application.prune-protection":"true"}}},
+				{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"other-stack","namespace":"apps","labels":{"io.portainer.kubernetes.application.stackid":"13"}}},
+				{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"unlabelled","namespace":"apps"}}
+			]}`,
+			expected: []portainer.KubernetesStackResource{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "apps", Name: "web"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := PrunableResources([]byte(tt.objects), 12)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, resources)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package kustomize
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// ManifestFileName is the name of the manifest rendered from a kustomization
+const ManifestFileName = "kustomized.yaml"
+
+// IsKustomization returns true when the directory holds a kustomization file
+func IsKustomization(dir string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Build renders the kustomization found at kustomizationPath, relative to the root directory, and returns
+// the resulting manifests. The kustomization is confined to the root directory: the files outside of it,
+// the remote bases and the plugins are not available
+func Build(root, kustomizationPath string) ([]byte, error) {
+	kustomizationPath = filepath.Clean(kustomizationPath)
+	if !filepath.IsLocal(kustomizationPath) {
+		return nil, errors.Errorf("invalid kustomization path %q, it must be a folder inside the repository", kustomizationPath)
+	}
+
+	if !IsKustomization(filepath.Join(root, kustomizationPath)) {
+		return nil, errors.Errorf("no kustomization file found in %q", kustomizationPath)
+	}
+
+	fSys, err := loadInMemory(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the repository")
+	}
+
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, filepath.Join(string(filepath.Separator), kustomizationPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to render the kustomization")
+	}
+
+	return resources.AsYaml()
+}
+
+// loadInMemory copies the files of the root directory, except the git metadata, into an in-memory file system
+func loadInMemory(root string) (filesys.FileSystem, error) {
+	fSys := filesys.MakeFsInMemory()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		// Symbolic links could point outside of the repository
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return fSys.WriteFile(filepath.Join(string(filepath.Separator), rel), content)
+	})
+
+	return fSys, err
+}
@@ -0,0 +1,79 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles(t, root, map[string]string{
+		"base/kustomization.yaml": "resources:\n  - deployment.yaml\n",
+		"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+`,
+		"overlays/production/kustomization.yaml": `resources:
+  - ../../base
+namespace: production
+replicas:
+  - name: web
+    count: 3
+images:
+  - name: nginx
+    newTag: "1.27"
+`,
+		"overlays/escape/kustomization.yaml": "resources:\n  - ../../../outside\n",
+		"plain/deployment.yaml":              "kind: Deployment\n",
+	})
+
+	manifests, err := Build(root, "overlays/production")
+	require.NoError(t, err)
+	require.Contains(t, string(manifests), "namespace: production")
+	require.Contains(t, string(manifests), "replicas: 3")
+	require.Contains(t, string(manifests), "image: nginx:1.27")
+
+	_, err = Build(root, "../outside")
+	require.Error(t, err, "the kustomization must be inside the repository")
+
+	_, err = Build(root, "plain")
+	require.Error(t, err, "the folder must hold a kustomization file")
+
+	_, err = Build(root, "overlays/escape")
+	require.Error(t, err, "the resources outside of the repository must not be loaded")
+}
+
+func TestIsKustomization(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles(t, root, map[string]string{
+		"a/kustomization.yml": "resources: []\n",
+		"b/Kustomization":     "resources: []\n",
+		"c/deployment.yaml":   "kind: Deployment\n",
+	})
+
+	require.True(t, IsKustomization(filepath.Join(root, "a")))
+	require.True(t, IsKustomization(filepath.Join(root, "b")))
+	require.False(t, IsKustomization(filepath.Join(root, "c")))
+	require.False(t, IsKustomization(filepath.Join(root, "d")))
+}
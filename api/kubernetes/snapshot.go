@@ -1,6 +1,8 @@
 package kubernetes
 
 import (
+	"context"
+
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/kubernetes/cli"
 	"github.com/portainer/portainer/pkg/snapshot"
@@ -18,11 +20,11 @@ func NewSnapshotter(clientFactory *cli.ClientFactory) *Snapshotter {
 }
 
 // CreateSnapshot creates a snapshot of a specific Kubernetes environment(endpoint)
-func (snapshotter *Snapshotter) CreateSnapshot(endpoint *portainer.Endpoint) (*portainer.KubernetesSnapshot, error) {
+func (snapshotter *Snapshotter) CreateSnapshot(ctx context.Context, endpoint *portainer.Endpoint) (*portainer.KubernetesSnapshot, error) {
 	client, err := snapshotter.clientFactory.CreateClient(endpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	return snapshot.CreateKubernetesSnapshot(client)
+	return snapshot.CreateKubernetesSnapshot(ctx, client)
 }
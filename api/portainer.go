@@ -126,11 +126,22 @@ type (
 		SSLKey                    *string
 		Rollback                  *bool
 		SnapshotInterval          *string
+		SnapshotConcurrency       *int
+		SnapshotTimeout           *time.Duration
+		DockerInventoryResync     *time.Duration
 		BaseURL                   *string
 		InitialMmapSize           *int
 		MaxBatchSize              *int
 		MaxBatchDelay             *time.Duration
 		SecretKeyName             *string
+		DatabaseType              *string
+		MigrateToSQLite           *bool
+		CheckIntegrity            *bool
+		RepairIntegrity           *bool
+		CompactDatabase           *bool
+		RotateSecretKeyName       *string
+		ConfigApply               *string
+		ConfigDryRun              *bool
 		LogLevel                  *string
 		LogMode                   *string
 		KubectlShellImage         *string
@@ -142,7 +153,30 @@ type (
 		Label        string `json:"label" example:"My Variable"`
 		DefaultValue string `json:"defaultValue" example:"default value"`
 		Description  string `json:"description" example:"Description"`
-	}
+		// Type of the variable, string when empty
+		Type CustomTemplateVariableType `json:"type,omitempty" example:"string" enums:"string,number,boolean,select,secret,multiline"`
+		// Whether a value must be provided when the default value is empty
+		Required bool `json:"required,omitempty" example:"true"`
+		// Regular expression the value must match, for string, secret and multiline variables
+		Pattern string `json:"pattern,omitempty" example:"^[a-z0-9-]+$"`
+		// Minimum value of a number variable, or minimum length of a string, secret and multiline variable
+		Min *float64 `json:"min,omitempty" example:"1"`
+		// Maximum value of a number variable, or maximum length of a string, secret and multiline variable
+		Max *float64 `json:"max,omitempty" example:"65535"`
+		// Values the variable can take, for select variables
+		Options []CustomTemplateVariableOption `json:"options,omitempty"`
+	}
+
+	// CustomTemplateVariableOption represents a value a select variable can take
+	CustomTemplateVariableOption struct {
+		// Text displayed to the user
+		Label string `json:"label" example:"Production"`
+		// Value used to render the template
+		Value string `json:"value" example:"prod"`
+	}
+
+	// CustomTemplateVariableType represents the type of a custom template variable
+	CustomTemplateVariableType string
 
 	// CustomTemplate represents a custom template
 	CustomTemplate struct {
@@ -208,26 +242,27 @@ type (
 
 	// DockerSnapshot represents a snapshot of a specific Docker environment(endpoint) at a specific time
 	DockerSnapshot struct {
-		Time                    int64             `json:"Time"`
-		DockerVersion           string            `json:"DockerVersion"`
-		Swarm                   bool              `json:"Swarm"`
-		TotalCPU                int               `json:"TotalCPU"`
-		TotalMemory             int64             `json:"TotalMemory"`
-		ContainerCount          int               `json:"ContainerCount"`
-		RunningContainerCount   int               `json:"RunningContainerCount"`
-		StoppedContainerCount   int               `json:"StoppedContainerCount"`
-		HealthyContainerCount   int               `json:"HealthyContainerCount"`
-		UnhealthyContainerCount int               `json:"UnhealthyContainerCount"`
-		VolumeCount             int               `json:"VolumeCount"`
-		ImageCount              int               `json:"ImageCount"`
-		ServiceCount            int               `json:"ServiceCount"`
-		StackCount              int               `json:"StackCount"`
-		SnapshotRaw             DockerSnapshotRaw `json:"DockerSnapshotRaw"`
-		NodeCount               int               `json:"NodeCount"`
-		GpuUseAll               bool              `json:"GpuUseAll"`
-		GpuUseList              []string          `json:"GpuUseList"`
-		IsPodman                bool              `json:"IsPodman"`
-		DiagnosticsData         *DiagnosticsData  `json:"DiagnosticsData"`
+		Time                    int64  `json:"Time"`
+		DockerVersion           string `json:"DockerVersion"`
+		Swarm                   bool   `json:"Swarm"`
+		TotalCPU                int    `json:"TotalCPU"`
+		TotalMemory             int64  `json:"TotalMemory"`
+		ContainerCount          int    `json:"ContainerCount"`
+		RunningContainerCount   int    `json:"RunningContainerCount"`
+		StoppedContainerCount   int    `json:"StoppedContainerCount"`
+		HealthyContainerCount   int    `json:"HealthyContainerCount"`
+		UnhealthyContainerCount int    `json:"UnhealthyContainerCount"`
+		VolumeCount             int    `json:"VolumeCount"`
+		ImageCount              int    `json:"ImageCount"`
+		ServiceCount            int    `json:"ServiceCount"`
+		StackCount              int    `json:"StackCount"`
+		// Raw data as returned by the Docker API, stored separately and only loaded on demand
+		SnapshotRaw     *DockerSnapshotRaw `json:"DockerSnapshotRaw,omitempty"`
+		NodeCount       int                `json:"NodeCount"`
+		GpuUseAll       bool               `json:"GpuUseAll"`
+		GpuUseList      []string           `json:"GpuUseList"`
+		IsPodman        bool               `json:"IsPodman"`
+		DiagnosticsData *DiagnosticsData   `json:"DiagnosticsData"`
 	}
 
 	// DockerContainerSnapshot is an extent of Docker's Container struct
@@ -256,11 +291,30 @@ type (
 		TagIDs       []TagID      `json:"TagIds"`
 		Endpoints    []EndpointID `json:"Endpoints"`
 		PartialMatch bool         `json:"PartialMatch"`
+		// List of rules that all need to match for an environment to belong to a dynamic Edge group
+		Rules []EdgeGroupRule `json:"Rules"`
 	}
 
 	// EdgeGroupID represents an Edge group identifier
 	EdgeGroupID int
 
+	// EdgeGroupRule represents an expression evaluated against an environment attribute
+	// to compute the membership of a dynamic Edge group
+	EdgeGroupRule struct {
+		// Environment attribute the rule is evaluated against
+		Field EdgeGroupRuleField `json:"Field" example:"name" enums:"name,endpointGroup,agentVersion,platform,asyncMode,lastCheckInAge,architecture,dockerVersion,kubernetesVersion"`
+		// Operator used to compare the attribute with the value
+		Operator EdgeGroupRuleOperator `json:"Operator" example:"glob" enums:"equals,notEquals,glob,regex,semver,lessThan,greaterThan"`
+		// Value the attribute is compared with
+		Value string `json:"Value" example:"edge-*"`
+	}
+
+	// EdgeGroupRuleField represents the environment attribute targeted by an Edge group rule
+	EdgeGroupRuleField string
+
+	// EdgeGroupRuleOperator represents the comparison used by an Edge group rule
+	EdgeGroupRuleOperator string
+
 	// EdgeJob represents a job that can run on Edge environments(endpoints).
 	EdgeJob struct {
 		// EdgeJob Identifier
@@ -329,6 +383,22 @@ type (
 		DeploymentType EdgeStackDeploymentType `json:"DeploymentType"`
 		// Uses the manifest's namespaces instead of the default one
 		UseManifestNamespaces bool
+		// Whether each environment receives its own configs, only available for stacks created from a git repository
+		SupportPerDeviceConfigs bool `json:"SupportPerDeviceConfigs" example:"false"`
+		// Path, relative to the root of the git repository, of the folder holding the per-device configs
+		PerDeviceConfigsPath string `json:"PerDeviceConfigsPath" example:"configs"`
+		// Attribute of the environment the per-device configs are named after
+		PerDeviceConfigsMatchKey PerDevConfigsMatchKey `json:"PerDeviceConfigsMatchKey" example:"edgeID" enums:"edgeID,endpointName,edgeGroupName"`
+		// Whether a per-device config is a single file or a folder
+		PerDeviceConfigsMatchType PerDevConfigsFilterType `json:"PerDeviceConfigsMatchType" example:"file" enums:"file,dir"`
+		// Compose profiles enabled when deploying the stack
+		ComposeProfiles []string `json:"ComposeProfiles,omitempty" example:"debug"`
+		// Env files, relative to the project path, loaded in order when deploying the stack
+		EnvFiles []string `json:"EnvFiles,omitempty" example:"common.env,production.env"`
+		// Path, relative to the root of the git repository, of the kustomization folder of a Kubernetes edge stack
+		KustomizePath string `json:"KustomizePath,omitempty" example:"overlays/production"`
+		// Kustomization folders overriding KustomizePath for some environments
+		KustomizeOverlayPaths map[EndpointID]string `json:"KustomizeOverlayPaths,omitempty"`
 	}
 
 	EdgeStackDeploymentType int
@@ -487,6 +557,8 @@ type (
 		TeamAccessPolicies TeamAccessPolicies `json:"TeamAccessPolicies"`
 		// List of tags associated to this environment(endpoint) group
 		TagIDs []TagID `json:"TagIds"`
+		// Admission policy applied to the Docker workloads deployed on the environments(endpoints) of the group
+		AdmissionPolicy *AdmissionPolicy `json:"AdmissionPolicy,omitempty"`
 
 		// Deprecated fields
 		Labels []Pair `json:"Labels"`
@@ -502,6 +574,40 @@ type (
 	// EndpointGroupID represents an environment(endpoint) group identifier
 	EndpointGroupID int
 
+	// AdmissionPolicy represents the rules the containers, services and stacks deployed on
+	// a Docker environment(endpoint) must comply with
+	AdmissionPolicy struct {
+		// Whether the policy is evaluated
+		Enabled bool `json:"Enabled" example:"true"`
+		// In audit mode the violations are only logged, in enforce mode the deployment is rejected
+		Mode AdmissionPolicyMode `json:"Mode" example:"enforce" enums:"enforce,audit"`
+		// Whether the policy also applies to administrators and environment administrators
+		ApplyToAdministrators bool `json:"ApplyToAdministrators" example:"false"`
+		// Registries the images can be pulled from, any registry is allowed when empty
+		AllowedRegistries []string `json:"AllowedRegistries" example:"docker.io,registry.example.com:5000"`
+		// Whether images without tag or with the latest tag are forbidden
+		ForbidLatestTag bool `json:"ForbidLatestTag" example:"true"`
+		// Whether a memory limit must be set
+		RequireMemoryLimit bool `json:"RequireMemoryLimit" example:"true"`
+		// Whether a CPU limit must be set
+		RequireCPULimit bool `json:"RequireCPULimit" example:"true"`
+		// Labels that must be set
+		RequiredLabels []string `json:"RequiredLabels" example:"com.example.team"`
+		// Host port ranges that can be published, any port is allowed when empty
+		AllowedPublishedPortRanges []AdmissionPolicyPortRange `json:"AllowedPublishedPortRanges"`
+		// Whether the root filesystem must be mounted read-only
+		RequireReadOnlyRootFilesystem bool `json:"RequireReadOnlyRootFilesystem" example:"false"`
+	}
+
+	// AdmissionPolicyMode represents how the violations of an admission policy are handled
+	AdmissionPolicyMode string
+
+	// AdmissionPolicyPortRange represents an inclusive range of host ports
+	AdmissionPolicyPortRange struct {
+		Start uint16 `json:"Start" example:"8000"`
+		End   uint16 `json:"End" example:"8999"`
+	}
+
 	// EndpointID represents an environment(endpoint) identifier
 	EndpointID int
 
@@ -774,6 +880,8 @@ type (
 	Pair struct {
 		Name  string `json:"name" example:"name"`
 		Value string `json:"value" example:"value"`
+		// Whether the value is a secret, stack secrets are encrypted at rest and never returned by the API
+		Secret bool `json:"secret,omitempty" example:"false"`
 	}
 
 	// Registry represents a Docker registry with all the info required
@@ -1062,6 +1170,11 @@ type (
 		UpdatedBy string `example:"bob"`
 		// Only applies when deploying stack with multiple files
 		AdditionalFiles []string `json:"AdditionalFiles"`
+		// Compose profiles enabled when deploying the stack
+		ComposeProfiles []string `json:"ComposeProfiles,omitempty" example:"debug"`
+		// Env files, relative to the project path, loaded in order when deploying the stack.
+		// The variables of a file override the ones of the previous files
+		EnvFiles []string `json:"EnvFiles,omitempty" example:"common.env,production.env"`
 		// The GitOps update settings of a git stack
 		AutoUpdate *AutoUpdateSettings `json:"AutoUpdate"`
 		// The stack deployment option
@@ -1072,6 +1185,25 @@ type (
 		FromAppTemplate bool `example:"false"`
 		// Kubernetes namespace if stack is a kube application
 		Namespace string `example:"default"`
+		// Path, relative to the project path, of the kustomization folder of a Kubernetes stack.
+		// The kustomization is rendered and deployed instead of the manifest files
+		KustomizePath string `json:"KustomizePath,omitempty" example:"overlays/production"`
+		// Kubernetes objects deployed by the stack. The objects removed from the manifests are pruned when the stack is redeployed
+		KubernetesInventory []KubernetesStackResource `json:"KubernetesInventory,omitempty"`
+		// The policy used to redeploy the stack when a newer image is published
+		ImageWatch *StackImageWatchSettings `json:"ImageWatch,omitempty"`
+		// The policy used to compare the stack with the resources running on the environment
+		DriftCheck *StackDriftCheckSettings `json:"DriftCheck,omitempty"`
+	}
+
+	// KubernetesStackResource identifies a Kubernetes object deployed by a stack
+	KubernetesStackResource struct {
+		APIVersion string `json:"APIVersion" example:"apps/v1"`
+		Kind       string `json:"Kind" example:"Deployment"`
+		// Namespace of the object, the namespace of the stack when the manifest does not declare one.
+		// Empty for the cluster-scoped objects
+		Namespace string `json:"Namespace,omitempty" example:"default"`
+		Name      string `json:"Name" example:"web"`
 	}
 
 	// StackOption represents the options for stack deployment
@@ -1080,6 +1212,107 @@ type (
 		Prune bool `example:"false"`
 	}
 
+	// StackImageWatchSettings represents the policy used to check the images of a compose or swarm stack
+	// and redeploy the stack when a newer image digest is published
+	StackImageWatchSettings struct {
+		// Interval between two checks of the image digests
+		Interval string `example:"1h"`
+		// Only record the changes, without redeploying the stack
+		NotifyOnly bool `example:"false"`
+		// Window the redeployments are restricted to, the stack can be redeployed at any time when empty
+		MaintenanceWindow *StackMaintenanceWindow
+		// Image watch job id
+		JobID string `example:"15"`
+		// Unix timestamp of the last check
+		LastCheckDate int64 `example:"1587399600"`
+		// Most recent changes detected by the image watch, the latest first
+		History []StackImageWatchEvent
+	}
+
+	// StackDriftCheckSettings represents the policy used to detect the changes made to the resources of a stack
+	// outside of Portainer
+	StackDriftCheckSettings struct {
+		// Interval between two drift checks, the periodic check is disabled when empty
+		Interval string `example:"15m"`
+		// Redeploy the stack when a drift is detected
+		AutoReconcile bool `example:"false"`
+		// Drift check job id
+		JobID string `example:"15"`
+		// Result of the latest drift check
+		LastReport *StackDriftReport `json:"LastReport,omitempty"`
+	}
+
+	// StackDriftReport represents the differences found between the desired state of a stack and
+	// the resources running on the environment
+	StackDriftReport struct {
+		// Unix timestamp of the check
+		Date int64 `example:"1587399600"`
+		// Whether differences were found
+		Drifted bool `example:"true"`
+		// Differences found, sorted by resource
+		Differences []StackDriftDifference
+		// Whether the stack was redeployed to reconcile the differences
+		Reconciled bool `example:"false"`
+		// Error raised by the check or the reconciliation
+		Error string `json:"Error,omitempty"`
+	}
+
+	// StackDriftDifference represents a difference between the desired state of a resource of a stack and its live state
+	StackDriftDifference struct {
+		// Kind of difference
+		Kind StackDriftKind `example:"image"`
+		// Compose or swarm service, or Kubernetes workload formatted as Kind/namespace/name
+		Resource string `example:"web"`
+		// Container of the resource the difference applies to
+		Container string `json:"Container,omitempty" example:"mystack-web-1"`
+		// Environment variable the difference applies to, the values are never reported
+		Variable string `json:"Variable,omitempty" example:"LOG_LEVEL"`
+		// Desired value
+		Desired string `json:"Desired,omitempty" example:"nginx:1.27"`
+		// Live value
+		Actual string `json:"Actual,omitempty" example:"nginx:1.25"`
+	}
+
+	// StackDriftKind represents the kind of a drift difference
+	StackDriftKind string
+
+	// StackMaintenanceWindow represents a recurring window of time
+	StackMaintenanceWindow struct {
+		// Days of the week the window applies to, from 0 (Sunday) to 6, every day when empty
+		Days []time.Weekday `example:"6"`
+		// Start of the window, formatted as HH:MM in UTC
+		Start string `example:"02:00"`
+		// End of the window, formatted as HH:MM in UTC. The window spans midnight when it is before the start
+		End string `example:"04:00"`
+	}
+
+	// StackImageWatchEvent represents the images changes detected during a check
+	StackImageWatchEvent struct {
+		// Unix timestamp of the check
+		Date int64 `example:"1587399600"`
+		// What was done with the changes
+		Action StackImageWatchAction `example:"redeployed"`
+		// Images with a new digest
+		Images []StackImageChange
+		// Error raised by the redeployment
+		Error string `json:"Error,omitempty"`
+	}
+
+	// StackImageWatchAction represents what was done with the image changes detected during a check
+	StackImageWatchAction string
+
+	// StackImageChange represents an image of a stack for which a newer digest was published
+	StackImageChange struct {
+		// Image reference used by the stack
+		Image string `example:"nginx:latest"`
+		// Services using the image
+		Services []string `example:"web"`
+		// Digest of the image deployed
+		PreviousDigest string `example:"sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b"`
+		// Digest of the image published inside the registry
+		Digest string `example:"sha256:6457d53fb065d6f250e1504b9bc42d5b6c65941d57532c072d929dd0628977d0"`
+	}
+
 	// StackID represents a stack identifier (it must be composed of Name + "_" + SwarmID to create a unique identifier)
 	StackID int
 
@@ -1212,6 +1445,11 @@ type (
 		RestartPolicy string `json:"restart_policy,omitempty" example:"on-failure"`
 		// Container hostname
 		Hostname string `json:"hostname,omitempty" example:"mycontainer"`
+
+		// Identifier of the template source this template was retrieved from
+		SourceID TemplateSourceID `json:"sourceId,omitempty" example:"1"`
+		// Name of the template source this template was retrieved from
+		Source string `json:"source,omitempty" example:"Portainer templates"`
 	}
 
 	// TemplateEnv represents a template environment(endpoint) variable configuration
@@ -1252,6 +1490,44 @@ type (
 		StackFile string `json:"stackfile" example:"./subfolder/docker-compose.yml"`
 	}
 
+	// TemplateSource represents a location application templates are retrieved from,
+	// along with the last copy that was successfully retrieved
+	TemplateSource struct {
+		// TemplateSource Identifier
+		ID TemplateSourceID `json:"Id" example:"1"`
+		// Name of the source, used to attribute the templates it provides
+		Name string `json:"Name" example:"Portainer templates"`
+		// Type of the source. Valid values are: 1 (URL), 2 (git repository), 3 (uploaded file)
+		Type TemplateSourceType `json:"Type" example:"1" enums:"1,2,3"`
+		// URL of the templates file for a URL source. The URL defined in the settings is used when empty
+		URL string `json:"URL,omitempty" example:"https://raw.githubusercontent.com/portainer/templates/v3/templates.json"`
+		// Git repository holding the templates file for a git source
+		GitConfig *gittypes.RepoConfig `json:"GitConfig,omitempty"`
+		// Sources with a lower priority value win when two sources provide the same template
+		Priority int `json:"Priority" example:"0"`
+
+		// ETag returned by the server on the last successful retrieval
+		ETag string `json:"ETag,omitempty"`
+		// Last-Modified header returned by the server on the last successful retrieval
+		LastModified string `json:"LastModified,omitempty"`
+		// URL the templates were last retrieved from
+		FetchedURL string `json:"FetchedURL,omitempty"`
+		// Unix timestamp of the last successful retrieval
+		LastRefresh int64 `json:"LastRefresh" example:"1587399600"`
+		// Error encountered during the last retrieval, the last good copy is served meanwhile
+		LastError string `json:"LastError,omitempty"`
+		// Version of the templates file format
+		Version string `json:"Version,omitempty" example:"3"`
+		// Last good copy of the templates provided by the source, the uploaded templates for a file source
+		Templates []Template `json:"Templates,omitempty" swaggerignore:"true"`
+	}
+
+	// TemplateSourceID represents a template source identifier
+	TemplateSourceID int
+
+	// TemplateSourceType represents the type of a template source
+	TemplateSourceType int
+
 	// TemplateType represents the type of a template
 	TemplateType int
 
@@ -1373,6 +1649,26 @@ type (
 		Kubernetes *KubernetesSnapshot `json:"Kubernetes"`
 	}
 
+	// SnapshotStats represents the statistics of the snapshots of an environment(endpoint)
+	// since Portainer started
+	SnapshotStats struct {
+		EndpointID EndpointID `json:"EndpointId" example:"1"`
+		// Unix timestamp of the last snapshot attempt
+		LastAttempt int64 `json:"LastAttempt" example:"1721910740"`
+		// Duration of the last snapshot attempt, in milliseconds
+		LastDuration int64 `json:"LastDuration" example:"250"`
+		// Error returned by the last snapshot attempt, empty when it succeeded
+		LastError string `json:"LastError,omitempty" example:"context deadline exceeded"`
+		// Whether the last snapshot attempt exceeded the per environment timeout
+		TimedOut bool `json:"TimedOut" example:"false"`
+		// Average duration of the successful snapshots, in milliseconds
+		AverageDuration int64 `json:"AverageDuration" example:"300"`
+		SuccessCount    int   `json:"SuccessCount" example:"12"`
+		FailureCount    int   `json:"FailureCount" example:"1"`
+		// Number of failures since the last successful snapshot
+		ConsecutiveFailures int `json:"ConsecutiveFailures" example:"0"`
+	}
+
 	// CLIService represents a service for managing CLI
 	CLIService interface {
 		ParseFlags(version string) (*CLIFlags, error)
@@ -1403,6 +1699,36 @@ type (
 		RemoveVolumes bool
 	}
 
+	ComposeHealthOptions struct {
+		// Timeout is the maximum time the services have to become running and healthy
+		Timeout time.Duration
+		// LogLines is the number of log lines reported for each unhealthy service
+		LogLines int
+	}
+
+	// ComposeUnhealthyService is a service of a compose stack which failed to become running and healthy
+	ComposeUnhealthyService struct {
+		// Name of the service
+		Name string `json:"Name" example:"web"`
+		// Name of the container of the service
+		Container string `json:"Container" example:"mystack-web-1"`
+		// State of the container
+		State string `json:"State" example:"exited"`
+		// Health status of the container, empty when it has no health check
+		Health string `json:"Health" example:"unhealthy"`
+		// Exit code of the container
+		ExitCode int `json:"ExitCode" example:"1"`
+		// Last log lines of the container
+		Logs []string `json:"Logs"`
+	}
+
+	ComposeRecreateOptions struct {
+		ComposeOptions
+
+		// PullImage pulls the images of the services before recreating them
+		PullImage bool
+	}
+
 	ComposeRunOptions struct {
 		ComposeOptions
 
@@ -1422,6 +1748,12 @@ type (
 		Up(ctx context.Context, stack *Stack, endpoint *Endpoint, options ComposeUpOptions) error
 		Down(ctx context.Context, stack *Stack, endpoint *Endpoint) error
 		Pull(ctx context.Context, stack *Stack, endpoint *Endpoint, options ComposeOptions) error
+		RestartServices(ctx context.Context, stack *Stack, endpoint *Endpoint, serviceNames []string, options ComposeOptions) error
+		StopServices(ctx context.Context, stack *Stack, endpoint *Endpoint, serviceNames []string, options ComposeOptions) error
+		RecreateServices(ctx context.Context, stack *Stack, endpoint *Endpoint, serviceNames []string, options ComposeRecreateOptions) error
+		ScaleService(ctx context.Context, stack *Stack, endpoint *Endpoint, serviceName string, replicas int, options ComposeOptions) error
+		WaitForHealthy(ctx context.Context, stack *Stack, endpoint *Endpoint, options ComposeHealthOptions) ([]ComposeUnhealthyService, error)
+		Config(ctx context.Context, stack *Stack, endpoint *Endpoint) ([]byte, error)
 	}
 
 	// CryptoService represents a service for encrypting/hashing data
@@ -1441,7 +1773,7 @@ type (
 
 	// DockerSnapshotter represents a service used to create Docker environment(endpoint) snapshots
 	DockerSnapshotter interface {
-		CreateSnapshot(endpoint *Endpoint) (*DockerSnapshot, error)
+		CreateSnapshot(ctx context.Context, endpoint *Endpoint) (*DockerSnapshot, error)
 	}
 
 	// FileService represents a service for managing files
@@ -1475,6 +1807,9 @@ type (
 		KeyPairFilesExist() (bool, error)
 		StoreKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error
 		LoadKeyPair() ([]byte, []byte, error)
+		TemplateBundleKeyPairFilesExist() (bool, error)
+		StoreTemplateBundleKeyPair(private, public []byte, privatePEMHeader, publicPEMHeader string) error
+		LoadTemplateBundleKeyPair() ([]byte, []byte, error)
 		WriteJSONToFile(path string, content any) error
 		FileExists(path string) (bool, error)
 		StoreEdgeJobFileFromBytes(identifier string, data []byte) (string, error)
@@ -1579,11 +1914,12 @@ type (
 	KubernetesDeployer interface {
 		Deploy(userID UserID, endpoint *Endpoint, manifestFiles []string, namespace string) (string, error)
 		Remove(userID UserID, endpoint *Endpoint, manifestFiles []string, namespace string) (string, error)
+		Prune(userID UserID, endpoint *Endpoint, resources []KubernetesStackResource, namespace string, stackID StackID) (string, []KubernetesStackResource, error)
 	}
 
 	// KubernetesSnapshotter represents a service used to create Kubernetes environment(endpoint) snapshots
 	KubernetesSnapshotter interface {
-		CreateSnapshot(endpoint *Endpoint) (*KubernetesSnapshot, error)
+		CreateSnapshot(ctx context.Context, endpoint *Endpoint) (*KubernetesSnapshot, error)
 	}
 
 	// LDAPService represents a service used to authenticate users against a LDAP/AD
@@ -1623,6 +1959,7 @@ type (
 		SetSnapshotInterval(snapshotInterval string) error
 		SnapshotEndpoint(endpoint *Endpoint) error
 		FillSnapshotData(endpoint *Endpoint) error
+		SnapshotStats() []SnapshotStats
 	}
 
 	// SwarmStackManager represents a service to manage Swarm stacks
@@ -1669,6 +2006,12 @@ const (
 	PortainerAgentSignatureMessage = "Portainer-App"
 	// DefaultSnapshotInterval represents the default interval between each environment snapshot job
 	DefaultSnapshotInterval = "5m"
+	// DefaultSnapshotConcurrency represents the default number of environments snapshotted at the same time
+	DefaultSnapshotConcurrency = 10
+	// DefaultSnapshotTimeout represents the default maximum duration of the snapshot of a single environment
+	DefaultSnapshotTimeout = 2 * time.Minute
+	// DefaultDockerInventoryResyncInterval represents the default interval between each full resync of the Docker inventory
+	DefaultDockerInventoryResyncInterval = 5 * time.Minute
 	// DefaultEdgeAgentCheckinIntervalInSeconds represents the default interval (in seconds) used by Edge agents to checkin with the Portainer instance
 	DefaultEdgeAgentCheckinIntervalInSeconds = 5
 	// DefaultTemplatesURL represents the URL to the official templates supported by Portainer
@@ -1712,6 +2055,45 @@ const (
 	AgentPlatformKubernetes
 )
 
+const (
+	// EdgeGroupRuleFieldName matches against the environment name
+	EdgeGroupRuleFieldName EdgeGroupRuleField = "name"
+	// EdgeGroupRuleFieldEndpointGroup matches against the name of the environment group
+	EdgeGroupRuleFieldEndpointGroup EdgeGroupRuleField = "endpointGroup"
+	// EdgeGroupRuleFieldAgentVersion matches against the version of the agent
+	EdgeGroupRuleFieldAgentVersion EdgeGroupRuleField = "agentVersion"
+	// EdgeGroupRuleFieldPlatform matches against the platform of the environment (docker, podman or kubernetes)
+	EdgeGroupRuleFieldPlatform EdgeGroupRuleField = "platform"
+	// EdgeGroupRuleFieldAsyncMode matches against the async mode of the agent (true or false)
+	EdgeGroupRuleFieldAsyncMode EdgeGroupRuleField = "asyncMode"
+	// EdgeGroupRuleFieldLastCheckInAge matches against the time elapsed since the last check-in (e.g. 10m),
+	// the Edge groups using it cannot be targeted by Edge stacks
+	EdgeGroupRuleFieldLastCheckInAge EdgeGroupRuleField = "lastCheckInAge"
+	// EdgeGroupRuleFieldArchitecture matches against the architecture reported by the last snapshot
+	EdgeGroupRuleFieldArchitecture EdgeGroupRuleField = "architecture"
+	// EdgeGroupRuleFieldDockerVersion matches against the Docker version reported by the last snapshot
+	EdgeGroupRuleFieldDockerVersion EdgeGroupRuleField = "dockerVersion"
+	// EdgeGroupRuleFieldKubernetesVersion matches against the Kubernetes version reported by the last snapshot
+	EdgeGroupRuleFieldKubernetesVersion EdgeGroupRuleField = "kubernetesVersion"
+)
+
+const (
+	// EdgeGroupRuleOperatorEquals requires the attribute to be equal to the value
+	EdgeGroupRuleOperatorEquals EdgeGroupRuleOperator = "equals"
+	// EdgeGroupRuleOperatorNotEquals requires the attribute to be different from the value
+	EdgeGroupRuleOperatorNotEquals EdgeGroupRuleOperator = "notEquals"
+	// EdgeGroupRuleOperatorGlob requires the attribute to match the glob pattern in the value
+	EdgeGroupRuleOperatorGlob EdgeGroupRuleOperator = "glob"
+	// EdgeGroupRuleOperatorRegex requires the attribute to match the regular expression in the value
+	EdgeGroupRuleOperatorRegex EdgeGroupRuleOperator = "regex"
+	// EdgeGroupRuleOperatorSemver requires the attribute to satisfy the semver range in the value
+	EdgeGroupRuleOperatorSemver EdgeGroupRuleOperator = "semver"
+	// EdgeGroupRuleOperatorLessThan requires the attribute to be lower than the value
+	EdgeGroupRuleOperatorLessThan EdgeGroupRuleOperator = "lessThan"
+	// EdgeGroupRuleOperatorGreaterThan requires the attribute to be greater than the value
+	EdgeGroupRuleOperatorGreaterThan EdgeGroupRuleOperator = "greaterThan"
+)
+
 const (
 	_ EdgeJobLogsStatus = iota
 	// EdgeJobLogsStatusIdle represents an idle log collection job
@@ -1722,6 +2104,28 @@ const (
 	EdgeJobLogsStatusCollected
 )
 
+const (
+	// CustomTemplateVariableTypeString represents a single line text variable
+	CustomTemplateVariableTypeString CustomTemplateVariableType = "string"
+	// CustomTemplateVariableTypeNumber represents a numeric variable
+	CustomTemplateVariableTypeNumber CustomTemplateVariableType = "number"
+	// CustomTemplateVariableTypeBoolean represents a true/false variable
+	CustomTemplateVariableTypeBoolean CustomTemplateVariableType = "boolean"
+	// CustomTemplateVariableTypeSelect represents a variable restricted to a list of options
+	CustomTemplateVariableTypeSelect CustomTemplateVariableType = "select"
+	// CustomTemplateVariableTypeSecret represents a text variable whose value is masked
+	CustomTemplateVariableTypeSecret CustomTemplateVariableType = "secret"
+	// CustomTemplateVariableTypeMultiline represents a multiline text variable
+	CustomTemplateVariableTypeMultiline CustomTemplateVariableType = "multiline"
+)
+
+const (
+	// AdmissionPolicyModeEnforce rejects the deployments violating the policy
+	AdmissionPolicyModeEnforce AdmissionPolicyMode = "enforce"
+	// AdmissionPolicyModeAudit only logs the violations of the policy
+	AdmissionPolicyModeAudit AdmissionPolicyMode = "audit"
+)
+
 const (
 	_ CustomTemplatePlatform = iota
 	// CustomTemplatePlatformLinux represents a custom template for linux
@@ -1905,6 +2309,30 @@ const (
 	StackStatusInactive
 )
 
+const (
+	// StackImageWatchRedeployed is recorded when the stack was redeployed with the new images
+	StackImageWatchRedeployed StackImageWatchAction = "redeployed"
+	// StackImageWatchNotified is recorded when the stack only notifies about new images
+	StackImageWatchNotified StackImageWatchAction = "notified"
+	// StackImageWatchDeferred is recorded when new images are detected outside of the maintenance window
+	StackImageWatchDeferred StackImageWatchAction = "deferred"
+	// StackImageWatchFailed is recorded when the redeployment with the new images failed
+	StackImageWatchFailed StackImageWatchAction = "failed"
+)
+
+const (
+	// StackDriftMissing is reported when a resource of the stack is not running
+	StackDriftMissing StackDriftKind = "missing"
+	// StackDriftUnexpected is reported when a resource is running alongside the stack without being part of it
+	StackDriftUnexpected StackDriftKind = "unexpected"
+	// StackDriftImage is reported when a container runs another image
+	StackDriftImage StackDriftKind = "image"
+	// StackDriftEnvironment is reported when an environment variable of a container is changed or unset
+	StackDriftEnvironment StackDriftKind = "environment"
+	// StackDriftReplicas is reported when a resource runs another number of replicas
+	StackDriftReplicas StackDriftKind = "replicas"
+)
+
 const (
 	_ TemplateType = iota
 	// ContainerTemplate represents a container template
@@ -1915,6 +2343,16 @@ const (
 	ComposeStackTemplate
 )
 
+const (
+	_ TemplateSourceType = iota
+	// TemplateSourceURL represents a templates file retrieved over HTTP
+	TemplateSourceURL
+	// TemplateSourceGit represents a templates file stored in a git repository
+	TemplateSourceGit
+	// TemplateSourceFile represents a templates file uploaded to Portainer
+	TemplateSourceFile
+)
+
 const (
 	// TLSFileCA represents a TLS CA certificate file
 	TLSFileCA TLSFileType = iota
@@ -2189,6 +2627,15 @@ const (
 	PerDevConfigsTypeDir  PerDevConfigsFilterType = "dir"
 )
 
+// PerDevConfigsMatchKey is the attribute of an environment its per-device configs are named after
+type PerDevConfigsMatchKey string
+
+const (
+	PerDevConfigsMatchEdgeID        PerDevConfigsMatchKey = "edgeID"
+	PerDevConfigsMatchEndpointName  PerDevConfigsMatchKey = "endpointName"
+	PerDevConfigsMatchEdgeGroupName PerDevConfigsMatchKey = "edgeGroupName"
+)
+
 const (
 	ContainerEngineDocker = "docker"
 	ContainerEnginePodman = "podman"
@@ -5,6 +5,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/internal/registryutils"
 )
 
@@ -56,8 +57,13 @@ func (d *stackDeployer) buildUnpackerCmdForStack(stack *portainer.Stack, operati
 		return nil, fmt.Errorf("unknown stack operation %s", operation)
 	}
 
+	env, err := envsecrets.Open(stack.Env)
+	if err != nil {
+		return nil, err
+	}
+
 	registriesStrings := generateRegistriesStrings(opts.registries, d.dataStore)
-	envStrings := getEnv(stack.Env)
+	envStrings := getEnv(env)
 
 	return fn(stack, opts, registriesStrings, envStrings), nil
 }
@@ -162,7 +168,7 @@ func buildSwarmUndeployCmd(stack *portainer.Stack, opts unpackerCmdBuilderOption
 func buildSwarmStartCmd(stack *portainer.Stack, opts unpackerCmdBuilderOptions, registries []string, env []string) []string {
 	cmd := []string{UnpackerCmdSwarmDeploy, "-f", "-r", "-k"}
 	cmd = appendSkipTLSVerifyIfNeeded(cmd, stack)
-	cmd = append(cmd, getEnv(stack.Env)...)
+	cmd = append(cmd, env...)
 	cmd = append(cmd, registries...)
 	cmd = append(cmd, stack.GitConfig.URL,
 		stack.GitConfig.ReferenceName,
@@ -145,6 +145,12 @@ func redeployWhenChangedSecondStage(
 		return err
 	}
 
+	if stack.Type == portainer.DockerComposeStack || stack.Type == portainer.DockerSwarmStack {
+		if err := validateDeployment(datastore, stack, endpoint, user); err != nil {
+			return errors.WithMessagef(err, "the stack %v cannot be deployed", stack.ID)
+		}
+	}
+
 	switch stack.Type {
 	case portainer.DockerComposeStack:
 		if stackutils.IsRelativePathStack(stack) {
@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -363,3 +365,44 @@ func Test_getUserRegistries(t *testing.T) {
 		assert.ElementsMatch(t, []portainer.Registry{registryReachableByUser, registryReachableByTeam}, registries)
 	})
 }
+
+func Test_redeployWhenChanged_EnforcesAdmissionPolicy(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "docker-compose.yml"), []byte("services:\n  web:\n    image: nginx:latest\n"), 0600))
+
+	require.NoError(t, store.EndpointGroup().Create(&portainer.EndpointGroup{
+		ID:              2,
+		Name:            "production",
+		AdmissionPolicy: &portainer.AdmissionPolicy{Enabled: true, Mode: portainer.AdmissionPolicyModeEnforce, ForbidLatestTag: true},
+	}))
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{
+		ID:               1,
+		GroupID:          2,
+		SecuritySettings: portainer.EndpointSecuritySettings{AllowBindMountsForRegularUsers: true, AllowPrivilegedModeForRegularUsers: true, AllowHostNamespaceForRegularUsers: true, AllowDeviceMappingForRegularUsers: true, AllowSysctlSettingForRegularUsers: true, AllowContainerCapabilitiesForRegularUsers: true},
+	}))
+	require.NoError(t, store.User().Create(&portainer.User{Username: "user", Role: portainer.StandardUserRole}))
+
+	require.NoError(t, store.Stack().Create(&portainer.Stack{
+		ID:          1,
+		Name:        "app",
+		Type:        portainer.DockerComposeStack,
+		EndpointID:  1,
+		ProjectPath: tmpDir,
+		EntryPoint:  "docker-compose.yml",
+		UpdatedBy:   "user",
+		GitConfig: &gittypes.RepoConfig{
+			URL:           "url",
+			ReferenceName: "ref",
+			ConfigHash:    "oldHash",
+		},
+	}))
+
+	err := RedeployWhenChanged(1, &noopDeployer{}, store, testhelpers.NewGitService(nil, "newHash"))
+	require.ErrorContains(t, err, "latest")
+
+	stack, err := store.Stack().Read(1)
+	require.NoError(t, err)
+	require.Equal(t, "oldHash", stack.GitConfig.ConfigHash, "the stack must not be updated when the deployment is rejected")
+}
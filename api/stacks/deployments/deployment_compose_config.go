@@ -22,6 +22,7 @@ type ComposeStackDeploymentConfig struct {
 	ForceCreate    bool
 	FileService    portainer.FileService
 	StackDeployer  StackDeployer
+	dataStore      dataservices.DataStore
 }
 
 func CreateComposeStackDeploymentConfig(securityContext *security.RestrictedRequestContext, stack *portainer.Stack, endpoint *portainer.Endpoint, dataStore dataservices.DataStore, fileService portainer.FileService, deployer StackDeployer, forcePullImage, forceCreate bool) (*ComposeStackDeploymentConfig, error) {
@@ -45,6 +46,7 @@ func CreateComposeStackDeploymentConfig(securityContext *security.RestrictedRequ
 		user:           user,
 		forcePullImage: forcePullImage,
 		ForceCreate:    forceCreate,
+		dataStore:      dataStore,
 		FileService:    fileService,
 		StackDeployer:  deployer,
 	}
@@ -65,24 +67,8 @@ func (config *ComposeStackDeploymentConfig) Deploy() error {
 		return errors.New("file service or stack deployer cannot be nil")
 	}
 
-	isAdminOrEndpointAdmin, err := stackutils.UserIsAdminOrEndpointAdmin(config.user, config.endpoint.ID)
-	if err != nil {
-		return errors.Wrap(err, "failed to validate user admin privileges")
-	}
-
-	securitySettings := &config.endpoint.SecuritySettings
-
-	if (!securitySettings.AllowBindMountsForRegularUsers ||
-		!securitySettings.AllowPrivilegedModeForRegularUsers ||
-		!securitySettings.AllowHostNamespaceForRegularUsers ||
-		!securitySettings.AllowDeviceMappingForRegularUsers ||
-		!securitySettings.AllowSysctlSettingForRegularUsers ||
-		!securitySettings.AllowContainerCapabilitiesForRegularUsers) &&
-		!isAdminOrEndpointAdmin {
-
-		if err := stackutils.ValidateStackFiles(config.stack, securitySettings, config.FileService); err != nil {
-			return err
-		}
+	if err := validateDeployment(config.dataStore, config.stack, config.endpoint, config.user); err != nil {
+		return err
 	}
 
 	if stackutils.IsRelativePathStack(config.stack) {
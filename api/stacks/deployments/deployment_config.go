@@ -1,7 +1,55 @@
 package deployments
 
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/docker/admission"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/pkg/errors"
+)
+
 type StackDeploymentConfiger interface {
 	GetUsername() string
 	Deploy() error
 	GetResponse() string
 }
+
+// validateDeployment enforces, on behalf of the user, the security settings of the environment(endpoint) and
+// the admission policy of its group on the files of a compose or swarm stack. It runs before every deployment,
+// whether requested by the user or triggered by a git update, an image update or a drift reconciliation
+func validateDeployment(tx dataservices.DataStoreTx, stack *portainer.Stack, endpoint *portainer.Endpoint, user *portainer.User) error {
+	isAdminOrEndpointAdmin, err := stackutils.UserIsAdminOrEndpointAdmin(user, endpoint.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to validate user admin privileges")
+	}
+
+	settings := &endpoint.SecuritySettings
+
+	restricted := !settings.AllowBindMountsForRegularUsers
+	if stack.Type == portainer.DockerComposeStack {
+		restricted = restricted ||
+			!settings.AllowPrivilegedModeForRegularUsers ||
+			!settings.AllowHostNamespaceForRegularUsers ||
+			!settings.AllowDeviceMappingForRegularUsers ||
+			!settings.AllowSysctlSettingForRegularUsers ||
+			!settings.AllowContainerCapabilitiesForRegularUsers
+	}
+
+	if restricted && !isAdminOrEndpointAdmin {
+		if err := stackutils.ValidateStackFiles(stack, settings); err != nil {
+			return err
+		}
+	}
+
+	policy, err := admission.PolicyForEndpoint(tx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	if !admission.Applies(policy, isAdminOrEndpointAdmin) {
+		return nil
+	}
+
+	return stackutils.EnforceAdmissionPolicy(stack, endpoint, policy, user.Username)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/portainer/portainer/api/filesystem"
 	k "github.com/portainer/portainer/api/kubernetes"
 	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/rs/zerolog/log"
 )
 
 type KubernetesStackDeploymentConfig struct {
@@ -36,9 +38,13 @@ func (config *KubernetesStackDeploymentConfig) GetUsername() string {
 }
 
 func (config *KubernetesStackDeploymentConfig) Deploy() error {
-	fileNames := stackutils.GetStackFilePaths(config.stack, false)
+	manifests, err := stackutils.GetKubernetesStackManifests(config.stack)
+	if err != nil {
+		return err
+	}
 
-	manifestFilePaths := make([]string, 0, len(fileNames))
+	manifestFilePaths := make([]string, 0, len(manifests))
+	var inventory []portainer.KubernetesStackResource
 
 	tmpDir, err := os.MkdirTemp("", "kub_deployment")
 	if err != nil {
@@ -47,17 +53,19 @@ func (config *KubernetesStackDeploymentConfig) Deploy() error {
 
 	defer os.RemoveAll(tmpDir)
 
-	for _, fileName := range fileNames {
-		manifestFilePath := filesystem.JoinPaths(tmpDir, fileName)
-		manifestContent, err := os.ReadFile(filesystem.JoinPaths(config.stack.ProjectPath, fileName))
+	for _, manifest := range manifests {
+		manifestFilePath := filesystem.JoinPaths(tmpDir, manifest.Name)
+
+		manifestContent, err := k.AddAppLabels(manifest.Content, config.appLabels.ToMap())
 		if err != nil {
-			return errors.Wrap(err, "failed to read manifest file")
+			return errors.Wrap(err, "failed to add application labels")
 		}
 
-		manifestContent, err = k.AddAppLabels(manifestContent, config.appLabels.ToMap())
+		resources, err := k.GetResources(manifestContent)
 		if err != nil {
-			return errors.Wrap(err, "failed to add application labels")
+			return errors.Wrap(err, "failed to list the resources of the manifest")
 		}
+		inventory = append(inventory, resources...)
 
 		if err := filesystem.WriteToFile(manifestFilePath, manifestContent); err != nil {
 			return errors.Wrap(err, "failed to create temp manifest file")
@@ -72,6 +80,25 @@ func (config *KubernetesStackDeploymentConfig) Deploy() error {
 	}
 
 	config.output = output
+
+	// The objects without a namespace are created in the namespace of the stack, the inventories recorded
+	// before it was taken into account are normalized the same way
+	inventory = k.WithDefaultNamespace(inventory, config.stack.Namespace)
+	stale := k.StaleResources(k.WithDefaultNamespace(config.stack.KubernetesInventory, config.stack.Namespace), inventory)
+	config.stack.KubernetesInventory = inventory
+
+	if len(stale) > 0 {
+		pruneOutput, remaining, err := config.kubernetesDeployer.Prune(config.user.ID, config.endpoint, stale, config.stack.Namespace, config.stack.ID)
+		config.output += pruneOutput
+
+		if err != nil {
+			// The deployment succeeded, the resources which could not be pruned are kept in the inventory to be pruned
+			// on the next deployment
+			log.Warn().Err(err).Int("stack_id", int(config.stack.ID)).Msg("failed to prune the resources removed from the stack")
+			config.stack.KubernetesInventory = append(inventory, remaining...)
+		}
+	}
+
 	return nil
 }
 
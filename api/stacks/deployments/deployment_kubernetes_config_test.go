@@ -0,0 +1,115 @@
+package deployments
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	k "github.com/portainer/portainer/api/kubernetes"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pruningKubernetesDeployer struct {
+	pruned    [][]portainer.KubernetesStackResource
+	remaining []portainer.KubernetesStackResource
+	pruneErr  error
+}
+
+func (d *pruningKubernetesDeployer) Deploy(userID portainer.UserID, endpoint *portainer.Endpoint, manifestFiles []string, namespace string) (string, error) {
+	return "applied\n", nil
+}
+
+func (d *pruningKubernetesDeployer) Remove(userID portainer.UserID, endpoint *portainer.Endpoint, manifestFiles []string, namespace string) (string, error) {
+	return "", nil
+}
+
+func (d *pruningKubernetesDeployer) Prune(userID portainer.UserID, endpoint *portainer.Endpoint, resources []portainer.KubernetesStackResource, namespace string, stackID portainer.StackID) (string, []portainer.KubernetesStackResource, error) {
+	d.pruned = append(d.pruned, resources)
+
+	return "pruned\n", d.remaining, d.pruneErr
+}
+
+func TestKubernetesStackDeploymentPrune(t *testing.T) {
+	web := portainer.KubernetesStackResource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "apps", Name: "web"}
+	service := portainer.KubernetesStackResource{APIVersion: "v1", Kind: "Service", Namespace: "apps", Name: "web"}
+	route := portainer.KubernetesStackResource{APIVersion: "route.openshift.io/v1", Kind: "Route", Namespace: "apps", Name: "web"}
+
+	dir := t.TempDir()
+	writeManifest := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(content), 0600))
+	}
+
+	stack := &portainer.Stack{ID: 1, Name: "app", Type: portainer.KubernetesStack, ProjectPath: dir, EntryPoint: "app.yaml", Namespace: "apps"}
+	deployer := &pruningKubernetesDeployer{}
+
+	deploy := func() *KubernetesStackDeploymentConfig {
+		config, err := CreateKubernetesStackDeploymentConfig(stack, deployer, k.KubeAppLabels{StackID: 1, StackName: "app", Owner: "admin", Kind: "git"}, &portainer.User{ID: 1}, &portainer.Endpoint{ID: 1})
+		require.NoError(t, err)
+		require.NoError(t, config.Deploy())
+
+		return config
+	}
+
+	writeManifest("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n")
+	deploy()
+	require.Empty(t, deployer.pruned)
+	require.Equal(t, []portainer.KubernetesStackResource{web, service}, stack.KubernetesInventory)
+
+	// The service is removed from the manifest
+	writeManifest("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n")
+	config := deploy()
+	require.Equal(t, [][]portainer.KubernetesStackResource{{service}}, deployer.pruned)
+	require.Equal(t, []portainer.KubernetesStackResource{web}, stack.KubernetesInventory)
+	require.Equal(t, "applied\npruned\n", config.GetResponse())
+
+	// A failed pruning does not fail the deployment, only the resources which could not be pruned
+	// are pruned again on the next deployment
+	stack.KubernetesInventory = []portainer.KubernetesStackResource{web, service, route}
+	deployer.pruned = nil
+	deployer.remaining = []portainer.KubernetesStackResource{service}
+	deployer.pruneErr = errors.New("forbidden")
+
+	deploy()
+	require.Equal(t, [][]portainer.KubernetesStackResource{{service, route}}, deployer.pruned)
+	require.Equal(t, []portainer.KubernetesStackResource{web, service}, stack.KubernetesInventory)
+}
+
+func TestKubernetesStackDeploymentPruneDefaultNamespace(t *testing.T) {
+	web := portainer.KubernetesStackResource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "apps", Name: "web"}
+	role := portainer.KubernetesStackResource{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "web"}
+
+	dir := t.TempDir()
+	writeManifest := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(content), 0600))
+	}
+
+	stack := &portainer.Stack{ID: 1, Name: "app", Type: portainer.KubernetesStack, ProjectPath: dir, EntryPoint: "app.yaml", Namespace: "apps"}
+	deployer := &pruningKubernetesDeployer{}
+
+	deploy := func() {
+		config, err := CreateKubernetesStackDeploymentConfig(stack, deployer, k.KubeAppLabels{StackID: 1, StackName: "app", Owner: "admin", Kind: "git"}, &portainer.User{ID: 1}, &portainer.Endpoint{ID: 1})
+		require.NoError(t, err)
+		require.NoError(t, config.Deploy())
+	}
+
+	// The namespace of the stack is declared by the manifest
+	writeManifest("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: apps\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: web\n")
+	deploy()
+	require.Equal(t, []portainer.KubernetesStackResource{web, role}, stack.KubernetesInventory)
+
+	// The namespace is omitted, the deployment is the same object
+	writeManifest("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: web\n")
+	deploy()
+	require.Empty(t, deployer.pruned)
+	require.Equal(t, []portainer.KubernetesStackResource{web, role}, stack.KubernetesInventory)
+
+	// An inventory recorded without the namespace of the stack
+	stack.KubernetesInventory = []portainer.KubernetesStackResource{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}, role}
+	writeManifest("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: apps\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: web\n")
+	deploy()
+	require.Empty(t, deployer.pruned)
+	require.Equal(t, []portainer.KubernetesStackResource{web, role}, stack.KubernetesInventory)
+}
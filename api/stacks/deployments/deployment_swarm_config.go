@@ -21,6 +21,7 @@ type SwarmStackDeploymentConfig struct {
 	pullImage     bool
 	FileService   portainer.FileService
 	StackDeployer StackDeployer
+	dataStore     dataservices.DataStore
 }
 
 func CreateSwarmStackDeploymentConfig(securityContext *security.RestrictedRequestContext, stack *portainer.Stack, endpoint *portainer.Endpoint, dataStore dataservices.DataStore, fileService portainer.FileService, deployer StackDeployer, prune bool, pullImage bool) (*SwarmStackDeploymentConfig, error) {
@@ -44,6 +45,7 @@ func CreateSwarmStackDeploymentConfig(securityContext *security.RestrictedReques
 		isAdmin:       securityContext.IsAdmin,
 		user:          user,
 		pullImage:     pullImage,
+		dataStore:     dataStore,
 		FileService:   fileService,
 		StackDeployer: deployer,
 	}
@@ -64,18 +66,8 @@ func (config *SwarmStackDeploymentConfig) Deploy() error {
 		return errors.New("file service or stack deployer cannot be nil")
 	}
 
-	isAdminOrEndpointAdmin, err := stackutils.UserIsAdminOrEndpointAdmin(config.user, config.endpoint.ID)
-	if err != nil {
-		return errors.Wrap(err, "failed to validate user admin privileges")
-	}
-
-	settings := &config.endpoint.SecuritySettings
-
-	if !settings.AllowBindMountsForRegularUsers && !isAdminOrEndpointAdmin {
-		err = stackutils.ValidateStackFiles(config.stack, settings, config.FileService)
-		if err != nil {
-			return err
-		}
+	if err := validateDeployment(config.dataStore, config.stack, config.endpoint, config.user); err != nil {
+		return err
 	}
 
 	if stackutils.IsRelativePathStack(config.stack) {
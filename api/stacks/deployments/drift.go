@@ -0,0 +1,427 @@
+package deployments
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	dockerclient "github.com/portainer/portainer/api/docker/client"
+	kubecli "github.com/portainer/portainer/api/kubernetes/cli"
+	"github.com/portainer/portainer/api/scheduler"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// minDriftCheckInterval is the shortest interval allowed between two drift checks
+const minDriftCheckInterval = time.Minute
+
+// driftResource is the state of a compose or swarm service, or of a Kubernetes workload
+type driftResource struct {
+	// replicas is nil when the number of replicas is not managed by the stack
+	replicas   *int
+	containers []driftContainer
+}
+
+// driftContainer is the state of a container. The desired state of a compose or swarm service
+// is a single unnamed container which applies to all the containers of the service
+type driftContainer struct {
+	name  string
+	image string
+	env   map[string]string
+}
+
+// driftState is the state of the resources of a stack, indexed by resource name
+type driftState map[string]driftResource
+
+// DriftDetector periodically compares the desired state of compose, swarm and Kubernetes stacks
+// with the resources running on their environment, and redeploys the stacks which drifted when asked to
+type DriftDetector struct {
+	scheduler    *scheduler.Scheduler
+	deployer     StackDeployer
+	dataStore    dataservices.DataStore
+	desiredState func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error)
+	liveState    func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, desired driftState) (driftState, error)
+	now          func() time.Time
+}
+
+// NewDriftDetector creates a DriftDetector
+func NewDriftDetector(scheduler *scheduler.Scheduler, deployer StackDeployer, dataStore dataservices.DataStore, composeStackManager portainer.ComposeStackManager, dockerClientFactory *dockerclient.ClientFactory, kubeClientFactory *kubecli.ClientFactory) *DriftDetector {
+	return &DriftDetector{
+		scheduler: scheduler,
+		deployer:  deployer,
+		dataStore: dataStore,
+		desiredState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error) {
+			switch stack.Type {
+			case portainer.DockerComposeStack:
+				return composeDesiredState(ctx, composeStackManager, stack, endpoint)
+			case portainer.DockerSwarmStack:
+				return swarmDesiredState(ctx, stack)
+			default:
+				return kubernetesDesiredState(stack)
+			}
+		},
+		liveState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, desired driftState) (driftState, error) {
+			if stack.Type == portainer.KubernetesStack {
+				cli, err := kubeClientFactory.CreateClient(endpoint)
+				if err != nil {
+					return nil, err
+				}
+
+				return kubernetesLiveState(ctx, cli, desired)
+			}
+
+			cli, err := dockerClientFactory.CreateClient(endpoint, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			defer cli.Close()
+
+			if stack.Type == portainer.DockerSwarmStack {
+				return swarmLiveState(ctx, cli, stack)
+			}
+
+			return composeLiveState(ctx, cli, stack)
+		},
+		now: time.Now,
+	}
+}
+
+// ValidateDriftCheckSettings validates the drift check settings of a stack
+func ValidateDriftCheckSettings(settings *portainer.StackDriftCheckSettings) error {
+	if settings == nil || settings.Interval == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(settings.Interval)
+	if err != nil {
+		return errors.Wrap(err, "invalid drift check interval")
+	}
+
+	if interval < minDriftCheckInterval {
+		return errors.Errorf("drift check interval must be at least %s", minDriftCheckInterval)
+	}
+
+	return nil
+}
+
+// Start schedules the drift check of the stack and returns the job identifier
+func (d *DriftDetector) Start(stackID portainer.StackID, interval string) (string, error) {
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse the stack drift check interval")
+	}
+
+	return d.scheduler.StartJobEvery(duration, func() error {
+		return d.Check(stackID)
+	}), nil
+}
+
+// Stop stops the drift check job of the stack
+func (d *DriftDetector) Stop(stackID portainer.StackID, jobID string) {
+	StopAutoupdate(stackID, jobID, d.scheduler)
+}
+
+// StartSchedules schedules the drift check of all the stacks configured for it
+func (d *DriftDetector) StartSchedules() error {
+	stacks, err := d.dataStore.Stack().DriftCheckedStacks()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch drift checked stacks")
+	}
+
+	for _, stack := range stacks {
+		if stack.Status == portainer.StackStatusInactive {
+			continue
+		}
+
+		jobID, err := d.Start(stack.ID, stack.DriftCheck.Interval)
+		if err != nil {
+			return err
+		}
+
+		stack.DriftCheck.JobID = jobID
+		if err := d.dataStore.Stack().Update(stack.ID, &stack); err != nil {
+			return errors.Wrap(err, "failed to update stack job id")
+		}
+	}
+
+	return nil
+}
+
+// Check compares the stack with the resources running on its environment, records the report
+// and redeploys the stack when it drifted and its policy asks for it
+func (d *DriftDetector) Check(stackID portainer.StackID) error {
+	_, err, _ := singleflightGroup.Do("drift:"+strconv.Itoa(int(stackID)), func() (any, error) {
+		return nil, d.check(stackID)
+	})
+
+	return err
+}
+
+func (d *DriftDetector) check(stackID portainer.StackID) error {
+	stack, err := d.dataStore.Stack().Read(stackID)
+	if dataservices.IsErrObjectNotFound(err) {
+		return scheduler.NewPermanentError(errors.WithMessagef(err, "failed to get the stack %v", stackID))
+	} else if err != nil {
+		return errors.WithMessagef(err, "failed to get the stack %v", stackID)
+	}
+
+	if stack.DriftCheck == nil || stack.Status == portainer.StackStatusInactive {
+		return nil
+	}
+
+	endpoint, err := d.dataStore.Endpoint().Endpoint(stack.EndpointID)
+	if dataservices.IsErrObjectNotFound(err) {
+		return scheduler.NewPermanentError(errors.WithMessagef(err, "failed to find the environment %v associated to the stack %v", stack.EndpointID, stack.ID))
+	} else if err != nil {
+		return errors.WithMessagef(err, "failed to find the environment %v associated to the stack %v", stack.EndpointID, stack.ID)
+	}
+
+	if !isEnvironmentOnline(endpoint) {
+		return nil
+	}
+
+	report, checkErr := d.Run(context.TODO(), stack, endpoint, stack.DriftCheck.AutoReconcile, nil)
+	if report.Drifted {
+		log.Warn().
+			Int("stack_id", int(stack.ID)).
+			Str("stack", stack.Name).
+			Int("differences", len(report.Differences)).
+			Bool("reconciled", report.Reconciled).
+			Msg("drift detected for the stack")
+	}
+
+	if err := d.SaveReport(stack, report); err != nil {
+		return err
+	}
+
+	return checkErr
+}
+
+// SaveReport records the report as the latest drift report of the stack. The stack is read again so that
+// the changes made to it during the check are kept, and nothing is recorded when it was deleted meanwhile.
+// The Kubernetes inventory of a reconciled stack is recorded along with the report
+func (d *DriftDetector) SaveReport(stack *portainer.Stack, report *portainer.StackDriftReport) error {
+	err := d.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		current, err := tx.Stack().Read(stack.ID)
+		if tx.IsErrObjectNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if current.DriftCheck == nil {
+			current.DriftCheck = &portainer.StackDriftCheckSettings{}
+		}
+
+		current.DriftCheck.LastReport = report
+
+		if report.Reconciled && stack.Type == portainer.KubernetesStack {
+			current.KubernetesInventory = stack.KubernetesInventory
+		}
+
+		return tx.Stack().Update(current.ID, current)
+	})
+
+	return errors.WithMessagef(err, "failed to record the drift report of the stack %v", stack.ID)
+}
+
+// Run compares the stack with the resources running on its environment and, when reconcile is set and
+// differences are found, redeploys the stack on behalf of the user, or of its author when the user is nil.
+// Recording the report is left to the caller, see SaveReport
+func (d *DriftDetector) Run(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, reconcile bool, user *portainer.User) (*portainer.StackDriftReport, error) {
+	report := &portainer.StackDriftReport{Date: d.now().Unix(), Differences: []portainer.StackDriftDifference{}}
+
+	differences, err := d.differences(ctx, stack, endpoint)
+	if err != nil {
+		report.Error = err.Error()
+
+		return report, errors.WithMessagef(err, "failed to check the drift of the stack %v", stack.ID)
+	}
+
+	report.Differences = differences
+	report.Drifted = len(differences) > 0
+
+	if !reconcile || !report.Drifted {
+		return report, nil
+	}
+
+	if err := d.reconcile(stack, endpoint, user); err != nil {
+		report.Error = err.Error()
+
+		return report, errors.WithMessagef(err, "failed to reconcile the stack %v", stack.ID)
+	}
+
+	report.Reconciled = true
+
+	return report, nil
+}
+
+func (d *DriftDetector) differences(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]portainer.StackDriftDifference, error) {
+	desired, err := d.desiredState(ctx, stack, endpoint)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to render the desired state of the stack")
+	}
+
+	live, err := d.liveState(ctx, stack, endpoint, desired)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to retrieve the live state of the stack")
+	}
+
+	return compareDriftState(desired, live), nil
+}
+
+// reconcile redeploys the stack on behalf of the user, or of its author when the user is nil, recreating
+// the containers of compose stacks and pruning the services of swarm stacks which are not part of it anymore
+func (d *DriftDetector) reconcile(stack *portainer.Stack, endpoint *portainer.Endpoint, user *portainer.User) error {
+	if user == nil {
+		author := cmp.Or(stack.UpdatedBy, stack.CreatedBy)
+
+		var err error
+		if user, err = d.dataStore.User().UserByUsername(author); err != nil {
+			return &StackAuthorMissingErr{int(stack.ID), author}
+		}
+	}
+
+	if stack.Type == portainer.KubernetesStack {
+		return d.deployer.DeployKubernetesStack(stack, endpoint, user)
+	}
+
+	registries, err := getUserRegistries(d.dataStore, user, endpoint.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateDeployment(d.dataStore, stack, endpoint, user); err != nil {
+		return err
+	}
+
+	if stack.Type == portainer.DockerSwarmStack {
+		if stackutils.IsRelativePathStack(stack) {
+			return d.deployer.DeployRemoteSwarmStack(stack, endpoint, registries, true, false)
+		}
+
+		return d.deployer.DeploySwarmStack(stack, endpoint, registries, true, false)
+	}
+
+	if stackutils.IsRelativePathStack(stack) {
+		return d.deployer.DeployRemoteComposeStack(stack, endpoint, registries, false, true)
+	}
+
+	return d.deployer.DeployComposeStack(stack, endpoint, registries, false, true)
+}
+
+// compareDriftState returns the differences between the desired and the live state of a stack,
+// sorted by resource. Only the environment variables set by the stack are compared, as the
+// containers also inherit the variables of their image
+func compareDriftState(desired, live driftState) []portainer.StackDriftDifference {
+	differences := []portainer.StackDriftDifference{}
+
+	for _, name := range sortedDriftResources(desired) {
+		want := desired[name]
+
+		got, ok := live[name]
+		if !ok {
+			differences = append(differences, portainer.StackDriftDifference{Kind: portainer.StackDriftMissing, Resource: name})
+
+			continue
+		}
+
+		if want.replicas != nil && got.replicas != nil && *want.replicas != *got.replicas {
+			differences = append(differences, portainer.StackDriftDifference{
+				Kind:     portainer.StackDriftReplicas,
+				Resource: name,
+				Desired:  strconv.Itoa(*want.replicas),
+				Actual:   strconv.Itoa(*got.replicas),
+			})
+		}
+
+		for _, wantContainer := range want.containers {
+			if wantContainer.name == "" {
+				for _, gotContainer := range got.containers {
+					differences = append(differences, compareDriftContainer(name, wantContainer, gotContainer)...)
+				}
+
+				continue
+			}
+
+			i := slices.IndexFunc(got.containers, func(c driftContainer) bool { return c.name == wantContainer.name })
+			if i == -1 {
+				differences = append(differences, portainer.StackDriftDifference{Kind: portainer.StackDriftMissing, Resource: name, Container: wantContainer.name})
+
+				continue
+			}
+
+			differences = append(differences, compareDriftContainer(name, wantContainer, got.containers[i])...)
+		}
+	}
+
+	for _, name := range sortedDriftResources(live) {
+		if _, ok := desired[name]; !ok {
+			differences = append(differences, portainer.StackDriftDifference{Kind: portainer.StackDriftUnexpected, Resource: name})
+		}
+	}
+
+	return differences
+}
+
+func compareDriftContainer(resource string, want, got driftContainer) []portainer.StackDriftDifference {
+	var differences []portainer.StackDriftDifference
+
+	if want.image != "" && normalizeDriftImage(want.image) != normalizeDriftImage(got.image) {
+		differences = append(differences, portainer.StackDriftDifference{
+			Kind:      portainer.StackDriftImage,
+			Resource:  resource,
+			Container: got.name,
+			Desired:   want.image,
+			Actual:    got.image,
+		})
+	}
+
+	for _, variable := range slices.Sorted(maps.Keys(want.env)) {
+		actual := "changed"
+
+		value, ok := got.env[variable]
+		if !ok {
+			actual = "unset"
+		} else if value == want.env[variable] {
+			continue
+		}
+
+		// The values might be secrets, only the name of the variable is reported
+		differences = append(differences, portainer.StackDriftDifference{
+			Kind:      portainer.StackDriftEnvironment,
+			Resource:  resource,
+			Container: got.name,
+			Variable:  variable,
+			Actual:    actual,
+		})
+	}
+
+	return differences
+}
+
+// normalizeDriftImage returns the familiar name of the image with its tag, without its digest,
+// so that nginx, nginx:latest and docker.io/library/nginx:latest@sha256:... are equal
+func normalizeDriftImage(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return image
+	}
+
+	return reference.FamiliarString(reference.TagNameOnly(named))
+}
+
+func sortedDriftResources(state driftState) []string {
+	return slices.Sorted(maps.Keys(state))
+}
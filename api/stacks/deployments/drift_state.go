@@ -0,0 +1,372 @@
+package deployments
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/consts"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/compose-spec/compose-go/v2/loader"
+	"github.com/compose-spec/compose-go/v2/types"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	composeServiceLabel = "com.docker.compose.service"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+)
+
+// composeDesiredState returns the services of a compose stack, as rendered by compose
+func composeDesiredState(ctx context.Context, composeStackManager portainer.ComposeStackManager, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error) {
+	config, err := composeStackManager.Config(ctx, stack, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rendered config is already interpolated and its environment already resolved
+	project, err := loader.LoadWithContext(ctx, types.ConfigDetails{
+		WorkingDir:  stack.ProjectPath,
+		ConfigFiles: []types.ConfigFile{{Filename: stack.EntryPoint, Content: config}},
+	}, func(o *loader.Options) {
+		o.SetProjectName(stack.Name, true)
+		o.SkipInterpolation = true
+		o.SkipResolveEnvironment = true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the rendered config of the stack")
+	}
+
+	return projectDriftState(project), nil
+}
+
+// swarmDesiredState returns the services of a swarm stack, interpolated with the environment variables of the stack
+func swarmDesiredState(ctx context.Context, stack *portainer.Stack) (driftState, error) {
+	stackEnv, err := envsecrets.Open(stack.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	env := types.Mapping{}
+	for _, pair := range stackEnv {
+		env[pair.Name] = pair.Value
+	}
+
+	var configFiles []types.ConfigFile
+	for _, filePath := range stackutils.GetStackFilePaths(stack, true) {
+		configFiles = append(configFiles, types.ConfigFile{Filename: filePath})
+	}
+
+	project, err := loader.LoadWithContext(ctx, types.ConfigDetails{
+		WorkingDir:  stack.ProjectPath,
+		ConfigFiles: configFiles,
+		Environment: env,
+	}, func(o *loader.Options) {
+		o.SetProjectName(stack.Name, true)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the stack files")
+	}
+
+	return projectDriftState(project), nil
+}
+
+func projectDriftState(project *types.Project) driftState {
+	state := driftState{}
+
+	for name, service := range project.Services {
+		env := map[string]string{}
+		for variable, value := range service.Environment {
+			// Variables without a value are not set inside the containers
+			if value != nil {
+				env[variable] = *value
+			}
+		}
+
+		resource := driftResource{containers: []driftContainer{{image: service.Image, env: env}}}
+
+		if service.Deploy == nil || service.Deploy.Mode != "global" {
+			replicas := service.GetScale()
+			resource.replicas = &replicas
+		}
+
+		state[name] = resource
+	}
+
+	return state
+}
+
+// composeLiveState returns the containers of a compose stack grouped by service, and the containers
+// started alongside the stack inside its networks
+func composeLiveState(ctx context.Context, cli *client.Client, stack *portainer.Stack) (driftState, error) {
+	stackFilter := filters.NewArgs(filters.Arg("label", consts.ComposeStackNameLabel+"="+stack.Name))
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: stackFilter})
+	if err != nil {
+		return nil, err
+	}
+
+	state := driftState{}
+	known := map[string]bool{}
+
+	for _, ct := range containers {
+		known[ct.ID] = true
+
+		// Containers started with "compose run" are not part of the services
+		if ct.Labels[composeOneoffLabel] == "True" {
+			continue
+		}
+
+		inspect, err := cli.ContainerInspect(ctx, ct.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		service := ct.Labels[composeServiceLabel]
+		resource := state[service]
+		resource.containers = append(resource.containers, driftContainer{
+			name:  strings.TrimPrefix(inspect.Name, "/"),
+			image: inspect.Config.Image,
+			env:   parseDriftEnv(inspect.Config.Env),
+		})
+		state[service] = resource
+	}
+
+	for service, resource := range state {
+		replicas := len(resource.containers)
+		resource.replicas = &replicas
+		state[service] = resource
+	}
+
+	networks, err := cli.NetworkList(ctx, network.ListOptions{Filters: stackFilter})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range networks {
+		inspect, err := cli.NetworkInspect(ctx, n.ID, network.InspectOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for id, endpoint := range inspect.Containers {
+			if !known[id] {
+				known[id] = true
+				state["container/"+endpoint.Name] = driftResource{}
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// swarmLiveState returns the services of a swarm stack, named as in the stack file
+func swarmLiveState(ctx context.Context, cli *client.Client, stack *portainer.Stack) (driftState, error) {
+	services, err := cli.ServiceList(ctx, dockertypes.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", consts.SwarmStackNameLabel+"="+stack.Name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	state := driftState{}
+
+	for _, service := range services {
+		spec := service.Spec.TaskTemplate.ContainerSpec
+		if spec == nil {
+			continue
+		}
+
+		resource := driftResource{containers: []driftContainer{{
+			name:  service.Spec.Name,
+			image: spec.Image,
+			env:   parseDriftEnv(spec.Env),
+		}}}
+
+		if replicated := service.Spec.Mode.Replicated; replicated != nil && replicated.Replicas != nil {
+			replicas := int(*replicated.Replicas)
+			resource.replicas = &replicas
+		}
+
+		state[strings.TrimPrefix(service.Spec.Name, stack.Name+"_")] = resource
+	}
+
+	return state, nil
+}
+
+// kubernetesManifest holds the fields of a Kubernetes workload compared by the drift check
+type kubernetesManifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Replicas *int `yaml:"replicas"`
+		Template struct {
+			Spec struct {
+				Containers []struct {
+					Name  string `yaml:"name"`
+					Image string `yaml:"image"`
+					Env   []struct {
+						Name  string  `yaml:"name"`
+						Value *string `yaml:"value"`
+					} `yaml:"env"`
+				} `yaml:"containers"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// kubernetesDesiredState returns the workloads declared by the manifests of a Kubernetes stack
+func kubernetesDesiredState(stack *portainer.Stack) (driftState, error) {
+	state := driftState{}
+
+	manifests, err := stackutils.GetKubernetesStackManifests(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range manifests {
+		if err := parseKubernetesManifests(bytes.NewReader(manifest.Content), cmp.Or(stack.Namespace, "default"), state); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse the manifest %s", manifest.Name)
+		}
+	}
+
+	return state, nil
+}
+
+// parseKubernetesManifests adds the deployments, statefulsets and daemonsets of the manifests to the state.
+// The replicas are only compared when the manifest sets them, otherwise they are left to the cluster
+func parseKubernetesManifests(r io.Reader, namespace string, state driftState) error {
+	decoder := yaml.NewDecoder(r)
+
+	for {
+		var manifest kubernetesManifest
+		if err := decoder.Decode(&manifest); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if manifest.Kind != "Deployment" && manifest.Kind != "StatefulSet" && manifest.Kind != "DaemonSet" {
+			continue
+		}
+
+		resource := driftResource{replicas: manifest.Spec.Replicas}
+		if manifest.Kind == "DaemonSet" {
+			resource.replicas = nil
+		}
+
+		for _, c := range manifest.Spec.Template.Spec.Containers {
+			env := map[string]string{}
+			for _, e := range c.Env {
+				// Variables set from a reference are resolved by the cluster
+				if e.Value != nil {
+					env[e.Name] = *e.Value
+				}
+			}
+
+			resource.containers = append(resource.containers, driftContainer{name: c.Name, image: c.Image, env: env})
+		}
+
+		state[kubernetesResourceName(manifest.Kind, cmp.Or(manifest.Metadata.Namespace, namespace), manifest.Metadata.Name)] = resource
+	}
+}
+
+// kubernetesLiveState returns the workloads of the desired state which exist inside the cluster
+func kubernetesLiveState(ctx context.Context, cli kubernetes.Interface, desired driftState) (driftState, error) {
+	state := driftState{}
+
+	for name := range desired {
+		kind, namespace, workload := parseKubernetesResourceName(name)
+
+		var replicas *int32
+		var podSpec corev1.PodSpec
+		var err error
+
+		switch kind {
+		case "Deployment":
+			var deployment *appsv1.Deployment
+			if deployment, err = cli.AppsV1().Deployments(namespace).Get(ctx, workload, metav1.GetOptions{}); err == nil {
+				replicas, podSpec = deployment.Spec.Replicas, deployment.Spec.Template.Spec
+			}
+		case "StatefulSet":
+			var statefulSet *appsv1.StatefulSet
+			if statefulSet, err = cli.AppsV1().StatefulSets(namespace).Get(ctx, workload, metav1.GetOptions{}); err == nil {
+				replicas, podSpec = statefulSet.Spec.Replicas, statefulSet.Spec.Template.Spec
+			}
+		case "DaemonSet":
+			var daemonSet *appsv1.DaemonSet
+			if daemonSet, err = cli.AppsV1().DaemonSets(namespace).Get(ctx, workload, metav1.GetOptions{}); err == nil {
+				podSpec = daemonSet.Spec.Template.Spec
+			}
+		}
+
+		if k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		resource := driftResource{}
+		if replicas != nil {
+			count := int(*replicas)
+			resource.replicas = &count
+		}
+
+		for _, c := range podSpec.Containers {
+			env := map[string]string{}
+			for _, e := range c.Env {
+				if e.ValueFrom == nil {
+					env[e.Name] = e.Value
+				}
+			}
+
+			resource.containers = append(resource.containers, driftContainer{name: c.Name, image: c.Image, env: env})
+		}
+
+		state[name] = resource
+	}
+
+	return state, nil
+}
+
+func kubernetesResourceName(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+func parseKubernetesResourceName(resource string) (kind, namespace, name string) {
+	kind, rest, _ := strings.Cut(resource, "/")
+	namespace, name, _ = strings.Cut(rest, "/")
+
+	return kind, namespace, name
+}
+
+// parseDriftEnv parses a list of KEY=VALUE environment variables
+func parseDriftEnv(env []string) map[string]string {
+	vars := make(map[string]string, len(env))
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		vars[name] = value
+	}
+
+	return vars
+}
@@ -0,0 +1,363 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/envsecrets"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func replicas(n int) *int {
+	return &n
+}
+
+func TestValidateDriftCheckSettings(t *testing.T) {
+	require.NoError(t, ValidateDriftCheckSettings(nil))
+	require.NoError(t, ValidateDriftCheckSettings(&portainer.StackDriftCheckSettings{}))
+	require.NoError(t, ValidateDriftCheckSettings(&portainer.StackDriftCheckSettings{Interval: "15m", AutoReconcile: true}))
+	require.Error(t, ValidateDriftCheckSettings(&portainer.StackDriftCheckSettings{Interval: "often"}))
+	require.Error(t, ValidateDriftCheckSettings(&portainer.StackDriftCheckSettings{Interval: "10s"}))
+}
+
+func TestCompareDriftState(t *testing.T) {
+	desired := driftState{
+		"web": {replicas: replicas(2), containers: []driftContainer{{image: "nginx", env: map[string]string{"MODE": "prod", "TOKEN": "secret"}}}},
+		"db":  {replicas: replicas(1), containers: []driftContainer{{image: "postgres:16"}}},
+		"Deployment/default/api": {replicas: replicas(3), containers: []driftContainer{
+			{name: "api", image: "registry.example.com/api:1.0"},
+			{name: "sidecar", image: "envoy:1.30"},
+		}},
+	}
+
+	live := driftState{
+		"web": {replicas: replicas(3), containers: []driftContainer{
+			{name: "app-web-1", image: "docker.io/library/nginx:latest@sha256:6457d53fb065d6f250e1504b9bc42d5b6c65941d57532c072d929dd0628977d0", env: map[string]string{"MODE": "prod", "TOKEN": "secret", "PATH": "/usr/bin"}},
+			{name: "app-web-2", image: "nginx:1.27", env: map[string]string{"MODE": "debug"}},
+		}},
+		"Deployment/default/api": {replicas: replicas(3), containers: []driftContainer{
+			{name: "api", image: "registry.example.com/api:1.0"},
+		}},
+		"container/debug": {},
+	}
+
+	require.Equal(t, []portainer.StackDriftDifference{
+		{Kind: portainer.StackDriftMissing, Resource: "Deployment/default/api", Container: "sidecar"},
+		{Kind: portainer.StackDriftMissing, Resource: "db"},
+		{Kind: portainer.StackDriftReplicas, Resource: "web", Desired: "2", Actual: "3"},
+		{Kind: portainer.StackDriftImage, Resource: "web", Container: "app-web-2", Desired: "nginx", Actual: "nginx:1.27"},
+		{Kind: portainer.StackDriftEnvironment, Resource: "web", Container: "app-web-2", Variable: "MODE", Actual: "changed"},
+		{Kind: portainer.StackDriftEnvironment, Resource: "web", Container: "app-web-2", Variable: "TOKEN", Actual: "unset"},
+		{Kind: portainer.StackDriftUnexpected, Resource: "container/debug"},
+	}, compareDriftState(desired, live))
+
+	require.Empty(t, compareDriftState(desired, desired))
+}
+
+func TestSwarmDesiredState(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(`
+services:
+  web:
+    image: nginx:${TAG}
+    environment:
+      - MODE=prod
+      - HOST_VAR
+    deploy:
+      replicas: 3
+  agent:
+    image: agent
+    deploy:
+      mode: global
+`), 0600)
+	require.NoError(t, err)
+
+	state, err := swarmDesiredState(context.Background(), &portainer.Stack{
+		Name:        "app",
+		ProjectPath: dir,
+		EntryPoint:  "docker-compose.yml",
+		Env:         []portainer.Pair{{Name: "TAG", Value: "1.27"}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, driftState{
+		"web":   {replicas: replicas(3), containers: []driftContainer{{image: "nginx:1.27", env: map[string]string{"MODE": "prod"}}}},
+		"agent": {containers: []driftContainer{{image: "agent", env: map[string]string{}}}},
+	}, state)
+}
+
+func TestSwarmDesiredStateDecryptsSecrets(t *testing.T) {
+	require.NoError(t, envsecrets.Init(t.TempDir()))
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(`
+services:
+  web:
+    image: nginx
+    environment:
+      - TOKEN=${TOKEN}
+`), 0600)
+	require.NoError(t, err)
+
+	env, err := envsecrets.Seal([]portainer.Pair{{Name: "TOKEN", Value: "s3cr3t", Secret: true}}, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, "s3cr3t", env[0].Value)
+
+	state, err := swarmDesiredState(context.Background(), &portainer.Stack{
+		Name:        "app",
+		ProjectPath: dir,
+		EntryPoint:  "docker-compose.yml",
+		Env:         env,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"TOKEN": "s3cr3t"}, state["web"].containers[0].env)
+}
+
+func TestKubernetesDriftState(t *testing.T) {
+	state := driftState{}
+
+	err := parseKubernetesManifests(strings.NewReader(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+          env:
+            - name: MODE
+              value: prod
+            - name: TOKEN
+              valueFrom:
+                secretKeyRef:
+                  name: web
+                  key: token
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+  namespace: monitoring
+spec:
+  template:
+    spec:
+      containers:
+        - name: agent
+          image: agent:1.0
+`), "apps", state)
+	require.NoError(t, err)
+
+	require.Equal(t, driftState{
+		"Deployment/apps/web":        {replicas: replicas(2), containers: []driftContainer{{name: "web", image: "nginx:1.27", env: map[string]string{"MODE": "prod"}}}},
+		"DaemonSet/monitoring/agent": {containers: []driftContainer{{name: "agent", image: "agent:1.0", env: map[string]string{}}}},
+	}, state)
+
+	liveReplicas := int32(5)
+	cli := kfake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &liveReplicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Image: "nginx:1.25",
+				Env:   []corev1.EnvVar{{Name: "MODE", Value: "prod"}},
+			}}}},
+		},
+	})
+
+	live, err := kubernetesLiveState(context.Background(), cli, state)
+	require.NoError(t, err)
+
+	require.Equal(t, []portainer.StackDriftDifference{
+		{Kind: portainer.StackDriftMissing, Resource: "DaemonSet/monitoring/agent"},
+		{Kind: portainer.StackDriftReplicas, Resource: "Deployment/apps/web", Desired: "2", Actual: "5"},
+		{Kind: portainer.StackDriftImage, Resource: "Deployment/apps/web", Container: "web", Desired: "nginx:1.27", Actual: "nginx:1.25"},
+	}, compareDriftState(state, live))
+}
+
+func TestDriftDetectorCheck(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 3, 0, 0, 0, time.UTC)
+
+	desired := driftState{"web": {replicas: replicas(1), containers: []driftContainer{{image: "nginx:1.27"}}}}
+	drifted := driftState{"web": {replicas: replicas(1), containers: []driftContainer{{name: "app-web-1", image: "nginx:1.25"}}}}
+
+	cases := []struct {
+		name               string
+		live               driftState
+		autoReconcile      bool
+		deployErr          error
+		expectedDeploy     int
+		expectedDrifted    bool
+		expectedReconciled bool
+		expectedError      bool
+	}{
+		{name: "no drift", live: desired, autoReconcile: true},
+		{name: "drift", live: drifted, expectedDrifted: true},
+		{name: "drift reconciled", live: drifted, autoReconcile: true, expectedDeploy: 1, expectedDrifted: true, expectedReconciled: true},
+		{name: "failed reconciliation", live: drifted, autoReconcile: true, deployErr: errors.New("deploy failed"), expectedDeploy: 1, expectedDrifted: true, expectedError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, store := datastore.MustNewTestStore(t, true, true)
+
+			require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+			require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+			require.NoError(t, store.Stack().Create(&portainer.Stack{
+				ID:         1,
+				Name:       "app",
+				Type:       portainer.DockerComposeStack,
+				Status:     portainer.StackStatusActive,
+				EndpointID: 1,
+				CreatedBy:  "admin",
+				DriftCheck: &portainer.StackDriftCheckSettings{Interval: "15m", AutoReconcile: tc.autoReconcile},
+			}))
+
+			deployer := &recordingDeployer{err: tc.deployErr}
+
+			detector := &DriftDetector{
+				deployer:  deployer,
+				dataStore: store,
+				desiredState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error) {
+					return desired, nil
+				},
+				liveState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, desired driftState) (driftState, error) {
+					return tc.live, nil
+				},
+				now: func() time.Time { return now },
+			}
+
+			err := detector.Check(1)
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.expectedDeploy, deployer.composeDeploys)
+
+			stack, err := store.Stack().Read(1)
+			require.NoError(t, err)
+
+			report := stack.DriftCheck.LastReport
+			require.NotNil(t, report)
+			require.Equal(t, now.Unix(), report.Date)
+			require.Equal(t, tc.expectedDrifted, report.Drifted)
+			require.Equal(t, tc.expectedReconciled, report.Reconciled)
+			require.Equal(t, tc.expectedError, report.Error != "")
+		})
+	}
+}
+
+func TestDriftDetectorCheckKeepsConcurrentChanges(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+	require.NoError(t, store.Stack().Create(&portainer.Stack{
+		ID:         1,
+		Name:       "app",
+		Type:       portainer.DockerComposeStack,
+		Status:     portainer.StackStatusActive,
+		EndpointID: 1,
+		DriftCheck: &portainer.StackDriftCheckSettings{Interval: "15m", JobID: "job"},
+	}))
+
+	var liveState func() error
+
+	detector := &DriftDetector{
+		deployer:  &recordingDeployer{},
+		dataStore: store,
+		desiredState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error) {
+			return driftState{}, nil
+		},
+		liveState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, desired driftState) (driftState, error) {
+			return driftState{}, liveState()
+		},
+		now: time.Now,
+	}
+
+	// The drift check is disabled while the stack is checked
+	liveState = func() error {
+		return store.Stack().Update(1, &portainer.Stack{ID: 1, Name: "app", Type: portainer.DockerComposeStack, Status: portainer.StackStatusActive, EndpointID: 1, DriftCheck: &portainer.StackDriftCheckSettings{}})
+	}
+
+	require.NoError(t, detector.Check(1))
+
+	stack, err := store.Stack().Read(1)
+	require.NoError(t, err)
+	require.Empty(t, stack.DriftCheck.Interval)
+	require.Empty(t, stack.DriftCheck.JobID)
+	require.NotNil(t, stack.DriftCheck.LastReport)
+
+	// The stack is deleted while it is checked
+	liveState = func() error {
+		return store.Stack().Delete(1)
+	}
+
+	require.NoError(t, detector.Check(1))
+
+	_, err = store.Stack().Read(1)
+	require.True(t, store.IsErrObjectNotFound(err))
+}
+
+func TestDriftDetectorRunReconcilesOnBehalfOfTheUser(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+	require.NoError(t, store.User().Create(&portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}))
+
+	stack := &portainer.Stack{ID: 1, Name: "app", Type: portainer.DockerComposeStack, Status: portainer.StackStatusActive, EndpointID: 1, CreatedBy: "removed"}
+	require.NoError(t, store.Stack().Create(stack))
+
+	deployer := &recordingDeployer{}
+
+	detector := &DriftDetector{
+		deployer:  deployer,
+		dataStore: store,
+		desiredState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) (driftState, error) {
+			return driftState{"web": {}}, nil
+		},
+		liveState: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint, desired driftState) (driftState, error) {
+			return driftState{}, nil
+		},
+		now: time.Now,
+	}
+
+	endpoint := &portainer.Endpoint{ID: 1}
+
+	_, err := detector.Run(context.Background(), stack, endpoint, true, nil)
+	require.ErrorContains(t, err, "removed")
+	require.Equal(t, 0, deployer.composeDeploys)
+
+	user, err := store.User().Read(1)
+	require.NoError(t, err)
+
+	report, err := detector.Run(context.Background(), stack, endpoint, true, user)
+	require.NoError(t, err)
+	require.True(t, report.Reconciled)
+	require.Equal(t, 1, deployer.composeDeploys)
+}
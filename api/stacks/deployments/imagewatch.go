@@ -0,0 +1,517 @@
+package deployments
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/docker/consts"
+	"github.com/portainer/portainer/api/docker/images"
+	"github.com/portainer/portainer/api/scheduler"
+	"github.com/portainer/portainer/api/stacks/stackutils"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// minImageWatchInterval is the shortest interval allowed between two checks, to protect the registries rate limits
+	minImageWatchInterval = time.Minute
+	// maxImageWatchHistory is the number of events kept in the history of a stack
+	maxImageWatchHistory = 20
+	// imageWatchListTimeout bounds the listing of the images deployed by a stack
+	imageWatchListTimeout = time.Minute
+)
+
+// stackImage is an image used by the services of a stack
+type stackImage struct {
+	// reference used by the stack, e.g. nginx:latest
+	image    string
+	services []string
+	// digests of the image deployed
+	digests []digest.Digest
+}
+
+// ImageWatcher periodically checks the images of compose and swarm stacks and redeploys the stacks
+// when a newer image digest is published
+type ImageWatcher struct {
+	scheduler    *scheduler.Scheduler
+	deployer     StackDeployer
+	dataStore    dataservices.DataStore
+	listImages   func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]stackImage, error)
+	remoteDigest func(image images.Image) (digest.Digest, error)
+	now          func() time.Time
+}
+
+// NewImageWatcher creates an ImageWatcher
+func NewImageWatcher(scheduler *scheduler.Scheduler, deployer StackDeployer, dataStore dataservices.DataStore, clientFactory *dockerclient.ClientFactory) *ImageWatcher {
+	digestClient := images.NewClientWithRegistry(images.NewRegistryClient(dataStore), clientFactory)
+
+	return &ImageWatcher{
+		scheduler: scheduler,
+		deployer:  deployer,
+		dataStore: dataStore,
+		listImages: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]stackImage, error) {
+			return listStackImages(ctx, clientFactory, stack, endpoint)
+		},
+		remoteDigest: digestClient.RemoteDigest,
+		now:          time.Now,
+	}
+}
+
+// ValidateImageWatchSettings validates the image watch settings of a stack
+func ValidateImageWatchSettings(settings *portainer.StackImageWatchSettings) error {
+	if settings == nil || settings.Interval == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(settings.Interval)
+	if err != nil {
+		return errors.Wrap(err, "invalid image watch interval")
+	}
+
+	if interval < minImageWatchInterval {
+		return errors.Errorf("image watch interval must be at least %s", minImageWatchInterval)
+	}
+
+	window := settings.MaintenanceWindow
+	if window == nil {
+		return nil
+	}
+
+	if _, err := parseClock(window.Start); err != nil {
+		return errors.Wrap(err, "invalid maintenance window start")
+	}
+
+	if _, err := parseClock(window.End); err != nil {
+		return errors.Wrap(err, "invalid maintenance window end")
+	}
+
+	if window.Start == window.End {
+		return errors.New("the maintenance window start and end must be different")
+	}
+
+	for _, day := range window.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return errors.Errorf("invalid maintenance window day %d", day)
+		}
+	}
+
+	return nil
+}
+
+// InMaintenanceWindow returns true when the time is inside the window, a nil window is always open
+func InMaintenanceWindow(window *portainer.StackMaintenanceWindow, t time.Time) bool {
+	if window == nil {
+		return true
+	}
+
+	start, err := parseClock(window.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := parseClock(window.End)
+	if err != nil {
+		return false
+	}
+
+	t = t.UTC()
+	minutes := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	inWindow := minutes >= start && minutes < end
+	if start > end {
+		inWindow = minutes >= start || minutes < end
+
+		// After midnight, the window belongs to the day it started
+		if minutes < end {
+			day = (day + 6) % 7
+		}
+	}
+
+	if !inWindow {
+		return false
+	}
+
+	return len(window.Days) == 0 || slices.Contains(window.Days, day)
+}
+
+// parseClock parses a HH:MM time into the number of minutes since midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Start schedules the image watch of the stack and returns the job identifier
+func (w *ImageWatcher) Start(stackID portainer.StackID, interval string) (string, error) {
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse the stack image watch interval")
+	}
+
+	return w.scheduler.StartJobEvery(d, func() error {
+		return w.Check(stackID)
+	}), nil
+}
+
+// Stop stops the image watch job of the stack
+func (w *ImageWatcher) Stop(stackID portainer.StackID, jobID string) {
+	StopAutoupdate(stackID, jobID, w.scheduler)
+}
+
+// StartSchedules schedules the image watch of all the stacks configured for it
+func (w *ImageWatcher) StartSchedules() error {
+	stacks, err := w.dataStore.Stack().ImageWatchedStacks()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch image watched stacks")
+	}
+
+	for _, stack := range stacks {
+		if stack.Status == portainer.StackStatusInactive {
+			continue
+		}
+
+		jobID, err := w.Start(stack.ID, stack.ImageWatch.Interval)
+		if err != nil {
+			return err
+		}
+
+		stack.ImageWatch.JobID = jobID
+		if err := w.dataStore.Stack().Update(stack.ID, &stack); err != nil {
+			return errors.Wrap(err, "failed to update stack job id")
+		}
+	}
+
+	return nil
+}
+
+// Check compares the images of the stack with the digests published inside the registries,
+// and depending on the policy of the stack redeploys it or records the changes
+func (w *ImageWatcher) Check(stackID portainer.StackID) error {
+	_, err, _ := singleflightGroup.Do("imagewatch:"+strconv.Itoa(int(stackID)), func() (any, error) {
+		return nil, w.check(stackID)
+	})
+
+	return err
+}
+
+func (w *ImageWatcher) check(stackID portainer.StackID) error {
+	stack, err := w.dataStore.Stack().Read(stackID)
+	if dataservices.IsErrObjectNotFound(err) {
+		return scheduler.NewPermanentError(errors.WithMessagef(err, "failed to get the stack %v", stackID))
+	} else if err != nil {
+		return errors.WithMessagef(err, "failed to get the stack %v", stackID)
+	}
+
+	if stack.ImageWatch == nil || stack.Status == portainer.StackStatusInactive {
+		return nil
+	}
+
+	if stack.Type != portainer.DockerComposeStack && stack.Type != portainer.DockerSwarmStack {
+		return scheduler.NewPermanentError(errors.Errorf("cannot watch the images of the stack %v, type %v is unsupported", stack.ID, stack.Type))
+	}
+
+	endpoint, err := w.dataStore.Endpoint().Endpoint(stack.EndpointID)
+	if dataservices.IsErrObjectNotFound(err) {
+		return scheduler.NewPermanentError(errors.WithMessagef(err, "failed to find the environment %v associated to the stack %v", stack.EndpointID, stack.ID))
+	} else if err != nil {
+		return errors.WithMessagef(err, "failed to find the environment %v associated to the stack %v", stack.EndpointID, stack.ID)
+	}
+
+	if !isEnvironmentOnline(endpoint) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), imageWatchListTimeout)
+	defer cancel()
+
+	changes, err := w.changes(ctx, stack, endpoint)
+	if err != nil {
+		return err
+	}
+
+	now := w.now()
+
+	var event *portainer.StackImageWatchEvent
+
+	if len(changes) > 0 {
+		event = &portainer.StackImageWatchEvent{Date: now.Unix(), Images: changes}
+
+		switch {
+		case stack.ImageWatch.NotifyOnly:
+			event.Action = portainer.StackImageWatchNotified
+		case !InMaintenanceWindow(stack.ImageWatch.MaintenanceWindow, now):
+			event.Action = portainer.StackImageWatchDeferred
+		default:
+			event.Action = portainer.StackImageWatchRedeployed
+
+			if err := w.redeploy(stack, endpoint); err != nil {
+				event.Action = portainer.StackImageWatchFailed
+				event.Error = err.Error()
+
+				log.Error().Err(err).Int("stack_id", int(stack.ID)).Str("stack", stack.Name).Msg("unable to redeploy the stack with the new images")
+			}
+		}
+
+		logEvent := log.Info()
+		if event.Action != portainer.StackImageWatchRedeployed {
+			logEvent = log.Warn()
+		}
+
+		logEvent.Int("stack_id", int(stack.ID)).
+			Str("stack", stack.Name).
+			Str("action", string(event.Action)).
+			Int("images", len(changes)).
+			Msg("newer images detected for the stack")
+	}
+
+	return w.saveCheck(stack, now, event)
+}
+
+// saveCheck records the check date and the event in the image watch settings of the stack. The stack is
+// read again so that the changes made to it while its images were checked are kept
+func (w *ImageWatcher) saveCheck(stack *portainer.Stack, now time.Time, event *portainer.StackImageWatchEvent) error {
+	err := w.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		current, err := tx.Stack().Read(stack.ID)
+		if tx.IsErrObjectNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// The image watch was disabled while the images were checked
+		if current.ImageWatch == nil {
+			return nil
+		}
+
+		current.ImageWatch.LastCheckDate = now.Unix()
+
+		if event != nil {
+			recordImageWatchEvent(current.ImageWatch, *event)
+
+			if event.Action == portainer.StackImageWatchRedeployed {
+				current.UpdateDate = stack.UpdateDate
+			}
+		}
+
+		return tx.Stack().Update(current.ID, current)
+	})
+
+	return errors.WithMessagef(err, "failed to update the stack %v", stack.ID)
+}
+
+// changes returns the images of the stack for which a different digest is published inside the registry
+func (w *ImageWatcher) changes(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]portainer.StackImageChange, error) {
+	stackImages, err := w.listImages(ctx, stack, endpoint)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to list the images of the stack %v", stack.ID)
+	}
+
+	changes := make([]portainer.StackImageChange, 0)
+
+	for _, si := range stackImages {
+		img, err := images.ParseImage(images.ParseImageOptions{Name: si.image})
+		if err != nil {
+			log.Debug().Err(err).Str("image", si.image).Msg("unable to parse the image of the stack")
+
+			continue
+		}
+
+		// Images referenced by digest never change, and locally built images have no digest to compare with
+		if img.Tag == "" || len(si.digests) == 0 {
+			continue
+		}
+
+		remote, err := w.remoteDigest(img)
+		if err != nil {
+			log.Warn().Err(err).Str("image", si.image).Int("stack_id", int(stack.ID)).Msg("unable to retrieve the remote digest of the image")
+
+			continue
+		}
+
+		if slices.Contains(si.digests, remote) {
+			continue
+		}
+
+		changes = append(changes, portainer.StackImageChange{
+			Image:          si.image,
+			Services:       si.services,
+			PreviousDigest: si.digests[0].String(),
+			Digest:         remote.String(),
+		})
+	}
+
+	return changes, nil
+}
+
+func (w *ImageWatcher) redeploy(stack *portainer.Stack, endpoint *portainer.Endpoint) error {
+	author := cmp.Or(stack.UpdatedBy, stack.CreatedBy)
+
+	user, err := w.dataStore.User().UserByUsername(author)
+	if err != nil {
+		return &StackAuthorMissingErr{int(stack.ID), author}
+	}
+
+	registries, err := getUserRegistries(w.dataStore, user, endpoint.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateDeployment(w.dataStore, stack, endpoint, user); err != nil {
+		return err
+	}
+
+	switch stack.Type {
+	case portainer.DockerComposeStack:
+		if stackutils.IsRelativePathStack(stack) {
+			err = w.deployer.DeployRemoteComposeStack(stack, endpoint, registries, true, false)
+		} else {
+			err = w.deployer.DeployComposeStack(stack, endpoint, registries, true, false)
+		}
+	case portainer.DockerSwarmStack:
+		prune := stack.Option != nil && stack.Option.Prune
+
+		if stackutils.IsRelativePathStack(stack) {
+			err = w.deployer.DeployRemoteSwarmStack(stack, endpoint, registries, prune, true)
+		} else {
+			err = w.deployer.DeploySwarmStack(stack, endpoint, registries, prune, true)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	images.EvictImageStatus(stack.Name)
+	stack.UpdateDate = w.now().Unix()
+
+	return nil
+}
+
+// recordImageWatchEvent adds the event to the history, unless it repeats the latest event
+func recordImageWatchEvent(settings *portainer.StackImageWatchSettings, event portainer.StackImageWatchEvent) {
+	if len(settings.History) > 0 && sameImageWatchEvent(settings.History[0], event) {
+		return
+	}
+
+	settings.History = append([]portainer.StackImageWatchEvent{event}, settings.History...)
+
+	if len(settings.History) > maxImageWatchHistory {
+		settings.History = settings.History[:maxImageWatchHistory]
+	}
+}
+
+func sameImageWatchEvent(a, b portainer.StackImageWatchEvent) bool {
+	if a.Action != b.Action || a.Action == portainer.StackImageWatchFailed || len(a.Images) != len(b.Images) {
+		return false
+	}
+
+	for i := range a.Images {
+		if a.Images[i].Image != b.Images[i].Image || a.Images[i].Digest != b.Images[i].Digest {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listStackImages returns the images used by the containers of a compose stack or by the services of a swarm stack
+func listStackImages(ctx context.Context, clientFactory *dockerclient.ClientFactory, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]stackImage, error) {
+	cli, err := clientFactory.CreateClient(endpoint, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	byImage := map[string]*stackImage{}
+	var ordered []string
+
+	add := func(image, service string, digests []digest.Digest) {
+		si, ok := byImage[image]
+		if !ok {
+			si = &stackImage{image: image}
+			byImage[image] = si
+			ordered = append(ordered, image)
+		}
+
+		if !slices.Contains(si.services, service) {
+			si.services = append(si.services, service)
+		}
+
+		for _, d := range digests {
+			if !slices.Contains(si.digests, d) {
+				si.digests = append(si.digests, d)
+			}
+		}
+	}
+
+	if stack.Type == portainer.DockerSwarmStack {
+		services, err := cli.ServiceList(ctx, types.ServiceListOptions{
+			Filters: filters.NewArgs(filters.Arg("label", consts.SwarmStackNameLabel+"="+stack.Name)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, service := range services {
+			if service.Spec.TaskTemplate.ContainerSpec == nil {
+				continue
+			}
+
+			// Swarm pins the digest of the image inside the service spec
+			image, pinned, _ := strings.Cut(service.Spec.TaskTemplate.ContainerSpec.Image, "@")
+
+			var digests []digest.Digest
+			if d, err := digest.Parse(pinned); err == nil {
+				digests = append(digests, d)
+			}
+
+			add(image, service.Spec.Name, digests)
+		}
+	} else {
+		containers, err := cli.ContainerList(ctx, container.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("label", consts.ComposeStackNameLabel+"="+stack.Name)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ct := range containers {
+			inspect, err := cli.ContainerInspect(ctx, ct.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			imageInspect, _, err := cli.ImageInspectWithRaw(ctx, inspect.Image)
+			if err != nil {
+				return nil, fmt.Errorf("unable to inspect the image of the container %s: %w", ct.ID, err)
+			}
+
+			add(inspect.Config.Image, ct.Labels["com.docker.compose.service"], images.ParseRepoDigests(imageInspect.RepoDigests))
+		}
+	}
+
+	result := make([]stackImage, 0, len(ordered))
+	for _, image := range ordered {
+		result = append(result, *byImage[image])
+	}
+
+	return result, nil
+}
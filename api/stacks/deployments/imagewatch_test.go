@@ -0,0 +1,252 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/docker/images"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDeployer struct {
+	noopDeployer
+	composeDeploys int
+	swarmDeploys   int
+	err            error
+}
+
+func (d *recordingDeployer) DeployComposeStack(stack *portainer.Stack, endpoint *portainer.Endpoint, registries []portainer.Registry, forcePullImage, forceRecreate bool) error {
+	d.composeDeploys++
+
+	return d.err
+}
+
+func (d *recordingDeployer) DeploySwarmStack(stack *portainer.Stack, endpoint *portainer.Endpoint, registries []portainer.Registry, prune, pullImage bool) error {
+	d.swarmDeploys++
+
+	return d.err
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	saturday := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		window   *portainer.StackMaintenanceWindow
+		t        time.Time
+		expected bool
+	}{
+		{name: "no window", window: nil, t: saturday, expected: true},
+		{name: "inside", window: &portainer.StackMaintenanceWindow{Start: "02:00", End: "04:00"}, t: saturday.Add(3 * time.Hour), expected: true},
+		{name: "at the end", window: &portainer.StackMaintenanceWindow{Start: "02:00", End: "04:00"}, t: saturday.Add(4 * time.Hour), expected: false},
+		{name: "before", window: &portainer.StackMaintenanceWindow{Start: "02:00", End: "04:00"}, t: saturday.Add(time.Hour), expected: false},
+		{name: "other day", window: &portainer.StackMaintenanceWindow{Start: "02:00", End: "04:00", Days: []time.Weekday{time.Sunday}}, t: saturday.Add(3 * time.Hour), expected: false},
+		{name: "matching day", window: &portainer.StackMaintenanceWindow{Start: "02:00", End: "04:00", Days: []time.Weekday{time.Saturday}}, t: saturday.Add(3 * time.Hour), expected: true},
+		{name: "spanning midnight before midnight", window: &portainer.StackMaintenanceWindow{Start: "22:00", End: "02:00", Days: []time.Weekday{time.Saturday}}, t: saturday.Add(23 * time.Hour), expected: true},
+		{name: "spanning midnight after midnight", window: &portainer.StackMaintenanceWindow{Start: "22:00", End: "02:00", Days: []time.Weekday{time.Saturday}}, t: saturday.Add(25 * time.Hour), expected: true},
+		{name: "spanning midnight started the day before", window: &portainer.StackMaintenanceWindow{Start: "22:00", End: "02:00", Days: []time.Weekday{time.Saturday}}, t: saturday.Add(time.Hour), expected: false},
+		{name: "spanning midnight outside", window: &portainer.StackMaintenanceWindow{Start: "22:00", End: "02:00"}, t: saturday.Add(12 * time.Hour), expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, InMaintenanceWindow(tc.window, tc.t))
+		})
+	}
+}
+
+func TestValidateImageWatchSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings *portainer.StackImageWatchSettings
+		valid    bool
+	}{
+		{name: "disabled", settings: nil, valid: true},
+		{name: "interval", settings: &portainer.StackImageWatchSettings{Interval: "1h"}, valid: true},
+		{name: "invalid interval", settings: &portainer.StackImageWatchSettings{Interval: "often"}, valid: false},
+		{name: "too short interval", settings: &portainer.StackImageWatchSettings{Interval: "10s"}, valid: false},
+		{name: "window", settings: &portainer.StackImageWatchSettings{Interval: "1h", MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "22:00", End: "02:00", Days: []time.Weekday{time.Monday}}}, valid: true},
+		{name: "invalid window start", settings: &portainer.StackImageWatchSettings{Interval: "1h", MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "25:00", End: "02:00"}}, valid: false},
+		{name: "empty window", settings: &portainer.StackImageWatchSettings{Interval: "1h", MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "02:00", End: "02:00"}}, valid: false},
+		{name: "invalid window day", settings: &portainer.StackImageWatchSettings{Interval: "1h", MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "02:00", End: "03:00", Days: []time.Weekday{7}}}, valid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateImageWatchSettings(tc.settings)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestImageWatcherCheck(t *testing.T) {
+	const (
+		oldDigest = digest.Digest("sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b")
+		newDigest = digest.Digest("sha256:6457d53fb065d6f250e1504b9bc42d5b6c65941d57532c072d929dd0628977d0")
+	)
+
+	saturday := time.Date(2024, time.June, 1, 3, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name           string
+		settings       portainer.StackImageWatchSettings
+		remote         digest.Digest
+		deployErr      error
+		expectedDeploy int
+		expectedAction portainer.StackImageWatchAction
+	}{
+		{name: "up to date", remote: oldDigest},
+		{name: "redeploy", remote: newDigest, expectedDeploy: 1, expectedAction: portainer.StackImageWatchRedeployed},
+		{name: "notify only", settings: portainer.StackImageWatchSettings{NotifyOnly: true}, remote: newDigest, expectedAction: portainer.StackImageWatchNotified},
+		{
+			name:           "outside of the maintenance window",
+			settings:       portainer.StackImageWatchSettings{MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "04:00", End: "05:00"}},
+			remote:         newDigest,
+			expectedAction: portainer.StackImageWatchDeferred,
+		},
+		{
+			name:           "inside the maintenance window",
+			settings:       portainer.StackImageWatchSettings{MaintenanceWindow: &portainer.StackMaintenanceWindow{Start: "02:00", End: "05:00"}},
+			remote:         newDigest,
+			expectedDeploy: 1,
+			expectedAction: portainer.StackImageWatchRedeployed,
+		},
+		{name: "failed redeploy", remote: newDigest, deployErr: errors.New("pull failed"), expectedDeploy: 1, expectedAction: portainer.StackImageWatchFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, store := datastore.MustNewTestStore(t, true, true)
+
+			require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+			require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+
+			settings := tc.settings
+			settings.Interval = "1h"
+
+			require.NoError(t, store.Stack().Create(&portainer.Stack{
+				ID:         1,
+				Name:       "app",
+				Type:       portainer.DockerComposeStack,
+				Status:     portainer.StackStatusActive,
+				EndpointID: 1,
+				CreatedBy:  "admin",
+				ImageWatch: &settings,
+			}))
+
+			deployer := &recordingDeployer{err: tc.deployErr}
+
+			watcher := &ImageWatcher{
+				deployer:  deployer,
+				dataStore: store,
+				listImages: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]stackImage, error) {
+					return []stackImage{
+						{image: "nginx:latest", services: []string{"web"}, digests: []digest.Digest{oldDigest}},
+						{image: "local-build", services: []string{"worker"}},
+						{image: "redis@" + oldDigest.String(), services: []string{"cache"}, digests: []digest.Digest{oldDigest}},
+					}, nil
+				},
+				remoteDigest: func(image images.Image) (digest.Digest, error) {
+					require.Equal(t, "latest", image.Tag)
+
+					return tc.remote, nil
+				},
+				now: func() time.Time { return saturday },
+			}
+
+			require.NoError(t, watcher.Check(1))
+			require.Equal(t, tc.expectedDeploy, deployer.composeDeploys)
+
+			stack, err := store.Stack().Read(1)
+			require.NoError(t, err)
+			require.Equal(t, saturday.Unix(), stack.ImageWatch.LastCheckDate)
+
+			if tc.expectedAction == "" {
+				require.Empty(t, stack.ImageWatch.History)
+
+				return
+			}
+
+			require.Len(t, stack.ImageWatch.History, 1)
+			require.Equal(t, tc.expectedAction, stack.ImageWatch.History[0].Action)
+			require.Equal(t, []portainer.StackImageChange{{
+				Image:          "nginx:latest",
+				Services:       []string{"web"},
+				PreviousDigest: oldDigest.String(),
+				Digest:         newDigest.String(),
+			}}, stack.ImageWatch.History[0].Images)
+
+			// The same changes are not recorded twice, unless the redeployment failed
+			require.NoError(t, watcher.Check(1))
+
+			stack, err = store.Stack().Read(1)
+			require.NoError(t, err)
+
+			expectedEvents := 1
+			if tc.expectedAction == portainer.StackImageWatchFailed {
+				expectedEvents = 2
+			}
+			require.Len(t, stack.ImageWatch.History, expectedEvents)
+		})
+	}
+}
+
+func TestImageWatcherCheckKeepsConcurrentChanges(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1}))
+	require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+
+	require.NoError(t, store.Stack().Create(&portainer.Stack{
+		ID:         1,
+		Name:       "app",
+		Type:       portainer.DockerComposeStack,
+		Status:     portainer.StackStatusActive,
+		EndpointID: 1,
+		CreatedBy:  "admin",
+		ImageWatch: &portainer.StackImageWatchSettings{Interval: "1h", NotifyOnly: true},
+	}))
+
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	watcher := &ImageWatcher{
+		deployer:  &recordingDeployer{},
+		dataStore: store,
+		listImages: func(ctx context.Context, stack *portainer.Stack, endpoint *portainer.Endpoint) ([]stackImage, error) {
+			_, hasDeadline := ctx.Deadline()
+			require.True(t, hasDeadline)
+
+			// The stack is updated while its images are checked
+			updated, err := store.Stack().Read(stack.ID)
+			require.NoError(t, err)
+
+			updated.Env = []portainer.Pair{{Name: "MODE", Value: "production"}}
+			require.NoError(t, store.Stack().Update(updated.ID, updated))
+
+			return []stackImage{{image: "nginx:latest", services: []string{"web"}, digests: []digest.Digest{digest.FromString("old")}}}, nil
+		},
+		remoteDigest: func(image images.Image) (digest.Digest, error) {
+			return digest.FromString("new"), nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	require.NoError(t, watcher.Check(1))
+
+	stack, err := store.Stack().Read(1)
+	require.NoError(t, err)
+	require.Equal(t, []portainer.Pair{{Name: "MODE", Value: "production"}}, stack.Env)
+	require.Equal(t, now.Unix(), stack.ImageWatch.LastCheckDate)
+	require.Len(t, stack.ImageWatch.History, 1)
+	require.Equal(t, portainer.StackImageWatchNotified, stack.ImageWatch.History[0].Action)
+}
@@ -44,6 +44,7 @@ func (b *ComposeStackFileContentBuilder) SetUniqueInfo(payload *StackPayload) Fi
 	b.stack.EntryPoint = filesystem.ComposeFileDefaultName
 	b.stack.Env = payload.Env
 	b.stack.FromAppTemplate = payload.FromAppTemplate
+	b.stack.ComposeProfiles = payload.ComposeProfiles
 	return b
 }
 
@@ -60,6 +61,8 @@ func (b *ComposeStackFileContentBuilder) SetFileContent(payload *StackPayload) F
 	}
 	b.stack.ProjectPath = projectPath
 
+	b.storeUploadedEnvFiles(payload)
+
 	return b
 }
 
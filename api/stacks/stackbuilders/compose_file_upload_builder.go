@@ -41,6 +41,7 @@ func (b *ComposeStackFileUploadBuilder) SetUniqueInfo(payload *StackPayload) Fil
 	b.stack.Type = portainer.DockerComposeStack
 	b.stack.EntryPoint = filesystem.ComposeFileDefaultName
 	b.stack.Env = payload.Env
+	b.stack.ComposeProfiles = payload.ComposeProfiles
 	return b
 }
 
@@ -50,6 +51,7 @@ func (b *ComposeStackFileUploadBuilder) SetUploadedFile(payload *StackPayload) F
 	}
 
 	b.FileUploadMethodStackBuilder.SetUploadedFile(payload)
+	b.storeUploadedEnvFiles(payload)
 
 	return b
 }
@@ -46,6 +46,8 @@ func (b *ComposeStackGitBuilder) SetUniqueInfo(payload *StackPayload) GitMethodS
 	b.stack.EntryPoint = payload.ComposeFile
 	b.stack.FromAppTemplate = payload.FromAppTemplate
 	b.stack.Env = payload.Env
+	b.stack.ComposeProfiles = payload.ComposeProfiles
+	b.stack.EnvFiles = payload.EnvFiles
 	return b
 }
 
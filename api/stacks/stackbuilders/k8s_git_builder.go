@@ -55,6 +55,11 @@ func (b *KubernetesStackGitBuilder) SetUniqueInfo(payload *StackPayload) GitMeth
 	b.stack.Namespace = payload.Namespace
 	b.stack.Name = payload.StackName
 	b.stack.EntryPoint = payload.ManifestFile
+	b.stack.KustomizePath = payload.KustomizePath
+	if payload.KustomizePath != "" {
+		b.stack.EntryPoint = payload.KustomizePath
+		b.stack.AdditionalFiles = nil
+	}
 	b.stack.CreatedBy = b.user.Username
 
 	return b
@@ -1,8 +1,11 @@
 package stackbuilders
 
 import (
+	"strconv"
+
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 	"github.com/portainer/portainer/api/stacks/deployments"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 
@@ -35,7 +38,14 @@ func (b *StackBuilder) SaveStack() (*portainer.Stack, *httperror.HandlerError) {
 		return nil, b.err
 	}
 
-	err := b.dataStore.Stack().Create(b.stack)
+	env, err := envsecrets.Seal(b.stack.Env, nil)
+	if err != nil {
+		b.err = httperror.BadRequest("Unable to encrypt the secret environment variables", err)
+		return nil, b.err
+	}
+	b.stack.Env = env
+
+	err = b.dataStore.Stack().Create(b.stack)
 	if err != nil {
 		b.err = httperror.InternalServerError("Unable to persist the stack inside the database", err)
 		return nil, b.err
@@ -62,3 +72,21 @@ func (b *StackBuilder) cleanUp() error {
 func (b *StackBuilder) hasError() bool {
 	return b.err != nil
 }
+
+// storeUploadedEnvFiles persists the env files uploaded along with the stack file
+// next to it, in the order they must be loaded
+func (b *StackBuilder) storeUploadedEnvFiles(payload *StackPayload) {
+	if b.hasError() {
+		return
+	}
+
+	stackFolder := strconv.Itoa(int(b.stack.ID))
+	for _, envFile := range payload.UploadedEnvFiles {
+		if _, err := b.fileService.StoreStackFileFromBytes(stackFolder, envFile.Name, []byte(envFile.Content)); err != nil {
+			b.err = httperror.InternalServerError("Unable to persist env file on disk", err)
+			return
+		}
+
+		b.stack.EnvFiles = append(b.stack.EnvFiles, envFile.Name)
+	}
+}
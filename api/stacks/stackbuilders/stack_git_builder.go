@@ -80,6 +80,10 @@ func (b *GitMethodStackBuilder) SetGitRepository(payload *StackPayload) GitMetho
 		repoConfig.ConfigFilePath = payload.ManifestFile
 	}
 
+	if payload.KustomizePath != "" {
+		repoConfig.ConfigFilePath = payload.KustomizePath
+	}
+
 	stackFolder := strconv.Itoa(int(b.stack.ID))
 	// Set the project path on the disk
 	b.stack.ProjectPath = b.fileService.GetStackProjectPath(stackFolder)
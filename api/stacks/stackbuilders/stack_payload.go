@@ -1,7 +1,11 @@
 package stackbuilders
 
 import (
+	"errors"
+	"path/filepath"
+
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/filesystem"
 )
 
 // StackPayload contains all the fields for creating a stack with all kinds of methods
@@ -27,12 +31,20 @@ type StackPayload struct {
 	Namespace string
 	// Path to the k8s Stack file. Used by k8s git repository method
 	ManifestFile string
+	// Path to the kustomization folder inside the Git repository, rendered instead of the manifest files. Used by k8s git repository method
+	KustomizePath string `example:"overlays/production"`
 	// URL to the k8s Stack file. Used by k8s git repository method
 	ManifestURL string
 	// Path to the Stack file inside the Git repository
 	ComposeFile string `example:"docker-compose.yml" default:"docker-compose.yml"`
 	// Applicable when deploying with multiple stack files
 	AdditionalFiles []string `example:"[nz.compose.yml, uat.compose.yml]"`
+	// Compose profiles to enable when deploying the stack
+	ComposeProfiles []string `example:"[debug]"`
+	// Paths to the env files inside the Git repository, loaded in order. Used by git repository method
+	EnvFiles []string `example:"[common.env, production.env]"`
+	// Env files uploaded along with the stack file, loaded in order. Used by file content and file upload methods
+	UploadedEnvFiles []EnvFilePayload
 	// Git repository configuration of a stack
 	RepositoryConfigPayload
 }
@@ -53,3 +65,23 @@ type RepositoryConfigPayload struct {
 	// TLSSkipVerify skips SSL verification when cloning the Git repository
 	TLSSkipVerify bool `example:"false"`
 }
+
+// EnvFilePayload is an env file uploaded along with the stack file
+type EnvFilePayload struct {
+	// Name of the env file, stored next to the stack file
+	Name string `example:"production.env"`
+	// Content of the env file
+	Content string `example:"TAG=1.27"`
+}
+
+func (payload *EnvFilePayload) Validate() error {
+	if payload.Name == "" || filepath.Base(payload.Name) != payload.Name || !filepath.IsLocal(payload.Name) {
+		return errors.New("Invalid env file name. Must be a file name without any folder")
+	}
+
+	if payload.Name == filesystem.ComposeFileDefaultName || payload.Name == "stack.env" {
+		return errors.New("Invalid env file name. The name is reserved")
+	}
+
+	return nil
+}
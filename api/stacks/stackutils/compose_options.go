@@ -0,0 +1,38 @@
+package stackutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// composeProfileRegex matches the profile names accepted by docker compose
+var composeProfileRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateComposeProfiles checks that the given compose profile names are valid
+func ValidateComposeProfiles(profiles []string) error {
+	for _, profile := range profiles {
+		if !composeProfileRegex.MatchString(profile) {
+			return fmt.Errorf("invalid compose profile %q", profile)
+		}
+	}
+
+	return nil
+}
+
+// CleanEnvFilePaths cleans the given env file paths, which must be relative to the
+// project and must not escape it. The order of the paths is kept
+func CleanEnvFilePaths(envFiles []string) ([]string, error) {
+	var cleanedEnvFiles []string
+
+	for _, envFile := range envFiles {
+		cleanedEnvFile := filepath.Clean(envFile)
+		if envFile == "" || !filepath.IsLocal(cleanedEnvFile) {
+			return nil, fmt.Errorf("invalid env file path %q, must be a file inside the project", envFile)
+		}
+
+		cleanedEnvFiles = append(cleanedEnvFiles, cleanedEnvFile)
+	}
+
+	return cleanedEnvFiles, nil
+}
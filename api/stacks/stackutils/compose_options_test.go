@@ -0,0 +1,26 @@
+package stackutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateComposeProfiles(t *testing.T) {
+	assert.NoError(t, ValidateComposeProfiles([]string{"debug", "db.v2", "front_end-1"}))
+	assert.Error(t, ValidateComposeProfiles([]string{"-debug"}))
+	assert.Error(t, ValidateComposeProfiles([]string{"with space"}))
+	assert.Error(t, ValidateComposeProfiles([]string{""}))
+}
+
+func TestCleanEnvFilePaths(t *testing.T) {
+	envFiles, err := CleanEnvFilePaths([]string{"./production.env", "config/../common.env", "config/db.env"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"production.env", "common.env", "config/db.env"}, envFiles)
+
+	for _, envFile := range []string{"", "../secrets.env", "/etc/passwd", "config/../../secrets.env"} {
+		_, err := CleanEnvFilePaths([]string{envFile})
+		assert.Error(t, err, envFile)
+	}
+}
@@ -0,0 +1,45 @@
+package stackutils
+
+import (
+	"os"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
+
+	"github.com/pkg/errors"
+)
+
+// KubernetesManifest is a manifest file of a Kubernetes stack
+type KubernetesManifest struct {
+	// Name of the file, relative to the project path of the stack
+	Name    string
+	Content []byte
+}
+
+// GetKubernetesStackManifests returns the manifests deployed by a Kubernetes stack.
+// The kustomization of a stack is rendered into a single manifest
+func GetKubernetesStackManifests(stack *portainer.Stack) ([]KubernetesManifest, error) {
+	if stack.KustomizePath != "" {
+		content, err := kustomize.Build(stack.ProjectPath, stack.KustomizePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return []KubernetesManifest{{Name: kustomize.ManifestFileName, Content: content}}, nil
+	}
+
+	fileNames := GetStackFilePaths(stack, false)
+
+	manifests := make([]KubernetesManifest, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(filesystem.JoinPaths(stack.ProjectPath, fileName))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read manifest file")
+		}
+
+		manifests = append(manifests, KubernetesManifest{Name: fileName, Content: content})
+	}
+
+	return manifests, nil
+}
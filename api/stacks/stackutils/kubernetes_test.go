@@ -0,0 +1,51 @@
+package stackutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/kubernetes/kustomize"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKubernetesStackManifests(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"deployment.yaml":             "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"service.yaml":                "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+		"base/kustomization.yaml":     "resources:\n  - deployment.yaml\n",
+		"base/deployment.yaml":        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"overlays/kustomization.yaml": "resources:\n  - ../base\nnamespace: apps\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	manifests, err := GetKubernetesStackManifests(&portainer.Stack{
+		ProjectPath:     dir,
+		EntryPoint:      "deployment.yaml",
+		AdditionalFiles: []string{"service.yaml"},
+	})
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	require.Equal(t, "deployment.yaml", manifests[0].Name)
+	require.Equal(t, "service.yaml", manifests[1].Name)
+
+	manifests, err = GetKubernetesStackManifests(&portainer.Stack{
+		ProjectPath:   dir,
+		EntryPoint:    "overlays",
+		KustomizePath: "overlays",
+	})
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	require.Equal(t, kustomize.ManifestFileName, manifests[0].Name)
+	require.Contains(t, string(manifests[0].Content), "namespace: apps")
+
+	_, err = GetKubernetesStackManifests(&portainer.Stack{ProjectPath: dir, KustomizePath: "missing"})
+	require.Error(t, err)
+}
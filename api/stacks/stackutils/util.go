@@ -33,6 +33,17 @@ func GetStackFilePaths(stack *portainer.Stack, absolute bool) []string {
 	return filePaths
 }
 
+// GetStackEnvFilePaths returns the absolute paths of the env files of the stack, in
+// the order they must be loaded
+func GetStackEnvFilePaths(stack *portainer.Stack) []string {
+	var envFilePaths []string
+	for _, file := range stack.EnvFiles {
+		envFilePaths = append(envFilePaths, filesystem.JoinPaths(stack.ProjectPath, file))
+	}
+
+	return envFilePaths
+}
+
 // ResourceControlID returns the stack resource control id
 func ResourceControlID(endpointID portainer.EndpointID, name string) string {
 	return fmt.Sprintf("%d_%s", endpointID, name)
@@ -1,10 +1,20 @@
 package stackutils
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	composeloader "github.com/compose-spec/compose-go/v2/loader"
+	composetypes "github.com/compose-spec/compose-go/v2/types"
 	"github.com/docker/cli/cli/compose/loader"
 	"github.com/docker/cli/cli/compose/types"
 	"github.com/pkg/errors"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/docker/admission"
+	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/internal/envsecrets"
 )
 
 func IsValidStackFile(stackFileContent []byte, securitySettings *portainer.EndpointSecuritySettings) error {
@@ -64,9 +74,9 @@ func IsValidStackFile(stackFileContent []byte, securitySettings *portainer.Endpo
 	return nil
 }
 
-func ValidateStackFiles(stack *portainer.Stack, securitySettings *portainer.EndpointSecuritySettings, fileService portainer.FileService) error {
+func ValidateStackFiles(stack *portainer.Stack, securitySettings *portainer.EndpointSecuritySettings) error {
 	for _, file := range GetStackFilePaths(stack, false) {
-		stackContent, err := fileService.GetFileContent(stack.ProjectPath, file)
+		stackContent, err := stackFileContent(stack, file)
 		if err != nil {
 			return errors.Wrap(err, "failed to get stack file content")
 		}
@@ -78,3 +88,72 @@ func ValidateStackFiles(stack *portainer.Stack, securitySettings *portainer.Endp
 	}
 	return nil
 }
+
+// EnforceAdmissionPolicy evaluates the services of the stack against the admission policy of the
+// environment(endpoint). The stack files are merged the way compose resolves the project, along with
+// their includes, and interpolated with the env files of the stack and its environment variables
+func EnforceAdmissionPolicy(stack *portainer.Stack, endpoint *portainer.Endpoint, policy *portainer.AdmissionPolicy, username string) error {
+	environment, err := stackEnvironment(stack)
+	if err != nil {
+		return err
+	}
+
+	var configFiles []composetypes.ConfigFile
+	for _, filePath := range GetStackFilePaths(stack, true) {
+		configFiles = append(configFiles, composetypes.ConfigFile{Filename: filePath})
+	}
+
+	project, err := composeloader.LoadWithContext(context.TODO(), composetypes.ConfigDetails{
+		WorkingDir:  filesystem.JoinPaths(stack.ProjectPath, filepath.Dir(stack.EntryPoint)),
+		ConfigFiles: configFiles,
+		Environment: environment,
+	}, func(o *composeloader.Options) {
+		o.SetProjectName(stack.Name, true)
+		o.Profiles = stack.ComposeProfiles
+		o.SkipValidation = true
+		o.SkipResolveEnvironment = true
+	})
+	if err != nil {
+		return errors.Wrap(err, "stack config file is invalid")
+	}
+
+	workloads, err := admission.FromComposeProject(project)
+	if err != nil {
+		return errors.Wrap(err, "stack config file is invalid")
+	}
+
+	return admission.Enforce(policy, endpoint, username, workloads...)
+}
+
+// stackEnvironment returns the variables used to interpolate the stack files: the env files of the
+// stack in order, or the default .env file when it has none, overridden by the stack environment variables
+func stackEnvironment(stack *portainer.Stack) (map[string]string, error) {
+	envFilePaths := GetStackEnvFilePaths(stack)
+	if len(envFilePaths) == 0 {
+		defaultEnvFilePath := filesystem.JoinPaths(stack.ProjectPath, filepath.Dir(stack.EntryPoint), ".env")
+		if info, err := os.Stat(defaultEnvFilePath); err == nil && !info.IsDir() {
+			envFilePaths = []string{defaultEnvFilePath}
+		}
+	}
+
+	environment, err := dotenv.GetEnvFromFile(map[string]string{}, envFilePaths)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the env files of the stack")
+	}
+
+	env, err := envsecrets.Open(stack.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range env {
+		environment[pair.Name] = pair.Value
+	}
+
+	return environment, nil
+}
+
+// stackFileContent reads a file of the stack project, the path is sanitized against the project path
+func stackFileContent(stack *portainer.Stack, file string) ([]byte, error) {
+	return os.ReadFile(filesystem.JoinPaths(stack.ProjectPath, file))
+}
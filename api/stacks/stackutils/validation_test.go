@@ -0,0 +1,95 @@
+package stackutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/stretchr/testify/require"
+)
+
+func writeStackFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	return dir
+}
+
+func TestEnforceAdmissionPolicy(t *testing.T) {
+	endpoint := &portainer.Endpoint{ID: 1}
+	policy := &portainer.AdmissionPolicy{Enabled: true, ForbidLatestTag: true, RequireMemoryLimit: true, RequireCPULimit: true}
+
+	t.Run("the override file is merged with the base file", func(t *testing.T) {
+		dir := writeStackFiles(t, map[string]string{
+			"docker-compose.yml": `
+services:
+  web:
+    image: nginx:1.25
+`,
+			"docker-compose.override.yml": `
+services:
+  web:
+    mem_limit: 64m
+    cpus: 0.5
+`,
+		})
+
+		stack := &portainer.Stack{Name: "test", ProjectPath: dir, EntryPoint: "docker-compose.yml", AdditionalFiles: []string{"docker-compose.override.yml"}}
+
+		require.NoError(t, EnforceAdmissionPolicy(stack, endpoint, policy, "admin"))
+	})
+
+	t.Run("the included files are evaluated", func(t *testing.T) {
+		dir := writeStackFiles(t, map[string]string{
+			"docker-compose.yml": `
+include:
+  - worker/compose.yml
+services:
+  web:
+    image: nginx:1.25
+    mem_limit: 64m
+    cpus: 0.5
+`,
+			"worker/compose.yml": `
+services:
+  worker:
+    image: redis:latest
+    mem_limit: 64m
+    cpus: 0.5
+`,
+		})
+
+		stack := &portainer.Stack{Name: "test", ProjectPath: dir, EntryPoint: "docker-compose.yml"}
+
+		require.ErrorContains(t, EnforceAdmissionPolicy(stack, endpoint, policy, "admin"), "worker")
+	})
+
+	t.Run("the env files and the stack variables are used for the interpolation", func(t *testing.T) {
+		dir := writeStackFiles(t, map[string]string{
+			"docker-compose.yml": `
+services:
+  web:
+    image: nginx:${TAG}
+    mem_limit: ${MEMORY}
+    cpus: 0.5
+`,
+			".env":       "TAG=latest\nMEMORY=0\n",
+			"common.env": "TAG=1.25\nMEMORY=0\n",
+		})
+
+		stack := &portainer.Stack{Name: "test", ProjectPath: dir, EntryPoint: "docker-compose.yml"}
+		require.Error(t, EnforceAdmissionPolicy(stack, endpoint, policy, "admin"))
+
+		stack.EnvFiles = []string{"common.env"}
+		stack.Env = []portainer.Pair{{Name: "MEMORY", Value: "64m"}}
+		require.NoError(t, EnforceAdmissionPolicy(stack, endpoint, policy, "admin"))
+	})
+}
@@ -106,6 +106,7 @@ func withComposeService(
 			func(o *loader.Options) {
 				o.SkipResolveEnvironment = true
 				o.ResolvePaths = !slices.Contains(options.ConfigOptions, "--no-path-resolution")
+				o.Profiles = options.Profiles
 
 				if options.ProjectName != "" {
 					o.SetProjectName(options.ProjectName, true)
@@ -339,7 +340,8 @@ func parseEnvironment(options libstack.Options, filePaths []string) (map[string]
 		maps.Copy(env, e)
 	}
 
-	if options.EnvFilePath == "" {
+	envFilePaths := options.EnvFilePaths
+	if len(envFilePaths) == 0 {
 		if len(filePaths) == 0 {
 			return env, nil
 		}
@@ -355,12 +357,12 @@ func parseEnvironment(options libstack.Options, filePaths []string) (map[string]
 		if s.IsDir() {
 			return env, nil
 		}
-		options.EnvFilePath = defaultDotEnv
+		envFilePaths = []string{defaultDotEnv}
 	}
 
-	e, err := dotenv.GetEnvFromFile(make(map[string]string), []string{options.EnvFilePath})
+	e, err := dotenv.GetEnvFromFile(make(map[string]string), envFilePaths)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get the environment from the env file: %w", err)
+		return nil, fmt.Errorf("unable to get the environment from the env files: %w", err)
 	}
 
 	maps.Copy(env, e)
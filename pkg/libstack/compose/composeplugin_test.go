@@ -330,16 +330,16 @@ networks:
 		t.Run(tc.name, func(t *testing.T) {
 			composeFilePath := createFile(t, dir, "docker-compose.yml", tc.composeFileContent)
 
-			envFilePath := ""
+			var envFilePaths []string
 			if tc.envFileContent != "" {
-				envFilePath = createFile(t, dir, "stack.env", tc.envFileContent)
+				envFilePaths = []string{createFile(t, dir, "stack.env", tc.envFileContent)}
 			}
 
 			w := NewComposeDeployer()
 			actual, err := w.Config(ctx, []string{composeFilePath}, libstack.Options{
 				WorkingDir:    dir,
 				ProjectName:   projectName,
-				EnvFilePath:   envFilePath,
+				EnvFilePaths:  envFilePaths,
 				Env:           tc.env,
 				ConfigOptions: []string{"--no-path-resolution"},
 			})
@@ -349,3 +349,49 @@ networks:
 		})
 	}
 }
+
+func Test_ConfigProfilesEnvFilesAndIncludes(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "db"), 0o755))
+
+	createFile(t, dir, "db/compose.yml", `services:
+  db:
+    image: postgres:${POSTGRES_TAG}
+`)
+
+	composeFilePath := createFile(t, dir, "docker-compose.yml", `include:
+  - db/compose.yml
+services:
+  web:
+    image: nginx:${NGINX_TAG}
+  debug:
+    image: busybox
+    profiles:
+      - debug
+`)
+
+	envFilePaths := []string{
+		createFile(t, dir, "common.env", "NGINX_TAG=1.25\nPOSTGRES_TAG=15"),
+		createFile(t, dir, "production.env", "NGINX_TAG=1.27"),
+	}
+
+	w := NewComposeDeployer()
+
+	config, err := w.Config(context.Background(), []string{composeFilePath}, libstack.Options{
+		ProjectName:  "profilestest",
+		EnvFilePaths: envFilePaths,
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(config), "image: nginx:1.27", "the last env file must take precedence")
+	require.Contains(t, string(config), "image: postgres:15", "the included files must be loaded")
+	require.NotContains(t, string(config), "busybox", "the services of inactive profiles must be ignored")
+
+	config, err = w.Config(context.Background(), []string{composeFilePath}, libstack.Options{
+		ProjectName:  "profilestest",
+		EnvFilePaths: envFilePaths,
+		Profiles:     []string{"debug"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(config), "image: busybox")
+}
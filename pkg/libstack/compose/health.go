@@ -0,0 +1,209 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/portainer/portainer/pkg/libstack"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/compose/v2/pkg/compose"
+	"github.com/rs/zerolog/log"
+)
+
+const healthCheckInterval = time.Second
+
+type containerHealth int
+
+const (
+	containerPending containerHealth = iota
+	containerHealthy
+	containerFailed
+)
+
+// getContainerHealth returns whether the container is running and healthy, is still starting
+// or failed. One-off containers which exited successfully are considered healthy
+func getContainerHealth(cs api.ContainerSummary) containerHealth {
+	switch cs.State {
+	case "running":
+		switch cs.Health {
+		case "", "healthy":
+			return containerHealthy
+		case "unhealthy":
+			return containerFailed
+		default:
+			return containerPending
+		}
+	case "exited":
+		if cs.ExitCode == 0 {
+			return containerHealthy
+		}
+
+		return containerFailed
+	case "dead":
+		return containerFailed
+	default:
+		return containerPending
+	}
+}
+
+// unhealthyContainers returns the containers which are not healthy yet, and whether one of them failed
+func unhealthyContainers(containerSummaries []api.ContainerSummary) ([]api.ContainerSummary, bool) {
+	var unhealthy []api.ContainerSummary
+	failed := false
+
+	for _, cs := range containerSummaries {
+		switch getContainerHealth(cs) {
+		case containerHealthy:
+			continue
+		case containerFailed:
+			failed = true
+		}
+
+		unhealthy = append(unhealthy, cs)
+	}
+
+	return unhealthy, failed
+}
+
+// WaitForHealthy waits until all the containers of the stack are running and healthy. It stops
+// waiting as soon as a container fails, and collects the last log lines of the unhealthy ones
+func (c *ComposeDeployer) WaitForHealthy(ctx context.Context, options libstack.WaitForHealthyOptions) ([]libstack.UnhealthyService, error) {
+	var unhealthy []api.ContainerSummary
+	var lastErr error
+	inspected, healthy := false, false
+
+	err := withComposeService(ctx, nil, options.Options, func(composeService api.Service, _ *types.Project) error {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			containerSummaries, err := composeService.Ps(ctx, options.ProjectName, api.PsOptions{All: true})
+			if err != nil {
+				lastErr = err
+
+				log.Debug().Err(err).Str("project_name", options.ProjectName).Msg("error from docker compose ps")
+			} else {
+				inspected = true
+
+				var failed bool
+				unhealthy, failed = unhealthyContainers(containerSummaries)
+				if len(containerSummaries) > 0 && len(unhealthy) == 0 {
+					healthy = true
+
+					return nil
+				}
+
+				if failed {
+					return nil
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if healthy {
+		return nil, nil
+	}
+
+	if !inspected {
+		return nil, fmt.Errorf("unable to inspect the containers of the stack: %w", errors.Join(lastErr, ctx.Err()))
+	}
+
+	if len(unhealthy) == 0 && ctx.Err() != nil {
+		return nil, fmt.Errorf("no container of the stack was found: %w", ctx.Err())
+	}
+
+	return c.describeUnhealthyServices(options, unhealthy), nil
+}
+
+// describeUnhealthyServices collects the state and the last log lines of the unhealthy containers
+func (c *ComposeDeployer) describeUnhealthyServices(options libstack.WaitForHealthyOptions, containers []api.ContainerSummary) []libstack.UnhealthyService {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	logs := &logCollector{lines: make(map[string][]string)}
+
+	if options.LogLines > 0 {
+		// The context of the wait is likely done, the logs are fetched with a context of their own
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var services []string
+		for _, cs := range containers {
+			if !slices.Contains(services, cs.Service) {
+				services = append(services, cs.Service)
+			}
+		}
+
+		if err := withCli(ctx, options.Options, func(_ context.Context, cli *command.DockerCli) error {
+			return compose.NewComposeService(cli).Logs(ctx, options.ProjectName, logs, api.LogOptions{
+				Services: services,
+				Tail:     strconv.Itoa(options.LogLines),
+			})
+		}); err != nil {
+			log.Warn().Err(err).Str("project_name", options.ProjectName).Msg("unable to retrieve the logs of the unhealthy services")
+		}
+	}
+
+	unhealthyServices := make([]libstack.UnhealthyService, 0, len(containers))
+	for _, cs := range containers {
+		unhealthyServices = append(unhealthyServices, libstack.UnhealthyService{
+			Name:      cs.Service,
+			Container: cs.Name,
+			State:     cs.State,
+			Health:    cs.Health,
+			ExitCode:  cs.ExitCode,
+			Logs:      logs.get(cs.Name),
+		})
+	}
+
+	return unhealthyServices
+}
+
+// logCollector is a compose log consumer keeping the log lines of each container
+type logCollector struct {
+	mu    sync.Mutex
+	lines map[string][]string
+}
+
+func (l *logCollector) Log(containerName, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		l.lines[containerName] = append(l.lines[containerName], line)
+	}
+}
+
+func (l *logCollector) Err(containerName, message string) {
+	l.Log(containerName, message)
+}
+
+func (l *logCollector) Status(container, msg string) {}
+
+func (l *logCollector) Register(container string) {}
+
+func (l *logCollector) get(containerName string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lines[containerName]
+}
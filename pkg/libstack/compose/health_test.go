@@ -0,0 +1,55 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getContainerHealth(t *testing.T) {
+	tests := []struct {
+		container api.ContainerSummary
+		expected  containerHealth
+	}{
+		{api.ContainerSummary{State: "running"}, containerHealthy},
+		{api.ContainerSummary{State: "running", Health: "healthy"}, containerHealthy},
+		{api.ContainerSummary{State: "running", Health: "starting"}, containerPending},
+		{api.ContainerSummary{State: "running", Health: "unhealthy"}, containerFailed},
+		{api.ContainerSummary{State: "created"}, containerPending},
+		{api.ContainerSummary{State: "restarting"}, containerPending},
+		{api.ContainerSummary{State: "exited", ExitCode: 0}, containerHealthy},
+		{api.ContainerSummary{State: "exited", ExitCode: 1}, containerFailed},
+		{api.ContainerSummary{State: "dead"}, containerFailed},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, getContainerHealth(tt.container), "%+v", tt.container)
+	}
+}
+
+func Test_unhealthyContainers(t *testing.T) {
+	unhealthy, failed := unhealthyContainers([]api.ContainerSummary{
+		{Name: "web", State: "running", Health: "healthy"},
+		{Name: "db", State: "running", Health: "starting"},
+	})
+	require.False(t, failed)
+	require.Len(t, unhealthy, 1)
+	require.Equal(t, "db", unhealthy[0].Name)
+
+	unhealthy, failed = unhealthyContainers([]api.ContainerSummary{
+		{Name: "web", State: "exited", ExitCode: 137},
+		{Name: "db", State: "running", Health: "starting"},
+	})
+	require.True(t, failed)
+	require.Len(t, unhealthy, 2)
+}
+
+func Test_logCollector(t *testing.T) {
+	logs := &logCollector{lines: make(map[string][]string)}
+	logs.Log("web", "line 1\nline 2\n")
+	logs.Err("web", "error")
+
+	require.Equal(t, []string{"line 1", "line 2", "error"}, logs.get("web"))
+	require.Empty(t, logs.get("db"))
+}
@@ -0,0 +1,140 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/portainer/portainer/pkg/libstack"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/rs/zerolog/log"
+)
+
+// Restart restarts the containers of the given services
+func (c *ComposeDeployer) Restart(ctx context.Context, filePaths []string, serviceNames []string, options libstack.Options) error {
+	if err := withComposeService(ctx, filePaths, options, func(composeService api.Service, project *types.Project) error {
+		project, err := selectServices(project, serviceNames)
+		if err != nil {
+			return err
+		}
+
+		return composeService.Restart(ctx, project.Name, api.RestartOptions{
+			Project:  project,
+			Services: serviceNames,
+			NoDeps:   true,
+		})
+	}); err != nil {
+		return fmt.Errorf("compose restart operation failed: %w", err)
+	}
+
+	log.Info().Strs("services", serviceNames).Msg("Stack services restart successful")
+
+	return nil
+}
+
+// Stop stops the containers of the given services without removing them
+func (c *ComposeDeployer) Stop(ctx context.Context, filePaths []string, serviceNames []string, options libstack.Options) error {
+	if err := withComposeService(ctx, filePaths, options, func(composeService api.Service, project *types.Project) error {
+		project, err := selectServices(project, serviceNames)
+		if err != nil {
+			return err
+		}
+
+		return composeService.Stop(ctx, project.Name, api.StopOptions{
+			Project:  project,
+			Services: serviceNames,
+		})
+	}); err != nil {
+		return fmt.Errorf("compose stop operation failed: %w", err)
+	}
+
+	log.Info().Strs("services", serviceNames).Msg("Stack services stop successful")
+
+	return nil
+}
+
+// Recreate recreates the containers of the given services, their dependencies are
+// only recreated when their configuration changed
+func (c *ComposeDeployer) Recreate(ctx context.Context, filePaths []string, serviceNames []string, options libstack.RecreateOptions) error {
+	if err := withComposeService(ctx, filePaths, options.Options, func(composeService api.Service, project *types.Project) error {
+		addServiceLabels(project, false, 0)
+
+		project, err := selectServices(project, serviceNames)
+		if err != nil {
+			return err
+		}
+
+		if options.PullImage {
+			if err := composeService.Pull(ctx, project.WithoutUnnecessaryResources(), api.PullOptions{}); err != nil {
+				return fmt.Errorf("compose pull operation failed: %w", err)
+			}
+		}
+
+		return composeService.Up(ctx, project, api.UpOptions{
+			Create: api.CreateOptions{
+				Services:             serviceNames,
+				Recreate:             api.RecreateForce,
+				RecreateDependencies: api.RecreateDiverged,
+			},
+			Start: api.StartOptions{
+				Project:  project,
+				Services: serviceNames,
+			},
+		})
+	}); err != nil {
+		return fmt.Errorf("compose recreate operation failed: %w", err)
+	}
+
+	log.Info().Strs("services", serviceNames).Msg("Stack services recreation successful")
+
+	return nil
+}
+
+// Scale sets the number of containers of the given service
+func (c *ComposeDeployer) Scale(ctx context.Context, filePaths []string, serviceName string, replicas int, options libstack.Options) error {
+	if replicas < 0 {
+		return fmt.Errorf("invalid number of replicas: %d", replicas)
+	}
+
+	if err := withComposeService(ctx, filePaths, options, func(composeService api.Service, project *types.Project) error {
+		addServiceLabels(project, false, 0)
+
+		project, err := selectServices(project, []string{serviceName})
+		if err != nil {
+			return err
+		}
+
+		service, err := project.GetService(serviceName)
+		if err != nil {
+			return err
+		}
+
+		service.SetScale(replicas)
+		project.Services[serviceName] = service
+
+		return composeService.Scale(ctx, project, api.ScaleOptions{Services: []string{serviceName}})
+	}); err != nil {
+		return fmt.Errorf("compose scale operation failed: %w", err)
+	}
+
+	log.Info().Str("service", serviceName).Int("replicas", replicas).Msg("Stack service scale successful")
+
+	return nil
+}
+
+// selectServices restricts the project to the given services and their dependencies,
+// it fails when one of the services is not part of the project
+func selectServices(project *types.Project, serviceNames []string) (*types.Project, error) {
+	if len(serviceNames) == 0 {
+		return nil, fmt.Errorf("at least one service must be specified")
+	}
+
+	for _, serviceName := range serviceNames {
+		if _, err := project.GetService(serviceName); err != nil {
+			return nil, fmt.Errorf("service %q not found in the stack: %w", serviceName, err)
+		}
+	}
+
+	return project.WithSelectedServices(serviceNames)
+}
@@ -0,0 +1,30 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectServices(t *testing.T) {
+	project := &types.Project{
+		Name: "test",
+		Services: types.Services{
+			"web":    {Name: "web", DependsOn: types.DependsOnConfig{"db": {Condition: types.ServiceConditionStarted}}},
+			"db":     {Name: "db"},
+			"worker": {Name: "worker"},
+		},
+	}
+
+	selected, err := selectServices(project, []string{"web"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"web", "db"}, selected.ServiceNames(), "the dependencies must be kept")
+	require.Equal(t, []string{"worker"}, selected.DisabledServiceNames())
+
+	_, err = selectServices(project, []string{"web", "cache"})
+	require.ErrorContains(t, err, `service "cache" not found`)
+
+	_, err = selectServices(project, nil)
+	require.Error(t, err)
+}
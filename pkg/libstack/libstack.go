@@ -17,8 +17,21 @@ type Deployer interface {
 	Remove(ctx context.Context, projectName string, filePaths []string, options RemoveOptions) error
 	Pull(ctx context.Context, filePaths []string, options Options) error
 	Run(ctx context.Context, filePaths []string, serviceName string, options RunOptions) error
+	// Restart restarts the containers of the given services of a stack
+	Restart(ctx context.Context, filePaths []string, serviceNames []string, options Options) error
+	// Stop stops the containers of the given services of a stack without removing them
+	Stop(ctx context.Context, filePaths []string, serviceNames []string, options Options) error
+	// Recreate recreates the containers of the given services of a stack, the other
+	// services are left untouched
+	Recreate(ctx context.Context, filePaths []string, serviceNames []string, options RecreateOptions) error
+	// Scale sets the number of containers of a service of a stack
+	Scale(ctx context.Context, filePaths []string, serviceName string, replicas int, options Options) error
 	Validate(ctx context.Context, filePaths []string, options Options) error
 	WaitForStatus(ctx context.Context, name string, status Status) WaitResult
+	// WaitForHealthy waits until all the containers of the stack are running, and healthy
+	// when they define a health check. The services that failed to do so before ctx is done
+	// are returned, an empty list means the stack is healthy
+	WaitForHealthy(ctx context.Context, options WaitForHealthyOptions) ([]UnhealthyService, error)
 	Config(ctx context.Context, filePaths []string, options Options) ([]byte, error)
 	GetExistingEdgeStacks(ctx context.Context) ([]EdgeStack, error)
 }
@@ -46,8 +59,13 @@ type Options struct {
 	WorkingDir  string
 	Host        string
 	ProjectName string
-	// EnvFilePath is the path to a .env file
-	EnvFilePath string
+	// EnvFilePaths are the paths to the .env files, the variables of a file
+	// override the ones of the previous files. The .env file next to the first
+	// compose file is used when it is empty
+	EnvFilePaths []string
+	// Profiles are the compose profiles to enable, the services of the other
+	// profiles are ignored
+	Profiles []string
 	// Env is a list of environment variables to pass to the command, example: "FOO=bar"
 	Env []string
 	// ProjectDir is the working directory for containers created by docker compose file.
@@ -81,12 +99,35 @@ type RunOptions struct {
 	Detached bool
 }
 
+type RecreateOptions struct {
+	Options
+	// PullImage pulls the images of the services before recreating them
+	PullImage bool
+}
+
 type RemoveOptions struct {
 	Options
 
 	Volumes bool
 }
 
+type WaitForHealthyOptions struct {
+	Options
+	// LogLines is the number of log lines collected for each unhealthy service
+	LogLines int
+}
+
+// UnhealthyService is a service which failed to become running and healthy
+type UnhealthyService struct {
+	Name      string
+	Container string
+	State     string
+	Health    string
+	ExitCode  int
+	// Logs are the last log lines of the container
+	Logs []string
+}
+
 type EdgeStack struct {
 	ID   int
 	Name string
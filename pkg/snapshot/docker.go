@@ -24,54 +24,60 @@ import (
 	"github.com/segmentio/encoding/json"
 )
 
-func CreateDockerSnapshot(cli *client.Client) (*portainer.DockerSnapshot, error) {
-	if _, err := cli.Ping(context.Background()); err != nil {
+// CreateDockerSnapshot creates a snapshot of the Docker engine reachable through cli.
+// The snapshot fails when ctx is done before every resource has been collected.
+func CreateDockerSnapshot(ctx context.Context, cli *client.Client) (*portainer.DockerSnapshot, error) {
+	if _, err := cli.Ping(ctx); err != nil {
 		return nil, err
 	}
 
-	dockerSnapshot := &portainer.DockerSnapshot{}
+	dockerSnapshot := &portainer.DockerSnapshot{SnapshotRaw: &portainer.DockerSnapshotRaw{}}
 
-	if err := dockerSnapshotInfo(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotInfo(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot engine information")
 	}
 
 	if dockerSnapshot.Swarm {
-		if err := dockerSnapshotSwarmServices(dockerSnapshot, cli); err != nil {
+		if err := dockerSnapshotSwarmServices(ctx, dockerSnapshot, cli); err != nil {
 			log.Warn().Err(err).Msg("unable to snapshot Swarm services")
 		}
 
-		if err := dockerSnapshotNodes(dockerSnapshot, cli); err != nil {
+		if err := dockerSnapshotNodes(ctx, dockerSnapshot, cli); err != nil {
 			log.Warn().Err(err).Msg("unable to snapshot Swarm nodes")
 		}
 	}
 
-	if err := dockerSnapshotContainers(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotContainers(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot containers")
 	}
 
-	if err := dockerSnapshotImages(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotImages(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot images")
 	}
 
-	if err := dockerSnapshotVolumes(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotVolumes(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot volumes")
 	}
 
-	if err := dockerSnapshotNetworks(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotNetworks(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot networks")
 	}
 
-	if err := dockerSnapshotVersion(dockerSnapshot, cli); err != nil {
+	if err := dockerSnapshotVersion(ctx, dockerSnapshot, cli); err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot engine version")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	dockerSnapshot.Time = time.Now().Unix()
 
 	return dockerSnapshot, nil
 }
 
-func dockerSnapshotInfo(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	info, err := cli.Info(context.Background())
+func dockerSnapshotInfo(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	info, err := cli.Info(ctx)
 	if err != nil {
 		return err
 	}
@@ -85,8 +91,8 @@ func dockerSnapshotInfo(snapshot *portainer.DockerSnapshot, cli *client.Client)
 	return nil
 }
 
-func dockerSnapshotNodes(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	nodes, err := cli.NodeList(context.Background(), types.NodeListOptions{})
+func dockerSnapshotNodes(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	nodes, err := cli.NodeList(ctx, types.NodeListOptions{})
 	if err != nil {
 		return err
 	}
@@ -105,10 +111,10 @@ func dockerSnapshotNodes(snapshot *portainer.DockerSnapshot, cli *client.Client)
 	return nil
 }
 
-func dockerSnapshotSwarmServices(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+func dockerSnapshotSwarmServices(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
 	stacks := make(map[string]struct{})
 
-	services, err := cli.ServiceList(context.Background(), types.ServiceListOptions{})
+	services, err := cli.ServiceList(ctx, types.ServiceListOptions{})
 	if err != nil {
 		return err
 	}
@@ -127,8 +133,8 @@ func dockerSnapshotSwarmServices(snapshot *portainer.DockerSnapshot, cli *client
 	return nil
 }
 
-func dockerSnapshotContainers(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	containers, err := cli.ContainerList(context.Background(), container.ListOptions{All: true})
+func dockerSnapshotContainers(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return err
 	}
@@ -149,7 +155,7 @@ func dockerSnapshotContainers(snapshot *portainer.DockerSnapshot, cli *client.Cl
 		}
 
 		// Snapshot GPUs
-		response, err := cli.ContainerInspect(context.Background(), container.ID)
+		response, err := cli.ContainerInspect(ctx, container.ID)
 		if err != nil && !snapshot.Swarm {
 			return err
 		} else if err != nil {
@@ -212,8 +218,8 @@ func dockerSnapshotContainers(snapshot *portainer.DockerSnapshot, cli *client.Cl
 	return nil
 }
 
-func dockerSnapshotImages(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	images, err := cli.ImageList(context.Background(), image.ListOptions{})
+func dockerSnapshotImages(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	images, err := cli.ImageList(ctx, image.ListOptions{})
 	if err != nil {
 		return err
 	}
@@ -224,8 +230,8 @@ func dockerSnapshotImages(snapshot *portainer.DockerSnapshot, cli *client.Client
 	return nil
 }
 
-func dockerSnapshotVolumes(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	volumes, err := cli.VolumeList(context.Background(), volume.ListOptions{})
+func dockerSnapshotVolumes(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{})
 	if err != nil {
 		return err
 	}
@@ -236,8 +242,8 @@ func dockerSnapshotVolumes(snapshot *portainer.DockerSnapshot, cli *client.Clien
 	return nil
 }
 
-func dockerSnapshotNetworks(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	networks, err := cli.NetworkList(context.Background(), types.NetworkListOptions{})
+func dockerSnapshotNetworks(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{})
 	if err != nil {
 		return err
 	}
@@ -247,8 +253,8 @@ func dockerSnapshotNetworks(snapshot *portainer.DockerSnapshot, cli *client.Clie
 	return nil
 }
 
-func dockerSnapshotVersion(snapshot *portainer.DockerSnapshot, cli *client.Client) error {
-	version, err := cli.ServerVersion(context.Background())
+func dockerSnapshotVersion(ctx context.Context, snapshot *portainer.DockerSnapshot, cli *client.Client) error {
+	version, err := cli.ServerVersion(ctx)
 	if err != nil {
 		return err
 	}
@@ -18,38 +18,51 @@ import (
 	"github.com/rs/zerolog/log"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
 )
 
-func CreateKubernetesSnapshot(cli *kubernetes.Clientset) (*portainer.KubernetesSnapshot, error) {
+// CreateKubernetesSnapshot creates a snapshot of the cluster reachable through cli.
+// The snapshot fails when ctx is done before every resource has been collected.
+func CreateKubernetesSnapshot(ctx context.Context, cli *kubernetes.Clientset) (*portainer.KubernetesSnapshot, error) {
 	kubernetesSnapshot := &portainer.KubernetesSnapshot{}
 
-	err := kubernetesSnapshotVersion(kubernetesSnapshot, cli)
+	err := kubernetesSnapshotVersion(ctx, kubernetesSnapshot, cli)
 	if err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot cluster version")
 	}
 
-	err = kubernetesSnapshotNodes(kubernetesSnapshot, cli)
+	err = kubernetesSnapshotNodes(ctx, kubernetesSnapshot, cli)
 	if err != nil {
 		log.Warn().Err(err).Msg("unable to snapshot cluster nodes")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	kubernetesSnapshot.Time = time.Now().Unix()
 	return kubernetesSnapshot, nil
 }
 
-func kubernetesSnapshotVersion(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
-	versionInfo, err := cli.ServerVersion()
+func kubernetesSnapshotVersion(ctx context.Context, snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
+	// ServerVersion() does not accept a context, query the endpoint directly instead
+	body, err := cli.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
 	if err != nil {
 		return err
 	}
 
+	var versionInfo version.Info
+	if err := json.Unmarshal(body, &versionInfo); err != nil {
+		return err
+	}
+
 	snapshot.KubernetesVersion = versionInfo.GitVersion
 	return nil
 }
 
-func kubernetesSnapshotNodes(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
-	nodeList, err := cli.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+func kubernetesSnapshotNodes(ctx context.Context, snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
+	nodeList, err := cli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}